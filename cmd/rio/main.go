@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"rio/internal/config"
+	"rio/utils/token"
+)
+
+const usage = `usage: rio [-config file] <command> [arguments]
+
+commands:
+  keys list                 list JWT signing keys
+  keys rotate [-alg EdDSA]  generate a new signing key; it starts signing
+                            tokens once every instance has had time to load it
+  keys prune [-keep 2]      delete all but the newest signing keys; keys
+                            signing tokens or not yet active are kept
+  migrate status            list the schema migrations and whether they
+                            have been applied
+  migrate up [-to N]        apply the pending migrations, or those up to N
+  migrate down [-steps 1]   roll back the newest applied migrations
+`
+
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	configFile := flag.String("config", os.Getenv("RIO_CONFIG"), "YAML or TOML configuration file")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// The CLI reads the same configuration as the server, but only the
+	// settings a command uses have to be valid.
+	cfg, err := config.Load(*configFile, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "rio:", err)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "keys":
+		err = runKeys(cfg, args[1], args[2:])
+	case "migrate":
+		err = runMigrate(cfg, args[1], args[2:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "rio:", err)
+		os.Exit(1)
+	}
+}
+
+func runKeys(cfg *config.Config, cmd string, args []string) error {
+	fs := flag.NewFlagSet("keys "+cmd, flag.ExitOnError)
+	dir := fs.String("dir", cfg.Auth.JWTKeysDir, "directory holding the signing keys")
+
+	switch cmd {
+	case "list":
+		fs.Parse(args)
+
+		keys, err := token.ListKeys(*dir)
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			fmt.Printf("%s  %-6s  %s\n", k.Kid, k.Alg, k.Created.Format(time.RFC3339))
+		}
+		return nil
+
+	case "rotate":
+		defaultAlg := cfg.Auth.JWTSigningAlg
+		if defaultAlg == "" || defaultAlg == "HS256" {
+			defaultAlg = "EdDSA"
+		}
+		alg := fs.String("alg", defaultAlg, "key algorithm: EdDSA or RS256")
+		fs.Parse(args)
+
+		kid, err := token.GenerateKey(*dir, *alg)
+		if err != nil {
+			return err
+		}
+		fmt.Println(kid)
+		return nil
+
+	case "prune":
+		keep := fs.Int("keep", 2, "number of newest keys to keep")
+		fs.Parse(args)
+
+		removed, err := token.PruneKeys(*dir, *keep)
+		if err != nil {
+			return err
+		}
+		for _, kid := range removed {
+			fmt.Println("removed", kid)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown keys command %q", cmd)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"rio/internal/config"
+	"rio/internal/db"
+	"rio/internal/db/migrations"
+
+	"github.com/jinzhu/gorm"
+)
+
+func runMigrate(cfg *config.Config, cmd string, args []string) error {
+	fs := flag.NewFlagSet("migrate "+cmd, flag.ExitOnError)
+
+	var run func() error
+	switch cmd {
+	case "status":
+		run = func() error { return migrateStatus() }
+	case "up":
+		to := fs.Int("to", 0, "version to migrate up to; all pending migrations if 0")
+		run = func() error { return migrateApply(migrations.Up, *to, "applied") }
+	case "down":
+		steps := fs.Int("steps", 1, "number of migrations to roll back")
+		run = func() error { return migrateApply(migrations.Down, *steps, "rolled back") }
+	default:
+		return fmt.Errorf("unknown migrate command %q", cmd)
+	}
+	fs.Parse(args)
+
+	var err error
+	db.DB, err = db.Open(cfg.Database.Config())
+	if err != nil {
+		return err
+	}
+	defer db.DB.Close()
+
+	return run()
+}
+
+func migrateStatus() error {
+	states, err := migrations.Status(db.DB)
+	if err != nil {
+		return err
+	}
+	for _, s := range states {
+		state := "pending"
+		if s.AppliedAt != nil {
+			state = "applied " + s.AppliedAt.Format(time.RFC3339)
+		}
+		if s.Unknown {
+			state += ", unknown to this version"
+		}
+		fmt.Printf("%04d  %-30s  %s\n", s.Version, s.Name, state)
+	}
+	return nil
+}
+
+func migrateApply(apply func(*gorm.DB, int) ([]migrations.Migration, error), n int, verb string) error {
+	done, err := apply(db.DB, n)
+	for _, m := range done {
+		fmt.Printf("%s %04d %s\n", verb, m.Version, m.Name)
+	}
+	if err == nil && len(done) == 0 {
+		fmt.Println("nothing to do")
+	}
+	return err
+}
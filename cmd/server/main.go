@@ -1,6 +1,16 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"rio/internal/apperr"
+	"rio/internal/config"
+	"rio/internal/handlers"
+	"rio/internal/ratelimit"
+	"rio/internal/service"
 	"rio/internal/setup"
 	"rio/middlewares"
 
@@ -8,38 +18,217 @@ import (
 )
 
 func main() {
-	deps := setup.Setup()
+	configFile := flag.String("config", os.Getenv("RIO_CONFIG"), "YAML or TOML configuration `file`; the environment and flags override it")
+	printConfig := flag.Bool("print-config", false, "print the configuration, secrets redacted, and exit")
+	flags := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile, flags)
+	if err != nil {
+		log.Fatal("cannot load configuration: ", err)
+	}
+	if *printConfig {
+		fmt.Print(cfg)
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("invalid configuration:\n", err)
+	}
+
+	deps := setup.Setup(cfg)
 
 	router := gin.Default()
 
+	// Errors are attached to the context by handlers and middlewares and
+	// rendered in one envelope by ErrorMiddleware, tagged with the
+	// request's ID. Every route then gets its deadline, see
+	// REQUEST_TIMEOUT and REQUEST_TIMEOUT_ROUTES.
+	router.Use(
+		middlewares.RequestIDMiddleware(),
+		middlewares.ErrorMiddleware(),
+		middlewares.TimeoutMiddleware(deps.Timeouts),
+	)
+	router.NoRoute(func(c *gin.Context) {
+		c.Error(apperr.NotFound("no route for %s %s", c.Request.Method, c.Request.URL.Path))
+	})
+
+	// Login throttling keys on the client IP, so only trusted proxies may
+	// set X-Forwarded-For.
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatal("invalid trusted proxies: ", err)
+	}
+
+	router.GET("/.well-known/jwks.json", handlers.JWKS)
+	router.GET(service.FederationDocumentPath, deps.FederationHandler.Document)
+
+	rateLimit := func(bucket string) gin.HandlerFunc {
+		return middlewares.RateLimitMiddleware(deps.RateLimitStore, bucket, deps.RateLimits[bucket])
+	}
+
 	public := router.Group("/api")
+	public.Use(rateLimit(ratelimit.Default))
 
-	public.POST("/register", deps.UserHandler.Register)
-	public.POST("/login", deps.UserHandler.Login)
+	public.POST("/register", rateLimit(ratelimit.Register), deps.UserHandler.Register)
+	public.POST("/login", rateLimit(ratelimit.Login), deps.UserHandler.Login)
+	public.POST("/login/mfa", rateLimit(ratelimit.Login), deps.MFAHandler.Login)
+	public.POST("/login/passkey/begin", rateLimit(ratelimit.Login), deps.PasskeyHandler.BeginLogin)
+	public.POST("/login/passkey/finish", rateLimit(ratelimit.Login), deps.PasskeyHandler.FinishLogin)
+	public.POST("/token/refresh", rateLimit(ratelimit.Login), deps.TokenHandler.Refresh)
+	public.POST("/password/forgot", rateLimit(ratelimit.Login), deps.PasswordHandler.ForgotPassword)
+	public.POST("/password/reset", rateLimit(ratelimit.Login), deps.PasswordHandler.ResetPassword)
+	public.POST("/webhooks/:id/:token", rateLimit(ratelimit.WebhookPost), deps.IncomingWebhookHandler.Execute)
 	public.GET("/users", deps.UserHandler.GetUsers)
 	public.GET("/users/:username", deps.UserHandler.FindUsername)
 
+	// Requests from other instances are signed by the instance instead of
+	// carrying a user's token.
+	federation := router.Group(service.FederationAPIPrefix)
+	federation.Use(
+		middlewares.FederationAuthMiddleware(deps.FederationService, service.MaxFederationBody),
+		rateLimit(ratelimit.Default),
+	)
+
+	federation.POST("/invites/:code/join", deps.FederationHandler.ServeJoin)
+	federation.POST("/servers/:id/leave", deps.FederationHandler.ServeLeave)
+	federation.GET("/servers/:id/channels", deps.FederationHandler.ServeChannels)
+	federation.GET("/channels/:id/messages", deps.FederationHandler.ServeMessages)
+	federation.POST("/channels/:id/messages", rateLimit(ratelimit.MessageCreate), deps.FederationHandler.ServeSendMessage)
+	federation.POST("/events", deps.FederationHandler.ReceiveEvent)
+
 	protected := router.Group("/api")
-	protected.Use(middlewares.JwtAuthMiddleware())
+	protected.Use(
+		middlewares.JwtAuthMiddleware(deps.TokenService, deps.SessionService, deps.AppService),
+		rateLimit(ratelimit.Default),
+	)
 
 	protected.GET("/me", deps.UserHandler.CurrentUser)
+	protected.GET("/gateway", deps.GatewayHandler.Connect)
+
+	// Account management is for people; bots are managed by their owner
+	// through /applications.
+	account := protected.Group("")
+	account.Use(middlewares.HumanOnlyMiddleware())
+
+	account.POST("/logout", deps.TokenHandler.Logout)
+	account.PATCH("/me/password", deps.PasswordHandler.ChangePassword)
+	account.GET("/me/security-log", deps.UserHandler.GetSecurityLog)
+	account.GET("/me/2fa", deps.MFAHandler.Status)
+	account.POST("/me/2fa/enroll", deps.MFAHandler.BeginEnrollment)
+	account.POST("/me/2fa/confirm", deps.MFAHandler.ConfirmEnrollment)
+	account.POST("/me/2fa/disable", deps.MFAHandler.Disable)
+	account.POST("/me/2fa/recovery-codes", deps.MFAHandler.RegenerateRecoveryCodes)
+	account.GET("/me/passkeys", deps.PasskeyHandler.GetPasskeys)
+	account.POST("/me/passkeys/register/begin", deps.PasskeyHandler.BeginRegistration)
+	account.POST("/me/passkeys/register/finish", deps.PasskeyHandler.FinishRegistration)
+	account.DELETE("/me/passkeys/:id", deps.PasskeyHandler.RemovePasskey)
+	account.GET("/me/sessions", deps.SessionHandler.GetSessions)
+	account.DELETE("/me/sessions", deps.SessionHandler.RevokeOtherSessions)
+	account.DELETE("/me/sessions/:id", deps.SessionHandler.RevokeSession)
+
+	account.POST("/applications", deps.AppHandler.CreateApplication)
+	account.GET("/applications", deps.AppHandler.GetApplications)
+	account.GET("/applications/:id", deps.AppHandler.GetApplication)
+	account.POST("/applications/:id/token", deps.AppHandler.ResetToken)
+	account.PUT("/applications/:id/interactions", deps.CommandHandler.SetInteractionsEndpoint)
 
 	protected.POST("/servers", deps.ServerHandler.CreateServer)
 	protected.GET("/servers", deps.ServerHandler.GetServers)
 	protected.GET("/servers/:id", deps.ServerHandler.GetServer)
 	protected.PATCH("/servers/:id", deps.ServerHandler.UpdateServer)
 	protected.DELETE("/servers/:id", deps.ServerHandler.DeleteServer)
+	protected.PATCH("/servers/:id/mfa", deps.ServerHandler.SetMFARequirement)
+	protected.POST("/servers/:id/bots", deps.ServerHandler.AuthorizeBot)
 
 	protected.POST("/servers/:id/members", deps.ServerHandler.AddMember)
 	protected.DELETE("/servers/:id/members/:userId", deps.ServerHandler.RemoveMember)
 	protected.PATCH("/servers/:id/members/:userId/role", deps.ServerHandler.ChangeMemberRole)
+	protected.PUT("/servers/:id/members/:userId/timeout", deps.ServerHandler.TimeoutMember)
+
+	protected.GET("/servers/:id/bans", deps.ServerHandler.GetBans)
+	protected.PUT("/servers/:id/bans/:userId", deps.ServerHandler.BanMember)
+	protected.DELETE("/servers/:id/bans/:userId", deps.ServerHandler.UnbanMember)
+
+	protected.POST("/servers/:id/invites", deps.ServerHandler.CreateInvite)
+	protected.POST("/invites/:code", rateLimit(ratelimit.InviteJoin), deps.ServerHandler.JoinInvite)
+
+	protected.GET("/servers/:id/commands", deps.CommandHandler.GetCommands)
+	protected.POST("/servers/:id/commands", deps.CommandHandler.RegisterCommand)
+	protected.DELETE("/servers/:id/commands/:commandId", deps.CommandHandler.DeleteCommand)
+	protected.POST("/channels/:id/commands", rateLimit(ratelimit.MessageCreate), deps.CommandHandler.InvokeCommand)
+	protected.POST("/interactions/:id/response", deps.CommandHandler.RespondToInteraction)
+
+	protected.POST("/servers/:id/channels", deps.ChannelHandler.CreateChannel)
+	protected.GET("/servers/:id/channels", deps.ChannelHandler.GetChannels)
+
+	protected.POST("/channels/:id/messages", rateLimit(ratelimit.MessageCreate), deps.MessageHandler.SendMessage)
+	protected.GET("/channels/:id/messages", deps.MessageHandler.GetMessages)
+	protected.PATCH("/messages/:id", deps.MessageHandler.EditMessage)
+	protected.POST("/messages/:id/components", rateLimit(ratelimit.MessageCreate), deps.CommandHandler.ClickComponent)
+	protected.POST("/channels/:id/webhooks", deps.IncomingWebhookHandler.CreateIncomingWebhook)
+	protected.GET("/channels/:id/webhooks", deps.IncomingWebhookHandler.GetIncomingWebhooks)
+	protected.DELETE("/channels/:id/webhooks/:webhookId", deps.IncomingWebhookHandler.DeleteIncomingWebhook)
+	protected.POST("/channels/:id/emails", deps.ChannelEmailHandler.CreateChannelEmail)
+	protected.GET("/channels/:id/emails", deps.ChannelEmailHandler.GetChannelEmails)
+	protected.DELETE("/channels/:id/emails/:emailId", deps.ChannelEmailHandler.DeleteChannelEmail)
+	protected.GET("/attachments/:id", deps.MessageHandler.GetAttachment)
+
+	protected.POST("/federation/servers", rateLimit(ratelimit.InviteJoin), deps.FederationHandler.JoinRemoteServer)
+	protected.DELETE("/federation/servers/:id", deps.FederationHandler.LeaveRemoteServer)
+	protected.GET("/federation/servers/:id/channels", deps.FederationHandler.GetRemoteChannels)
+	protected.GET("/federation/servers/:id/channels/:channelId/messages", deps.FederationHandler.GetRemoteMessages)
+	protected.POST("/federation/servers/:id/channels/:channelId/messages", rateLimit(ratelimit.MessageCreate), deps.FederationHandler.SendRemoteMessage)
+
+	protected.POST("/servers/:id/webhooks", deps.WebhookHandler.CreateWebhook)
+	protected.GET("/servers/:id/webhooks", deps.WebhookHandler.GetWebhooks)
+	protected.PATCH("/servers/:id/webhooks/:webhookId", deps.WebhookHandler.UpdateWebhook)
+	protected.DELETE("/servers/:id/webhooks/:webhookId", deps.WebhookHandler.DeleteWebhook)
+	protected.GET("/servers/:id/webhooks/:webhookId/deliveries", deps.WebhookHandler.GetDeliveries)
+	protected.POST("/servers/:id/webhooks/:webhookId/deliveries/:deliveryId/redeliver", deps.WebhookHandler.Redeliver)
+
+	// The Discord-compatible API authenticates like the rest of the API;
+	// bots send "Authorization: Bot <token>" there as well. Its errors,
+	// those of the middlewares included, take Discord's shape.
+	if deps.DiscordHandler != nil {
+		compat := router.Group("/api/compat/v10")
+		compat.Use(
+			handlers.DiscordErrorMiddleware(),
+			middlewares.JwtAuthMiddleware(deps.TokenService, deps.SessionService, deps.AppService),
+			rateLimit(ratelimit.Default),
+		)
+
+		compat.GET("/users/:userId", deps.DiscordHandler.GetUser)
+		compat.GET("/guilds/:id", deps.DiscordHandler.GetGuild)
+		compat.GET("/guilds/:id/roles", deps.DiscordHandler.GetGuildRoles)
+		compat.GET("/guilds/:id/channels", deps.DiscordHandler.GetGuildChannels)
+		compat.GET("/guilds/:id/members", deps.DiscordHandler.ListMembers)
+		compat.GET("/guilds/:id/members/:userId", deps.DiscordHandler.GetMember)
+		compat.PUT("/guilds/:id/members/:userId", deps.DiscordHandler.AddMember)
+		compat.PATCH("/guilds/:id/members/:userId", deps.DiscordHandler.ModifyMember)
+		compat.DELETE("/guilds/:id/members/:userId", deps.DiscordHandler.RemoveMember)
+		compat.PUT("/guilds/:id/members/:userId/roles/:roleId", deps.DiscordHandler.AddMemberRole)
+		compat.DELETE("/guilds/:id/members/:userId/roles/:roleId", deps.DiscordHandler.RemoveMemberRole)
+		compat.GET("/channels/:id", deps.DiscordHandler.GetChannel)
+		compat.GET("/channels/:id/messages", deps.DiscordHandler.GetMessages)
+		compat.POST("/channels/:id/messages", rateLimit(ratelimit.MessageCreate), deps.DiscordHandler.CreateMessage)
+		compat.GET("/channels/:id/messages/:messageId", deps.DiscordHandler.GetMessage)
+		compat.PATCH("/channels/:id/messages/:messageId", deps.DiscordHandler.EditMessage)
+	}
+
+	deps.WebhookService.Start()
 
-	// protected.POST("/servers/:serverId/channels", deps.ChannelHandler.CreateChannel)
-	// protected.GET("/servers/:serverId/channels", deps.ChannelHandler.GetChannels)
+	if deps.IRCServer != nil {
+		go func() {
+			log.Fatal("irc: ", deps.IRCServer.ListenAndServe())
+		}()
+	}
 
-	// protected.POST("/channels/:channelId/messages", deps.MessageHandler.SendMessage)
-	// protected.GET("/channels/:channelId/messages", deps.MessageHandler.GetMessages)
+	if deps.SMTPServer != nil {
+		go func() {
+			log.Fatal("smtpd: ", deps.SMTPServer.ListenAndServe())
+		}()
+	}
 
-	// Start the server
-	router.Run("localhost:8080")
+	// Start the server. Giving each instance its own listen address lets
+	// several run side by side, e.g. to try federation locally.
+	router.Run(cfg.Server.ListenAddr)
 }
@@ -0,0 +1,213 @@
+// Package apperr defines the errors services and repositories return for
+// failures a client can act on. Each error has a Kind that decides the HTTP
+// status and the machine-readable code of the API's error envelope, so
+// handlers never have to look at messages. Errors of any other type are
+// internal and are reported to clients without their message.
+package apperr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Kind classifies an error. Its value is the code clients see.
+type Kind string
+
+const (
+	KindInternal       Kind = "internal"
+	KindInvalid        Kind = "validation_failed"
+	KindUnauthorized   Kind = "unauthorized"
+	KindForbidden      Kind = "forbidden"
+	KindNotFound       Kind = "not_found"
+	KindConflict       Kind = "conflict"
+	KindGone           Kind = "gone"
+	KindTooLarge       Kind = "payload_too_large"
+	KindRateLimited    Kind = "rate_limited"
+	KindNotImplemented Kind = "not_implemented"
+	KindUnavailable    Kind = "upstream_unavailable"
+	KindTimeout        Kind = "timeout"
+)
+
+// Status is the HTTP status errors of the kind are answered with.
+func (k Kind) Status() int {
+	switch k {
+	case KindInvalid:
+		return http.StatusBadRequest
+	case KindUnauthorized:
+		return http.StatusUnauthorized
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindGone:
+		return http.StatusGone
+	case KindTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case KindRateLimited:
+		return http.StatusTooManyRequests
+	case KindNotImplemented:
+		return http.StatusNotImplemented
+	case KindUnavailable:
+		return http.StatusBadGateway
+	case KindTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// FieldError is a problem with one field of a request body.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is an error of a known kind. Fields is set for validation errors
+// that concern particular fields of the request, RetryAfter (in seconds)
+// for rate limiting.
+type Error struct {
+	Kind       Kind
+	Message    string
+	Fields     []FieldError
+	RetryAfter int
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return string(e.Kind)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is makes every error match the sentinel of its kind, so callers can write
+// errors.Is(err, apperr.ErrNotFound).
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Message == "" && t.Kind == e.Kind
+}
+
+// Sentinels of each kind, for errors.Is.
+var (
+	ErrInvalid        = &Error{Kind: KindInvalid}
+	ErrUnauthorized   = &Error{Kind: KindUnauthorized}
+	ErrForbidden      = &Error{Kind: KindForbidden}
+	ErrNotFound       = &Error{Kind: KindNotFound}
+	ErrConflict       = &Error{Kind: KindConflict}
+	ErrGone           = &Error{Kind: KindGone}
+	ErrTooLarge       = &Error{Kind: KindTooLarge}
+	ErrRateLimited    = &Error{Kind: KindRateLimited}
+	ErrNotImplemented = &Error{Kind: KindNotImplemented}
+	ErrUnavailable    = &Error{Kind: KindUnavailable}
+	ErrTimeout        = &Error{Kind: KindTimeout}
+)
+
+// newf formats the message like fmt.Errorf, so %w records the cause.
+func newf(kind Kind, format string, args ...any) *Error {
+	err := fmt.Errorf(format, args...)
+	return &Error{Kind: kind, Message: err.Error(), Err: errors.Unwrap(err)}
+}
+
+// Invalid reports a request that breaks a rule of the input it takes.
+func Invalid(format string, args ...any) *Error {
+	return newf(KindInvalid, format, args...)
+}
+
+// Unauthorized reports missing or rejected credentials.
+func Unauthorized(format string, args ...any) *Error {
+	return newf(KindUnauthorized, format, args...)
+}
+
+// Forbidden reports a caller who may not do what they asked.
+func Forbidden(format string, args ...any) *Error {
+	return newf(KindForbidden, format, args...)
+}
+
+// NotFound reports a record that does not exist or the caller cannot see.
+func NotFound(format string, args ...any) *Error {
+	return newf(KindNotFound, format, args...)
+}
+
+// Conflict reports a request that clashes with the current state.
+func Conflict(format string, args ...any) *Error {
+	return newf(KindConflict, format, args...)
+}
+
+// Gone reports something that existed but has expired.
+func Gone(format string, args ...any) *Error {
+	return newf(KindGone, format, args...)
+}
+
+// TooLarge reports a request body over the size the endpoint accepts.
+func TooLarge(format string, args ...any) *Error {
+	return newf(KindTooLarge, format, args...)
+}
+
+// RateLimited reports a caller who must wait retryAfter seconds.
+func RateLimited(retryAfter int, format string, args ...any) *Error {
+	e := newf(KindRateLimited, format, args...)
+	e.RetryAfter = retryAfter
+	return e
+}
+
+// NotImplemented reports a feature this instance has not enabled.
+func NotImplemented(format string, args ...any) *Error {
+	return newf(KindNotImplemented, format, args...)
+}
+
+// Unavailable reports a failure of another system the request depends on,
+// such as a bot's endpoint or another instance.
+func Unavailable(format string, args ...any) *Error {
+	return newf(KindUnavailable, format, args...)
+}
+
+// Timeout reports work that did not finish before the request's deadline.
+func Timeout(format string, args ...any) *Error {
+	return newf(KindTimeout, format, args...)
+}
+
+// KindOf returns the kind of the first *Error in err's chain, and
+// KindInternal if there is none. An expired context deadline anywhere in
+// the chain is KindTimeout.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return KindTimeout
+	}
+	return KindInternal
+}
+
+// As returns the first *Error in err's chain, or nil.
+func As(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return nil
+}
+
+// FromStatus makes an error of the kind an HTTP status stands for, for
+// relaying the answer of another service. 401 and statuses without a kind
+// of their own become KindUnavailable: the caller's credentials are not
+// the ones the other side rejected.
+func FromStatus(status int, format string, args ...any) *Error {
+	kind := KindUnavailable
+	for _, k := range []Kind{KindInvalid, KindForbidden, KindNotFound, KindConflict, KindGone, KindTooLarge, KindRateLimited} {
+		if k.Status() == status {
+			kind = k
+			break
+		}
+	}
+	return newf(kind, format, args...)
+}
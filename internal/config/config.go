@@ -0,0 +1,319 @@
+// Package config describes every setting rio has and where it comes from.
+// Settings start at their defaults and are overridden, in turn, by a YAML
+// or TOML file, by the environment (including a .env file in the working
+// directory) and by command-line flags.
+//
+// Each setting is tagged with its key in the file, written as
+// section.key on the command line, and with the environment variable it
+// is read from. Settings tagged secret are redacted when the configuration
+// is printed.
+package config
+
+import (
+	"time"
+
+	"rio/internal/db"
+	"rio/internal/mailer"
+	"rio/internal/ratelimit"
+	"rio/internal/service"
+	"rio/internal/store"
+	"rio/middlewares"
+	"rio/utils/token"
+	"rio/utils/webauthn"
+)
+
+type Config struct {
+	Server      Server      `key:"server"`
+	Database    Database    `key:"database"`
+	Store       Store       `key:"store"`
+	Auth        Auth        `key:"auth"`
+	Passwords   Passwords   `key:"passwords"`
+	WebAuthn    WebAuthn    `key:"webauthn"`
+	RateLimit   RateLimit   `key:"rate_limit"`
+	Mail        Mail        `key:"mail"`
+	Webhooks    Webhooks    `key:"webhooks"`
+	IRC         IRC         `key:"irc"`
+	InboundMail InboundMail `key:"inbound_mail"`
+	Federation  Federation  `key:"federation"`
+	Discord     Discord     `key:"discord"`
+}
+
+type Server struct {
+	ListenAddr string `key:"listen_addr" env:"LISTEN_ADDR" usage:"address the HTTP API listens on"`
+	// PublicURL is the externally visible base URL, used to build the
+	// secret URLs of incoming webhooks.
+	PublicURL string `key:"public_url" env:"PUBLIC_URL" usage:"externally visible base URL"`
+	// Login throttling keys on the client IP, so only these proxies may
+	// set X-Forwarded-For.
+	TrustedProxies []string `key:"trusted_proxies" env:"TRUSTED_PROXIES" usage:"comma-separated proxies allowed to set X-Forwarded-For"`
+	// RequestTimeout is the deadline of every route not listed in
+	// RequestTimeoutRoutes, whose entries are written
+	// "METHOD /path=duration". Zero means no deadline.
+	RequestTimeout       time.Duration `key:"request_timeout" env:"REQUEST_TIMEOUT" usage:"deadline of a request, or 0 for none"`
+	RequestTimeoutRoutes []string      `key:"request_timeout_routes" env:"REQUEST_TIMEOUT_ROUTES" usage:"comma-separated per-route deadlines such as \"POST /api/channels/:id/messages=30s\""`
+}
+
+type Database struct {
+	Driver   string `key:"driver" env:"DB_DRIVER" usage:"mysql, postgres or sqlite3"`
+	Host     string `key:"host" env:"DB_HOST" usage:"database host"`
+	Port     string `key:"port" env:"DB_PORT" usage:"database port"`
+	User     string `key:"user" env:"DB_USER" usage:"database user"`
+	Password string `key:"password" env:"DB_PASSWORD" secret:"true" usage:"database password"`
+	// Name is the database, or for SQLite the file holding it.
+	Name    string `key:"name" env:"DB_NAME" usage:"database name, or the SQLite file or :memory:"`
+	SSLMode string `key:"sslmode" env:"DB_SSLMODE" usage:"PostgreSQL sslmode; disable if unset"`
+	// LogQueries logs every query rather than only the failed ones.
+	LogQueries bool `key:"log_queries" env:"DB_LOG_QUERIES" usage:"log every SQL query"`
+}
+
+type Store struct {
+	// InMemory keeps all data in memory instead of the database, for
+	// development and demos. Nothing survives a restart unless Dir names
+	// a directory to persist it to.
+	InMemory         bool             `key:"in_memory" env:"STORE_IN_MEMORY" flag:"in-memory" usage:"keep all data in memory instead of the database; it is lost on exit unless store.dir is set"`
+	Dir              string           `key:"dir" env:"STORE_DIR" usage:"directory the in-memory store is persisted to"`
+	FSync            store.SyncPolicy `key:"fsync" env:"STORE_FSYNC" usage:"when the write-ahead log is flushed: always, interval or never"`
+	FSyncInterval    time.Duration    `key:"fsync_interval" env:"STORE_FSYNC_INTERVAL" usage:"how often the write-ahead log is flushed when fsync is interval"`
+	SnapshotInterval time.Duration    `key:"snapshot_interval" env:"STORE_SNAPSHOT_INTERVAL" usage:"how often a snapshot is taken, or 0 only on start"`
+}
+
+type Auth struct {
+	// APISecret signs tokens with HS256.
+	APISecret     string `key:"api_secret" env:"API_SECRET" secret:"true" usage:"key tokens are signed with using HS256"`
+	JWTSigningAlg string `key:"jwt_signing_alg" env:"JWT_SIGNING_ALG" usage:"HS256, EdDSA or RS256"`
+	JWTKeysDir    string `key:"jwt_keys_dir" env:"JWT_KEYS_DIR" usage:"directory holding the EdDSA and RS256 signing keys"`
+	// JWTVerifyKeys keeps tokens signed with the keys in JWTKeysDir valid
+	// after switching to HS256.
+	JWTVerifyKeys bool `key:"jwt_verify_keys" env:"JWT_VERIFY_KEYS" usage:"accept tokens signed with the keys in jwt_keys_dir while signing with HS256"`
+
+	AccessTokenMinutes int `key:"access_token_minute_lifespan" env:"ACCESS_TOKEN_MINUTE_LIFESPAN" usage:"minutes an access token lasts"`
+	RefreshTokenHours  int `key:"refresh_token_hour_lifespan" env:"REFRESH_TOKEN_HOUR_LIFESPAN" usage:"hours a refresh token lasts"`
+}
+
+type Passwords struct {
+	MinLength     int  `key:"min_length" env:"PASSWORD_MIN_LENGTH" usage:"minimum password length"`
+	RequireUpper  bool `key:"require_upper" env:"PASSWORD_REQUIRE_UPPER" usage:"require an uppercase letter"`
+	RequireLower  bool `key:"require_lower" env:"PASSWORD_REQUIRE_LOWER" usage:"require a lowercase letter"`
+	RequireDigit  bool `key:"require_digit" env:"PASSWORD_REQUIRE_DIGIT" usage:"require a digit"`
+	RequireSymbol bool `key:"require_symbol" env:"PASSWORD_REQUIRE_SYMBOL" usage:"require a symbol"`
+	DenyCommon    bool `key:"deny_common" env:"PASSWORD_DENY_COMMON" usage:"refuse commonly used passwords"`
+
+	// ResetURL is the page password reset emails link to; without it they
+	// carry the bare token.
+	ResetURL     string `key:"reset_url" env:"PASSWORD_RESET_URL" usage:"page password reset emails link to"`
+	ResetMinutes int    `key:"reset_minute_lifespan" env:"PASSWORD_RESET_MINUTE_LIFESPAN" usage:"minutes a password reset token lasts"`
+}
+
+// WebAuthn describes this deployment to authenticators. RPID must be the
+// registrable domain clients see and Origins those the web client is
+// served from.
+type WebAuthn struct {
+	RPID    string   `key:"rp_id" env:"WEBAUTHN_RP_ID" usage:"WebAuthn relying party ID"`
+	RPName  string   `key:"rp_name" env:"WEBAUTHN_RP_NAME" usage:"WebAuthn relying party name"`
+	Origins []string `key:"origins" env:"WEBAUTHN_ORIGINS" usage:"comma-separated origins the web client is served from"`
+}
+
+type RateLimit struct {
+	// Store is "memory" to keep buckets in this process or "db" to keep
+	// them in the database, so limits hold across every rio instance.
+	Store string `key:"store" env:"RATE_LIMIT_STORE" usage:"where rate limit buckets are kept: memory or db"`
+
+	Default       ratelimit.Limit `key:"default" env:"RATE_LIMIT_DEFAULT" usage:"limit on every request, as requests/duration"`
+	Register      ratelimit.Limit `key:"register" env:"RATE_LIMIT_REGISTER" usage:"limit on registrations"`
+	Login         ratelimit.Limit `key:"login" env:"RATE_LIMIT_LOGIN" usage:"limit on logins, token refreshes and password resets"`
+	MessageCreate ratelimit.Limit `key:"message_create" env:"RATE_LIMIT_MESSAGE_CREATE" usage:"limit on posting messages"`
+	InviteJoin    ratelimit.Limit `key:"invite_join" env:"RATE_LIMIT_INVITE_JOIN" usage:"limit on joining through invites"`
+	WebhookPost   ratelimit.Limit `key:"webhook_post" env:"RATE_LIMIT_WEBHOOK_POST" usage:"limit on incoming webhook posts"`
+}
+
+type Mail struct {
+	// Mailer is "smtp" for real delivery, or "log" to write messages to
+	// LogFile or stdout for local development. It has no default: the log
+	// mailer writes out password reset links, which anyone who can read
+	// the log could use.
+	Mailer       string `key:"mailer" env:"MAILER" usage:"smtp, or log to write mail to a file or stdout in development"`
+	SMTPHost     string `key:"smtp_host" env:"SMTP_HOST" usage:"SMTP server host"`
+	SMTPPort     int    `key:"smtp_port" env:"SMTP_PORT" usage:"SMTP server port"`
+	SMTPUsername string `key:"smtp_username" env:"SMTP_USERNAME" usage:"SMTP user"`
+	SMTPPassword string `key:"smtp_password" env:"SMTP_PASSWORD" secret:"true" usage:"SMTP password"`
+	SMTPFrom     string `key:"smtp_from" env:"SMTP_FROM" usage:"sender of outgoing email"`
+	LogFile      string `key:"log_file" env:"MAILER_LOG_FILE" usage:"file the log mailer writes to; stdout if unset"`
+}
+
+type Webhooks struct {
+	// AllowInsecure lets webhooks and interactions URLs target plain-HTTP
+	// and private addresses, for local development only.
+	AllowInsecure bool `key:"allow_insecure" env:"WEBHOOK_ALLOW_INSECURE" usage:"allow webhooks to plain-HTTP and private addresses"`
+}
+
+// IRC enables the IRC gateway when Addr is set. With a certificate and key
+// it only accepts TLS connections.
+type IRC struct {
+	Addr    string `key:"addr" env:"IRC_ADDR" usage:"address the IRC gateway listens on"`
+	TLSCert string `key:"tls_cert" env:"IRC_TLS_CERT" usage:"IRC TLS certificate file"`
+	TLSKey  string `key:"tls_key" env:"IRC_TLS_KEY" usage:"IRC TLS key file"`
+}
+
+// InboundMail hands out channel email addresses at Domain, whose MX record
+// must point at SMTPAddr. Setting SMTPAddr enables the SMTP listener; with a
+// certificate and key it offers STARTTLS.
+type InboundMail struct {
+	Domain   string `key:"domain" env:"INBOUND_MAIL_DOMAIN" usage:"domain channel email addresses are handed out at"`
+	SMTPAddr string `key:"smtp_addr" env:"INBOUND_SMTP_ADDR" usage:"address the inbound SMTP listener listens on"`
+	TLSCert  string `key:"tls_cert" env:"INBOUND_SMTP_TLS_CERT" usage:"inbound SMTP TLS certificate file"`
+	TLSKey   string `key:"tls_key" env:"INBOUND_SMTP_TLS_KEY" usage:"inbound SMTP TLS key file"`
+}
+
+// Federation is enabled by Name, the host or host:port other instances
+// reach this one at. Requests to them are signed with the Ed25519 key in
+// KeyFile, generated on first start. AllowedInstances limits which
+// instances may federate; AllowInsecure talks plain HTTP and allows
+// private addresses, for running several instances on one machine.
+type Federation struct {
+	Name             string   `key:"name" env:"FEDERATION_NAME" usage:"name other instances reach this one at"`
+	KeyFile          string   `key:"key_file" env:"FEDERATION_KEY_FILE" usage:"file holding the federation signing key"`
+	AllowedInstances []string `key:"allowed_instances" env:"FEDERATION_ALLOWED_INSTANCES" usage:"comma-separated instances allowed to federate; any if unset"`
+	AllowInsecure    bool     `key:"allow_insecure" env:"FEDERATION_ALLOW_INSECURE" usage:"federate over plain HTTP and with private addresses"`
+}
+
+// Discord serves a subset of Discord's HTTP API under /api/compat/v10 for
+// bots written against it.
+type Discord struct {
+	Compat bool `key:"compat" env:"DISCORD_COMPAT" usage:"serve the Discord-compatible API"`
+}
+
+// Default returns the configuration used for every setting not given.
+func Default() *Config {
+	policy := service.DefaultPasswordPolicy()
+	persistence := store.DefaultPersistence()
+
+	return &Config{
+		Server: Server{
+			ListenAddr:     "localhost:8080",
+			RequestTimeout: middlewares.DefaultRequestTimeout,
+		},
+		Database: Database{
+			Driver: "mysql",
+		},
+		Store: Store{
+			FSync:            persistence.Sync,
+			FSyncInterval:    persistence.SyncInterval,
+			SnapshotInterval: persistence.SnapshotInterval,
+		},
+		Auth: Auth{
+			JWTSigningAlg:      "HS256",
+			JWTKeysDir:         "keys",
+			AccessTokenMinutes: 15,
+			RefreshTokenHours:  7 * 24,
+		},
+		Passwords: Passwords{
+			MinLength:     policy.MinLength,
+			RequireUpper:  policy.RequireUpper,
+			RequireLower:  policy.RequireLower,
+			RequireDigit:  policy.RequireDigit,
+			RequireSymbol: policy.RequireSymbol,
+			DenyCommon:    policy.DenyCommon,
+			ResetMinutes:  int(service.DefaultResetLifespan / time.Minute),
+		},
+		WebAuthn: WebAuthn{
+			RPID:    "localhost",
+			RPName:  "rio",
+			Origins: []string{"http://localhost:8080"},
+		},
+		RateLimit: RateLimit{
+			Store:         "memory",
+			Default:       ratelimit.DefaultLimit(ratelimit.Default),
+			Register:      ratelimit.DefaultLimit(ratelimit.Register),
+			Login:         ratelimit.DefaultLimit(ratelimit.Login),
+			MessageCreate: ratelimit.DefaultLimit(ratelimit.MessageCreate),
+			InviteJoin:    ratelimit.DefaultLimit(ratelimit.InviteJoin),
+			WebhookPost:   ratelimit.DefaultLimit(ratelimit.WebhookPost),
+		},
+		Mail: Mail{
+			SMTPPort: 587,
+		},
+		Federation: Federation{
+			KeyFile: "federation.pem",
+		},
+	}
+}
+
+func (s Server) Timeouts() (middlewares.Timeouts, error) {
+	return middlewares.NewTimeouts(s.RequestTimeout, s.RequestTimeoutRoutes)
+}
+
+func (d Database) Config() db.Config {
+	return db.Config{
+		Driver:     d.Driver,
+		Host:       d.Host,
+		Port:       d.Port,
+		User:       d.User,
+		Password:   d.Password,
+		Name:       d.Name,
+		SSLMode:    d.SSLMode,
+		LogQueries: d.LogQueries,
+	}
+}
+
+func (s Store) Persistence() store.Persistence {
+	return store.Persistence{
+		Dir:              s.Dir,
+		Sync:             s.FSync,
+		SyncInterval:     s.FSyncInterval,
+		SnapshotInterval: s.SnapshotInterval,
+	}
+}
+
+func (a Auth) Tokens() token.Settings {
+	return token.Settings{
+		Alg:             a.JWTSigningAlg,
+		Secret:          a.APISecret,
+		KeysDir:         a.JWTKeysDir,
+		VerifyKeys:      a.JWTVerifyKeys,
+		AccessLifespan:  time.Duration(a.AccessTokenMinutes) * time.Minute,
+		RefreshLifespan: time.Duration(a.RefreshTokenHours) * time.Hour,
+	}
+}
+
+func (p Passwords) Policy() service.PasswordPolicy {
+	return service.PasswordPolicy{
+		MinLength:     p.MinLength,
+		RequireUpper:  p.RequireUpper,
+		RequireLower:  p.RequireLower,
+		RequireDigit:  p.RequireDigit,
+		RequireSymbol: p.RequireSymbol,
+		DenyCommon:    p.DenyCommon,
+	}
+}
+
+func (p Passwords) ResetLifespan() time.Duration {
+	return time.Duration(p.ResetMinutes) * time.Minute
+}
+
+func (w WebAuthn) RelyingParty() *webauthn.RelyingParty {
+	return &webauthn.RelyingParty{ID: w.RPID, Name: w.RPName, Origins: w.Origins}
+}
+
+// Limits returns the limit of every bucket.
+func (r RateLimit) Limits() map[string]ratelimit.Limit {
+	return map[string]ratelimit.Limit{
+		ratelimit.Default:       r.Default,
+		ratelimit.Register:      r.Register,
+		ratelimit.Login:         r.Login,
+		ratelimit.MessageCreate: r.MessageCreate,
+		ratelimit.InviteJoin:    r.InviteJoin,
+		ratelimit.WebhookPost:   r.WebhookPost,
+	}
+}
+
+func (m Mail) Config() mailer.Config {
+	return mailer.Config{
+		Kind:         m.Mailer,
+		SMTPHost:     m.SMTPHost,
+		SMTPPort:     m.SMTPPort,
+		SMTPUsername: m.SMTPUsername,
+		SMTPPassword: m.SMTPPassword,
+		SMTPFrom:     m.SMTPFrom,
+		LogFile:      m.LogFile,
+	}
+}
@@ -0,0 +1,342 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// setting is one field of a section of Config.
+type setting struct {
+	// path is the setting's key in the file, as section.key.
+	path   string
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	value  reflect.Value
+}
+
+// settings lists every setting of c, in the order they are declared.
+func settings(c *Config) []setting {
+	var all []setting
+	root := reflect.ValueOf(c).Elem()
+	for i := range root.NumField() {
+		section := root.Field(i)
+		sectionKey := root.Type().Field(i).Tag.Get("key")
+		for j := range section.NumField() {
+			field := section.Type().Field(j)
+			s := setting{
+				path:   sectionKey + "." + field.Tag.Get("key"),
+				env:    field.Tag.Get("env"),
+				flag:   field.Tag.Get("flag"),
+				usage:  field.Tag.Get("usage"),
+				secret: field.Tag.Get("secret") == "true",
+				value:  section.Field(j),
+			}
+			if s.flag == "" {
+				s.flag = s.path
+			}
+			all = append(all, s)
+		}
+	}
+	return all
+}
+
+// describe names the setting at path for error messages.
+func describe(path string) string {
+	for _, s := range settings(Default()) {
+		if s.path == path && s.env != "" {
+			return fmt.Sprintf("%s (%s)", path, s.env)
+		}
+	}
+	return path
+}
+
+// Load builds the configuration from the defaults, overridden by the YAML
+// or TOML file at path unless path is empty, then by the environment and
+// last by flags, if given. A .env file in the working directory is loaded
+// into the environment first; variables that are already set win over it.
+//
+// The result still has to be validated.
+func Load(path string, flags *Flags) (*Config, error) {
+	c := Default()
+	all := settings(c)
+
+	if path != "" {
+		if err := loadFile(path, all); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := godotenv.Load(".env"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("cannot read .env: %w", err)
+	}
+	if err := loadDeprecatedEnv(all); err != nil {
+		return nil, err
+	}
+	for _, s := range all {
+		if s.env == "" {
+			continue
+		}
+		if v := os.Getenv(s.env); v != "" {
+			if err := set(s.value, v); err != nil {
+				return nil, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+
+	if flags != nil {
+		for _, given := range flags.given {
+			i := slices.IndexFunc(all, func(s setting) bool { return s.path == given.path })
+			if err := set(all[i].value, given.value); err != nil {
+				return nil, fmt.Errorf("-%s: %w", all[i].flag, err)
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// deprecatedEnv lists environment variables that have been renamed. Each
+// is still read, with a warning, unless the variable replacing it is set.
+var deprecatedEnv = []struct {
+	name        string
+	replacement string
+	// convert turns the old value into one for the replacement.
+	convert func(string) (string, error)
+}{
+	// TOKEN_HOUR_LIFESPAN was the lifetime, in hours, of the single token
+	// rio issued before it had refresh tokens.
+	{"TOKEN_HOUR_LIFESPAN", "ACCESS_TOKEN_MINUTE_LIFESPAN", func(v string) (string, error) {
+		hours, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return "", fmt.Errorf("invalid value %q; must be a whole number", v)
+		}
+		return strconv.Itoa(hours * 60), nil
+	}},
+}
+
+// loadDeprecatedEnv applies the deprecated environment variables that are
+// set to the settings replacing them.
+func loadDeprecatedEnv(all []setting) error {
+	for _, d := range deprecatedEnv {
+		v := os.Getenv(d.name)
+		if v == "" {
+			continue
+		}
+		if os.Getenv(d.replacement) != "" {
+			log.Printf("config: %s is deprecated and ignored because %s is set", d.name, d.replacement)
+			continue
+		}
+		log.Printf("config: %s is deprecated; set %s instead", d.name, d.replacement)
+
+		converted, err := d.convert(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", d.name, err)
+		}
+		i := slices.IndexFunc(all, func(s setting) bool { return s.env == d.replacement })
+		if err := set(all[i].value, converted); err != nil {
+			return fmt.Errorf("%s: %w", d.name, err)
+		}
+	}
+	return nil
+}
+
+// loadFile applies the settings in a YAML (.yaml or .yml) or TOML (.toml)
+// file. Every setting in it must be known, so that a misspelt key is not
+// silently ignored.
+func loadFile(path string, all []setting) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var doc map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return fmt.Errorf("%s: unknown configuration format; the file must end in .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	byPath := make(map[string]setting, len(all))
+	for _, s := range all {
+		byPath[s.path] = s
+	}
+
+	for _, sectionKey := range slices.Sorted(maps.Keys(doc)) {
+		if doc[sectionKey] == nil {
+			continue
+		}
+		section, ok := doc[sectionKey].(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: %s must be a section of settings", path, sectionKey)
+		}
+		for _, key := range slices.Sorted(maps.Keys(section)) {
+			s, ok := byPath[sectionKey+"."+key]
+			if !ok {
+				return fmt.Errorf("%s: unknown setting %s.%s", path, sectionKey, key)
+			}
+			if err := setFromFile(s.value, section[key]); err != nil {
+				return fmt.Errorf("%s: %s: %w", path, s.path, err)
+			}
+		}
+	}
+	return nil
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// set parses s into v as it is written in the environment or on the
+// command line. Lists are comma-separated.
+func set(v reflect.Value, s string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("invalid duration %q; must be such as 10s or 5m", s)
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("invalid value %q; must be true or false", s)
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("invalid value %q; must be a whole number", s)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Slice:
+		var list []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	default:
+		panic("config: unsupported setting type " + v.Type().String())
+	}
+	return nil
+}
+
+// setFromFile assigns a value decoded from a file. Scalars are parsed as
+// set parses them; lists may be written as lists or comma-separated.
+func setFromFile(v reflect.Value, raw any) error {
+	switch raw := raw.(type) {
+	case nil:
+		return nil
+	case map[string]any:
+		return errors.New("must be a single value, not a section")
+	case []any:
+		if v.Kind() != reflect.Slice {
+			return errors.New("must be a single value, not a list")
+		}
+		list := make([]string, 0, len(raw))
+		for _, item := range raw {
+			switch item.(type) {
+			case map[string]any, []any:
+				return errors.New("must be a list of single values")
+			}
+			list = append(list, fmt.Sprint(item))
+		}
+		v.Set(reflect.ValueOf(list))
+		return nil
+	}
+	return set(v, fmt.Sprint(raw))
+}
+
+// Flags collects the settings given on the command line, which Load
+// applies over every other source.
+type Flags struct {
+	given []givenFlag
+}
+
+type givenFlag struct {
+	path  string
+	value string
+}
+
+// RegisterFlags defines a flag on fs for every setting, named after its
+// key as section.key, such as -server.listen_addr.
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	flags := &Flags{}
+	for _, s := range settings(Default()) {
+		f := &flagValue{flags: flags, path: s.path, typ: s.value.Type()}
+		if !s.value.IsZero() {
+			f.text = format(s.value)
+		}
+		fs.Var(f, s.flag, s.usage)
+	}
+	return flags
+}
+
+type flagValue struct {
+	flags *Flags
+	path  string
+	typ   reflect.Type
+	text  string
+}
+
+func (f *flagValue) String() string {
+	return f.text
+}
+
+// Set checks the value parses and records it for Load.
+func (f *flagValue) Set(s string) error {
+	if err := set(reflect.New(f.typ).Elem(), s); err != nil {
+		return err
+	}
+	f.text = s
+	f.flags.given = append(f.flags.given, givenFlag{path: f.path, value: s})
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f.typ.Kind() == reflect.Bool
+}
+
+// format writes v as set parses it.
+func format(v reflect.Value) string {
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		text, _ := m.MarshalText()
+		return string(text)
+	}
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Slice:
+		return strings.Join(v.Interface().([]string), ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
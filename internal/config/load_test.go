@@ -0,0 +1,126 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv unsets, for the rest of the test, every variable Load reads.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, s := range settings(Default()) {
+		if s.env != "" {
+			t.Setenv(s.env, "")
+		}
+	}
+	for _, d := range deprecatedEnv {
+		t.Setenv(d.name, "")
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func parseFlags(t *testing.T, args ...string) *Flags {
+	t.Helper()
+	fs := flag.NewFlagSet("rio", flag.ContinueOnError)
+	flags := RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return flags
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+	path := writeFile(t, "rio.yaml", `
+server:
+  listen_addr: file:1
+  request_timeout: 5s
+  trusted_proxies: [10.0.0.1, 10.0.0.2]
+auth:
+  access_token_minute_lifespan: 1
+  refresh_token_hour_lifespan: 1
+store:
+  in_memory: true
+`)
+	t.Setenv("REQUEST_TIMEOUT", "6s")
+	t.Setenv("ACCESS_TOKEN_MINUTE_LIFESPAN", "2")
+	t.Setenv("REFRESH_TOKEN_HOUR_LIFESPAN", "2")
+	flags := parseFlags(t, "-auth.refresh_token_hour_lifespan=3", "-in-memory=false")
+
+	c, err := Load(path, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, check := range []struct {
+		setting   string
+		got, want any
+	}{
+		{"server.listen_addr, only in the file", c.Server.ListenAddr, "file:1"},
+		{"server.trusted_proxies, only in the file", strings.Join(c.Server.TrustedProxies, ","), "10.0.0.1,10.0.0.2"},
+		{"server.request_timeout, in the file and the environment", c.Server.RequestTimeout, 6 * time.Second},
+		{"auth.access_token_minute_lifespan, in the file and the environment", c.Auth.AccessTokenMinutes, 2},
+		{"auth.refresh_token_hour_lifespan, everywhere", c.Auth.RefreshTokenHours, 3},
+		{"store.in_memory, in the file and a flag", c.Store.InMemory, false},
+		{"auth.jwt_signing_alg, nowhere", c.Auth.JWTSigningAlg, "HS256"},
+	} {
+		if check.got != check.want {
+			t.Errorf("%s = %v; want %v", check.setting, check.got, check.want)
+		}
+	}
+}
+
+func TestLoadDeprecatedEnv(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("TOKEN_HOUR_LIFESPAN", "2")
+	c, err := Load("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Auth.AccessTokenMinutes != 120 {
+		t.Fatalf("access token lifespan from TOKEN_HOUR_LIFESPAN=2 = %d minutes; want 120", c.Auth.AccessTokenMinutes)
+	}
+
+	t.Setenv("ACCESS_TOKEN_MINUTE_LIFESPAN", "30")
+	if c, err = Load("", nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.Auth.AccessTokenMinutes != 30 {
+		t.Fatalf("access token lifespan with both variables set = %d minutes; want the new one's 30", c.Auth.AccessTokenMinutes)
+	}
+
+	t.Setenv("ACCESS_TOKEN_MINUTE_LIFESPAN", "")
+	t.Setenv("TOKEN_HOUR_LIFESPAN", "two")
+	if _, err := Load("", nil); err == nil || !strings.Contains(err.Error(), "TOKEN_HOUR_LIFESPAN") {
+		t.Fatalf("Load with TOKEN_HOUR_LIFESPAN=two: %v; want it refused", err)
+	}
+}
+
+func TestLoadUnknownKeys(t *testing.T) {
+	clearEnv(t)
+	for _, tt := range []struct {
+		name, content, want string
+	}{
+		{"rio.yaml", "server:\n  listen_adr: :8080\n", "unknown setting server.listen_adr"},
+		{"rio.toml", "[server]\nlisten_adr = \":8080\"\n", "unknown setting server.listen_adr"},
+		{"rio.yaml", "servers:\n  listen_addr: :8080\n", "unknown setting servers.listen_addr"},
+		{"rio.yaml", "server: :8080\n", "server must be a section of settings"},
+		{"rio.json", "{}", "unknown configuration format"},
+	} {
+		_, err := Load(writeFile(t, tt.name, tt.content), nil)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Load(%s %q): %v; want %q", tt.name, tt.content, err, tt.want)
+		}
+	}
+}
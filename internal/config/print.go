@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const redacted = "[redacted]"
+
+// String writes the configuration as YAML that Load reads back, with every
+// secret that is set redacted, so it is safe to log.
+func (c Config) String() string {
+	var b strings.Builder
+	section := ""
+	for _, s := range settings(&c) {
+		sectionKey, key, _ := strings.Cut(s.path, ".")
+		if sectionKey != section {
+			if section != "" {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "%s:\n", sectionKey)
+			section = sectionKey
+		}
+		fmt.Fprintf(&b, "  %s: %s\n", key, yamlValue(s))
+	}
+	return b.String()
+}
+
+func yamlValue(s setting) string {
+	v := s.value
+	switch {
+	case s.secret && !v.IsZero():
+		return strconv.Quote(redacted)
+	case v.Type() == durationType:
+		return strconv.Quote(format(v))
+	case v.Kind() == reflect.Bool || v.Kind() == reflect.Int:
+		return format(v)
+	case v.Kind() == reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = strconv.Quote(v.Index(i).String())
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	return strconv.Quote(format(v))
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStringRedactsSecrets(t *testing.T) {
+	clearEnv(t)
+	c := Default()
+	c.Auth.APISecret = "api-secret"
+	c.Mail.SMTPPassword = "smtp-secret"
+	c.Mail.SMTPUsername = "mailer@example.com"
+
+	printed := c.String()
+	for _, secret := range []string{"api-secret", "smtp-secret"} {
+		if strings.Contains(printed, secret) {
+			t.Errorf("printed configuration holds the secret %q:\n%s", secret, printed)
+		}
+	}
+	for _, line := range []string{
+		`  api_secret: "[redacted]"`,
+		`  smtp_password: "[redacted]"`,
+		`  smtp_username: "mailer@example.com"`,
+		// An unset secret is printed as unset.
+		`  password: ""`,
+	} {
+		if !strings.Contains(printed, line+"\n") {
+			t.Errorf("printed configuration lacks %q:\n%s", line, printed)
+		}
+	}
+
+	// What is printed loads back.
+	loaded, err := Load(writeFile(t, "rio.yaml", printed), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Mail.SMTPUsername != c.Mail.SMTPUsername || loaded.Auth.AccessTokenMinutes != c.Auth.AccessTokenMinutes {
+		t.Fatalf("printed configuration loaded back as %+v", loaded)
+	}
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"rio/internal/mailer"
+	"rio/utils/token"
+)
+
+// Validate reports every setting that cannot be used, so that rio refuses
+// to start instead of failing once the setting is needed.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(path string, err error) {
+		errs = append(errs, fmt.Errorf("%s: %w", describe(path), err))
+	}
+
+	if c.Server.ListenAddr == "" {
+		invalid("server.listen_addr", errors.New("must be set"))
+	}
+	if c.Server.RequestTimeout < 0 {
+		invalid("server.request_timeout", errors.New("must not be negative"))
+	} else if _, err := c.Server.Timeouts(); err != nil {
+		invalid("server.request_timeout_routes", err)
+	}
+
+	if c.Store.InMemory {
+		if err := c.Store.Persistence().Validate(); err != nil {
+			invalid("store", err)
+		}
+		if c.RateLimit.Store == "db" {
+			invalid("rate_limit.store", errors.New("db needs a database; use memory when running in memory"))
+		}
+	} else if err := c.Database.Config().Validate(); err != nil {
+		invalid("database", err)
+	}
+
+	alg, err := token.SigningAlgorithm(c.Auth.JWTSigningAlg)
+	if err != nil {
+		invalid("auth.jwt_signing_alg", err)
+	}
+	if alg == "HS256" && c.Auth.APISecret == "" {
+		invalid("auth.api_secret", errors.New("must be set to sign tokens with HS256"))
+	}
+	if alg != "HS256" && c.Auth.JWTKeysDir == "" {
+		invalid("auth.jwt_keys_dir", fmt.Errorf("must be set to sign tokens with %s", alg))
+	}
+	if alg == "HS256" && c.Auth.JWTVerifyKeys && c.Auth.JWTKeysDir == "" {
+		invalid("auth.jwt_keys_dir", errors.New("must be set to verify tokens with its keys"))
+	}
+	if c.Auth.AccessTokenMinutes <= 0 {
+		invalid("auth.access_token_minute_lifespan", errors.New("must be positive"))
+	}
+	if c.Auth.RefreshTokenHours <= 0 {
+		invalid("auth.refresh_token_hour_lifespan", errors.New("must be positive"))
+	}
+
+	if err := c.Passwords.Policy().Check(); err != nil {
+		invalid("passwords.min_length", err)
+	}
+	if c.Passwords.ResetMinutes <= 0 {
+		invalid("passwords.reset_minute_lifespan", errors.New("must be positive"))
+	}
+
+	if c.WebAuthn.RPID == "" {
+		invalid("webauthn.rp_id", errors.New("must be set"))
+	}
+	if len(c.WebAuthn.Origins) == 0 {
+		invalid("webauthn.origins", errors.New("must list at least one origin"))
+	}
+
+	switch c.RateLimit.Store {
+	case "memory", "db":
+	default:
+		invalid("rate_limit.store", fmt.Errorf("unknown store %q; must be memory or db", c.RateLimit.Store))
+	}
+
+	if c.Mail.Mailer == "" {
+		invalid("mail.mailer", errors.New("must be set: smtp, or log to write mail, password reset links included, to mail.log_file or stdout"))
+	} else if _, err := mailer.New(c.Mail.Config()); err != nil {
+		invalid("mail", err)
+	}
+
+	if (c.IRC.TLSCert == "") != (c.IRC.TLSKey == "") {
+		invalid("irc", errors.New("tls_cert and tls_key must be set together"))
+	}
+	if c.InboundMail.SMTPAddr != "" && c.InboundMail.Domain == "" {
+		invalid("inbound_mail.domain", errors.New("must be set to accept mail on inbound_mail.smtp_addr"))
+	}
+	if (c.InboundMail.TLSCert == "") != (c.InboundMail.TLSKey == "") {
+		invalid("inbound_mail", errors.New("tls_cert and tls_key must be set together"))
+	}
+
+	if c.Federation.Name != "" && c.Federation.KeyFile == "" {
+		invalid("federation.key_file", errors.New("must be set to federate"))
+	}
+
+	return errors.Join(errs...)
+}
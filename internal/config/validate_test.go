@@ -0,0 +1,31 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateMailer(t *testing.T) {
+	for _, tt := range []struct {
+		mailer string
+		want   string
+	}{
+		{"", "mail.mailer (MAILER): must be set"},
+		{"carrier-pigeon", `unknown mailer "carrier-pigeon"`},
+		{"smtp", "the smtp mailer needs a host"},
+		{"log", ""},
+	} {
+		c := Default()
+		c.Auth.APISecret = "secret"
+		c.Database.Name = "rio"
+		c.Mail.Mailer = tt.mailer
+
+		err := c.Validate()
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("mailer %q: %v; want it accepted", tt.mailer, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("mailer %q: %v; want %q", tt.mailer, err, tt.want)
+		}
+	}
+}
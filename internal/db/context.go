@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jinzhu/gorm"
+)
+
+// WithContext returns a handle on DB whose queries run under ctx, so they
+// are cancelled when the request that issued them is. gorm v1 has no
+// context support of its own; the handle is opened on a connection that
+// passes ctx to every call, with the logging set by SetLogging. If ctx
+// carries a transaction started by Transaction, the handle is that
+// transaction.
+func WithContext(ctx context.Context) *gorm.DB {
+	if ctx == nil {
+		return DB
+	}
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx
+	}
+	if ctx.Done() == nil {
+		return DB
+	}
+	sqlDB, ok := DB.CommonDB().(*sql.DB)
+	if !ok {
+		return DB
+	}
+
+	scoped, err := gorm.Open(DB.Dialect().GetName(), ctxConn{ctx: ctx, db: sqlDB})
+	if err != nil {
+		return DB
+	}
+	return applyLogging(scoped)
+}
+
+// Logger receives the lines gorm logs.
+type Logger interface {
+	Print(v ...interface{})
+}
+
+var logging struct {
+	logger  Logger
+	queries bool
+}
+
+// SetLogging makes DB and the handles WithContext opens log through logger,
+// or gorm's own logger if it is nil: every query if queries is set, only
+// failures otherwise. gorm cannot tell what a handle's settings are, so the
+// handles take theirs from here rather than from DB.
+func SetLogging(logger Logger, queries bool) {
+	logging.logger, logging.queries = logger, queries
+	applyLogging(DB)
+}
+
+func applyLogging(h *gorm.DB) *gorm.DB {
+	if logging.logger != nil {
+		h.SetLogger(logging.logger)
+	}
+	if logging.queries {
+		h.LogMode(true)
+	}
+	return h
+}
+
+type txKey struct{}
+
+// Transaction runs fn in a database transaction, passing it a context that
+// carries the transaction, so every repository querying through
+// WithContext takes part in it. The transaction is committed if fn returns
+// nil and rolled back if it returns an error or panics. Called with a
+// context that already carries a transaction, fn joins it.
+func Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+
+	tx := WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// registerDialect makes the dialect conn was opened with available to
+// gorm.Open by name, for WithContext. Drivers without a gorm dialect of
+// their own run under gorm's unexported common dialect, which is not
+// registered, and opening it again would log a compatibility warning on
+// every call.
+func registerDialect(conn *gorm.DB) {
+	gorm.RegisterDialect(conn.Dialect().GetName(), conn.Dialect())
+}
+
+// ctxConn is the gorm.SQLCommon gorm queries through, bound to a context.
+// It also implements gorm's transaction interface, so Begin and
+// Transaction work on a handle from WithContext.
+type ctxConn struct {
+	ctx context.Context
+	db  *sql.DB
+}
+
+func (c ctxConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.db.ExecContext(c.ctx, query, args...)
+}
+
+func (c ctxConn) Prepare(query string) (*sql.Stmt, error) {
+	return c.db.PrepareContext(c.ctx, query)
+}
+
+func (c ctxConn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.db.QueryContext(c.ctx, query, args...)
+}
+
+func (c ctxConn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.db.QueryRowContext(c.ctx, query, args...)
+}
+
+func (c ctxConn) Begin() (*sql.Tx, error) {
+	return c.db.BeginTx(c.ctx, nil)
+}
+
+// BeginTx starts the transaction under the handle's context: gorm's Begin
+// passes context.Background.
+func (c ctxConn) BeginTx(_ context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return c.db.BeginTx(c.ctx, opts)
+}
@@ -0,0 +1,61 @@
+//go:build cgo
+
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type recordingLogger struct {
+	lines int
+}
+
+func (l *recordingLogger) Print(v ...any) {
+	l.lines++
+}
+
+func openTestDB(t *testing.T) {
+	t.Helper()
+	conn, err := Open(Config{Driver: SQLite, Name: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	DB = conn
+}
+
+func TestWithContext(t *testing.T) {
+	openTestDB(t)
+	logger := &recordingLogger{}
+	SetLogging(logger, true)
+	t.Cleanup(func() { SetLogging(nil, false) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := WithContext(ctx).Exec("CREATE TABLE t (n INTEGER)").Error; err != nil {
+		t.Fatal(err)
+	}
+	if logger.lines == 0 {
+		t.Error("a handle from WithContext does not log like DB")
+	}
+
+	err := Transaction(ctx, func(ctx context.Context) error {
+		return WithContext(ctx).Exec("INSERT INTO t (n) VALUES (1)").Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := DB.Table("t").Count(&count).Error; err != nil || count != 1 {
+		t.Fatalf("count = %d, %v; want 1 committed row", count, err)
+	}
+
+	cancel()
+	err = WithContext(ctx).Exec("INSERT INTO t (n) VALUES (2)").Error
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("query under a cancelled context: %v; want context.Canceled", err)
+	}
+}
@@ -3,43 +3,144 @@ package db
 import (
 	"fmt"
 	"log"
-	"os"
 
-	"rio/internal/models"
+	"rio/internal/db/migrations"
 
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/mysql"
-	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 var DB *gorm.DB
 
-func ConnectDataBase() {
-	err := godotenv.Load(".env")
+// The drivers rio can use. SQLite needs cgo.
+const (
+	MySQL    = "mysql"
+	Postgres = "postgres"
+	SQLite   = "sqlite3"
+)
+
+// Config is where the database is and how to reach it.
+type Config struct {
+	Driver   string
+	Host     string
+	Port     string
+	User     string
+	Password string
+	// Name is the database, or for SQLite the file holding it, ":memory:"
+	// keeping it in memory instead.
+	Name string
+	// SSLMode is passed to PostgreSQL; it defaults to disable.
+	SSLMode string
+	// LogQueries logs every query, not only those that fail.
+	LogQueries bool
+}
 
+// Driver returns the driver name stands for: mysql, postgres or sqlite3,
+// also written postgresql and sqlite. An empty name means mysql.
+func Driver(name string) (string, error) {
+	switch name {
+	case "":
+		return MySQL, nil
+	case "postgresql":
+		return Postgres, nil
+	case "sqlite":
+		return SQLite, nil
+	case MySQL, Postgres, SQLite:
+		return name, nil
+	}
+	return "", fmt.Errorf("unknown database driver %q; must be mysql, postgres or sqlite3", name)
+}
+
+// Validate reports whether c can be opened.
+func (c Config) Validate() error {
+	driver, err := Driver(c.Driver)
 	if err != nil {
-		log.Fatalf("Error loading .env file")
+		return err
+	}
+	if driver == SQLite && c.Name == "" {
+		return fmt.Errorf("the database name must be the SQLite database file, or :memory:")
 	}
+	return nil
+}
 
-	Dbdriver := os.Getenv("DB_DRIVER")
-	DbHost := os.Getenv("DB_HOST")
-	DbUser := os.Getenv("DB_USER")
-	DbPassword := os.Getenv("DB_PASSWORD")
-	DbName := os.Getenv("DB_NAME")
-	DbPort := os.Getenv("DB_PORT")
+// DSN is the data source name the driver is opened with.
+func (c Config) DSN() string {
+	switch c.Driver {
+	case Postgres:
+		sslMode := c.SSLMode
+		if sslMode == "" {
+			sslMode = "disable"
+		}
+		return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s", c.Host, c.Port, c.User, c.Password, c.Name, sslMode)
+	case SQLite:
+		// Transactions take the write lock when they begin, so the rows
+		// they read cannot change before they write; SQLite has no
+		// SELECT ... FOR UPDATE. Writers wait for each other instead of
+		// failing straight away.
+		return c.Name + "?_txlock=immediate&_busy_timeout=5000&_journal_mode=WAL"
+	default:
+		return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8&parseTime=True&loc=Local", c.User, c.Password, c.Host, c.Port, c.Name)
+	}
+}
 
-	DBURL := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8&parseTime=True&loc=Local", DbUser, DbPassword, DbHost, DbPort, DbName)
+// Open connects to the database c describes. It leaves the schema as it
+// is; see package migrations.
+func Open(c Config) (*gorm.DB, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	c.Driver, _ = Driver(c.Driver)
 
-	DB, err = gorm.Open(Dbdriver, DBURL)
+	conn, err := gorm.Open(c.Driver, c.DSN())
+	if err != nil {
+		return nil, err
+	}
+	registerDialect(conn)
+
+	if c.Driver == SQLite && c.Name == ":memory:" {
+		// Every connection to :memory: opens a database of its own, so
+		// there must only ever be one, and it must be kept open.
+		conn.DB().SetMaxOpenConns(1)
+		conn.DB().SetMaxIdleConns(1)
+	}
+	return conn, nil
+}
+
+// ConnectDataBase opens the database config describes as DB and brings
+// its schema up to date, exiting if either fails.
+func ConnectDataBase(config Config) {
+	var err error
+	DB, err = Open(config)
 
 	if err != nil {
-		fmt.Println("Cannot connect to database", Dbdriver)
+		fmt.Println("Cannot connect to database", config.Driver)
 		log.Fatal("connection error: ", err)
 	} else {
-		fmt.Println("We are connected to the database ", Dbdriver)
+		fmt.Println("We are connected to the database ", config.Driver)
+	}
+	SetLogging(nil, config.LogQueries)
+
+	applied, err := migrations.Up(DB, 0)
+	for _, m := range applied {
+		log.Printf("applied migration %04d %s", m.Version, m.Name)
 	}
+	if err != nil {
+		log.Fatal("cannot migrate the database: ", err)
+	}
+}
 
-	DB.AutoMigrate(&models.User{})
-	DB.AutoMigrate(&models.Server{})
-	DB.AutoMigrate(&models.UserServer{})
+// Dialect is the name of the driver DB was opened with.
+func Dialect() string {
+	return DB.Dialect().GetName()
+}
+
+// EqualFold is a condition matching column against one argument ignoring
+// case, as MySQL's default collation compares strings.
+func EqualFold(column string) string {
+	if Dialect() == MySQL {
+		return column + " = ?"
+	}
+	return "LOWER(" + column + ") = LOWER(?)"
 }
@@ -0,0 +1,27 @@
+//go:build cgo
+
+// Package dbtest sets up databases for the tests of the database
+// repositories.
+package dbtest
+
+import (
+	"testing"
+
+	"rio/internal/db"
+	"rio/internal/db/migrations"
+)
+
+// Open points db.DB at a migrated in-memory SQLite database for the rest
+// of the test.
+func Open(t testing.TB) {
+	t.Helper()
+	conn, err := db.Open(db.Config{Driver: db.SQLite, Name: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if _, err := migrations.Up(conn, 0); err != nil {
+		t.Fatal(err)
+	}
+	db.DB = conn
+}
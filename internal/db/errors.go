@@ -0,0 +1,22 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/jinzhu/mysql"
+	"github.com/lib/pq"
+)
+
+// IsUniqueViolation reports whether err is an insert or update refused by
+// a unique index, whichever database refused it.
+func IsUniqueViolation(err error) bool {
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return myErr.Number == 1062 // ER_DUP_ENTRY
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505" // unique_violation
+	}
+	return isSQLiteUniqueViolation(err)
+}
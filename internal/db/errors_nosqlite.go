@@ -0,0 +1,9 @@
+//go:build !cgo
+
+package db
+
+// Without cgo the SQLite driver is a stub that cannot open a database, so
+// it has no errors to recognise.
+func isSQLiteUniqueViolation(err error) bool {
+	return false
+}
@@ -0,0 +1,321 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 0001 is the schema as AutoMigrate built it before migrations existed,
+// from copies of the models at the time. On a database AutoMigrate already
+// set up it only adds whatever was missing.
+func init() {
+	register(Migration{
+		Version: 1,
+		Name:    "initial_schema",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(initialSchema()...).Error; err != nil {
+				return err
+			}
+			// MySQL compares usernames ignoring case, so its unique index
+			// already keeps out "Alice" once there is an "alice"; elsewhere
+			// that takes an index on the lowercased name.
+			if tx.Dialect().GetName() != "mysql" {
+				return tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_lower ON users (LOWER(username))").Error
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			tables := initialSchema()
+			for i := len(tables) - 1; i >= 0; i-- {
+				if err := tx.DropTableIfExists(tables[i]).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// initialSchema returns the tables of 0001, in the order they are created.
+// user_servers comes first so that it is built from its own columns rather
+// than from the many2many relation between users and servers, which would
+// give it integer keys.
+func initialSchema() []interface{} {
+	type userServer struct {
+		UserID       string    `gorm:"primaryKey;type:varchar(26)"`
+		ServerID     string    `gorm:"primaryKey;type:varchar(26)"`
+		Role         string    `gorm:"type:varchar(20);not null;default:'member'"`
+		JoinedAt     time.Time `gorm:"autoCreateTime"`
+		TimeoutUntil *time.Time
+	}
+
+	type user struct {
+		gorm.Model
+		ULID         string `gorm:"type:varchar(26);primaryKey;not null;unique"`
+		Username     string `gorm:"size:255;not null;unique"`
+		Password     string `gorm:"size:255;not null"`
+		Email        string `gorm:"size:255;index"`
+		Role         string `gorm:"size:20;default:'user'"`
+		TOTPSecret   string `gorm:"size:64"`
+		TOTPEnabled  bool   `gorm:"not null;default:false"`
+		TOTPLastStep int64
+		IsBot        bool   `gorm:"not null;default:false"`
+		OwnerID      string `gorm:"type:varchar(26);index"`
+		Instance     string `gorm:"size:255;index"`
+		RemoteID     string `gorm:"type:varchar(26)"`
+	}
+
+	type server struct {
+		gorm.Model
+		ULID       string `gorm:"column:ul_id;type:varchar(26);primaryKey;unique;not null"`
+		Name       string `gorm:"size:255;not null"`
+		OwnerID    string `gorm:"type:varchar(26);index"`
+		RequireMFA bool   `gorm:"not null;default:false"`
+		Instance   string `gorm:"size:255;index"`
+	}
+
+	type channel struct {
+		gorm.Model
+		ULID     string `gorm:"type:varchar(26);primaryKey"`
+		ServerID string `gorm:"type:varchar(26);index"`
+		Name     string `gorm:"not null"`
+	}
+
+	type message struct {
+		gorm.Model
+		ULID           string `gorm:"type:varchar(26);primaryKey"`
+		ChannelID      string `gorm:"type:varchar(26);index"`
+		UserID         string `gorm:"type:varchar(26);index"`
+		Content        string `gorm:"not null"`
+		WebhookID      string `gorm:"type:varchar(26);index"`
+		DisplayName    string `gorm:"size:80"`
+		AvatarURL      string `gorm:"size:2048"`
+		ComponentsJSON string `gorm:"column:components;type:text"`
+	}
+
+	type session struct {
+		gorm.Model
+		ULID       string `gorm:"type:varchar(26);unique;not null"`
+		UserID     string `gorm:"type:varchar(26);index;not null"`
+		DeviceName string `gorm:"size:255"`
+		UserAgent  string `gorm:"size:512"`
+		IP         string `gorm:"size:45"`
+		LastSeenAt time.Time
+		RevokedAt  *time.Time `gorm:"index"`
+	}
+
+	type refreshToken struct {
+		gorm.Model
+		ULID       string     `gorm:"type:varchar(26);unique;not null"`
+		UserID     string     `gorm:"type:varchar(26);index;not null"`
+		FamilyID   string     `gorm:"type:varchar(26);index;not null"`
+		TokenHash  string     `gorm:"type:char(64);unique;not null"`
+		ExpiresAt  time.Time  `gorm:"not null"`
+		RevokedAt  *time.Time `gorm:"index"`
+		ReplacedBy string     `gorm:"type:varchar(26)"`
+	}
+
+	type revokedToken struct {
+		JTI       string    `gorm:"primaryKey;type:varchar(26)"`
+		ExpiresAt time.Time `gorm:"index;not null"`
+	}
+
+	type passwordReset struct {
+		gorm.Model
+		ULID      string    `gorm:"type:varchar(26);unique;not null"`
+		UserID    string    `gorm:"type:varchar(26);index;not null"`
+		TokenHash string    `gorm:"type:char(64);unique;not null"`
+		ExpiresAt time.Time `gorm:"not null"`
+		UsedAt    *time.Time
+	}
+
+	type recoveryCode struct {
+		gorm.Model
+		UserID   string `gorm:"type:varchar(26);index;not null"`
+		CodeHash string `gorm:"type:char(64);not null"`
+		UsedAt   *time.Time
+	}
+
+	type passkey struct {
+		gorm.Model
+		ULID             string `gorm:"type:varchar(26);unique;not null"`
+		UserID           string `gorm:"type:varchar(26);index;not null"`
+		Name             string `gorm:"size:100;not null"`
+		CredentialID     string `gorm:"type:text;not null"`
+		CredentialIDHash string `gorm:"type:char(64);unique;not null"`
+		PublicKey        []byte `gorm:"not null"`
+		SignCount        uint32
+		AAGUID           string `gorm:"type:varchar(36)"`
+		Transports       string `gorm:"size:255"`
+		LastUsedAt       *time.Time
+	}
+
+	type loginAttempt struct {
+		gorm.Model
+		UserID    string `gorm:"type:varchar(26);index"`
+		Username  string `gorm:"size:255;index"`
+		IP        string `gorm:"size:45;index"`
+		UserAgent string `gorm:"size:512"`
+		Method    string `gorm:"size:20"`
+		Success   bool   `gorm:"not null;default:false"`
+		Reason    string `gorm:"size:50"`
+	}
+
+	type rateLimitBucket struct {
+		BucketKey  string  `gorm:"primary_key;size:191"`
+		Tokens     float64 `gorm:"not null"`
+		RefilledAt int64   `gorm:"not null;index"`
+		ResetAt    int64   `gorm:"not null;index"`
+	}
+
+	type application struct {
+		gorm.Model
+		ULID               string `gorm:"type:varchar(26);unique;not null"`
+		Name               string `gorm:"size:255;not null"`
+		Description        string `gorm:"size:400"`
+		OwnerID            string `gorm:"type:varchar(26);index;not null"`
+		BotID              string `gorm:"type:varchar(26);unique;not null"`
+		Public             bool   `gorm:"not null;default:false"`
+		TokenHash          string `gorm:"type:char(64);unique;not null"`
+		InteractionsURL    string `gorm:"size:2048"`
+		InteractionsSecret string `gorm:"size:64"`
+	}
+
+	type webhook struct {
+		gorm.Model
+		ULID      string `gorm:"type:varchar(26);unique;not null"`
+		ServerID  string `gorm:"type:varchar(26);index;not null"`
+		URL       string `gorm:"size:2048;not null"`
+		Secret    string `gorm:"size:64;not null"`
+		Events    string `gorm:"size:255;not null"`
+		Active    bool   `gorm:"not null"`
+		CreatedBy string `gorm:"type:varchar(26)"`
+	}
+
+	type webhookDelivery struct {
+		gorm.Model
+		ULID           string     `gorm:"type:varchar(26);unique;not null"`
+		WebhookID      string     `gorm:"type:varchar(26);index;not null"`
+		EventID        string     `gorm:"type:varchar(26);not null"`
+		EventType      string     `gorm:"size:50;not null"`
+		Payload        string     `gorm:"type:text;not null"`
+		Status         string     `gorm:"size:20;index;not null"`
+		Attempts       int        `gorm:"not null"`
+		NextAttemptAt  *time.Time `gorm:"index"`
+		LastStatusCode int
+		LastError      string `gorm:"size:512"`
+		DeliveredAt    *time.Time
+	}
+
+	type incomingWebhook struct {
+		gorm.Model
+		ULID      string `gorm:"type:varchar(26);unique;not null"`
+		ChannelID string `gorm:"type:varchar(26);index;not null"`
+		ServerID  string `gorm:"type:varchar(26);index;not null"`
+		Name      string `gorm:"size:80;not null"`
+		AvatarURL string `gorm:"size:2048"`
+		TokenHash string `gorm:"type:char(64);not null"`
+		CreatedBy string `gorm:"type:varchar(26)"`
+	}
+
+	type serverBan struct {
+		UserID    string `gorm:"primaryKey;type:varchar(26)"`
+		ServerID  string `gorm:"primaryKey;type:varchar(26)"`
+		BannedBy  string `gorm:"type:varchar(26);not null"`
+		Reason    string `gorm:"size:512"`
+		CreatedAt time.Time
+	}
+
+	type invite struct {
+		gorm.Model
+		Code      string `gorm:"type:varchar(16);unique;not null"`
+		ServerID  string `gorm:"type:varchar(26);index;not null"`
+		CreatedBy string `gorm:"type:varchar(26);not null"`
+		MaxUses   int    `gorm:"not null"`
+		Uses      int    `gorm:"not null"`
+		ExpiresAt *time.Time
+	}
+
+	type applicationCommand struct {
+		gorm.Model
+		ULID          string `gorm:"type:varchar(26);unique;not null"`
+		ApplicationID string `gorm:"type:varchar(26);index;not null"`
+		ServerID      string `gorm:"type:varchar(26);index;not null"`
+		Name          string `gorm:"size:32;not null"`
+		Description   string `gorm:"size:100;not null"`
+		Options       string `gorm:"type:text"`
+	}
+
+	type interaction struct {
+		gorm.Model
+		ULID          string    `gorm:"type:varchar(26);unique;not null"`
+		ApplicationID string    `gorm:"type:varchar(26);index;not null"`
+		CommandID     string    `gorm:"type:varchar(26);not null"`
+		ServerID      string    `gorm:"type:varchar(26);not null"`
+		ChannelID     string    `gorm:"type:varchar(26);not null"`
+		UserID        string    `gorm:"type:varchar(26);not null"`
+		CommandName   string    `gorm:"size:32;not null"`
+		Options       string    `gorm:"type:text"`
+		Status        string    `gorm:"size:20;not null"`
+		ExpiresAt     time.Time `gorm:"not null"`
+		Type          string    `gorm:"size:20;not null"`
+		MessageID     string    `gorm:"type:varchar(26)"`
+		CustomID      string    `gorm:"size:100"`
+	}
+
+	type channelEmail struct {
+		gorm.Model
+		ULID           string `gorm:"type:varchar(26);unique;not null"`
+		ChannelID      string `gorm:"type:varchar(26);index;not null"`
+		ServerID       string `gorm:"type:varchar(26);index;not null"`
+		Name           string `gorm:"size:80;not null"`
+		LocalPart      string `gorm:"size:32;unique;not null"`
+		AllowedSenders string `gorm:"type:text"`
+		MaxSize        int    `gorm:"not null"`
+		CreatedBy      string `gorm:"type:varchar(26)"`
+	}
+
+	type attachment struct {
+		gorm.Model
+		ULID        string `gorm:"type:varchar(26);unique;not null"`
+		MessageID   string `gorm:"type:varchar(26);index;not null"`
+		Filename    string `gorm:"size:255;not null"`
+		ContentType string `gorm:"size:255;not null"`
+		Size        int    `gorm:"not null"`
+		Data        []byte `gorm:"size:4294967295"`
+	}
+
+	type snowflake struct {
+		gorm.Model
+		Snowflake string `gorm:"type:varchar(20);unique;not null"`
+		ULID      string `gorm:"type:varchar(26);unique;not null"`
+	}
+
+	return []interface{}{
+		&userServer{},
+		&user{},
+		&server{},
+		&channel{},
+		&message{},
+		&session{},
+		&refreshToken{},
+		&revokedToken{},
+		&passwordReset{},
+		&recoveryCode{},
+		&passkey{},
+		&loginAttempt{},
+		&rateLimitBucket{},
+		&application{},
+		&webhook{},
+		&webhookDelivery{},
+		&incomingWebhook{},
+		&serverBan{},
+		&invite{},
+		&applicationCommand{},
+		&interaction{},
+		&channelEmail{},
+		&attachment{},
+		&snowflake{},
+	}
+}
@@ -0,0 +1,162 @@
+package migrations
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 0002 adds the keys 0001 meant to create: its copies of the models
+// spelled them primaryKey, which gorm v1 ignores, so revoked_tokens,
+// server_bans and user_servers were built with no key at all and could
+// hold the same row more than once. Duplicates are dropped, keeping one of
+// each, and a unique index takes the place of the missing primary key.
+//
+// 0001 also left messages.content as varchar(255), shorter than the
+// messages rio accepts; it becomes text. SQLite does not enforce the
+// length of a varchar, so there it already holds any message.
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "keys_and_message_text",
+		Up: func(tx *gorm.DB) error {
+			for _, k := range keys0002 {
+				if err := dropDuplicates(tx, k); err != nil {
+					return err
+				}
+				if tx.Dialect().HasIndex(k.table, k.index) {
+					continue
+				}
+				if err := tx.Table(k.table).AddUniqueIndex(k.index, k.columns...).Error; err != nil {
+					return err
+				}
+			}
+
+			switch tx.Dialect().GetName() {
+			case "mysql":
+				return tx.Exec("ALTER TABLE messages MODIFY content text NOT NULL").Error
+			case "postgres":
+				return tx.Exec("ALTER TABLE messages ALTER COLUMN content TYPE text").Error
+			}
+			return nil
+		},
+		// Down only drops the indexes: narrowing messages.content again
+		// would cut or refuse the messages longer than 255 characters.
+		Down: func(tx *gorm.DB) error {
+			for _, k := range keys0002 {
+				if !tx.Dialect().HasIndex(k.table, k.index) {
+					continue
+				}
+				if err := tx.Table(k.table).RemoveIndex(k.index).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
+
+type key0002 struct {
+	table   string
+	index   string
+	columns []string
+	// keep orders the rows of a duplicate group, the first one being kept.
+	keep string
+	// row makes a copy of the table's model to read a duplicate into.
+	row func() interface{}
+}
+
+// The models as of 0002, with the keys spelled the way gorm reads them.
+type (
+	revokedToken0002 struct {
+		JTI       string    `gorm:"primary_key;type:varchar(26)"`
+		ExpiresAt time.Time `gorm:"index;not null"`
+	}
+
+	serverBan0002 struct {
+		UserID    string `gorm:"primary_key;type:varchar(26)"`
+		ServerID  string `gorm:"primary_key;type:varchar(26)"`
+		BannedBy  string `gorm:"type:varchar(26);not null"`
+		Reason    string `gorm:"size:512"`
+		CreatedAt time.Time
+	}
+
+	userServer0002 struct {
+		UserID       string `gorm:"primary_key;type:varchar(26)"`
+		ServerID     string `gorm:"primary_key;type:varchar(26)"`
+		Role         string `gorm:"type:varchar(20);not null;default:'member'"`
+		JoinedAt     time.Time
+		TimeoutUntil *time.Time
+	}
+)
+
+func (revokedToken0002) TableName() string { return "revoked_tokens" }
+func (serverBan0002) TableName() string    { return "server_bans" }
+func (userServer0002) TableName() string   { return "user_servers" }
+
+var keys0002 = []key0002{
+	{
+		table: "revoked_tokens", index: "uix_revoked_tokens_jti",
+		columns: []string{"jti"}, keep: "expires_at DESC",
+		row: func() interface{} { return &revokedToken0002{} },
+	},
+	{
+		table: "server_bans", index: "uix_server_bans_user_id_server_id",
+		columns: []string{"user_id", "server_id"}, keep: "created_at",
+		row: func() interface{} { return &serverBan0002{} },
+	},
+	{
+		table: "user_servers", index: "uix_user_servers_user_id_server_id",
+		columns: []string{"user_id", "server_id"}, keep: "joined_at",
+		row: func() interface{} { return &userServer0002{} },
+	},
+}
+
+// dropDuplicates leaves one row of each group of rows k's columns do not
+// tell apart. The rows have nothing else to tell them apart by either, so
+// each group is deleted and its first row put back.
+func dropDuplicates(tx *gorm.DB, k key0002) error {
+	columns := strings.Join(k.columns, ", ")
+	rows, err := tx.Raw(fmt.Sprintf("SELECT %s FROM %s GROUP BY %s HAVING COUNT(*) > 1", columns, k.table, columns)).Rows()
+	if err != nil {
+		return err
+	}
+	var groups [][]interface{}
+	for rows.Next() {
+		values := make([]string, len(k.columns))
+		dest := make([]interface{}, len(values))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return err
+		}
+		group := make([]interface{}, len(values))
+		for i, v := range values {
+			group[i] = v
+		}
+		groups = append(groups, group)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	where := strings.Join(k.columns, " = ? AND ") + " = ?"
+	for _, group := range groups {
+		kept := k.row()
+		if err := tx.Where(where, group...).Order(k.keep).First(kept).Error; err != nil {
+			return err
+		}
+		if err := tx.Table(k.table).Where(where, group...).Delete(k.row()).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(kept).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 0003 makes the ULIDs of channels and messages unique. 0001 marked them
+// primaryKey, which gorm v1 ignores, so nothing stopped two rows from
+// sharing one; rows are looked up by ULID, so only the first of them was
+// ever reachable. Duplicates are dropped, keeping the row created first.
+func init() {
+	register(Migration{
+		Version: 3,
+		Name:    "channel_and_message_ids",
+		Up: func(tx *gorm.DB) error {
+			for _, k := range ids0003 {
+				// The derived table keeps MySQL from refusing to read the
+				// table it deletes from.
+				err := tx.Exec(fmt.Sprintf(
+					"DELETE FROM %s WHERE id NOT IN (SELECT id FROM (SELECT MIN(id) AS id FROM %s GROUP BY ul_id) AS kept)",
+					k.table, k.table)).Error
+				if err != nil {
+					return err
+				}
+				if tx.Dialect().HasIndex(k.table, k.index) {
+					continue
+				}
+				if err := tx.Table(k.table).AddUniqueIndex(k.index, "ul_id").Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, k := range ids0003 {
+				if !tx.Dialect().HasIndex(k.table, k.index) {
+					continue
+				}
+				if err := tx.Table(k.table).RemoveIndex(k.index).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
+
+var ids0003 = []struct {
+	table string
+	index string
+}{
+	{"channels", "uix_channels_ul_id"},
+	{"messages", "uix_messages_ul_id"},
+}
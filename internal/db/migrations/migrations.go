@@ -0,0 +1,268 @@
+// Package migrations evolves the database schema through numbered
+// migrations. Each is applied once, in order, and recorded in the
+// schema_migrations table, so every instance of rio agrees on which have
+// run.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Migration is one step in the schema's history. Up makes the change and
+// Down undoes it; both run in a transaction that also records the step.
+// MySQL commits schema changes as soon as they are made, so there a
+// migration failing halfway leaves what it did before the failure; Up and
+// Down should be safe to run again.
+//
+// A migration must not change once released: later model changes get a
+// migration of their own.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+var migrations []Migration
+
+// register adds a migration. Each lives in a file named after its version
+// and registers itself from init.
+func register(m Migration) {
+	for _, other := range migrations {
+		if other.Version == m.Version {
+			panic(fmt.Sprintf("migrations: version %04d registered twice", m.Version))
+		}
+	}
+	migrations = append(migrations, m)
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return a.Version - b.Version
+	})
+}
+
+// schemaMigration records an applied migration.
+type schemaMigration struct {
+	Version   int       `gorm:"primary_key;auto_increment:false"`
+	Name      string    `gorm:"size:255;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// State is a migration as the database sees it.
+type State struct {
+	Version int
+	Name    string
+	// AppliedAt is nil while the migration is pending.
+	AppliedAt *time.Time
+	// Unknown is set for a migration the database has applied that this
+	// build of rio does not have, as after a downgrade.
+	Unknown bool
+}
+
+// Status lists every migration, known or applied, in order.
+func Status(db *gorm.DB) ([]State, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var states []State
+	for _, m := range migrations {
+		s := State{Version: m.Version, Name: m.Name}
+		if a, ok := applied[m.Version]; ok {
+			s.AppliedAt = &a.AppliedAt
+			delete(applied, m.Version)
+		}
+		states = append(states, s)
+	}
+	for _, a := range applied {
+		states = append(states, State{Version: a.Version, Name: a.Name, AppliedAt: &a.AppliedAt, Unknown: true})
+	}
+	slices.SortFunc(states, func(a, b State) int {
+		return a.Version - b.Version
+	})
+	return states, nil
+}
+
+// Up applies the pending migrations up to and including version to, or
+// all of them if to is 0, and returns those it applied.
+func Up(db *gorm.DB, to int) ([]Migration, error) {
+	unlock, err := lock(db)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if err := db.AutoMigrate(&schemaMigration{}).Error; err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range migrations {
+		if to != 0 && m.Version > to {
+			break
+		}
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		ran, err := run(db, m, true)
+		if err != nil {
+			return done, fmt.Errorf("migration %04d %s: %w", m.Version, m.Name, err)
+		}
+		if ran {
+			done = append(done, m)
+		}
+	}
+	return done, nil
+}
+
+// Down rolls back the newest steps applied migrations and returns those it
+// rolled back.
+func Down(db *gorm.DB, steps int) ([]Migration, error) {
+	unlock, err := lock(db)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]int, 0, len(applied))
+	for v := range applied {
+		versions = append(versions, v)
+	}
+	slices.Sort(versions)
+	slices.Reverse(versions)
+
+	var done []Migration
+	for _, v := range versions[:min(steps, len(versions))] {
+		i := slices.IndexFunc(migrations, func(m Migration) bool {
+			return m.Version == v
+		})
+		if i < 0 {
+			return done, fmt.Errorf("migration %04d %s is unknown to this version of rio", v, applied[v].Name)
+		}
+		m := migrations[i]
+		if m.Down == nil {
+			return done, fmt.Errorf("migration %04d %s cannot be rolled back", m.Version, m.Name)
+		}
+
+		ran, err := run(db, m, false)
+		if err != nil {
+			return done, fmt.Errorf("rolling back migration %04d %s: %w", m.Version, m.Name, err)
+		}
+		if ran {
+			done = append(done, m)
+		}
+	}
+	return done, nil
+}
+
+// run applies or rolls back m and records it, reporting whether it did.
+// Whether m is applied is checked again inside the transaction: SQLite
+// takes no advisory lock, but its transactions exclude each other, so an
+// instance that was waiting on another's sees what that one did.
+func run(db *gorm.DB, m Migration, up bool) (bool, error) {
+	ran := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var count int
+		if err := tx.Model(&schemaMigration{}).Where("version = ?", m.Version).Count(&count).Error; err != nil {
+			return err
+		}
+		if (count > 0) == up {
+			return nil
+		}
+
+		if up {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			ran = true
+			return tx.Create(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		}
+		if err := m.Down(tx); err != nil {
+			return err
+		}
+		ran = true
+		return tx.Where("version = ?", m.Version).Delete(&schemaMigration{}).Error
+	})
+	return ran && err == nil, err
+}
+
+func appliedMigrations(db *gorm.DB) (map[int]schemaMigration, error) {
+	applied := map[int]schemaMigration{}
+	if !db.HasTable(&schemaMigration{}) {
+		return applied, nil
+	}
+
+	var rows []schemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+const (
+	lockName = "rio_schema_migrations"
+	// lockKey stands in for lockName on PostgreSQL, whose advisory locks
+	// are numbered; it spells "rio_migr".
+	lockKey int64 = 0x72696f5f6d696772
+)
+
+// lock takes a database-wide advisory lock, so only one instance migrates
+// at a time; the others wait and then find nothing left to do. The lock
+// belongs to a connection, which is held until unlock. SQLite needs none,
+// see run.
+func lock(db *gorm.DB) (unlock func(), err error) {
+	var acquire, release string
+	var arg interface{}
+	switch db.Dialect().GetName() {
+	case "mysql":
+		acquire, release, arg = "SELECT GET_LOCK(?, -1)", "SELECT RELEASE_LOCK(?)", lockName
+	case "postgres":
+		acquire, release, arg = "SELECT true FROM pg_advisory_lock($1)", "SELECT pg_advisory_unlock($1)", lockKey
+	default:
+		return func() {}, nil
+	}
+
+	ctx := context.Background()
+	conn, err := db.DB().Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ok sql.NullBool
+	if err := conn.QueryRowContext(ctx, acquire, arg).Scan(&ok); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("cannot lock the schema for migration: %w", err)
+	}
+	if !ok.Bool {
+		conn.Close()
+		return nil, errors.New("cannot lock the schema for migration")
+	}
+
+	return func() {
+		var released sql.NullBool
+		conn.QueryRowContext(ctx, release, arg).Scan(&released)
+		conn.Close()
+	}, nil
+}
@@ -0,0 +1,177 @@
+//go:build cgo
+
+package migrations_test
+
+import (
+	"slices"
+	"testing"
+
+	"rio/internal/db"
+	"rio/internal/db/migrations"
+
+	"github.com/jinzhu/gorm"
+)
+
+func openDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	conn, err := db.Open(db.Config{Driver: db.SQLite, Name: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// uniqueIndexes returns the columns of each unique index of table.
+func uniqueIndexes(t *testing.T, conn *gorm.DB, table string) [][]string {
+	t.Helper()
+	var indexes []struct {
+		Name   string
+		Unique bool
+	}
+	if err := conn.Raw("SELECT name, \"unique\" FROM pragma_index_list(?)", table).Scan(&indexes).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var unique [][]string
+	for _, index := range indexes {
+		if !index.Unique {
+			continue
+		}
+		var columns []string
+		if err := conn.Raw("SELECT name FROM pragma_index_info(?) ORDER BY seqno", index.Name).Pluck("name", &columns).Error; err != nil {
+			t.Fatal(err)
+		}
+		unique = append(unique, columns)
+	}
+	return unique
+}
+
+func TestKeys(t *testing.T) {
+	conn := openDB(t)
+	if _, err := migrations.Up(conn, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// 0001 left these tables without keys, so they may hold duplicates.
+	for _, stmt := range []string{
+		"INSERT INTO revoked_tokens (jti, expires_at) VALUES ('a', '2030-01-01'), ('a', '2031-01-01'), ('b', '2030-01-01')",
+		"INSERT INTO server_bans (user_id, server_id, banned_by, created_at) VALUES ('u', 's', 'x', '2024-01-01'), ('u', 's', 'y', '2025-01-01')",
+		"INSERT INTO user_servers (user_id, server_id, role, joined_at) VALUES ('u', 's', 'owner', '2024-01-01'), ('u', 's', 'member', '2025-01-01'), ('v', 's', 'member', '2024-01-01')",
+	} {
+		if err := conn.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := migrations.Up(conn, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []struct {
+		table   string
+		columns []string
+		rows    int
+	}{
+		{"revoked_tokens", []string{"jti"}, 2},
+		{"server_bans", []string{"user_id", "server_id"}, 1},
+		{"user_servers", []string{"user_id", "server_id"}, 2},
+	}
+	for _, k := range keys {
+		if !slices.ContainsFunc(uniqueIndexes(t, conn, k.table), func(columns []string) bool {
+			return slices.Equal(columns, k.columns)
+		}) {
+			t.Errorf("%s has no unique index on %v", k.table, k.columns)
+		}
+
+		var rows int
+		if err := conn.Table(k.table).Count(&rows).Error; err != nil {
+			t.Fatal(err)
+		}
+		if rows != k.rows {
+			t.Errorf("%s has %d rows after dropping duplicates; want %d", k.table, rows, k.rows)
+		}
+	}
+
+	// The first of each group of duplicates is the one kept.
+	var role string
+	if err := conn.Raw("SELECT role FROM user_servers WHERE user_id = 'u'").Row().Scan(&role); err != nil {
+		t.Fatal(err)
+	}
+	if role != "owner" {
+		t.Errorf("kept the membership with role %q; want the earliest, owner", role)
+	}
+	var expires string
+	if err := conn.Raw("SELECT expires_at FROM revoked_tokens WHERE jti = 'a'").Row().Scan(&expires); err != nil {
+		t.Fatal(err)
+	}
+	if expires[:4] != "2031" {
+		t.Errorf("kept the revocation expiring at %s; want the latest", expires)
+	}
+
+	err := conn.Exec("INSERT INTO user_servers (user_id, server_id, role) VALUES ('v', 's', 'member')").Error
+	if !db.IsUniqueViolation(err) {
+		t.Errorf("inserting a membership twice: %v; want a unique violation", err)
+	}
+
+	if _, err := migrations.Down(conn, 1); err != nil {
+		t.Fatal(err)
+	}
+	if indexes := uniqueIndexes(t, conn, "server_bans"); len(indexes) != 0 {
+		t.Errorf("server_bans still has unique indexes %v after rolling 0002 back", indexes)
+	}
+	if _, err := migrations.Up(conn, 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChannelAndMessageIDs(t *testing.T) {
+	conn := openDB(t)
+	if _, err := migrations.Up(conn, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, stmt := range []string{
+		"INSERT INTO channels (ul_id, server_id, name) VALUES ('c', 's', 'first'), ('c', 's', 'second'), ('d', 's', 'other')",
+		"INSERT INTO messages (ul_id, channel_id, content) VALUES ('m', 'c', 'first'), ('m', 'c', 'second')",
+	} {
+		if err := conn.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := migrations.Up(conn, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	for table, rows := range map[string]int{"channels": 2, "messages": 1} {
+		if !slices.ContainsFunc(uniqueIndexes(t, conn, table), func(columns []string) bool {
+			return slices.Equal(columns, []string{"ul_id"})
+		}) {
+			t.Errorf("%s has no unique index on ul_id", table)
+		}
+
+		var count int
+		if err := conn.Table(table).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		if count != rows {
+			t.Errorf("%s has %d rows after dropping duplicates; want %d", table, count, rows)
+		}
+	}
+
+	var name string
+	if err := conn.Raw("SELECT name FROM channels WHERE ul_id = 'c'").Row().Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	if name != "first" {
+		t.Errorf("kept channel %q; want the first one created", name)
+	}
+
+	if _, err := migrations.Down(conn, 2); err != nil {
+		t.Fatal(err)
+	}
+	if indexes := uniqueIndexes(t, conn, "channels"); len(indexes) != 0 {
+		t.Errorf("channels still has unique indexes %v after rolling 0003 back", indexes)
+	}
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// sqliteTimeFormats are the layouts the SQLite driver writes times in.
+var sqliteTimeFormats = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+}
+
+// NullTime scans a time the database computes, such as MAX(created_at).
+// SQLite only returns columns declared as timestamps as times; anything
+// computed from them comes back as the text they are stored as.
+type NullTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+func (t *NullTime) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*t = NullTime{}
+		return nil
+	case time.Time:
+		*t = NullTime{Time: v, Valid: true}
+		return nil
+	case []byte:
+		return t.parse(string(v))
+	case string:
+		return t.parse(v)
+	}
+	return fmt.Errorf("cannot scan %T into a time", value)
+}
+
+func (t *NullTime) parse(s string) error {
+	for _, layout := range sqliteTimeFormats {
+		if parsed, err := time.Parse(layout, s); err == nil {
+			*t = NullTime{Time: parsed, Valid: true}
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot parse %q as a time", s)
+}
+
+// Ptr returns the time, or nil if it is NULL.
+func (t NullTime) Ptr() *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,93 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// Event types published by the services.
+const (
+	MemberJoined      = "member.joined"
+	MemberLeft        = "member.left"
+	MemberRoleChanged = "member.role_changed"
+	MessageCreated    = "message.created"
+	MessageUpdated    = "message.updated"
+	ServerUpdated     = "server.updated"
+)
+
+// Types lists every event type, for validating subscriptions.
+var Types = []string{MemberJoined, MemberLeft, MemberRoleChanged, MessageCreated, MessageUpdated, ServerUpdated}
+
+// Interaction events are sent to a single user's gateway connections rather
+// than published on the bus, so they are not in Types.
+const (
+	InteractionCreated   = "interaction.created"
+	InteractionResponded = "interaction.responded"
+)
+
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	ServerID  string      `json:"server_id"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+type Handler func(Event)
+
+// Bus fans events out to in-process subscribers. Handlers run synchronously
+// on the publishing goroutine, so they must hand slow work off elsewhere.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+func (b *Bus) Subscribe(h Handler) {
+	b.mu.Lock()
+	b.handlers = append(b.handlers, h)
+	b.mu.Unlock()
+}
+
+// New returns an event with a fresh ID, stamped with the current time.
+func New(eventType, serverID string, data interface{}) Event {
+	return Event{
+		ID:        ulid.Make().String(),
+		Type:      eventType,
+		ServerID:  serverID,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+}
+
+func (b *Bus) Publish(eventType, serverID string, data interface{}) {
+	e := New(eventType, serverID, data)
+
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(e)
+	}
+}
+
+// MemberData is the payload of member events.
+type MemberData struct {
+	UserID       string `json:"user_id"`
+	Role         string `json:"role"`
+	PreviousRole string `json:"previous_role,omitempty"`
+	Bot          bool   `json:"bot,omitempty"`
+}
+
+// ServerData is the payload of server.updated.
+type ServerData struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	RequireMFA bool   `json:"require_mfa"`
+}
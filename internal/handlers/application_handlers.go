@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"rio/internal/apperr"
+	"rio/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateApplicationInput struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Public      bool   `json:"public"`
+}
+
+type ApplicationHandler struct {
+	service *service.ApplicationService
+}
+
+func NewApplicationHandler(svc *service.ApplicationService) *ApplicationHandler {
+	return &ApplicationHandler{service: svc}
+}
+
+func (h *ApplicationHandler) CreateApplication(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	var input CreateApplicationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	app, err := h.service.CreateApplication(c.Request.Context(), currentUserID, input.Name, input.Description, input.Public)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, app)
+}
+
+func (h *ApplicationHandler) GetApplications(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	apps, err := h.service.ListApplications(c.Request.Context(), currentUserID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, apps)
+}
+
+func (h *ApplicationHandler) GetApplication(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	app, err := h.service.GetApplication(c.Request.Context(), currentUserID, c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, app)
+}
+
+func (h *ApplicationHandler) ResetToken(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	app, err := h.service.ResetToken(c.Request.Context(), currentUserID, c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, app)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"rio/internal/apperr"
+	"rio/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateChannelEmailInput struct {
+	Name           string   `json:"name" binding:"required"`
+	AllowedSenders []string `json:"allowed_senders"`
+	MaxSize        int      `json:"max_size"`
+}
+
+type ChannelEmailHandler struct {
+	service *service.ChannelEmailService
+}
+
+func NewChannelEmailHandler(svc *service.ChannelEmailService) *ChannelEmailHandler {
+	return &ChannelEmailHandler{service: svc}
+}
+
+func (h *ChannelEmailHandler) CreateChannelEmail(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	var input CreateChannelEmailInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	email, err := h.service.CreateChannelEmail(c.Request.Context(), currentUserID, c.Param("id"), input.Name, input.AllowedSenders, input.MaxSize)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, email)
+}
+
+func (h *ChannelEmailHandler) GetChannelEmails(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	emails, err := h.service.ListChannelEmails(c.Request.Context(), currentUserID, c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, emails)
+}
+
+func (h *ChannelEmailHandler) DeleteChannelEmail(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	if err := h.service.DeleteChannelEmail(c.Request.Context(), currentUserID, c.Param("id"), c.Param("emailId")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
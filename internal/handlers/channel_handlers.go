@@ -2,39 +2,68 @@ package handlers
 
 import (
 	"net/http"
-	"rio/internal/models"
-	"rio/internal/store"
+	"rio/internal/apperr"
+	"rio/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
 
-func CreateChannel(c *gin.Context) {
-	var newChannel models.Channel
-	if err := c.ShouldBindJSON(&newChannel); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+type ChannelHandler struct {
+	service *service.ChannelService
+}
+
+func NewChannelHandler(svc *service.ChannelService) *ChannelHandler {
+	return &ChannelHandler{service: svc}
+}
+
+func (h *ChannelHandler) CreateChannel(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	serverID := c.Param("id")
+	if serverID == "" {
+		c.Error(apperr.Invalid("server ID is required"))
+		return
+	}
+
+	var input struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(bindError(err))
 		return
 	}
 
-	newChannel.ID = store.GetNextChannelId()
+	channel, err := h.service.CreateChannel(c.Request.Context(), currentUserID, serverID, input.Name)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
-	store.Channels = append(store.Channels, newChannel)
-	c.JSON(http.StatusCreated, newChannel)
+	c.JSON(http.StatusCreated, channel)
 }
 
-func GetChannels(c *gin.Context) {
-	serverIDStr := c.Param("server_id")
+func (h *ChannelHandler) GetChannels(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
 
-	var filteredChannels []models.Channel
-	for _, channel := range store.Channels {
-		if channel.ServerID == serverIDStr {
-			filteredChannels = append(filteredChannels, channel)
-		}
+	serverID := c.Param("id")
+	if serverID == "" {
+		c.Error(apperr.Invalid("server ID is required"))
+		return
 	}
 
-	if len(filteredChannels) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "no channels found for this server"})
+	channels, err := h.service.ListChannels(c.Request.Context(), currentUserID, serverID)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, filteredChannels)
+	c.JSON(http.StatusOK, channels)
 }
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"net/http"
+	"rio/internal/apperr"
+	"rio/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CommandHandler struct {
+	service *service.CommandService
+}
+
+func NewCommandHandler(svc *service.CommandService) *CommandHandler {
+	return &CommandHandler{service: svc}
+}
+
+func (h *CommandHandler) GetCommands(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	commands, err := h.service.ListCommands(c.Request.Context(), currentUserID, c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, commands)
+}
+
+func (h *CommandHandler) RegisterCommand(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	var input service.CommandDefinition
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	command, err := h.service.RegisterCommand(c.Request.Context(), currentUserID, c.Param("id"), input)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, command)
+}
+
+func (h *CommandHandler) DeleteCommand(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	if err := h.service.DeleteCommand(c.Request.Context(), currentUserID, c.Param("id"), c.Param("commandId")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// InvokeCommand answers 200 with the command's response, or 202 when the
+// application deferred it.
+func (h *CommandHandler) InvokeCommand(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	var input struct {
+		Name    string                 `json:"name" binding:"required"`
+		Options map[string]interface{} `json:"options"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	resp, err := h.service.Invoke(c.Request.Context(), currentUserID, c.Param("id"), input.Name, input.Options)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if resp.Type == service.InteractionCallbackDeferred {
+		c.JSON(http.StatusAccepted, resp)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *CommandHandler) RespondToInteraction(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	var input service.InteractionCallback
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	resp, err := h.service.Respond(c.Request.Context(), currentUserID, c.Param("id"), &input)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *CommandHandler) SetInteractionsEndpoint(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	var input struct {
+		URL string `json:"url"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	endpoint, err := h.service.SetInteractionsEndpoint(c.Request.Context(), currentUserID, c.Param("id"), input.URL)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, endpoint)
+}
+
+// ClickComponent answers like InvokeCommand: 200 with the application's
+// response, or 202 when it deferred.
+func (h *CommandHandler) ClickComponent(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	var input struct {
+		CustomID string   `json:"custom_id" binding:"required"`
+		Values   []string `json:"values"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	resp, err := h.service.Click(c.Request.Context(), currentUserID, c.Param("id"), input.CustomID, input.Values)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if resp.Type == service.InteractionCallbackDeferred {
+		c.JSON(http.StatusAccepted, resp)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,458 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"rio/internal/apperr"
+	"rio/internal/service"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errBadLimit = apperr.Invalid("limit must be a positive integer")
+
+// DiscordHandler serves the Discord-compatible API. Errors use Discord's
+// {"message", "code"} shape so client libraries raise their usual
+// exceptions.
+type DiscordHandler struct {
+	service *service.DiscordService
+}
+
+func NewDiscordHandler(svc *service.DiscordService) *DiscordHandler {
+	return &DiscordHandler{service: svc}
+}
+
+// discordCodes are the Discord error codes of errors that have one of
+// their own. Every other error gets the code of its kind.
+var discordCodes = []struct {
+	err  error
+	code int
+}{
+	{service.ErrUnknownGuild, 10004},
+	{service.ErrUnknownChannel, 10003},
+	{service.ErrChannelNotFound, 10003},
+	{service.ErrUnknownMessage, 10008},
+	{service.ErrMessageNotFound, 10008},
+	{service.ErrUnknownUser, 10013},
+	{service.ErrUnknownMember, 10007},
+	{service.ErrUnknownRole, 10011},
+	{service.ErrNotMember, 50001},
+	{service.ErrEmptyMessage, 50006},
+}
+
+// discordErrorStatus maps an error to an HTTP status and Discord's JSON
+// error code. The kind of the error decides the status.
+func discordErrorStatus(err error) (int, int) {
+	kind := apperr.KindOf(err)
+	for _, c := range discordCodes {
+		if errors.Is(err, c.err) {
+			return kind.Status(), c.code
+		}
+	}
+
+	switch kind {
+	case apperr.KindForbidden:
+		return kind.Status(), 50013
+	case apperr.KindInvalid:
+		return kind.Status(), 50035
+	default:
+		return kind.Status(), 0
+	}
+}
+
+func discordError(c *gin.Context, err error) {
+	status, code := discordErrorStatus(err)
+	if status == http.StatusInternalServerError {
+		log.Printf("discord compat: %s %s: %v", c.Request.Method, c.FullPath(), err)
+		c.JSON(status, gin.H{"message": "500: Internal Server Error", "code": code})
+		return
+	}
+	c.JSON(status, gin.H{"message": err.Error(), "code": code})
+}
+
+func discordUnauthorized(c *gin.Context) {
+	c.JSON(http.StatusUnauthorized, gin.H{"message": "401: Unauthorized", "code": 0})
+}
+
+// DiscordErrorMiddleware answers the errors the middlewares of the
+// Discord-compatible API attach with c.Error, such as failed
+// authentication or an exceeded rate limit, in Discord's shape rather than
+// ErrorResponse's. Register it first on the group.
+func DiscordErrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		err := c.Errors.Last().Err
+		switch e := apperr.As(err); {
+		case e != nil && e.Kind == apperr.KindUnauthorized:
+			discordUnauthorized(c)
+		case e != nil && e.RetryAfter > 0:
+			c.Header("Retry-After", strconv.Itoa(e.RetryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{"message": "You are being rate limited.", "code": 0, "retry_after": e.RetryAfter, "global": false})
+		default:
+			discordError(c, err)
+		}
+	}
+}
+
+func (h *DiscordHandler) GetCurrentUser(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		discordUnauthorized(c)
+		return
+	}
+
+	user, err := h.service.CurrentUser(c.Request.Context(), currentUserID)
+	if err != nil {
+		discordError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *DiscordHandler) GetUser(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		discordUnauthorized(c)
+		return
+	}
+
+	if c.Param("userId") == "@me" {
+		h.GetCurrentUser(c)
+		return
+	}
+
+	user, err := h.service.GetUser(c.Request.Context(), c.Param("userId"))
+	if err != nil {
+		discordError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *DiscordHandler) GetGuild(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		discordUnauthorized(c)
+		return
+	}
+
+	guild, err := h.service.GetGuild(c.Request.Context(), currentUserID, c.Param("id"))
+	if err != nil {
+		discordError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, guild)
+}
+
+func (h *DiscordHandler) GetGuildRoles(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		discordUnauthorized(c)
+		return
+	}
+
+	roles, err := h.service.GetRoles(c.Request.Context(), currentUserID, c.Param("id"))
+	if err != nil {
+		discordError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+func (h *DiscordHandler) GetGuildChannels(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		discordUnauthorized(c)
+		return
+	}
+
+	channels, err := h.service.GetGuildChannels(c.Request.Context(), currentUserID, c.Param("id"))
+	if err != nil {
+		discordError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, channels)
+}
+
+func (h *DiscordHandler) GetChannel(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		discordUnauthorized(c)
+		return
+	}
+
+	channel, err := h.service.GetChannel(c.Request.Context(), currentUserID, c.Param("id"))
+	if err != nil {
+		discordError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, channel)
+}
+
+func (h *DiscordHandler) GetMessages(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		discordUnauthorized(c)
+		return
+	}
+
+	limit := 50
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			discordError(c, errBadLimit)
+			return
+		}
+		limit = n
+	}
+
+	messages, err := h.service.GetMessages(c.Request.Context(), currentUserID, c.Param("id"), c.Query("before"), limit)
+	if err != nil {
+		discordError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, messages)
+}
+
+func (h *DiscordHandler) GetMessage(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		discordUnauthorized(c)
+		return
+	}
+
+	message, err := h.service.GetMessage(c.Request.Context(), currentUserID, c.Param("id"), c.Param("messageId"))
+	if err != nil {
+		discordError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, message)
+}
+
+// discordMessageInput is the part of Discord's message body rio supports.
+// Libraries may send it as JSON or, when uploading files, as the
+// payload_json field of a multipart form; files are ignored.
+type discordMessageInput struct {
+	Content string `json:"content"`
+}
+
+func bindDiscordMessage(c *gin.Context, input *discordMessageInput) error {
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		return json.Unmarshal([]byte(c.PostForm("payload_json")), input)
+	}
+	return c.ShouldBindJSON(input)
+}
+
+func (h *DiscordHandler) CreateMessage(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		discordUnauthorized(c)
+		return
+	}
+
+	var input discordMessageInput
+	if err := bindDiscordMessage(c, &input); err != nil {
+		discordError(c, err)
+		return
+	}
+
+	message, err := h.service.CreateMessage(c.Request.Context(), currentUserID, c.Param("id"), input.Content)
+	if err != nil {
+		discordError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, message)
+}
+
+func (h *DiscordHandler) EditMessage(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		discordUnauthorized(c)
+		return
+	}
+
+	var input discordMessageInput
+	if err := bindDiscordMessage(c, &input); err != nil {
+		discordError(c, err)
+		return
+	}
+
+	message, err := h.service.EditMessage(c.Request.Context(), currentUserID, c.Param("id"), c.Param("messageId"), input.Content)
+	if err != nil {
+		discordError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, message)
+}
+
+func (h *DiscordHandler) GetMember(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		discordUnauthorized(c)
+		return
+	}
+
+	member, err := h.service.GetMember(c.Request.Context(), currentUserID, c.Param("id"), c.Param("userId"))
+	if err != nil {
+		discordError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+func (h *DiscordHandler) ListMembers(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		discordUnauthorized(c)
+		return
+	}
+
+	limit := 1
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			discordError(c, errBadLimit)
+			return
+		}
+		limit = n
+	}
+
+	members, err := h.service.ListMembers(c.Request.Context(), currentUserID, c.Param("id"), c.Query("after"), limit)
+	if err != nil {
+		discordError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// AddMember answers 201 with the member when the user was added and 204
+// when they were a member already, as Discord does. Discord requires an
+// OAuth2 access token for the user, which rio has no use for and ignores.
+func (h *DiscordHandler) AddMember(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		discordUnauthorized(c)
+		return
+	}
+
+	var input struct {
+		Roles []string `json:"roles"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && c.Request.ContentLength != 0 {
+		discordError(c, err)
+		return
+	}
+
+	member, added, err := h.service.AddMember(c.Request.Context(), currentUserID, c.Param("id"), c.Param("userId"), input.Roles)
+	if err != nil {
+		discordError(c, err)
+		return
+	}
+	if !added {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	c.JSON(http.StatusCreated, member)
+}
+
+func (h *DiscordHandler) RemoveMember(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		discordUnauthorized(c)
+		return
+	}
+
+	if err := h.service.RemoveMember(c.Request.Context(), currentUserID, c.Param("id"), c.Param("userId")); err != nil {
+		discordError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ModifyMember supports the roles and communication_disabled_until fields
+// of Discord's modify guild member body; a null timeout lifts it.
+func (h *DiscordHandler) ModifyMember(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		discordUnauthorized(c)
+		return
+	}
+
+	var input struct {
+		Roles                      []string        `json:"roles"`
+		CommunicationDisabledUntil json.RawMessage `json:"communication_disabled_until"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		discordError(c, err)
+		return
+	}
+
+	var timeoutUntil *time.Time
+	switch raw := input.CommunicationDisabledUntil; {
+	case raw == nil:
+	case bytes.Equal(raw, []byte("null")):
+		timeoutUntil = &time.Time{}
+	default:
+		var t time.Time
+		if err := json.Unmarshal(raw, &t); err != nil {
+			discordError(c, err)
+			return
+		}
+		timeoutUntil = &t
+	}
+
+	member, err := h.service.ModifyMember(c.Request.Context(), currentUserID, c.Param("id"), c.Param("userId"), input.Roles, timeoutUntil)
+	if err != nil {
+		discordError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+func (h *DiscordHandler) AddMemberRole(c *gin.Context) {
+	h.setMemberRole(c, true)
+}
+
+func (h *DiscordHandler) RemoveMemberRole(c *gin.Context) {
+	h.setMemberRole(c, false)
+}
+
+func (h *DiscordHandler) setMemberRole(c *gin.Context, add bool) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		discordUnauthorized(c)
+		return
+	}
+
+	if err := h.service.SetMemberRole(c.Request.Context(), currentUserID, c.Param("id"), c.Param("userId"), c.Param("roleId"), add); err != nil {
+		discordError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/ratelimit"
+	"rio/internal/service"
+	"rio/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDiscordErrorStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   int
+	}{
+		{service.ErrUnknownGuild, http.StatusNotFound, 10004},
+		{service.ErrChannelNotFound, http.StatusNotFound, 10003},
+		{fmt.Errorf("loading message: %w", service.ErrMessageNotFound), http.StatusNotFound, 10008},
+		{service.ErrUnknownMember, http.StatusNotFound, 10007},
+		{service.ErrNotMember, http.StatusForbidden, 50001},
+		{service.ErrEmptyMessage, http.StatusBadRequest, 50006},
+		// Errors without a code of their own get their kind's, whatever
+		// their message says.
+		{apperr.NotFound("member channel message not found"), http.StatusNotFound, 0},
+		{apperr.Forbidden("you are not a member of this club"), http.StatusForbidden, 50013},
+		{apperr.Invalid("empty message"), http.StatusBadRequest, 50035},
+		{errors.New("database is down"), http.StatusInternalServerError, 0},
+	}
+	for _, tt := range tests {
+		status, code := discordErrorStatus(tt.err)
+		if status != tt.status || code != tt.code {
+			t.Errorf("%q: got %d/%d, want %d/%d", tt.err, status, code, tt.status, tt.code)
+		}
+	}
+}
+
+type noAuth struct{}
+
+func (noAuth) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) { return false, nil }
+func (noAuth) CheckSession(ctx context.Context, sessionID string) error           { return nil }
+func (noAuth) AuthenticateBot(ctx context.Context, apiToken string) (string, error) {
+	return "bot", nil
+}
+
+func TestDiscordErrorMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middlewares.ErrorMiddleware())
+	compat := router.Group("/api/compat/v10", DiscordErrorMiddleware(), middlewares.JwtAuthMiddleware(noAuth{}, noAuth{}, noAuth{}))
+	compat.Use(middlewares.RateLimitMiddleware(ratelimit.NewMemoryStore(), "test", ratelimit.Limit{Requests: 1, Per: time.Minute}))
+	compat.GET("/users/@me", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	get := func(authorization string) (*httptest.ResponseRecorder, map[string]any) {
+		req := httptest.NewRequest(http.MethodGet, "/api/compat/v10/users/@me", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var body map[string]any
+		if w.Code != http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("%d %s: %v", w.Code, w.Body, err)
+			}
+		}
+		return w, body
+	}
+
+	w, body := get("")
+	if w.Code != http.StatusUnauthorized || body["message"] != "401: Unauthorized" || body["code"] != float64(0) {
+		t.Errorf("without a token: %d %v; want 401 in Discord's shape", w.Code, body)
+	}
+
+	if w, _ := get("Bot token"); w.Code != http.StatusOK {
+		t.Fatalf("first request: %d; want 200", w.Code)
+	}
+	w, body = get("Bot token")
+	if w.Code != http.StatusTooManyRequests || body["message"] == nil || body["code"] != float64(0) || body["retry_after"] == nil {
+		t.Errorf("over the limit: %d %v; want 429 in Discord's shape", w.Code, body)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("over the limit: no Retry-After header")
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"rio/internal/apperr"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Name fields in validation errors as clients spell them: by their JSON
+// key rather than the Go field name.
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return f.Name
+			}
+			return name
+		})
+	}
+}
+
+// bindError turns an error binding a request body into a validation error
+// listing the fields at fault.
+func bindError(err error) error {
+	var invalid validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &invalid):
+		e := apperr.Invalid("invalid request body")
+		for _, fe := range invalid {
+			e.Fields = append(e.Fields, apperr.FieldError{Field: fe.Field(), Message: fieldMessage(fe)})
+		}
+		return e
+	case errors.As(err, &typeErr):
+		e := apperr.Invalid("invalid request body")
+		e.Fields = []apperr.FieldError{{Field: typeErr.Field, Message: "must be a " + typeErr.Type.String()}}
+		return e
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return apperr.Invalid("request body is not valid JSON")
+	case errors.Is(err, io.EOF):
+		return apperr.Invalid("request body is required")
+	default:
+		return apperr.Invalid("%s", err)
+	}
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	default:
+		return "is invalid"
+	}
+}
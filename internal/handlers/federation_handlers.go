@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"net/http"
+	"rio/internal/apperr"
+	"rio/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JoinRemoteServerInput struct {
+	Instance string `json:"instance" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type FederationHandler struct {
+	service *service.FederationService
+}
+
+func NewFederationHandler(svc *service.FederationService) *FederationHandler {
+	return &FederationHandler{service: svc}
+}
+
+// parseLimit reads the optional limit query parameter of message listings.
+func parseLimit(c *gin.Context) (int, bool) {
+	raw := c.Query("limit")
+	if raw == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		c.Error(apperr.Invalid("limit must be a positive integer"))
+		return 0, false
+	}
+	return n, true
+}
+
+// Document serves this instance's discovery document: its name and the
+// public key other instances verify its requests with.
+func (h *FederationHandler) Document(c *gin.Context) {
+	doc, err := h.service.Document()
+	if err != nil {
+		c.Error(apperr.NotFound("%w", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, doc)
+}
+
+// The handlers below serve other instances. FederationAuthMiddleware has
+// verified the request's signature and set the calling instance and the
+// user there it acts for.
+
+func (h *FederationHandler) ServeJoin(c *gin.Context) {
+	origin := c.GetString("federation_origin")
+	if origin == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	var input struct {
+		Username string `json:"username" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	server, err := h.service.ServeJoin(c.Request.Context(), origin, c.GetString("federation_user"), input.Username, c.Param("code"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, server)
+}
+
+func (h *FederationHandler) ServeLeave(c *gin.Context) {
+	origin := c.GetString("federation_origin")
+	if origin == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	if err := h.service.ServeLeave(c.Request.Context(), origin, c.GetString("federation_user"), c.Param("id")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *FederationHandler) ServeChannels(c *gin.Context) {
+	origin := c.GetString("federation_origin")
+	if origin == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	channels, err := h.service.ServeChannels(c.Request.Context(), origin, c.GetString("federation_user"), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, channels)
+}
+
+func (h *FederationHandler) ServeMessages(c *gin.Context) {
+	origin := c.GetString("federation_origin")
+	if origin == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+
+	messages, err := h.service.ServeMessages(c.Request.Context(), origin, c.GetString("federation_user"), c.Param("id"), c.Query("before"), limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, messages)
+}
+
+func (h *FederationHandler) ServeSendMessage(c *gin.Context) {
+	origin := c.GetString("federation_origin")
+	if origin == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	var input struct {
+		Content string `json:"content" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	message, err := h.service.ServeSendMessage(c.Request.Context(), origin, c.GetString("federation_user"), c.Param("id"), input.Content)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, message)
+}
+
+// ReceiveEvent takes an event of a server hosted by the calling instance.
+func (h *FederationHandler) ReceiveEvent(c *gin.Context) {
+	origin := c.GetString("federation_origin")
+	if origin == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	var input service.RelayedEvent
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	if err := h.service.ReceiveEvent(c.Request.Context(), origin, &input); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// The handlers below let local users use servers hosted on other
+// instances.
+
+func (h *FederationHandler) JoinRemoteServer(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	var input JoinRemoteServerInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	server, err := h.service.JoinRemoteServer(c.Request.Context(), currentUserID, input.Instance, input.Code)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, server)
+}
+
+func (h *FederationHandler) LeaveRemoteServer(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	if err := h.service.LeaveRemoteServer(c.Request.Context(), currentUserID, c.Param("id")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *FederationHandler) GetRemoteChannels(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	channels, err := h.service.ListRemoteChannels(c.Request.Context(), currentUserID, c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, channels)
+}
+
+func (h *FederationHandler) GetRemoteMessages(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+
+	messages, err := h.service.GetRemoteMessages(c.Request.Context(), currentUserID, c.Param("id"), c.Param("channelId"), c.Query("before"), limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, messages)
+}
+
+func (h *FederationHandler) SendRemoteMessage(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	var input struct {
+		Content string `json:"content" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	message, err := h.service.SendRemoteMessage(c.Request.Context(), currentUserID, c.Param("id"), c.Param("channelId"), input.Content)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, message)
+}
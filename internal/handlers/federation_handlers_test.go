@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/events"
+	"rio/internal/models"
+	appRepo "rio/internal/repository/application"
+	attachmentRepo "rio/internal/repository/attachment"
+	channelRepo "rio/internal/repository/channel"
+	inviteRepo "rio/internal/repository/invite"
+	messageRepo "rio/internal/repository/message"
+	serverRepo "rio/internal/repository/server"
+	unitOfWork "rio/internal/repository/unitofwork"
+	userRepo "rio/internal/repository/user"
+	"rio/internal/service"
+	"rio/internal/store"
+	"rio/middlewares"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oklog/ulid/v2"
+)
+
+// instance is a rio instance serving the federation API on localhost.
+type instance struct {
+	name       string
+	users      userRepo.UserRepository
+	serverRepo serverRepo.ServerRepository
+	servers    *service.ServerService
+	channels   *service.ChannelService
+	messages   *service.MessageService
+	federation *service.FederationService
+	events     chan events.Event
+}
+
+func startInstance(t *testing.T) *instance {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(nil)
+	t.Cleanup(srv.Close)
+
+	s := store.New()
+	bus := events.NewBus()
+	uow := unitOfWork.NewInMemoryUnitOfWork(s)
+	channels := channelRepo.NewInMemoryChannelRepository(s)
+	i := &instance{
+		name:       srv.Listener.Addr().String(),
+		users:      userRepo.NewInMemoryUserRepository(s),
+		serverRepo: serverRepo.NewInMemoryServerRepository(s),
+		events:     make(chan events.Event, 64),
+	}
+	i.servers = service.NewServerService(i.serverRepo, i.users, appRepo.NewInMemoryApplicationRepository(s), inviteRepo.NewInMemoryInviteRepository(s), uow, bus)
+	i.channels = service.NewChannelService(channels, i.serverRepo)
+	i.messages = service.NewMessageService(messageRepo.NewInMemoryMessageRepository(s), channels, i.serverRepo, i.users, attachmentRepo.NewInMemoryAttachmentRepository(s), uow, bus)
+
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.federation = service.NewFederationService(i.name, key, nil, true, i.users, i.serverRepo, uow, i.servers, i.channels, i.messages, bus)
+	bus.Subscribe(func(e events.Event) {
+		select {
+		case i.events <- e:
+		default:
+		}
+	})
+
+	h := NewFederationHandler(i.federation)
+	router := gin.New()
+	router.Use(middlewares.ErrorMiddleware())
+	router.GET(service.FederationDocumentPath, h.Document)
+	api := router.Group(service.FederationAPIPrefix, middlewares.FederationAuthMiddleware(i.federation, service.MaxFederationBody))
+	api.POST("/invites/:code/join", h.ServeJoin)
+	api.POST("/servers/:id/leave", h.ServeLeave)
+	api.GET("/servers/:id/channels", h.ServeChannels)
+	api.GET("/channels/:id/messages", h.ServeMessages)
+	api.POST("/channels/:id/messages", h.ServeSendMessage)
+	api.POST("/events", h.ReceiveEvent)
+
+	srv.Config.Handler = router
+	srv.Start()
+	return i
+}
+
+func (i *instance) createUser(t *testing.T, username string) *models.User {
+	t.Helper()
+	user := &models.User{ULID: ulid.Make().String(), Username: username, Password: "unused"}
+	if err := i.users.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// next waits for an event of type eventType that match accepts.
+func (i *instance) next(t *testing.T, eventType string, match func(events.Event) bool) events.Event {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-i.events:
+			if e.Type == eventType && match(e) {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("no %s event reached %s", eventType, i.name)
+		}
+	}
+}
+
+func TestFederation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	host, member := startInstance(t), startInstance(t)
+
+	owner := host.createUser(t, "owner")
+	alice := member.createUser(t, "alice")
+
+	server, err := host.servers.CreateServer(ctx, owner.ULID, "Hall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	channel, err := host.channels.CreateChannel(ctx, owner.ULID, server.ULID, "general")
+	if err != nil {
+		t.Fatal(err)
+	}
+	invite, err := host.servers.CreateInvite(ctx, owner.ULID, server.ULID, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mirror, err := member.federation.JoinRemoteServer(ctx, alice.ULID, host.name, invite.Code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mirror.ULID != server.ULID || mirror.Instance != host.name || mirror.Name != "Hall" {
+		t.Fatalf("mirror = %+v; want %s hosted on %s", mirror, server.ULID, host.name)
+	}
+
+	message := func(content string) func(events.Event) bool {
+		return func(e events.Event) bool {
+			m, ok := e.Data.(*models.Message)
+			return ok && m.Content == content
+		}
+	}
+
+	t.Run("messages are shown under remote identities", func(t *testing.T) {
+		sent, err := member.federation.SendRemoteMessage(ctx, alice.ULID, server.ULID, channel.ULID, "hello")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sent.UserID != "" || sent.DisplayName != "alice@"+member.name {
+			t.Fatalf("sent message by %q as %q; want no user ID, as alice@%s", sent.UserID, sent.DisplayName, member.name)
+		}
+
+		if _, err := host.messages.SendMessage(ctx, owner.ULID, channel.ULID, "hi alice", nil); err != nil {
+			t.Fatal(err)
+		}
+		relayed := member.next(t, events.MessageCreated, message("hi alice")).Data.(*models.Message)
+		if relayed.UserID != "" || relayed.DisplayName != "owner@"+host.name {
+			t.Fatalf("relayed message by %q as %q; want no user ID, as owner@%s", relayed.UserID, relayed.DisplayName, host.name)
+		}
+
+		listed, err := member.federation.GetRemoteMessages(ctx, alice.ULID, server.ULID, channel.ULID, "", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range listed {
+			if m.UserID != "" {
+				t.Errorf("listed message %q by user %s; want no user ID", m.Content, m.UserID)
+			}
+		}
+	})
+
+	t.Run("a relayed message cannot claim a local user", func(t *testing.T) {
+		data, err := json.Marshal(&models.Message{ULID: ulid.Make().String(), ChannelID: channel.ULID, UserID: alice.ULID, Content: "spoofed"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = member.federation.ReceiveEvent(ctx, host.name, &service.RelayedEvent{Type: events.MessageCreated, ServerID: server.ULID, Data: data})
+		if err != nil {
+			t.Fatal(err)
+		}
+		spoofed := member.next(t, events.MessageCreated, message("spoofed")).Data.(*models.Message)
+		if spoofed.UserID != "" || spoofed.DisplayName != "unknown@"+host.name {
+			t.Fatalf("spoofed message by %q as %q; want no user ID, as unknown@%s", spoofed.UserID, spoofed.DisplayName, host.name)
+		}
+	})
+
+	t.Run("server names are escaped", func(t *testing.T) {
+		if err := host.servers.UpdateServerName(ctx, owner.ULID, server.ULID, "<b>Hall</b> & co"); err != nil {
+			t.Fatal(err)
+		}
+		const escaped = "&lt;b&gt;Hall&lt;/b&gt; &amp; co"
+		member.next(t, events.ServerUpdated, func(e events.Event) bool {
+			return e.Data.(events.ServerData).Name == escaped
+		})
+		if mirror, err := member.serverRepo.GetServerByID(ctx, server.ULID); err != nil || mirror.Name != escaped {
+			t.Fatalf("mirror after a rename = %+v, %v; want it named %s", mirror, err, escaped)
+		}
+
+		relay := func(name string) error {
+			data, err := json.Marshal(events.ServerData{ID: server.ULID, Name: name})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return member.federation.ReceiveEvent(ctx, host.name, &service.RelayedEvent{Type: events.ServerUpdated, ServerID: server.ULID, Data: data})
+		}
+		if err := relay("<script>alert(1)</script>"); err != nil {
+			t.Fatal(err)
+		}
+		if mirror, err := member.serverRepo.GetServerByID(ctx, server.ULID); err != nil || mirror.Name != "&lt;script&gt;alert(1)&lt;/script&gt;" {
+			t.Fatalf("mirror after an unescaped rename = %+v, %v; want the name escaped", mirror, err)
+		}
+		if err := relay("ab"); !errors.Is(err, apperr.ErrInvalid) {
+			t.Fatalf("relaying a name that is too short: %v; want it refused as invalid", err)
+		}
+	})
+}
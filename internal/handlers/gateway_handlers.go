@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"rio/internal/apperr"
+	"rio/internal/service"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const gatewayHeartbeat = 30 * time.Second
+
+type GatewayHandler struct {
+	service *service.GatewayService
+}
+
+func NewGatewayHandler(svc *service.GatewayService) *GatewayHandler {
+	return &GatewayHandler{service: svc}
+}
+
+// Connect streams the caller's events as server-sent events until the
+// client disconnects. Each SSE event is named after the event type; a
+// heartbeat event is sent when the stream is otherwise idle.
+func (h *GatewayHandler) Connect(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	conn := h.service.Connect(currentUserID)
+	defer h.service.Disconnect(conn)
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("ready", gin.H{"user_id": currentUserID})
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(gatewayHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case e, ok := <-conn.Events:
+			if !ok {
+				return
+			}
+			c.SSEvent(e.Type, e)
+		case <-heartbeat.C:
+			c.SSEvent("heartbeat", gin.H{"time": time.Now().UTC()})
+		case <-c.Request.Context().Done():
+			return
+		}
+		c.Writer.Flush()
+	}
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"rio/internal/apperr"
+	"rio/internal/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxIncomingWebhookBody = 64 << 10
+
+type CreateIncomingWebhookInput struct {
+	Name      string `json:"name" binding:"required"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+type IncomingWebhookHandler struct {
+	service *service.IncomingWebhookService
+}
+
+func NewIncomingWebhookHandler(svc *service.IncomingWebhookService) *IncomingWebhookHandler {
+	return &IncomingWebhookHandler{service: svc}
+}
+
+func (h *IncomingWebhookHandler) CreateIncomingWebhook(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	var input CreateIncomingWebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	hook, err := h.service.CreateIncomingWebhook(c.Request.Context(), currentUserID, c.Param("id"), input.Name, input.AvatarURL)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, hook)
+}
+
+func (h *IncomingWebhookHandler) GetIncomingWebhooks(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	hooks, err := h.service.ListIncomingWebhooks(c.Request.Context(), currentUserID, c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, hooks)
+}
+
+func (h *IncomingWebhookHandler) DeleteIncomingWebhook(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	if err := h.service.DeleteIncomingWebhook(c.Request.Context(), currentUserID, c.Param("id"), c.Param("webhookId")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Execute posts a message through an incoming webhook. It needs no JWT: the
+// secret in the URL is the credential. Slack-shaped calls get Slack's
+// plain-text responses ("ok", "no_text", ...) so existing integrations
+// behave as they would against Slack.
+func (h *IncomingWebhookHandler) Execute(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxIncomingWebhookBody)
+
+	var payload service.IncomingWebhookPayload
+	var err error
+	if strings.HasPrefix(c.ContentType(), "application/x-www-form-urlencoded") {
+		// Slack also accepts the JSON as a form field named payload.
+		err = json.Unmarshal([]byte(c.PostForm("payload")), &payload)
+	} else {
+		err = c.ShouldBindJSON(&payload)
+	}
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid_payload")
+		return
+	}
+
+	message, err := h.service.Execute(c.Request.Context(), c.Param("id"), c.Param("token"), &payload)
+	if err != nil {
+		// Slack answers with a bare error code, and with no_text whatever
+		// the payload's shape.
+		if !payload.IsSlack() && !errors.Is(err, service.ErrEmptyMessage) {
+			c.Error(err)
+			return
+		}
+
+		status := http.StatusBadRequest
+		code := "invalid_payload"
+		switch {
+		case errors.Is(err, apperr.ErrNotFound):
+			status, code = http.StatusNotFound, "no_service"
+		case errors.Is(err, service.ErrEmptyMessage):
+			code = "no_text"
+		}
+		c.String(status, code)
+		return
+	}
+
+	if payload.IsSlack() {
+		c.String(http.StatusOK, "ok")
+		return
+	}
+	c.JSON(http.StatusCreated, message)
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+	"rio/utils/token"
+
+	"github.com/gin-gonic/gin"
+)
+
+func JWKS(c *gin.Context) {
+	jwks, err := token.PublicJWKS()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": jwks})
+}
@@ -1,53 +1,118 @@
 package handlers
 
 import (
+	"mime"
 	"net/http"
+	"rio/internal/apperr"
 	"rio/internal/models"
-	"rio/internal/store"
+	"rio/internal/service"
 	"strconv"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SendMessage(c *gin.Context) {
-	channel_id, err := strconv.Atoi(c.Param("channel_id"))
-	if err != nil || channel_id <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "channel id must be a positive integer"})
-	}
+type MessageHandler struct {
+	service *service.MessageService
+}
+
+func NewMessageHandler(svc *service.MessageService) *MessageHandler {
+	return &MessageHandler{service: svc}
+}
 
-	var newMessage models.Message
-	if err := c.ShouldBindJSON(&newMessage); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+func (h *MessageHandler) SendMessage(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
 		return
 	}
 
-	newMessage.ChannelID = strconv.Itoa(channel_id)
+	var input struct {
+		Content    string             `json:"content" binding:"required"`
+		Components []models.ActionRow `json:"components"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(bindError(err))
+		return
+	}
 
-	if strings.TrimSpace(newMessage.Content) == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "message content must not be empty"})
+	message, err := h.service.SendMessage(c.Request.Context(), currentUserID, c.Param("id"), input.Content, input.Components)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	newMessage.ID = store.GetNextMessageId()
-	store.Messages = append(store.Messages, newMessage)
-	c.JSON(http.StatusCreated, newMessage)
+	c.JSON(http.StatusCreated, message)
 }
 
-func GetMessages(c *gin.Context) {
-	channel_id := c.Param("channel_id")
+func (h *MessageHandler) GetMessages(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
 
-	var filteredMessages []models.Message
-	for _, message := range store.Messages {
-		if message.ChannelID == channel_id {
-			filteredMessages = append(filteredMessages, message)
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.Error(apperr.Invalid("limit must be a positive integer"))
+			return
 		}
+		limit = n
+	}
+
+	messages, err := h.service.GetMessages(c.Request.Context(), currentUserID, c.Param("id"), c.Query("before"), limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, messages)
+}
+
+func (h *MessageHandler) EditMessage(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	var input struct {
+		Content    string             `json:"content"`
+		Components []models.ActionRow `json:"components"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	message, err := h.service.EditMessage(c.Request.Context(), currentUserID, c.Param("id"), input.Content, input.Components)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, message)
+}
+
+// GetAttachment downloads an attachment. It is always served as a download
+// with its declared type, so uploaded HTML or SVG never renders in the
+// API's origin.
+func (h *MessageHandler) GetAttachment(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
 	}
 
-	if len(filteredMessages) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "no messages in that channel"})
+	attachment, err := h.service.GetAttachment(c.Request.Context(), currentUserID, c.Param("id"))
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, filteredMessages)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "sandbox")
+	c.Data(http.StatusOK, attachment.ContentType, attachment.Data)
 }
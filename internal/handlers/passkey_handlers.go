@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"net/http"
+	"rio/internal/apperr"
+	"rio/internal/service"
+	"rio/utils/webauthn"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FinishPasskeyRegistrationInput struct {
+	CeremonyID string                        `json:"ceremony_id" binding:"required"`
+	Name       string                        `json:"name"`
+	Credential webauthn.RegistrationResponse `json:"credential"`
+}
+
+type FinishPasskeyLoginInput struct {
+	CeremonyID string                     `json:"ceremony_id" binding:"required"`
+	Credential webauthn.AssertionResponse `json:"credential"`
+}
+
+type PasskeyHandler struct {
+	service *service.PasskeyService
+}
+
+func NewPasskeyHandler(svc *service.PasskeyService) *PasskeyHandler {
+	return &PasskeyHandler{service: svc}
+}
+
+func (h *PasskeyHandler) BeginRegistration(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	options, err := h.service.BeginRegistration(c.Request.Context(), currentUserID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+func (h *PasskeyHandler) FinishRegistration(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	var input FinishPasskeyRegistrationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	passkey, err := h.service.FinishRegistration(c.Request.Context(), currentUserID, input.CeremonyID, input.Name, input.Credential)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, passkey)
+}
+
+func (h *PasskeyHandler) GetPasskeys(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	passkeys, err := h.service.ListPasskeys(c.Request.Context(), currentUserID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, passkeys)
+}
+
+func (h *PasskeyHandler) RemovePasskey(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	passkeyID := c.Param("id")
+	if passkeyID == "" {
+		c.Error(apperr.Invalid("passkey ID is required"))
+		return
+	}
+
+	if err := h.service.RemovePasskey(c.Request.Context(), currentUserID, passkeyID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *PasskeyHandler) BeginLogin(c *gin.Context) {
+	options, err := h.service.BeginLogin()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+func (h *PasskeyHandler) FinishLogin(c *gin.Context) {
+	var input FinishPasskeyLoginInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	client := service.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+
+	result, err := h.service.FinishLogin(c.Request.Context(), input.CeremonyID, input.Credential, client)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
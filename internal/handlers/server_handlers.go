@@ -2,8 +2,9 @@ package handlers
 
 import (
 	"net/http"
+	"rio/internal/apperr"
 	"rio/internal/service"
-	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,7 +20,7 @@ func NewServerHandler(svc *service.ServerService) *ServerHandler {
 func (h *ServerHandler) CreateServer(c *gin.Context) {
 	currentUserID := c.GetString("user_id")
 	if currentUserID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		c.Error(apperr.Unauthorized("authentication required"))
 		return
 	}
 
@@ -27,13 +28,13 @@ func (h *ServerHandler) CreateServer(c *gin.Context) {
 		Name string `json:"name" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(bindError(err))
 		return
 	}
 
-	server, err := h.service.CreateServer(currentUserID, input.Name)
+	server, err := h.service.CreateServer(c.Request.Context(), currentUserID, input.Name)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -43,13 +44,13 @@ func (h *ServerHandler) CreateServer(c *gin.Context) {
 func (h *ServerHandler) GetServers(c *gin.Context) {
 	currentUserID := c.GetString("user_id")
 	if currentUserID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		c.Error(apperr.Unauthorized("authentication required"))
 		return
 	}
 
-	servers, err := h.service.ListUserServers(currentUserID)
+	servers, err := h.service.ListUserServers(c.Request.Context(), currentUserID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -59,23 +60,19 @@ func (h *ServerHandler) GetServers(c *gin.Context) {
 func (h *ServerHandler) GetServer(c *gin.Context) {
 	currentUserID := c.GetString("user_id")
 	if currentUserID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		c.Error(apperr.Unauthorized("authentication required"))
 		return
 	}
 
 	serverID := c.Param("id")
 	if serverID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "server ID is required"})
+		c.Error(apperr.Invalid("server ID is required"))
 		return
 	}
 
-	server, err := h.service.GetServer(currentUserID, serverID)
+	server, err := h.service.GetServer(c.Request.Context(), currentUserID, serverID)
 	if err != nil {
-		if err.Error() == "user is not a member of server" {
-			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusNotFound, gin.H{"error": "server not found"})
+		c.Error(err)
 		return
 	}
 
@@ -85,13 +82,13 @@ func (h *ServerHandler) GetServer(c *gin.Context) {
 func (h *ServerHandler) UpdateServer(c *gin.Context) {
 	currentUserID := c.GetString("user_id")
 	if currentUserID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		c.Error(apperr.Unauthorized("authentication required"))
 		return
 	}
 
 	serverID := c.Param("id")
 	if serverID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "server ID is required"})
+		c.Error(apperr.Invalid("server ID is required"))
 		return
 	}
 
@@ -99,21 +96,13 @@ func (h *ServerHandler) UpdateServer(c *gin.Context) {
 		Name string `json:"name" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(bindError(err))
 		return
 	}
 
-	err := h.service.UpdateServerName(currentUserID, serverID, input.Name)
+	err := h.service.UpdateServerName(c.Request.Context(), currentUserID, serverID, input.Name)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
-		}
-		if strings.Contains(err.Error(), "permissions") {
-			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -123,23 +112,19 @@ func (h *ServerHandler) UpdateServer(c *gin.Context) {
 func (h *ServerHandler) DeleteServer(c *gin.Context) {
 	currentUserID := c.GetString("user_id")
 	if currentUserID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		c.Error(apperr.Unauthorized("authentication required"))
 		return
 	}
 
 	serverID := c.Param("id")
 	if serverID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "server ID is required"})
+		c.Error(apperr.Invalid("server ID is required"))
 		return
 	}
 
-	err := h.service.DeleteServer(currentUserID, serverID)
+	err := h.service.DeleteServer(c.Request.Context(), currentUserID, serverID)
 	if err != nil {
-		if strings.Contains(err.Error(), "permissions") {
-			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusNotFound, gin.H{"error": "server not found"})
+		c.Error(err)
 		return
 	}
 
@@ -149,13 +134,13 @@ func (h *ServerHandler) DeleteServer(c *gin.Context) {
 func (h *ServerHandler) AddMember(c *gin.Context) {
 	currentUserID := c.GetString("user_id")
 	if currentUserID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		c.Error(apperr.Unauthorized("authentication required"))
 		return
 	}
 
 	serverID := c.Param("id")
 	if serverID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "server ID is required"})
+		c.Error(apperr.Invalid("server ID is required"))
 		return
 	}
 
@@ -164,21 +149,13 @@ func (h *ServerHandler) AddMember(c *gin.Context) {
 		Role   string `json:"role" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(bindError(err))
 		return
 	}
 
-	err := h.service.AddMember(currentUserID, serverID, input.UserID, input.Role)
+	err := h.service.AddMember(c.Request.Context(), currentUserID, serverID, input.UserID, input.Role)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
-		}
-		if strings.Contains(err.Error(), "permissions") || strings.Contains(err.Error(), "insufficient") {
-			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -188,7 +165,7 @@ func (h *ServerHandler) AddMember(c *gin.Context) {
 func (h *ServerHandler) RemoveMember(c *gin.Context) {
 	currentUserID := c.GetString("user_id")
 	if currentUserID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		c.Error(apperr.Unauthorized("authentication required"))
 		return
 	}
 
@@ -196,21 +173,13 @@ func (h *ServerHandler) RemoveMember(c *gin.Context) {
 	targetUserID := c.Param("userId")
 
 	if serverID == "" || targetUserID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "server ID and user ID are required"})
+		c.Error(apperr.Invalid("server ID and user ID are required"))
 		return
 	}
 
-	err := h.service.RemoveMember(currentUserID, serverID, targetUserID)
+	err := h.service.RemoveMember(c.Request.Context(), currentUserID, serverID, targetUserID)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
-		}
-		if strings.Contains(err.Error(), "permissions") || strings.Contains(err.Error(), "insufficient") {
-			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -220,7 +189,7 @@ func (h *ServerHandler) RemoveMember(c *gin.Context) {
 func (h *ServerHandler) ChangeMemberRole(c *gin.Context) {
 	currentUserID := c.GetString("user_id")
 	if currentUserID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		c.Error(apperr.Unauthorized("authentication required"))
 		return
 	}
 
@@ -228,7 +197,7 @@ func (h *ServerHandler) ChangeMemberRole(c *gin.Context) {
 	targetUserID := c.Param("userId")
 
 	if serverID == "" || targetUserID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "server ID and user ID are required"})
+		c.Error(apperr.Invalid("server ID and user ID are required"))
 		return
 	}
 
@@ -236,23 +205,195 @@ func (h *ServerHandler) ChangeMemberRole(c *gin.Context) {
 		Role string `json:"role" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(bindError(err))
 		return
 	}
 
-	err := h.service.ChangeMemberRole(currentUserID, serverID, targetUserID, input.Role)
+	err := h.service.ChangeMemberRole(c.Request.Context(), currentUserID, serverID, targetUserID, input.Role)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
-		}
-		if strings.Contains(err.Error(), "permissions") || strings.Contains(err.Error(), "insufficient") {
-			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	c.Status(http.StatusOK)
 }
+
+func (h *ServerHandler) SetMFARequirement(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	serverID := c.Param("id")
+	if serverID == "" {
+		c.Error(apperr.Invalid("server ID is required"))
+		return
+	}
+
+	var input struct {
+		RequireMFA *bool `json:"require_mfa" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	err := h.service.SetMFARequirement(c.Request.Context(), currentUserID, serverID, *input.RequireMFA)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *ServerHandler) AuthorizeBot(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	serverID := c.Param("id")
+	if serverID == "" {
+		c.Error(apperr.Invalid("server ID is required"))
+		return
+	}
+
+	var input struct {
+		ApplicationID string `json:"application_id" binding:"required"`
+		Role          string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	err := h.service.AuthorizeBot(c.Request.Context(), currentUserID, serverID, input.ApplicationID, input.Role)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusCreated)
+}
+
+func (h *ServerHandler) BanMember(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && c.Request.ContentLength != 0 {
+		c.Error(bindError(err))
+		return
+	}
+
+	if err := h.service.BanMember(c.Request.Context(), currentUserID, c.Param("id"), c.Param("userId"), input.Reason); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ServerHandler) UnbanMember(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	if err := h.service.UnbanMember(c.Request.Context(), currentUserID, c.Param("id"), c.Param("userId")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ServerHandler) GetBans(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	bans, err := h.service.ListBans(c.Request.Context(), currentUserID, c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, bans)
+}
+
+func (h *ServerHandler) TimeoutMember(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	var input struct {
+		Seconds *int64 `json:"seconds" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	duration := time.Duration(*input.Seconds) * time.Second
+	if err := h.service.TimeoutMember(c.Request.Context(), currentUserID, c.Param("id"), c.Param("userId"), duration); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ServerHandler) CreateInvite(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	var input struct {
+		MaxUses int   `json:"max_uses"`
+		MaxAge  int64 `json:"max_age"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && c.Request.ContentLength != 0 {
+		c.Error(bindError(err))
+		return
+	}
+
+	invite, err := h.service.CreateInvite(c.Request.Context(), currentUserID, c.Param("id"), input.MaxUses, time.Duration(input.MaxAge)*time.Second)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, invite)
+}
+
+func (h *ServerHandler) JoinInvite(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	server, err := h.service.JoinInvite(c.Request.Context(), currentUserID, c.Param("code"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, server)
+}
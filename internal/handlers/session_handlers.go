@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"rio/internal/apperr"
+	"rio/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionHandler struct {
+	service *service.SessionService
+}
+
+func NewSessionHandler(svc *service.SessionService) *SessionHandler {
+	return &SessionHandler{service: svc}
+}
+
+func (h *SessionHandler) GetSessions(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	sessions, err := h.service.ListSessions(c.Request.Context(), currentUserID, c.GetString("session_id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	sessionID := c.Param("id")
+	if sessionID == "" {
+		c.Error(apperr.Invalid("session ID is required"))
+		return
+	}
+
+	if err := h.service.RevokeSession(c.Request.Context(), currentUserID, sessionID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *SessionHandler) RevokeOtherSessions(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
+
+	if err := h.service.RevokeOtherSessions(c.Request.Context(), currentUserID, c.GetString("session_id")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"rio/internal/apperr"
+	"rio/internal/service"
+	"rio/utils/token"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RefreshInput struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type TokenHandler struct {
+	service *service.TokenService
+}
+
+func NewTokenHandler(svc *service.TokenService) *TokenHandler {
+	return &TokenHandler{service: svc}
+}
+
+func (h *TokenHandler) Refresh(c *gin.Context) {
+	var input RefreshInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(bindError(err))
+		return
+	}
+
+	tokens, err := h.service.Refresh(c.Request.Context(), input.RefreshToken)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *TokenHandler) Logout(c *gin.Context) {
+	claims, err := token.ExtractClaims(c)
+	if err != nil {
+		c.Error(apperr.Unauthorized("%w", err))
+		return
+	}
+
+	if err := h.service.Logout(c.Request.Context(), claims); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"rio/internal/apperr"
 	"rio/internal/service"
 	"strings"
 
@@ -11,6 +14,7 @@ import (
 type RegisterInput struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	Email    string `json:"email"`
 }
 
 type LoginInput struct {
@@ -29,13 +33,13 @@ func NewUserHandler(svc *service.UserService) *UserHandler {
 func (h *UserHandler) Register(c *gin.Context) {
 	var input RegisterInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(bindError(err))
 		return
 	}
 
-	user, err := h.service.Register(input.Username, input.Password)
+	user, err := h.service.Register(c.Request.Context(), input.Username, input.Password, input.Email)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -49,24 +53,65 @@ func (h *UserHandler) Login(c *gin.Context) {
 	var input LoginInput
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(bindError(err))
 		return
 	}
 
-	token, err := h.service.LoginCheck(input.Username, input.Password)
+	client := service.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+
+	result, err := h.service.LoginCheck(c.Request.Context(), input.Username, input.Password, client)
+
+	if err != nil {
+		if respondThrottled(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			c.Error(apperr.Invalid("username or password is incorrect."))
+			return
+		}
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// respondThrottled answers 429 with a Retry-After header when err is a
+// *service.LoginThrottledError, and reports whether it did.
+func respondThrottled(c *gin.Context, err error) bool {
+	var throttled *service.LoginThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+
+	seconds := int(math.Ceil(throttled.RetryAfter.Seconds()))
+	c.Error(apperr.RateLimited(seconds, "%w", err))
+	return true
+}
+
+func (h *UserHandler) GetSecurityLog(c *gin.Context) {
+	currentUserID := c.GetString("user_id")
+	if currentUserID == "" {
+		c.Error(apperr.Unauthorized("authentication required"))
+		return
+	}
 
+	attempts, err := h.service.SecurityLog(c.Request.Context(), currentUserID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "username or password is incorrect."})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	c.JSON(http.StatusOK, attempts)
 }
 
 func (h *UserHandler) GetUsers(c *gin.Context) {
-	users, err := h.service.GetAllUsers()
+	users, err := h.service.GetAllUsers(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, users)
@@ -75,19 +120,19 @@ func (h *UserHandler) GetUsers(c *gin.Context) {
 func (h *UserHandler) FindUsername(c *gin.Context) {
 	username := c.Param("username")
 	if strings.TrimSpace(username) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "username required"})
+		c.Error(apperr.Invalid("username required"))
 		return
 	}
 
-	user, err := h.service.FindUser(username)
+	user, err := h.service.FindUser(c.Request.Context(), username)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	if user == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		c.Error(apperr.NotFound("user not found"))
 		return
 	}
 
@@ -98,7 +143,7 @@ func (h *UserHandler) CurrentUser(c *gin.Context) {
 	u, err := h.service.FindCurrentUser(c)
 
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -0,0 +1,764 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/events"
+	"rio/internal/models"
+	"rio/internal/service"
+	"rio/utils/token"
+)
+
+const (
+	// registrationTimeout is how long a client has to log in.
+	registrationTimeout = 30 * time.Second
+	// idleTimeout closes connections that send nothing, not even a PONG to
+	// the PING sent every pingInterval.
+	idleTimeout  = 5 * time.Minute
+	pingInterval = 2 * time.Minute
+	writeTimeout = 30 * time.Second
+	// maxLineLength allows for IRCv3 message tags on top of the 512 bytes
+	// of a plain line.
+	maxLineLength = 8191
+)
+
+// joinedChannel is a rio channel the client has joined.
+type joinedChannel struct {
+	name    string
+	server  *models.Server
+	channel *models.Channel
+}
+
+// client is one IRC connection. Commands are handled on the goroutine
+// reading the connection; relay delivers events and pings on another.
+type client struct {
+	srv  *Server
+	conn net.Conn
+
+	// ctx is cancelled when the connection ends, abandoning the work of
+	// the command being handled.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	writeMu sync.Mutex
+
+	// Registration state, only used by the reading goroutine.
+	pass           string
+	user           string
+	capNegotiating bool
+
+	// Set when registration completes, before relay starts.
+	nick      string
+	userID    string
+	sessionID string
+	tokenID   string
+	botToken  string
+
+	mu     sync.Mutex
+	joined map[string]*joinedChannel // by channel ID
+	nicks  map[string]string         // by user ID
+
+	done chan struct{}
+}
+
+func newClient(srv *Server, conn net.Conn) *client {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &client{
+		srv:    srv,
+		conn:   conn,
+		ctx:    ctx,
+		cancel: cancel,
+		joined: make(map[string]*joinedChannel),
+		nicks:  make(map[string]string),
+		done:   make(chan struct{}),
+	}
+}
+
+func (c *client) serve() {
+	defer c.conn.Close()
+	defer close(c.done)
+	defer c.cancel()
+
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 0, 512), maxLineLength)
+
+	// The registration deadline is not extended by further lines, so a
+	// client cannot hold a connection open without logging in.
+	c.conn.SetReadDeadline(time.Now().Add(registrationTimeout))
+
+	for scanner.Scan() {
+		cmd, params := parseLine(scanner.Text())
+		if cmd == "" {
+			continue
+		}
+		if !c.handle(cmd, params) {
+			return
+		}
+		if c.userID != "" {
+			c.conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		}
+	}
+}
+
+// send writes one line to the client. A failed write closes the connection,
+// which ends serve.
+func (c *client) send(line string) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := c.conn.Write([]byte(line + "\r\n")); err != nil {
+		c.conn.Close()
+	}
+}
+
+// numeric sends a numeric reply. The last parameter is sent as the
+// trailing parameter, so it may contain spaces.
+func (c *client) numeric(code string, params ...string) {
+	target := c.nick
+	if target == "" {
+		target = "*"
+	}
+	params[len(params)-1] = ":" + params[len(params)-1]
+	c.send(fmt.Sprintf(":%s %s %s %s", serverName, code, target, strings.Join(params, " ")))
+}
+
+// errorAndClose tells the client why it is being disconnected. The caller
+// must stop handling commands afterwards.
+func (c *client) errorAndClose(reason string) bool {
+	c.send("ERROR :" + clean(reason))
+	return false
+}
+
+// describe returns what the client is told about err. Only apperr errors
+// are meant for users; anything else is logged and reported as an internal
+// error, as the REST API does.
+func (c *client) describe(err error) string {
+	if apperr.As(err) != nil {
+		return err.Error()
+	}
+	who := c.userID
+	if who == "" {
+		who = c.conn.RemoteAddr().String()
+	}
+	log.Printf("irc: %s: %v", who, err)
+	return "internal error"
+}
+
+func (c *client) source(nick string) string {
+	return nick + "!" + nick + "@" + serverName
+}
+
+// handle runs one command and reports whether the connection stays open.
+func (c *client) handle(cmd string, params []string) bool {
+	switch cmd {
+	case "CAP":
+		return c.handleCap(params)
+	case "PING":
+		if len(params) == 0 {
+			c.numeric("409", "No origin specified")
+			return true
+		}
+		c.send(fmt.Sprintf(":%s PONG %s :%s", serverName, serverName, params[0]))
+		return true
+	case "PONG":
+		return true
+	case "QUIT":
+		return c.errorAndClose("Closing link")
+	}
+
+	if c.userID == "" {
+		return c.handleRegistration(cmd, params)
+	}
+
+	switch cmd {
+	case "PASS", "USER":
+		c.numeric("462", "You may not reregister")
+	case "NICK":
+		if len(params) > 0 && params[0] != c.nick {
+			c.numeric("400", "NICK", "Nicks cannot be changed; yours is your rio username")
+		}
+	case "JOIN":
+		c.handleJoin(params)
+	case "PART":
+		c.handlePart(params)
+	case "PRIVMSG", "NOTICE":
+		return c.handleMessage(cmd, params)
+	case "NAMES":
+		c.handleNames(params)
+	case "TOPIC":
+		c.handleTopic(params)
+	case "WHO":
+		c.handleWho(params)
+	case "LIST":
+		c.handleList()
+	case "MODE":
+		c.handleMode(params)
+	default:
+		c.numeric("421", cmd, "Unknown command")
+	}
+	return true
+}
+
+// handleCap supports just enough of capability negotiation for clients
+// that start with it: no capabilities are offered.
+func (c *client) handleCap(params []string) bool {
+	if len(params) == 0 {
+		c.numeric("461", "CAP", "Not enough parameters")
+		return true
+	}
+
+	switch strings.ToUpper(params[0]) {
+	case "LS":
+		if c.userID == "" {
+			c.capNegotiating = true
+		}
+		c.send(fmt.Sprintf(":%s CAP %s LS :", serverName, c.target()))
+	case "LIST":
+		c.send(fmt.Sprintf(":%s CAP %s LIST :", serverName, c.target()))
+	case "REQ":
+		if c.userID == "" {
+			c.capNegotiating = true
+		}
+		requested := ""
+		if len(params) > 1 {
+			requested = params[1]
+		}
+		c.send(fmt.Sprintf(":%s CAP %s NAK :%s", serverName, c.target(), requested))
+	case "END":
+		if c.userID == "" && c.capNegotiating {
+			c.capNegotiating = false
+			return c.tryRegister()
+		}
+	default:
+		c.numeric("410", params[0], "Invalid CAP command")
+	}
+	return true
+}
+
+func (c *client) target() string {
+	if c.nick == "" {
+		return "*"
+	}
+	return c.nick
+}
+
+func (c *client) handleRegistration(cmd string, params []string) bool {
+	switch cmd {
+	case "PASS":
+		if len(params) == 0 {
+			c.numeric("461", "PASS", "Not enough parameters")
+			return true
+		}
+		c.pass = params[0]
+	case "NICK":
+		if len(params) == 0 || params[0] == "" {
+			c.numeric("431", "No nickname given")
+			return true
+		}
+		c.nick = params[0]
+	case "USER":
+		if len(params) < 4 {
+			c.numeric("461", "USER", "Not enough parameters")
+			return true
+		}
+		c.user = params[0]
+	default:
+		c.numeric("451", "You have not registered")
+		return true
+	}
+	return c.tryRegister()
+}
+
+// tryRegister logs the client in once it has sent NICK and USER and
+// finished capability negotiation. The nick it asked for is replaced by
+// its rio username.
+func (c *client) tryRegister() bool {
+	if c.nick == "" || c.user == "" || c.capNegotiating {
+		return true
+	}
+
+	userID, err := c.authenticate()
+	if err != nil {
+		c.numeric("464", "Password incorrect: "+c.describe(err))
+		return c.errorAndClose("Authentication failed")
+	}
+
+	user, err := c.srv.users.GetUser(c.ctx, userID)
+	if err != nil || user == nil {
+		c.numeric("464", "Password incorrect: user not found")
+		return c.errorAndClose("Authentication failed")
+	}
+
+	requested := c.nick
+	c.nick = user.Username
+	c.userID = user.ULID
+	c.remember(user.ULID, user.Username)
+
+	c.numeric("001", "Welcome to rio, "+c.nick)
+	c.numeric("002", "Your host is "+serverName)
+	c.numeric("003", "Servers and channels are mapped to #server-name/channel-name")
+	c.numeric("004", serverName, serverName, "o", "nt")
+	c.numeric("005", "CHANTYPES=#", "CHANMODES=,,,nt", "CASEMAPPING=ascii", "NETWORK="+serverName, "are supported by this server")
+	c.numeric("422", "MOTD File is missing")
+	if requested != c.nick {
+		c.send(fmt.Sprintf(":%s NICK :%s", c.source(requested), c.nick))
+	}
+
+	c.conn.SetReadDeadline(time.Now().Add(idleTimeout))
+	go c.relay(c.srv.gateway.Connect(c.userID))
+	return true
+}
+
+// authenticate resolves PASS to a user ID. It accepts a rio access token,
+// checked like the REST API checks it, or a bot API token.
+func (c *client) authenticate() (string, error) {
+	pass := c.pass
+	c.pass = ""
+	if pass == "" {
+		return "", apperr.Unauthorized("send a rio access token or bot token with PASS")
+	}
+
+	if claims, err := token.ParseAccessToken(pass); err == nil {
+		c.sessionID = claims.SessionID
+		c.tokenID = claims.ID
+		if err := c.checkAuth(); err != nil {
+			return "", err
+		}
+		return claims.Subject, nil
+	}
+
+	botID, err := c.srv.bots.AuthenticateBot(c.ctx, pass)
+	if err != nil {
+		return "", err
+	}
+	c.botToken = pass
+	return botID, nil
+}
+
+// checkAuth reports whether the client's credentials are still good. An
+// IRC connection outlives the access token it logged in with, so it is
+// tied to the token's session instead: revoking the session or the token
+// disconnects the client.
+func (c *client) checkAuth() error {
+	if c.botToken != "" {
+		_, err := c.srv.bots.AuthenticateBot(c.ctx, c.botToken)
+		return err
+	}
+
+	revoked, err := c.srv.revocations.IsAccessTokenRevoked(c.ctx, c.tokenID)
+	if err != nil {
+		return fmt.Errorf("could not verify token: %w", err)
+	}
+	if revoked {
+		return apperr.Unauthorized("token has been revoked")
+	}
+	return c.srv.sessions.CheckSession(c.ctx, c.sessionID)
+}
+
+// relay delivers new messages in joined channels and pings the client,
+// checking its credentials each time.
+func (c *client) relay(conn *service.GatewayConnection) {
+	defer c.srv.gateway.Disconnect(conn)
+
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case e, ok := <-conn.Events:
+			if !ok {
+				c.errorAndClose("Too slow; reconnect to catch up")
+				c.conn.Close()
+				return
+			}
+			if e.Type != events.MessageCreated {
+				continue
+			}
+			if m, ok := e.Data.(*models.Message); ok {
+				c.deliver(m)
+			}
+		case <-ping.C:
+			if err := c.checkAuth(); err != nil {
+				c.errorAndClose(c.describe(err))
+				c.conn.Close()
+				return
+			}
+			c.send("PING :" + serverName)
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// deliver relays a message to the client if it is in a joined channel.
+// IRC clients show their own messages as they send them, so messages by
+// the client's own user are not relayed.
+func (c *client) deliver(m *models.Message) {
+	c.mu.Lock()
+	ch := c.joined[m.ChannelID]
+	c.mu.Unlock()
+	if ch == nil || m.UserID == c.userID {
+		return
+	}
+
+	nick := c.authorNick(m)
+	for _, line := range splitText(m.Content) {
+		c.send(fmt.Sprintf(":%s PRIVMSG %s :%s", c.source(nick), ch.name, line))
+	}
+}
+
+func (c *client) authorNick(m *models.Message) string {
+	if m.WebhookID != "" {
+		return nickFor(m.DisplayName)
+	}
+
+	c.mu.Lock()
+	nick, ok := c.nicks[m.UserID]
+	c.mu.Unlock()
+	if ok {
+		return nick
+	}
+
+	user, err := c.srv.users.GetUser(c.ctx, m.UserID)
+	if err != nil || user == nil {
+		return m.UserID
+	}
+	c.remember(user.ULID, user.Username)
+	return user.Username
+}
+
+func (c *client) remember(userID, nick string) {
+	c.mu.Lock()
+	c.nicks[userID] = nick
+	c.mu.Unlock()
+}
+
+// resolve finds the rio channel an IRC channel name refers to among the
+// servers the client belongs to.
+func (c *client) resolve(name string) (*joinedChannel, error) {
+	serverPart, channelPart, ok := strings.Cut(strings.ToLower(name), "/")
+	if !strings.HasPrefix(serverPart, "#") || !ok {
+		return nil, errors.New("channel names look like #server-name/channel-name")
+	}
+	serverPart = serverPart[1:]
+
+	servers, err := c.srv.servers.ListUserServers(c.ctx, c.userID)
+	if err != nil {
+		return nil, err
+	}
+	slugs := serverSlugs(servers)
+
+	for _, srv := range servers {
+		if slugs[srv.ULID] != serverPart {
+			continue
+		}
+
+		channels, err := c.srv.channels.ListChannels(c.ctx, c.userID, srv.ULID)
+		if err != nil {
+			return nil, err
+		}
+		chSlugs := channelSlugs(channels)
+		for _, ch := range channels {
+			if chSlugs[ch.ULID] == channelPart {
+				return &joinedChannel{
+					name:    "#" + slugs[srv.ULID] + "/" + chSlugs[ch.ULID],
+					server:  srv,
+					channel: ch,
+				}, nil
+			}
+		}
+	}
+	return nil, errors.New("no such channel")
+}
+
+func (c *client) joinedByName(name string) *joinedChannel {
+	name = strings.ToLower(name)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, ch := range c.joined {
+		if ch.name == name {
+			return ch
+		}
+	}
+	return nil
+}
+
+func (c *client) handleJoin(params []string) {
+	if len(params) == 0 {
+		c.numeric("461", "JOIN", "Not enough parameters")
+		return
+	}
+
+	if params[0] == "0" {
+		c.mu.Lock()
+		var names []string
+		for _, ch := range c.joined {
+			names = append(names, ch.name)
+		}
+		c.mu.Unlock()
+		c.handlePart([]string{strings.Join(names, ",")})
+		return
+	}
+
+	for _, name := range strings.Split(params[0], ",") {
+		if name == "" {
+			continue
+		}
+		ch, err := c.resolve(name)
+		if err != nil {
+			c.numeric("403", name, "No such channel")
+			continue
+		}
+
+		c.mu.Lock()
+		_, already := c.joined[ch.channel.ULID]
+		c.joined[ch.channel.ULID] = ch
+		c.mu.Unlock()
+		if already {
+			continue
+		}
+
+		c.send(fmt.Sprintf(":%s JOIN %s", c.source(c.nick), ch.name))
+		c.sendTopic(ch)
+		c.sendNames(ch)
+	}
+}
+
+func (c *client) handlePart(params []string) {
+	if len(params) == 0 {
+		c.numeric("461", "PART", "Not enough parameters")
+		return
+	}
+
+	reason := ""
+	if len(params) > 1 {
+		reason = " :" + clean(params[1])
+	}
+
+	for _, name := range strings.Split(params[0], ",") {
+		if name == "" {
+			continue
+		}
+		ch := c.joinedByName(name)
+		if ch == nil {
+			c.numeric("442", name, "You're not on that channel")
+			continue
+		}
+
+		c.mu.Lock()
+		delete(c.joined, ch.channel.ULID)
+		c.mu.Unlock()
+		c.send(fmt.Sprintf(":%s PART %s%s", c.source(c.nick), ch.name, reason))
+	}
+}
+
+// handleMessage posts PRIVMSG and NOTICE text to joined channels through
+// the message service. As IRC requires, NOTICE never gets error replies.
+func (c *client) handleMessage(cmd string, params []string) bool {
+	notice := cmd == "NOTICE"
+	if len(params) == 0 {
+		if !notice {
+			c.numeric("411", "No recipient given ("+cmd+")")
+		}
+		return true
+	}
+	if len(params) < 2 || params[1] == "" {
+		if !notice {
+			c.numeric("412", "No text to send")
+		}
+		return true
+	}
+
+	text := fromCTCPAction(params[1])
+	if strings.HasPrefix(text, "\x01") {
+		// Other CTCP requests, such as VERSION, are not supported.
+		return true
+	}
+
+	if err := c.checkAuth(); err != nil {
+		return c.errorAndClose(c.describe(err))
+	}
+
+	for _, target := range strings.Split(params[0], ",") {
+		if !strings.HasPrefix(target, "#") {
+			if !notice {
+				c.numeric("401", target, "Direct messages are not supported")
+			}
+			continue
+		}
+
+		ch := c.joinedByName(target)
+		if ch == nil {
+			if !notice {
+				c.numeric("404", target, "Cannot send to channel; join it first")
+			}
+			continue
+		}
+
+		if _, err := c.srv.messages.SendMessage(c.ctx, c.userID, ch.channel.ULID, text, nil); err != nil && !notice {
+			c.numeric("404", ch.name, "Cannot send to channel: "+c.describe(err))
+		}
+	}
+	return true
+}
+
+func (c *client) handleNames(params []string) {
+	if len(params) == 0 {
+		c.numeric("366", "*", "End of /NAMES list")
+		return
+	}
+
+	for _, name := range strings.Split(params[0], ",") {
+		ch, err := c.resolve(name)
+		if err != nil {
+			c.numeric("366", name, "End of /NAMES list")
+			continue
+		}
+		c.sendNames(ch)
+	}
+}
+
+// sendNames lists the members of the channel's server, which are the
+// members of the channel.
+func (c *client) sendNames(ch *joinedChannel) {
+	members, err := c.srv.servers.ListMembers(c.ctx, c.userID, ch.server.ULID)
+	if err != nil {
+		log.Printf("irc: cannot list members of %s: %v", ch.server.ULID, err)
+	}
+
+	var line []string
+	size := 0
+	for _, m := range members {
+		c.remember(m.ULID, m.Username)
+		if size+len(m.Username) > maxTextLength {
+			c.numeric("353", "=", ch.name, strings.Join(line, " "))
+			line, size = nil, 0
+		}
+		line = append(line, m.Username)
+		size += len(m.Username) + 1
+	}
+	if len(line) > 0 {
+		c.numeric("353", "=", ch.name, strings.Join(line, " "))
+	}
+	c.numeric("366", ch.name, "End of /NAMES list")
+}
+
+func (c *client) handleTopic(params []string) {
+	if len(params) == 0 {
+		c.numeric("461", "TOPIC", "Not enough parameters")
+		return
+	}
+
+	ch, err := c.resolve(params[0])
+	if err != nil {
+		c.numeric("403", params[0], "No such channel")
+		return
+	}
+	if len(params) > 1 {
+		c.numeric("482", ch.name, "Topics cannot be changed from IRC")
+		return
+	}
+	c.sendTopic(ch)
+}
+
+// sendTopic sends the channel's topic, which names the rio server and
+// channel it maps to.
+func (c *client) sendTopic(ch *joinedChannel) {
+	c.numeric("332", ch.name, clean(ch.server.Name)+" / "+clean(ch.channel.Name))
+}
+
+func (c *client) handleWho(params []string) {
+	if len(params) == 0 {
+		c.numeric("315", "*", "End of WHO list")
+		return
+	}
+
+	mask := params[0]
+	if ch, err := c.resolve(mask); err == nil {
+		members, err := c.srv.servers.ListMembers(c.ctx, c.userID, ch.server.ULID)
+		if err != nil {
+			log.Printf("irc: cannot list members of %s: %v", ch.server.ULID, err)
+		}
+		for _, m := range members {
+			flags := "H"
+			if m.IsBot {
+				flags += "B"
+			}
+			c.numeric("352", ch.name, m.Username, serverName, serverName, m.Username, flags, "0 "+m.Username)
+		}
+	}
+	c.numeric("315", mask, "End of WHO list")
+}
+
+func (c *client) handleList() {
+	c.numeric("321", "Channel", "Users  Name")
+
+	servers, err := c.srv.servers.ListUserServers(c.ctx, c.userID)
+	if err != nil {
+		log.Printf("irc: cannot list servers of %s: %v", c.userID, err)
+	}
+	slugs := serverSlugs(servers)
+
+	for _, srv := range servers {
+		channels, err := c.srv.channels.ListChannels(c.ctx, c.userID, srv.ULID)
+		if err != nil {
+			continue
+		}
+		members, err := c.srv.servers.ListMembers(c.ctx, c.userID, srv.ULID)
+		if err != nil {
+			continue
+		}
+
+		chSlugs := channelSlugs(channels)
+		for _, ch := range channels {
+			name := "#" + slugs[srv.ULID] + "/" + chSlugs[ch.ULID]
+			c.numeric("322", name, strconv.Itoa(len(members)), clean(srv.Name)+" / "+clean(ch.Name))
+		}
+	}
+	c.numeric("323", "End of /LIST")
+}
+
+// handleMode reports modes but does not change them: channels are always
+// +nt, and permissions are managed in rio.
+func (c *client) handleMode(params []string) {
+	if len(params) == 0 {
+		c.numeric("461", "MODE", "Not enough parameters")
+		return
+	}
+
+	target := params[0]
+	if !strings.HasPrefix(target, "#") {
+		if !strings.EqualFold(target, c.nick) {
+			c.numeric("502", "Cannot change mode for other users")
+			return
+		}
+		c.numeric("221", "+")
+		return
+	}
+
+	ch, err := c.resolve(target)
+	if err != nil {
+		c.numeric("403", target, "No such channel")
+		return
+	}
+	switch {
+	case len(params) == 1:
+		c.numeric("324", ch.name, "+nt")
+	case strings.TrimLeft(params[1], "+") == "b":
+		c.numeric("368", ch.name, "End of channel ban list")
+	default:
+		c.numeric("482", ch.name, "Channel modes cannot be changed from IRC")
+	}
+}
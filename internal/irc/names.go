@@ -0,0 +1,161 @@
+package irc
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	"rio/internal/models"
+)
+
+// slug turns a rio server or channel name into the lowercase form used in
+// IRC channel names: anything other than letters, digits, '-', '_' and '.'
+// becomes a single '-'.
+func slug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(html.UnescapeString(name)) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_' || r == '.' {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	s := strings.Trim(b.String(), "-")
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// uniqueSlugs returns the slug of each name, keyed by the matching ID.
+// Names are not unique, so colliding slugs get the end of their ID
+// appended.
+func uniqueSlugs(ids, names []string) map[string]string {
+	counts := make(map[string]int, len(names))
+	for _, name := range names {
+		counts[slug(name)]++
+	}
+
+	slugs := make(map[string]string, len(ids))
+	for i, id := range ids {
+		name := slug(names[i])
+		if counts[name] > 1 {
+			lower := strings.ToLower(id)
+			name += "-" + lower[max(0, len(lower)-6):]
+		}
+		slugs[id] = name
+	}
+	return slugs
+}
+
+func serverSlugs(servers []*models.Server) map[string]string {
+	ids := make([]string, len(servers))
+	names := make([]string, len(servers))
+	for i, srv := range servers {
+		ids[i], names[i] = srv.ULID, srv.Name
+	}
+	return uniqueSlugs(ids, names)
+}
+
+func channelSlugs(channels []*models.Channel) map[string]string {
+	ids := make([]string, len(channels))
+	names := make([]string, len(channels))
+	for i, ch := range channels {
+		ids[i], names[i] = ch.ULID, ch.Name
+	}
+	return uniqueSlugs(ids, names)
+}
+
+// clean makes free text safe to put on an IRC line.
+func clean(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ", "\x00", "").Replace(html.UnescapeString(s))
+}
+
+// nickFor returns a nick for a display name, which may contain spaces.
+func nickFor(name string) string {
+	nick := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', ',', '*', '?', '!', '@', ':', '#':
+			return '_'
+		}
+		return r
+	}, html.UnescapeString(name))
+	if nick == "" {
+		return "_"
+	}
+	return nick
+}
+
+// parseLine splits an IRC line into its command and parameters. Message
+// tags and the source prefix are ignored; clients have no use for them.
+func parseLine(line string) (string, []string) {
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(line, "@") {
+		_, line, _ = strings.Cut(line, " ")
+	}
+	line = strings.TrimLeft(line, " ")
+	if strings.HasPrefix(line, ":") {
+		_, line, _ = strings.Cut(line, " ")
+	}
+
+	var params []string
+	for {
+		line = strings.TrimLeft(line, " ")
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, ":") {
+			params = append(params, line[1:])
+			break
+		}
+		param, rest, _ := strings.Cut(line, " ")
+		params = append(params, param)
+		line = rest
+	}
+
+	if len(params) == 0 {
+		return "", nil
+	}
+	return strings.ToUpper(params[0]), params[1:]
+}
+
+// maxTextLength keeps relayed lines well inside IRC's 512-byte limit once
+// the source prefix and channel name are added.
+const maxTextLength = 400
+
+// splitText breaks message content into lines IRC can carry: one per line
+// of the message, further split at maxTextLength bytes without cutting a
+// UTF-8 sequence in half.
+func splitText(content string) []string {
+	content = strings.NewReplacer("\r", "", "\x00", "").Replace(content)
+
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		for len(line) > maxTextLength {
+			cut := maxTextLength
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			lines = append(lines, line[:cut])
+			line = line[cut:]
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// fromCTCPAction rewrites a CTCP ACTION (/me) as an emphasised message;
+// other text is returned unchanged.
+func fromCTCPAction(text string) string {
+	if action, ok := strings.CutPrefix(text, "\x01ACTION "); ok {
+		return "_" + strings.TrimSuffix(action, "\x01") + "_"
+	}
+	return text
+}
@@ -0,0 +1,109 @@
+package irc
+
+import (
+	"crypto/tls"
+	"log"
+	"net"
+	"sync"
+
+	"rio/internal/service"
+	"rio/utils/token"
+)
+
+// serverName is the prefix of every reply the gateway sends.
+const serverName = "rio"
+
+// Server is an IRC listener that exposes rio servers and channels to IRC
+// clients. Every rio channel becomes an IRC channel named
+// #server-name/channel-name. Clients log in by sending a rio access token or
+// bot API token with PASS, and everything they do goes through the same
+// services as the REST API, so the same membership and permission checks
+// apply.
+type Server struct {
+	addr      string
+	tlsConfig *tls.Config
+
+	users    *service.UserService
+	servers  *service.ServerService
+	channels *service.ChannelService
+	messages *service.MessageService
+	gateway  *service.GatewayService
+
+	revocations token.RevocationChecker
+	sessions    token.SessionChecker
+	bots        token.BotAuthenticator
+
+	mu       sync.Mutex
+	listener net.Listener
+}
+
+// NewServer returns a gateway that will listen on addr. With a non-nil
+// tlsConfig it only accepts TLS connections.
+func NewServer(
+	addr string,
+	tlsConfig *tls.Config,
+	users *service.UserService,
+	servers *service.ServerService,
+	channels *service.ChannelService,
+	messages *service.MessageService,
+	gateway *service.GatewayService,
+	revocations token.RevocationChecker,
+	sessions token.SessionChecker,
+	bots token.BotAuthenticator,
+) *Server {
+	return &Server{
+		addr:        addr,
+		tlsConfig:   tlsConfig,
+		users:       users,
+		servers:     servers,
+		channels:    channels,
+		messages:    messages,
+		gateway:     gateway,
+		revocations: revocations,
+		sessions:    sessions,
+		bots:        bots,
+	}
+}
+
+// ListenAndServe accepts IRC connections until Close is called.
+func (s *Server) ListenAndServe() error {
+	var (
+		l   net.Listener
+		err error
+	)
+	if s.tlsConfig != nil {
+		l, err = tls.Listen("tcp", s.addr, s.tlsConfig)
+	} else {
+		l, err = net.Listen("tcp", s.addr)
+	}
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts IRC connections on l until Close is called.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	s.listener = l
+	s.mu.Unlock()
+
+	log.Printf("irc: listening on %s", l.Addr())
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go newClient(s, conn).serve()
+	}
+}
+
+// Close stops accepting connections. Open connections are left to finish.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
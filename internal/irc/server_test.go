@@ -0,0 +1,257 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"rio/internal/events"
+	"rio/internal/models"
+	appRepo "rio/internal/repository/application"
+	attachmentRepo "rio/internal/repository/attachment"
+	channelRepo "rio/internal/repository/channel"
+	inviteRepo "rio/internal/repository/invite"
+	loginAttemptRepo "rio/internal/repository/loginattempt"
+	messageRepo "rio/internal/repository/message"
+	serverRepo "rio/internal/repository/server"
+	sessionRepo "rio/internal/repository/session"
+	tokenRepo "rio/internal/repository/token"
+	unitOfWork "rio/internal/repository/unitofwork"
+	userRepo "rio/internal/repository/user"
+	"rio/internal/service"
+	"rio/internal/store"
+	"rio/utils/token"
+)
+
+// gateway is an IRC gateway on a loopback port, backed by in-memory
+// services.
+type gateway struct {
+	addr     string
+	users    *service.UserService
+	servers  *service.ServerService
+	channels *service.ChannelService
+	messages *service.MessageService
+	sessions *service.SessionService
+	tokens   *service.TokenService
+	// revocations wraps tokens, to make the revocation check fail.
+	revocations *failingRevocations
+}
+
+// failingRevocations fails revocation checks with err while it is set.
+type failingRevocations struct {
+	token.RevocationChecker
+	mu  sync.Mutex
+	err error
+}
+
+func (r *failingRevocations) fail(err error) {
+	r.mu.Lock()
+	r.err = err
+	r.mu.Unlock()
+}
+
+func (r *failingRevocations) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	r.mu.Lock()
+	err := r.err
+	r.mu.Unlock()
+	if err != nil {
+		return false, err
+	}
+	return r.RevocationChecker.IsAccessTokenRevoked(ctx, jti)
+}
+
+func startGateway(t *testing.T) *gateway {
+	t.Helper()
+	if err := token.Configure(token.Settings{Alg: "HS256", Secret: "test", AccessLifespan: time.Hour, RefreshLifespan: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := store.New()
+	bus := events.NewBus()
+	uow := unitOfWork.NewInMemoryUnitOfWork(s)
+	users := userRepo.NewInMemoryUserRepository(s)
+	servers := serverRepo.NewInMemoryServerRepository(s)
+	channels := channelRepo.NewInMemoryChannelRepository(s)
+	apps := appRepo.NewInMemoryApplicationRepository(s)
+	tokens := tokenRepo.NewInMemoryTokenRepository(s)
+
+	g := &gateway{}
+	g.sessions = service.NewSessionService(sessionRepo.NewInMemorySessionRepository(s), tokens)
+	g.tokens = service.NewTokenService(tokens, g.sessions, uow)
+	guard := service.NewLoginGuard(loginAttemptRepo.NewInMemoryLoginAttemptRepository(s))
+	g.users = service.NewUserService(users, g.sessions, g.tokens, guard, service.DefaultPasswordPolicy())
+	g.servers = service.NewServerService(servers, users, apps, inviteRepo.NewInMemoryInviteRepository(s), uow, bus)
+	g.channels = service.NewChannelService(channels, servers)
+	g.messages = service.NewMessageService(messageRepo.NewInMemoryMessageRepository(s), channels, servers, users, attachmentRepo.NewInMemoryAttachmentRepository(s), uow, bus)
+	gw := service.NewGatewayService(servers, bus)
+	bots := service.NewApplicationService(apps, users, uow)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.revocations = &failingRevocations{RevocationChecker: g.tokens}
+	srv := NewServer(l.Addr().String(), nil, g.users, g.servers, g.channels, g.messages, gw, g.revocations, g.sessions, bots)
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+
+	g.addr = l.Addr().String()
+	return g
+}
+
+// register creates a user and returns it with an access token.
+func (g *gateway) register(t *testing.T, username string) (*models.User, string) {
+	t.Helper()
+	user, err := g.users.Register(context.Background(), username, "Correct-horse-42", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := g.tokens.StartSession(context.Background(), user.ULID, service.ClientInfo{UserAgent: "irc test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user, pair.AccessToken
+}
+
+// ircClient is a raw TCP IRC client.
+type ircClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dial(t *testing.T, addr string) *ircClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &ircClient{t: t, conn: conn, reader: bufio.NewReader(conn)}
+}
+
+func (c *ircClient) send(format string, args ...any) {
+	c.t.Helper()
+	if _, err := fmt.Fprintf(c.conn, format+"\r\n", args...); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// expect reads lines until one contains want and returns it.
+func (c *ircClient) expect(want string) string {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			c.t.Fatalf("waiting for %q: %v", want, err)
+		}
+		if strings.Contains(line, want) {
+			return strings.TrimRight(line, "\r\n")
+		}
+	}
+}
+
+// sync waits until the gateway has handled every command sent so far.
+func (c *ircClient) sync() {
+	c.t.Helper()
+	c.send("PING :sync")
+	c.expect("PONG rio :sync")
+}
+
+// login registers the connection with an access token.
+func (c *ircClient) login(accessToken, nick string) {
+	c.t.Helper()
+	c.send("PASS %s", accessToken)
+	c.send("NICK %s", nick)
+	c.send("USER %s 0 * :%s", nick, nick)
+	c.expect(" 001 ")
+}
+
+func TestGateway(t *testing.T) {
+	g := startGateway(t)
+	ctx := context.Background()
+
+	alice, aliceToken := g.register(t, "alice")
+	bob, _ := g.register(t, "bob")
+	_, carolToken := g.register(t, "carol")
+
+	server, err := g.servers.CreateServer(ctx, alice.ULID, "Rio Dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	channel, err := g.channels.CreateChannel(ctx, alice.ULID, server.ULID, "general")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.servers.AddMember(ctx, alice.ULID, server.ULID, bob.ULID, "member"); err != nil {
+		t.Fatal(err)
+	}
+	name := "#rio-dev/general"
+
+	t.Run("login", func(t *testing.T) {
+		c := dial(t, g.addr)
+		c.send("PASS not-a-token")
+		c.send("NICK mallory")
+		c.send("USER mallory 0 * :mallory")
+		c.expect(" 464 ")
+		c.expect("ERROR :Authentication failed")
+
+		c = dial(t, g.addr)
+		c.login(aliceToken, "ally")
+		// The nick asked for is replaced by the rio username.
+		c.expect(":ally!ally@rio NICK :alice")
+	})
+
+	t.Run("relay", func(t *testing.T) {
+		c := dial(t, g.addr)
+		c.login(aliceToken, "alice")
+		c.send("JOIN %s", name)
+		c.expect(":alice!alice@rio JOIN " + name)
+		c.expect(" 366 alice " + name)
+
+		if _, err := g.messages.SendMessage(ctx, bob.ULID, channel.ULID, "hello from rio", nil); err != nil {
+			t.Fatal(err)
+		}
+		c.expect(":bob!bob@rio PRIVMSG " + name + " :hello from rio")
+
+		c.send("PRIVMSG %s :hello from irc", name)
+		c.sync()
+		messages, err := g.messages.GetMessages(ctx, bob.ULID, channel.ULID, "", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(messages) == 0 || messages[0].Content != "hello from irc" || messages[0].UserID != alice.ULID {
+			t.Fatalf("newest message is not the one sent over IRC: %+v", messages)
+		}
+	})
+
+	t.Run("not a member", func(t *testing.T) {
+		c := dial(t, g.addr)
+		c.login(carolToken, "carol")
+
+		c.send("JOIN %s", name)
+		c.expect(" 403 carol " + name)
+
+		c.send("PRIVMSG %s :let me in", name)
+		c.expect(" 404 carol " + name)
+	})
+
+	t.Run("internal errors are not shown", func(t *testing.T) {
+		c := dial(t, g.addr)
+		c.login(aliceToken, "alice")
+
+		g.revocations.fail(errors.New("database is down"))
+		defer g.revocations.fail(nil)
+		c.send("PRIVMSG %s :hello", name)
+		if line := c.expect("ERROR :"); line != "ERROR :internal error" {
+			t.Fatalf("got %q; want the cause hidden", line)
+		}
+	})
+}
@@ -0,0 +1,44 @@
+package mailer
+
+import "fmt"
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional email such as password reset links.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Config selects a mailer and says how it delivers.
+type Config struct {
+	// Kind is "smtp" for real delivery, or "log" to write messages to
+	// LogFile or stdout for local development.
+	Kind string
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	LogFile string
+}
+
+// New builds the mailer c selects.
+func New(c Config) (Mailer, error) {
+	switch c.Kind {
+	case "smtp":
+		if c.SMTPHost == "" || c.SMTPPort <= 0 || c.SMTPPort > 65535 {
+			return nil, fmt.Errorf("the smtp mailer needs a host and a port between 1 and 65535")
+		}
+		return NewSMTPMailer(c.SMTPHost, c.SMTPPort, c.SMTPUsername, c.SMTPPassword, c.SMTPFrom), nil
+	case "log":
+		return NewLogMailer(c.LogFile), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q; must be smtp or log", c.Kind)
+	}
+}
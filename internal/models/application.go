@@ -0,0 +1,24 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// Application is a bot registered by a human user. The bot itself is a
+// User with IsBot set; the application holds its owner and API token.
+type Application struct {
+	gorm.Model
+	ULID        string `gorm:"type:varchar(26);unique;not null" json:"id"`
+	Name        string `gorm:"size:255;not null" json:"name"`
+	Description string `gorm:"size:400" json:"description"`
+	OwnerID     string `gorm:"type:varchar(26);index;not null" json:"owner_id"`
+	BotID       string `gorm:"type:varchar(26);unique;not null" json:"bot_id"`
+	Public      bool   `gorm:"not null;default:false" json:"public"`
+	TokenHash   string `gorm:"type:char(64);unique;not null" json:"-"`
+
+	// Command invocations are POSTed to InteractionsURL, signed with
+	// InteractionsSecret. Without a URL they go to the bot's gateway
+	// connection instead.
+	InteractionsURL    string `gorm:"size:2048" json:"interactions_url,omitempty"`
+	InteractionsSecret string `gorm:"size:64" json:"-"`
+}
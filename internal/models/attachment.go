@@ -0,0 +1,18 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// Attachment is a file attached to a message. Data is only loaded when the
+// file itself is downloaded.
+type Attachment struct {
+	gorm.Model
+	ULID        string `gorm:"type:varchar(26);unique;not null" json:"id"`
+	MessageID   string `gorm:"type:varchar(26);index;not null" json:"message_id"`
+	Filename    string `gorm:"size:255;not null" json:"filename"`
+	ContentType string `gorm:"size:255;not null" json:"content_type"`
+	Size        int    `gorm:"not null" json:"size"`
+	// The size makes the column a longblob on MySQL.
+	Data []byte `gorm:"size:4294967295" json:"-"`
+}
@@ -6,7 +6,7 @@ import (
 
 type Channel struct {
 	gorm.Model
-	ULID     string `gorm:"type:varchar(26);primaryKey"`
+	ULID     string `gorm:"type:varchar(26);unique_index"`
 	ServerID string `gorm:"type:varchar(26);index"`
 	Name     string `gorm:"not null"`
 }
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// ChannelEmail is a generated email address whose mail is posted into a
+// channel. AllowedSenders holds the comma-separated addresses and domains
+// allowed to send to it; when empty, anyone can.
+type ChannelEmail struct {
+	gorm.Model
+	ULID           string   `gorm:"type:varchar(26);unique;not null" json:"id"`
+	ChannelID      string   `gorm:"type:varchar(26);index;not null" json:"channel_id"`
+	ServerID       string   `gorm:"type:varchar(26);index;not null" json:"server_id"`
+	Name           string   `gorm:"size:80;not null" json:"name"`
+	LocalPart      string   `gorm:"size:32;unique;not null" json:"-"`
+	Address        string   `gorm:"-" json:"address"`
+	AllowedSenders string   `gorm:"type:text" json:"-"`
+	SenderList     []string `gorm:"-" json:"allowed_senders"`
+	MaxSize        int      `gorm:"not null" json:"max_size"`
+	CreatedBy      string   `gorm:"type:varchar(26)" json:"created_by"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Command option types.
+const (
+	CommandOptionString  = "string"
+	CommandOptionInteger = "integer"
+	CommandOptionBoolean = "boolean"
+	CommandOptionUser    = "user"
+	CommandOptionChannel = "channel"
+)
+
+type CommandOption struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Type        string `json:"type"`
+	Required    bool   `json:"required"`
+}
+
+// ApplicationCommand is a slash command an application registered in a
+// server. Options holds the option definitions as JSON.
+type ApplicationCommand struct {
+	gorm.Model
+	ULID          string          `gorm:"type:varchar(26);unique;not null" json:"id"`
+	ApplicationID string          `gorm:"type:varchar(26);index;not null" json:"application_id,omitempty"`
+	ServerID      string          `gorm:"type:varchar(26);index;not null" json:"server_id,omitempty"`
+	Name          string          `gorm:"size:32;not null" json:"name"`
+	Description   string          `gorm:"size:100;not null" json:"description"`
+	Options       string          `gorm:"type:text" json:"-"`
+	OptionList    []CommandOption `gorm:"-" json:"options"`
+	BuiltIn       bool            `gorm:"-" json:"built_in,omitempty"`
+}
+
+// Interaction states. An interaction is pending until the application
+// acknowledges it, deferred if it promised to answer later, and responded
+// once it has answered. Interactions the application never answered are
+// failed.
+const (
+	InteractionPending   = "pending"
+	InteractionDeferred  = "deferred"
+	InteractionResponded = "responded"
+	InteractionFailed    = "failed"
+)
+
+// Interaction types.
+const (
+	InteractionCommand   = "command"
+	InteractionComponent = "component"
+)
+
+// Interaction is one invocation of an application command, or one click
+// on a message component. Options holds the validated option values as
+// JSON.
+type Interaction struct {
+	gorm.Model
+	ULID          string    `gorm:"type:varchar(26);unique;not null" json:"id"`
+	ApplicationID string    `gorm:"type:varchar(26);index;not null" json:"application_id"`
+	CommandID     string    `gorm:"type:varchar(26);not null" json:"command_id"`
+	ServerID      string    `gorm:"type:varchar(26);not null" json:"server_id"`
+	ChannelID     string    `gorm:"type:varchar(26);not null" json:"channel_id"`
+	UserID        string    `gorm:"type:varchar(26);not null" json:"user_id"`
+	CommandName   string    `gorm:"size:32;not null" json:"command_name"`
+	Options       string    `gorm:"type:text" json:"-"`
+	Status        string    `gorm:"size:20;not null" json:"status"`
+	ExpiresAt     time.Time `gorm:"not null" json:"expires_at"`
+
+	// Component interactions come from a click on a message's component
+	// rather than a command; Options then holds the selected values.
+	Type      string `gorm:"size:20;not null" json:"type"`
+	MessageID string `gorm:"type:varchar(26)" json:"message_id,omitempty"`
+	CustomID  string `gorm:"size:100" json:"custom_id,omitempty"`
+}
@@ -0,0 +1,49 @@
+package models
+
+// Component types.
+const (
+	ComponentButton = "button"
+	ComponentSelect = "select"
+)
+
+// Button styles. Link buttons open URL instead of producing an
+// interaction, so they have no CustomID.
+const (
+	ButtonPrimary   = "primary"
+	ButtonSecondary = "secondary"
+	ButtonSuccess   = "success"
+	ButtonDanger    = "danger"
+	ButtonLink      = "link"
+)
+
+// ActionRow is one row of components under a message. A row holds up to
+// five buttons or a single select menu.
+type ActionRow struct {
+	Components []Component `json:"components"`
+}
+
+// Component is a button or a select menu. CustomID identifies it in the
+// interactions it produces.
+type Component struct {
+	Type     string `json:"type"`
+	CustomID string `json:"custom_id,omitempty"`
+	Disabled bool   `json:"disabled,omitempty"`
+
+	// Buttons.
+	Label string `json:"label,omitempty"`
+	Style string `json:"style,omitempty"`
+	URL   string `json:"url,omitempty"`
+
+	// Select menus.
+	Placeholder string         `json:"placeholder,omitempty"`
+	MinValues   int            `json:"min_values,omitempty"`
+	MaxValues   int            `json:"max_values,omitempty"`
+	Options     []SelectOption `json:"options,omitempty"`
+}
+
+type SelectOption struct {
+	Label       string `json:"label"`
+	Value       string `json:"value"`
+	Description string `json:"description,omitempty"`
+	Default     bool   `json:"default,omitempty"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// IncomingWebhook lets an external system post messages into a channel by
+// POSTing to a URL that embeds a secret token.
+type IncomingWebhook struct {
+	gorm.Model
+	ULID      string `gorm:"type:varchar(26);unique;not null" json:"id"`
+	ChannelID string `gorm:"type:varchar(26);index;not null" json:"channel_id"`
+	ServerID  string `gorm:"type:varchar(26);index;not null" json:"server_id"`
+	Name      string `gorm:"size:80;not null" json:"name"`
+	AvatarURL string `gorm:"size:2048" json:"avatar_url,omitempty"`
+	TokenHash string `gorm:"type:char(64);not null" json:"-"`
+	CreatedBy string `gorm:"type:varchar(26)" json:"created_by"`
+}
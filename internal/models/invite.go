@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Invite lets anyone holding its code join a server as a member. MaxUses
+// of zero means unlimited; a nil ExpiresAt never expires.
+type Invite struct {
+	gorm.Model
+	Code      string     `gorm:"type:varchar(16);unique;not null" json:"code"`
+	ServerID  string     `gorm:"type:varchar(26);index;not null" json:"server_id"`
+	CreatedBy string     `gorm:"type:varchar(26);not null" json:"created_by"`
+	MaxUses   int        `gorm:"not null" json:"max_uses"`
+	Uses      int        `gorm:"not null" json:"uses"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
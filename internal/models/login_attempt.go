@@ -0,0 +1,28 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// Reasons recorded against failed login attempts.
+const (
+	LoginReasonUnknownUser     = "unknown_user"
+	LoginReasonBadPassword     = "bad_password"
+	LoginReasonBadSecondFactor = "bad_second_factor"
+	LoginReasonBadPasskey      = "bad_passkey"
+	LoginReasonThrottled       = "throttled"
+)
+
+// LoginAttempt is one entry in the security log. Throttled attempts are
+// logged but never count towards further throttling, so an attacker cannot
+// keep an account locked by hammering it.
+type LoginAttempt struct {
+	gorm.Model
+	UserID    string `gorm:"type:varchar(26);index" json:"-"`
+	Username  string `gorm:"size:255;index" json:"username"`
+	IP        string `gorm:"size:45;index" json:"ip"`
+	UserAgent string `gorm:"size:512" json:"user_agent"`
+	Method    string `gorm:"size:20" json:"method"`
+	Success   bool   `gorm:"not null;default:false" json:"success"`
+	Reason    string `gorm:"size:50" json:"reason,omitempty"`
+}
@@ -6,8 +6,23 @@ import (
 
 type Message struct {
 	gorm.Model
-	ULID      string `gorm:"type:varchar(26);primaryKey"`
+	ULID      string `gorm:"type:varchar(26);unique_index"`
 	ChannelID string `gorm:"type:varchar(26);index"`
 	UserID    string `gorm:"type:varchar(26);index"`
-	Content   string `gorm:"not null"`
+	Content   string `gorm:"type:text;not null"`
+
+	// Messages posted by an incoming webhook or a channel email address
+	// have no UserID; they carry its ID and the name and avatar they were
+	// posted under.
+	WebhookID   string `gorm:"type:varchar(26);index"`
+	DisplayName string `gorm:"size:80"`
+	AvatarURL   string `gorm:"size:2048"`
+
+	// Bots can attach interactive components. ComponentsJSON stores them;
+	// Components is filled in when the message is read.
+	ComponentsJSON string      `gorm:"column:components;type:text" json:"-"`
+	Components     []ActionRow `gorm:"-" json:",omitempty"`
+
+	// Attachments is filled in when the message is read.
+	Attachments []Attachment `gorm:"-" json:",omitempty"`
 }
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type Passkey struct {
+	gorm.Model
+	ULID             string     `gorm:"type:varchar(26);unique;not null" json:"id"`
+	UserID           string     `gorm:"type:varchar(26);index;not null" json:"-"`
+	Name             string     `gorm:"size:100;not null" json:"name"`
+	CredentialID     string     `gorm:"type:text;not null" json:"-"`
+	CredentialIDHash string     `gorm:"type:char(64);unique;not null" json:"-"`
+	PublicKey        []byte     `gorm:"not null" json:"-"`
+	SignCount        uint32     `json:"-"`
+	AAGUID           string     `gorm:"type:varchar(36)" json:"aaguid"`
+	Transports       string     `gorm:"size:255" json:"-"`
+	LastUsedAt       *time.Time `json:"last_used_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type PasswordReset struct {
+	gorm.Model
+	ULID      string    `gorm:"type:varchar(26);unique;not null"`
+	UserID    string    `gorm:"type:varchar(26);index;not null"`
+	TokenHash string    `gorm:"type:char(64);unique;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
@@ -0,0 +1,11 @@
+package models
+
+// RateLimitBucket is the shared state of one token bucket. RefilledAt is a
+// Unix time in nanoseconds; DATETIME columns are too coarse for refill
+// arithmetic.
+type RateLimitBucket struct {
+	BucketKey  string  `gorm:"primary_key;size:191"`
+	Tokens     float64 `gorm:"not null"`
+	RefilledAt int64   `gorm:"not null;index"`
+	ResetAt    int64   `gorm:"not null;index"`
+}
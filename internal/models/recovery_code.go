@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type RecoveryCode struct {
+	gorm.Model
+	UserID   string `gorm:"type:varchar(26);index;not null"`
+	CodeHash string `gorm:"type:char(64);not null"`
+	UsedAt   *time.Time
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type RefreshToken struct {
+	gorm.Model
+	ULID       string     `gorm:"type:varchar(26);unique;not null"`
+	UserID     string     `gorm:"type:varchar(26);index;not null"`
+	FamilyID   string     `gorm:"type:varchar(26);index;not null"`
+	TokenHash  string     `gorm:"type:char(64);unique;not null"`
+	ExpiresAt  time.Time  `gorm:"not null"`
+	RevokedAt  *time.Time `gorm:"index"`
+	ReplacedBy string     `gorm:"type:varchar(26)"`
+}
+
+type RevokedToken struct {
+	JTI       string    `gorm:"primary_key;type:varchar(26)"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+}
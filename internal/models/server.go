@@ -11,4 +11,13 @@ type Server struct {
 	OwnerID  string `gorm:"type:varchar(26);index"`
 	Users    []User `gorm:"many2many:user_servers;"`
 	Channels []Channel
+
+	// RequireMFA forces moderators and above to have two-factor
+	// authentication enabled before they can use their privileges.
+	RequireMFA bool `gorm:"not null;default:false" json:"require_mfa"`
+
+	// Instance is set on servers hosted by another rio instance that local
+	// users joined through federation. Such rows mirror the remote server
+	// to hold local memberships; channels and messages stay on the host.
+	Instance string `gorm:"size:255;index" json:"instance,omitempty"`
 }
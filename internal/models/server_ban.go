@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// ServerBan keeps a user out of a server until it is lifted.
+type ServerBan struct {
+	UserID    string    `gorm:"primary_key;type:varchar(26)" json:"user_id"`
+	ServerID  string    `gorm:"primary_key;type:varchar(26)" json:"server_id"`
+	BannedBy  string    `gorm:"type:varchar(26);not null" json:"banned_by"`
+	Reason    string    `gorm:"size:512" json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
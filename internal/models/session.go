@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type Session struct {
+	gorm.Model
+	ULID       string     `gorm:"type:varchar(26);unique;not null" json:"id"`
+	UserID     string     `gorm:"type:varchar(26);index;not null" json:"-"`
+	DeviceName string     `gorm:"size:255" json:"device_name"`
+	UserAgent  string     `gorm:"size:512" json:"user_agent"`
+	IP         string     `gorm:"size:45" json:"ip"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `gorm:"index" json:"-"`
+	Current    bool       `gorm:"-" json:"current"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// Snowflake records the Discord-style ID the compatibility API derived from
+// a ULID, so IDs it handed out can be mapped back.
+type Snowflake struct {
+	gorm.Model
+	Snowflake string `gorm:"type:varchar(20);unique;not null"`
+	ULID      string `gorm:"type:varchar(26);unique;not null"`
+}
@@ -9,6 +9,22 @@ type User struct {
 	ULID     string   `gorm:"type:varchar(26);primaryKey;not null;unique"`
 	Username string   `gorm:"size:255;not null;unique" json:"username"`
 	Password string   `gorm:"size:255;not null" json:"-"`
+	Email    string   `gorm:"size:255;index" json:"-"`
 	Role     string   `gorm:"size:20;default:'user'"`
 	Servers  []Server `gorm:"many2many:user_servers;"`
+
+	TOTPSecret   string `gorm:"size:64" json:"-"`
+	TOTPEnabled  bool   `gorm:"not null;default:false" json:"totp_enabled"`
+	TOTPLastStep int64  `json:"-"`
+
+	// Bots are owned by a human user and authenticate with an API token
+	// instead of a password.
+	IsBot   bool   `gorm:"not null;default:false" json:"bot"`
+	OwnerID string `gorm:"type:varchar(26);index" json:"owner_id,omitempty"`
+
+	// Remote users live on another rio instance and act here through
+	// federation. Their Username is user@instance and they have no
+	// password; RemoteID is their ID on the home instance.
+	Instance string `gorm:"size:255;index" json:"instance,omitempty"`
+	RemoteID string `gorm:"type:varchar(26)" json:"-"`
 }
@@ -5,8 +5,12 @@ import (
 )
 
 type UserServer struct {
-	UserID   string    `gorm:"primaryKey;type:varchar(26)"`
-	ServerID string    `gorm:"primaryKey;type:varchar(26)"`
+	UserID   string    `gorm:"primary_key;type:varchar(26)"`
+	ServerID string    `gorm:"primary_key;type:varchar(26)"`
 	Role     string    `gorm:"type:varchar(20);not null;default:'member'"`
 	JoinedAt time.Time `gorm:"autoCreateTime"`
+
+	// A member with TimeoutUntil in the future cannot post messages or
+	// invoke commands.
+	TimeoutUntil *time.Time `json:"timeout_until,omitempty"`
 }
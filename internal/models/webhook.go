@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Webhook is an HTTPS endpoint a server's admins registered to receive
+// events. Events holds the subscribed event types, comma-separated.
+type Webhook struct {
+	gorm.Model
+	ULID       string   `gorm:"type:varchar(26);unique;not null" json:"id"`
+	ServerID   string   `gorm:"type:varchar(26);index;not null" json:"server_id"`
+	URL        string   `gorm:"size:2048;not null" json:"url"`
+	Secret     string   `gorm:"size:64;not null" json:"-"`
+	Events     string   `gorm:"size:255;not null" json:"-"`
+	Active     bool     `gorm:"not null" json:"active"`
+	CreatedBy  string   `gorm:"type:varchar(26)" json:"created_by"`
+	EventTypes []string `gorm:"-" json:"events"`
+}
+
+// Webhook delivery states. A delivery that keeps failing ends up dead, which
+// is the dead-letter list admins can inspect and redeliver from.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryDead      = "dead"
+)
+
+type WebhookDelivery struct {
+	gorm.Model
+	ULID           string     `gorm:"type:varchar(26);unique;not null" json:"id"`
+	WebhookID      string     `gorm:"type:varchar(26);index;not null" json:"webhook_id"`
+	EventID        string     `gorm:"type:varchar(26);not null" json:"event_id"`
+	EventType      string     `gorm:"size:50;not null" json:"event_type"`
+	Payload        string     `gorm:"type:text;not null" json:"payload"`
+	Status         string     `gorm:"size:20;index;not null" json:"status"`
+	Attempts       int        `gorm:"not null" json:"attempts"`
+	NextAttemptAt  *time.Time `gorm:"index" json:"next_attempt_at,omitempty"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `gorm:"size:512" json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"rio/internal/db"
+	"rio/internal/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+const dbSweepInterval = 10 * time.Minute
+
+// DBStore keeps buckets in the database so every rio instance shares them.
+// Each Take locks the bucket's row for the length of one transaction.
+type DBStore struct {
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewDBStore() *DBStore {
+	return &DBStore{lastSweep: time.Now()}
+}
+
+func (s *DBStore) Take(key string, limit Limit) (Result, error) {
+	s.sweep()
+
+	res, err := s.take(key, limit)
+	if err != nil {
+		// Two instances may race to create the same bucket; the loser's
+		// insert fails and a second attempt finds the winner's row.
+		res, err = s.take(key, limit)
+	}
+	return res, err
+}
+
+func (s *DBStore) take(key string, limit Limit) (Result, error) {
+	var res Result
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		// SQLite has no FOR UPDATE; its transactions lock the whole
+		// database when they begin instead.
+		query := tx
+		if db.Dialect() != db.SQLite {
+			query = tx.Set("gorm:query_option", "FOR UPDATE")
+		}
+
+		var b models.RateLimitBucket
+		err := query.Where("bucket_key = ?", key).First(&b).Error
+
+		exists := true
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			exists = false
+			b = models.RateLimitBucket{BucketKey: key, Tokens: float64(limit.Requests), RefilledAt: now.UnixNano()}
+		}
+
+		b.Tokens, res = take(b.Tokens, time.Unix(0, b.RefilledAt), now, limit)
+		b.RefilledAt = now.UnixNano()
+		b.ResetAt = now.Add(res.Reset).UnixNano()
+
+		if !exists {
+			return tx.Create(&b).Error
+		}
+		return tx.Model(&models.RateLimitBucket{}).
+			Where("bucket_key = ?", key).
+			Updates(map[string]interface{}{
+				"tokens":      b.Tokens,
+				"refilled_at": b.RefilledAt,
+				"reset_at":    b.ResetAt,
+			}).Error
+	})
+	return res, err
+}
+
+// sweep deletes buckets that have refilled completely, at most once per
+// dbSweepInterval per instance.
+func (s *DBStore) sweep() {
+	s.mu.Lock()
+	if time.Since(s.lastSweep) < dbSweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = time.Now()
+	s.mu.Unlock()
+
+	db.DB.Where("reset_at < ?", time.Now().UnixNano()).Delete(&models.RateLimitBucket{})
+}
@@ -0,0 +1,14 @@
+//go:build cgo
+
+package ratelimit
+
+import (
+	"testing"
+
+	"rio/internal/db/dbtest"
+)
+
+func TestDBStore(t *testing.T) {
+	dbtest.Open(t)
+	testStore(t, NewDBStore())
+}
@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+const memorySweepInterval = 10 * time.Minute
+
+type memoryBucket struct {
+	tokens  float64
+	last    time.Time
+	resetAt time.Time
+}
+
+// MemoryStore keeps buckets in process memory. Each rio instance enforces
+// its own limits, so use the db store when running more than one.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:   make(map[string]*memoryBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *MemoryStore) Take(key string, limit Limit) (Result, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A bucket that has refilled completely is the same as a missing one,
+	// so those can be dropped.
+	if now.Sub(s.lastSweep) > memorySweepInterval {
+		for k, b := range s.buckets {
+			if now.After(b.resetAt) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: float64(limit.Requests), last: now}
+		s.buckets[key] = b
+	}
+
+	tokens, res := take(b.tokens, b.last, now, limit)
+	b.tokens = tokens
+	b.last = now
+	b.resetAt = now.Add(res.Reset)
+	return res, nil
+}
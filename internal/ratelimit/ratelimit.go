@@ -0,0 +1,141 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit describes a token bucket: it holds up to Requests tokens and
+// refills completely over Per, so short bursts are allowed as long as the
+// average rate stays under Requests per Per.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Per)
+}
+
+func (l Limit) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText parses a limit as ParseLimit does.
+func (l *Limit) UnmarshalText(text []byte) error {
+	parsed, err := ParseLimit(string(text))
+	if err != nil {
+		return err
+	}
+	*l = parsed
+	return nil
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until the next token is available. It is zero
+	// when the request was allowed.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// Store holds bucket state. Take removes one token from the bucket named
+// key, creating a full bucket if it does not exist yet.
+type Store interface {
+	Take(key string, limit Limit) (Result, error)
+}
+
+// take applies the token bucket rules to a bucket that held tokens at
+// last, and returns its new token count along with the result.
+func take(tokens float64, last, now time.Time, limit Limit) (float64, Result) {
+	capacity := float64(limit.Requests)
+	rate := capacity / limit.Per.Seconds()
+
+	if elapsed := now.Sub(last).Seconds(); elapsed > 0 {
+		tokens = math.Min(capacity, tokens+elapsed*rate)
+	}
+
+	res := Result{Limit: limit.Requests}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = secondsToDuration((1 - tokens) / rate)
+	}
+
+	res.Remaining = int(math.Floor(tokens))
+	res.Reset = secondsToDuration((capacity - tokens) / rate)
+	return tokens, res
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// ParseLimit parses a limit written as "<requests>/<duration>", such as
+// "30/1m" or "5/1h".
+func ParseLimit(s string) (Limit, error) {
+	count, per, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q; want <requests>/<duration>", s)
+	}
+
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 1 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: request count must be a positive integer", s)
+	}
+
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: period must be a positive duration", s)
+	}
+
+	return Limit{Requests: n, Per: d}, nil
+}
+
+// Buckets used by the router. Every request counts against Default; the
+// others apply on top of it to routes that need stricter limits.
+const (
+	Default       = "default"
+	Register      = "register"
+	Login         = "login"
+	MessageCreate = "message_create"
+	InviteJoin    = "invite_join"
+	WebhookPost   = "webhook_post"
+)
+
+var defaultLimits = map[string]Limit{
+	Default:       {Requests: 120, Per: time.Minute},
+	Register:      {Requests: 5, Per: time.Hour},
+	Login:         {Requests: 20, Per: time.Minute},
+	MessageCreate: {Requests: 30, Per: time.Minute},
+	InviteJoin:    {Requests: 10, Per: time.Hour},
+	WebhookPost:   {Requests: 30, Per: time.Minute},
+}
+
+// DefaultLimit returns the limit of the named bucket when it is not
+// configured.
+func DefaultLimit(bucket string) Limit {
+	return defaultLimits[bucket]
+}
+
+// NewStore builds the store of the given kind: "memory" (or empty) keeps
+// buckets in this process, "db" keeps them in the database so limits hold
+// across every rio instance.
+func NewStore(kind string) (Store, error) {
+	switch kind {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "db":
+		return NewDBStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q; must be memory or db", kind)
+	}
+}
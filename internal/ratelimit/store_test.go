@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// testStore checks the behaviour both stores share.
+func testStore(t *testing.T, s Store) {
+	limit := Limit{Requests: 2, Per: time.Minute}
+
+	take := func(t *testing.T, key string) Result {
+		t.Helper()
+		res, err := s.Take(key, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	t.Run("a bucket runs dry and refills", func(t *testing.T) {
+		for want := 1; want >= 0; want-- {
+			res := take(t, "drained")
+			if !res.Allowed || res.Remaining != want || res.Limit != 2 || res.RetryAfter != 0 {
+				t.Fatalf("Take = %+v; want it allowed with %d left", res, want)
+			}
+		}
+		res := take(t, "drained")
+		if res.Allowed || res.Remaining != 0 {
+			t.Fatalf("Take from an empty bucket = %+v; want it refused", res)
+		}
+		// One token comes back every 30s.
+		if res.RetryAfter <= 25*time.Second || res.RetryAfter > 30*time.Second {
+			t.Fatalf("RetryAfter = %s; want just under 30s", res.RetryAfter)
+		}
+		if res.Reset <= 55*time.Second || res.Reset > time.Minute {
+			t.Fatalf("Reset = %s; want just under a minute", res.Reset)
+		}
+	})
+
+	t.Run("buckets are independent", func(t *testing.T) {
+		take(t, "busy")
+		take(t, "busy")
+		if res := take(t, "idle"); !res.Allowed || res.Remaining != 1 {
+			t.Fatalf("Take from a fresh bucket = %+v; want it full", res)
+		}
+	})
+
+	t.Run("concurrent takes share one bucket", func(t *testing.T) {
+		const workers = 8
+		limit := Limit{Requests: workers, Per: time.Hour}
+		var wg sync.WaitGroup
+		errs := make(chan error, workers)
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res, err := s.Take("shared", limit)
+				if err == nil && !res.Allowed {
+					t.Errorf("Take %+v; want all %d allowed", res, workers)
+				}
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		res, err := s.Take("shared", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Allowed {
+			t.Fatalf("Take after %d concurrent ones = %+v; want it refused", workers, res)
+		}
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
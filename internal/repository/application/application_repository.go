@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+	"rio/internal/models"
+)
+
+type ApplicationRepository interface {
+	Create(ctx context.Context, app *models.Application) error
+	GetApplicationByID(ctx context.Context, ulid string) (*models.Application, error)
+	GetApplicationByBotID(ctx context.Context, botID string) (*models.Application, error)
+	GetApplicationByTokenHash(ctx context.Context, hash string) (*models.Application, error)
+	GetApplicationsByOwner(ctx context.Context, u_id string) ([]*models.Application, error)
+	UpdateTokenHash(ctx context.Context, ulid, hash string) error
+	UpdateInteractionsEndpoint(ctx context.Context, ulid, url, secret string) error
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"rio/internal/apperr"
+	"rio/internal/db"
+	"rio/internal/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+type DBApplicationRepository struct{}
+
+func NewDBApplicationRepository() *DBApplicationRepository {
+	return &DBApplicationRepository{}
+}
+
+func (r *DBApplicationRepository) Create(ctx context.Context, app *models.Application) error {
+	err := db.WithContext(ctx).Create(app).Error
+	if db.IsUniqueViolation(err) {
+		return apperr.Conflict("application already exists")
+	}
+	return err
+}
+
+func (r *DBApplicationRepository) findOne(ctx context.Context, query string, arg interface{}) (*models.Application, error) {
+	var app models.Application
+	err := db.WithContext(ctx).Where(query, arg).First(&app).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &app, nil
+}
+
+func (r *DBApplicationRepository) GetApplicationByID(ctx context.Context, ulid string) (*models.Application, error) {
+	return r.findOne(ctx, "ul_id = ?", ulid)
+}
+
+func (r *DBApplicationRepository) GetApplicationByBotID(ctx context.Context, botID string) (*models.Application, error) {
+	return r.findOne(ctx, "bot_id = ?", botID)
+}
+
+func (r *DBApplicationRepository) GetApplicationByTokenHash(ctx context.Context, hash string) (*models.Application, error) {
+	return r.findOne(ctx, "token_hash = ?", hash)
+}
+
+func (r *DBApplicationRepository) GetApplicationsByOwner(ctx context.Context, u_id string) ([]*models.Application, error) {
+	var apps []*models.Application
+	err := db.WithContext(ctx).Where("owner_id = ?", u_id).Order("created_at").Find(&apps).Error
+	if err != nil {
+		return nil, err
+	}
+	return apps, nil
+}
+
+func (r *DBApplicationRepository) UpdateTokenHash(ctx context.Context, ulid, hash string) error {
+	result := db.WithContext(ctx).Model(&models.Application{}).Where("ul_id = ?", ulid).Update("token_hash", hash)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.NotFound("application not found")
+	}
+
+	return nil
+}
+
+func (r *DBApplicationRepository) UpdateInteractionsEndpoint(ctx context.Context, ulid, url, secret string) error {
+	result := db.WithContext(ctx).Model(&models.Application{}).Where("ul_id = ?", ulid).Updates(map[string]interface{}{
+		"interactions_url":    url,
+		"interactions_secret": secret,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return apperr.NotFound("application not found")
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+)
+
+type InMemoryApplicationRepository struct {
+	store *store.Store
+}
+
+func NewInMemoryApplicationRepository(s *store.Store) *InMemoryApplicationRepository {
+	return &InMemoryApplicationRepository{store: s}
+}
+
+func (r *InMemoryApplicationRepository) Create(ctx context.Context, app *models.Application) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		existing := t.Applications.Find(func(a *models.Application) bool {
+			return a.BotID == app.BotID || a.TokenHash == app.TokenHash
+		})
+		if t.Applications.Has(app.ULID) || existing != nil {
+			return apperr.Conflict("application already exists")
+		}
+		t.Stamp(&app.Model)
+		t.Applications.Put(app.ULID, *app)
+		return nil
+	})
+}
+
+func (r *InMemoryApplicationRepository) find(ctx context.Context, match func(a *models.Application) bool) (*models.Application, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.Application {
+		return t.Applications.Find(match)
+	})
+}
+
+func (r *InMemoryApplicationRepository) GetApplicationByID(ctx context.Context, ulid string) (*models.Application, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.Application {
+		return t.Applications.Get(ulid)
+	})
+}
+
+func (r *InMemoryApplicationRepository) GetApplicationByBotID(ctx context.Context, botID string) (*models.Application, error) {
+	return r.find(ctx, func(a *models.Application) bool { return a.BotID == botID })
+}
+
+func (r *InMemoryApplicationRepository) GetApplicationByTokenHash(ctx context.Context, hash string) (*models.Application, error) {
+	return r.find(ctx, func(a *models.Application) bool { return a.TokenHash == hash })
+}
+
+func (r *InMemoryApplicationRepository) GetApplicationsByOwner(ctx context.Context, u_id string) ([]*models.Application, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) []*models.Application {
+		return t.Applications.Filter(func(a *models.Application) bool {
+			return a.OwnerID == u_id
+		}, func(a, b *models.Application) int {
+			return cmp.Compare(a.ID, b.ID)
+		})
+	})
+}
+
+// update applies fn to the stored application with the given ULID.
+func (r *InMemoryApplicationRepository) update(ctx context.Context, ulid string, fn func(a *models.Application)) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		a, ok := t.Applications.Lookup(ulid)
+		if !ok {
+			return apperr.NotFound("application not found")
+		}
+		fn(&a)
+		t.Applications.Put(ulid, a)
+		return nil
+	})
+}
+
+func (r *InMemoryApplicationRepository) UpdateTokenHash(ctx context.Context, ulid, hash string) error {
+	return r.update(ctx, ulid, func(a *models.Application) {
+		a.TokenHash = hash
+	})
+}
+
+func (r *InMemoryApplicationRepository) UpdateInteractionsEndpoint(ctx context.Context, ulid, url, secret string) error {
+	return r.update(ctx, ulid, func(a *models.Application) {
+		a.InteractionsURL = url
+		a.InteractionsSecret = secret
+	})
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// testApplicationRepository checks the behaviour both backends share, down
+// to the errors they fail with.
+func testApplicationRepository(t *testing.T, repo ApplicationRepository) {
+	ctx := context.Background()
+	owner := ulid.Make().String()
+
+	newApp := func(name, botID, hash string) *models.Application {
+		return &models.Application{ULID: ulid.Make().String(), Name: name, OwnerID: owner, BotID: botID, TokenHash: hash}
+	}
+	first := newApp("First", ulid.Make().String(), "t1")
+	second := newApp("Second", ulid.Make().String(), "t2")
+	for _, app := range []*models.Application{first, second} {
+		if err := repo.Create(ctx, app); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("bots and tokens belong to one application", func(t *testing.T) {
+		for name, app := range map[string]*models.Application{
+			"the same bot":   newApp("Bot", first.BotID, "t3"),
+			"the same token": newApp("Token", ulid.Make().String(), "t1"),
+		} {
+			if err := repo.Create(ctx, app); !errors.Is(err, apperr.ErrConflict) {
+				t.Errorf("creating an application with %s: %v; want a conflict", name, err)
+			}
+		}
+	})
+
+	t.Run("lookups", func(t *testing.T) {
+		byID, err := repo.GetApplicationByID(ctx, second.ULID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		byBot, err := repo.GetApplicationByBotID(ctx, second.BotID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		byToken, err := repo.GetApplicationByTokenHash(ctx, "t2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, found := range []*models.Application{byID, byBot, byToken} {
+			if found == nil || found.ULID != second.ULID {
+				t.Fatalf("lookup = %+v; want Second", found)
+			}
+		}
+		if missing, err := repo.GetApplicationByTokenHash(ctx, "unknown"); err != nil || missing != nil {
+			t.Fatalf("GetApplicationByTokenHash(unknown) = %+v, %v; want nil, nil", missing, err)
+		}
+
+		owned, err := repo.GetApplicationsByOwner(ctx, owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(owned) != 2 || owned[0].Name != "First" || owned[1].Name != "Second" {
+			t.Fatalf("GetApplicationsByOwner = %+v; want First then Second", owned)
+		}
+	})
+
+	t.Run("updates", func(t *testing.T) {
+		if err := repo.UpdateTokenHash(ctx, first.ULID, "t4"); err != nil {
+			t.Fatal(err)
+		}
+		if old, err := repo.GetApplicationByTokenHash(ctx, "t1"); err != nil || old != nil {
+			t.Fatalf("GetApplicationByTokenHash(old) = %+v, %v; want the old token gone", old, err)
+		}
+
+		if err := repo.UpdateInteractionsEndpoint(ctx, first.ULID, "https://example.com/i", "secret"); err != nil {
+			t.Fatal(err)
+		}
+		updated, err := repo.GetApplicationByTokenHash(ctx, "t4")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated == nil || updated.InteractionsURL != "https://example.com/i" || updated.InteractionsSecret != "secret" {
+			t.Fatalf("updated application = %+v; want the new token and endpoint", updated)
+		}
+
+		missing := ulid.Make().String()
+		if err := repo.UpdateTokenHash(ctx, missing, "t5"); !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("UpdateTokenHash(unknown) = %v; want not found", err)
+		}
+		if err := repo.UpdateInteractionsEndpoint(ctx, missing, "", ""); !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("UpdateInteractionsEndpoint(unknown) = %v; want not found", err)
+		}
+	})
+}
+
+func TestInMemoryApplicationRepository(t *testing.T) {
+	testApplicationRepository(t, NewInMemoryApplicationRepository(store.New()))
+}
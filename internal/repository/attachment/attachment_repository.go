@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+
+	"rio/internal/models"
+)
+
+type AttachmentRepository interface {
+	Create(ctx context.Context, attachment *models.Attachment) error
+	// GetAttachmentByID returns the attachment along with its data.
+	GetAttachmentByID(ctx context.Context, ulid string) (*models.Attachment, error)
+	// GetAttachmentsByMessages returns the attachments of the given
+	// messages without their data.
+	GetAttachmentsByMessages(ctx context.Context, m_ids []string) ([]*models.Attachment, error)
+}
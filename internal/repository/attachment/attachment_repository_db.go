@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"rio/internal/apperr"
+	"rio/internal/db"
+	"rio/internal/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+// attachmentColumns is every column but data.
+const attachmentColumns = "id, created_at, updated_at, deleted_at, ul_id, message_id, filename, content_type, size"
+
+type DBAttachmentRepository struct{}
+
+func NewDBAttachmentRepository() *DBAttachmentRepository {
+	return &DBAttachmentRepository{}
+}
+
+func (r *DBAttachmentRepository) Create(ctx context.Context, attachment *models.Attachment) error {
+	err := db.WithContext(ctx).Create(attachment).Error
+	if db.IsUniqueViolation(err) {
+		return apperr.Conflict("attachment with this ULID already exists")
+	}
+	return err
+}
+
+func (r *DBAttachmentRepository) GetAttachmentByID(ctx context.Context, ulid string) (*models.Attachment, error) {
+	var a models.Attachment
+	err := db.WithContext(ctx).Where("ul_id = ?", ulid).First(&a).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *DBAttachmentRepository) GetAttachmentsByMessages(ctx context.Context, m_ids []string) ([]*models.Attachment, error) {
+	var attachments []*models.Attachment
+	if len(m_ids) == 0 {
+		return attachments, nil
+	}
+	err := db.WithContext(ctx).Select(attachmentColumns).Where("message_id IN (?)", m_ids).Order("id").Find(&attachments).Error
+	if err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+)
+
+type InMemoryAttachmentRepository struct {
+	store *store.Store
+}
+
+func NewInMemoryAttachmentRepository(s *store.Store) *InMemoryAttachmentRepository {
+	return &InMemoryAttachmentRepository{store: s}
+}
+
+func (r *InMemoryAttachmentRepository) Create(ctx context.Context, attachment *models.Attachment) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		if t.Attachments.Has(attachment.ULID) {
+			return apperr.Conflict("attachment with this ULID already exists")
+		}
+		t.Stamp(&attachment.Model)
+		t.Attachments.Put(attachment.ULID, *attachment)
+		return nil
+	})
+}
+
+func (r *InMemoryAttachmentRepository) GetAttachmentByID(ctx context.Context, ulid string) (*models.Attachment, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.Attachment {
+		return t.Attachments.Get(ulid)
+	})
+}
+
+func (r *InMemoryAttachmentRepository) GetAttachmentsByMessages(ctx context.Context, m_ids []string) ([]*models.Attachment, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) []*models.Attachment {
+		attachments := t.Attachments.Filter(func(a *models.Attachment) bool {
+			return slices.Contains(m_ids, a.MessageID)
+		}, func(a, b *models.Attachment) int {
+			return cmp.Compare(a.ID, b.ID)
+		})
+		for _, a := range attachments {
+			a.Data = nil
+		}
+		return attachments
+	})
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// testAttachmentRepository checks the behaviour both backends share, down
+// to the errors they fail with.
+func testAttachmentRepository(t *testing.T, repo AttachmentRepository) {
+	ctx := context.Background()
+	first, second, other := ulid.Make().String(), ulid.Make().String(), ulid.Make().String()
+
+	var attachments []*models.Attachment
+	for _, message := range []string{first, second, first, other} {
+		a := &models.Attachment{ULID: ulid.Make().String(), MessageID: message, Filename: "a.txt", ContentType: "text/plain", Size: 3, Data: []byte("abc")}
+		if err := repo.Create(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+		attachments = append(attachments, a)
+	}
+
+	again := &models.Attachment{ULID: attachments[0].ULID, MessageID: first, Filename: "b.txt", ContentType: "text/plain"}
+	if err := repo.Create(ctx, again); !errors.Is(err, apperr.ErrConflict) {
+		t.Fatalf("creating an attachment twice: %v; want a conflict", err)
+	}
+
+	found, err := repo.GetAttachmentByID(ctx, attachments[1].ULID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == nil || found.MessageID != second || string(found.Data) != "abc" {
+		t.Fatalf("GetAttachmentByID = %+v; want the second message's attachment with its data", found)
+	}
+	if missing, err := repo.GetAttachmentByID(ctx, ulid.Make().String()); err != nil || missing != nil {
+		t.Fatalf("GetAttachmentByID(unknown) = %+v, %v; want nil, nil", missing, err)
+	}
+
+	listed, err := repo.GetAttachmentsByMessages(ctx, []string{first, second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 3 {
+		t.Fatalf("GetAttachmentsByMessages = %d attachments; want the 3 of the two messages", len(listed))
+	}
+	for i, a := range listed {
+		if a.ULID != attachments[i].ULID || a.Filename != "a.txt" || a.Size != 3 || a.Data != nil {
+			t.Errorf("attachment %d = %+v; want %s without its data", i, a, attachments[i].ULID)
+		}
+	}
+
+	if none, err := repo.GetAttachmentsByMessages(ctx, nil); err != nil || len(none) != 0 {
+		t.Fatalf("GetAttachmentsByMessages(none) = %+v, %v; want none", none, err)
+	}
+}
+
+func TestInMemoryAttachmentRepository(t *testing.T) {
+	testAttachmentRepository(t, NewInMemoryAttachmentRepository(store.New()))
+}
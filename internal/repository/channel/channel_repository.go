@@ -0,0 +1,13 @@
+package repository
+
+import (
+	"context"
+
+	"rio/internal/models"
+)
+
+type ChannelRepository interface {
+	Create(ctx context.Context, channel *models.Channel) error
+	GetChannelByID(ctx context.Context, ulid string) (*models.Channel, error)
+	GetChannelsByServer(ctx context.Context, s_id string) ([]*models.Channel, error)
+}
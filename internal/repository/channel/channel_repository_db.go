@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"rio/internal/apperr"
+	"rio/internal/db"
+	"rio/internal/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+type DBChannelRepository struct{}
+
+func NewDBChannelRepository() *DBChannelRepository {
+	return &DBChannelRepository{}
+}
+
+func (r *DBChannelRepository) Create(ctx context.Context, channel *models.Channel) error {
+	err := db.WithContext(ctx).Create(channel).Error
+	if db.IsUniqueViolation(err) {
+		return apperr.Conflict("channel with this ULID already exists")
+	}
+	return err
+}
+
+func (r *DBChannelRepository) GetChannelByID(ctx context.Context, ulid string) (*models.Channel, error) {
+	var c models.Channel
+	err := db.WithContext(ctx).Where("ul_id = ?", ulid).First(&c).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *DBChannelRepository) GetChannelsByServer(ctx context.Context, s_id string) ([]*models.Channel, error) {
+	var channels []*models.Channel
+	err := db.WithContext(ctx).Where("server_id = ?", s_id).Order("created_at").Find(&channels).Error
+	if err != nil {
+		return nil, err
+	}
+	return channels, nil
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+)
+
+type InMemoryChannelRepository struct {
+	store *store.Store
+}
+
+func NewInMemoryChannelRepository(s *store.Store) *InMemoryChannelRepository {
+	return &InMemoryChannelRepository{store: s}
+}
+
+func (r *InMemoryChannelRepository) Create(ctx context.Context, channel *models.Channel) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		if t.Channels.Has(channel.ULID) {
+			return apperr.Conflict("channel with this ULID already exists")
+		}
+		t.Stamp(&channel.Model)
+		t.Channels.Put(channel.ULID, *channel)
+		return nil
+	})
+}
+
+func (r *InMemoryChannelRepository) GetChannelByID(ctx context.Context, ulid string) (*models.Channel, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.Channel {
+		return t.Channels.Get(ulid)
+	})
+}
+
+func (r *InMemoryChannelRepository) GetChannelsByServer(ctx context.Context, s_id string) ([]*models.Channel, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) []*models.Channel {
+		return t.Channels.Filter(func(c *models.Channel) bool {
+			return c.ServerID == s_id
+		}, func(a, b *models.Channel) int {
+			return cmp.Compare(a.ID, b.ID)
+		})
+	})
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// testChannelRepository checks the behaviour both backends share, down to
+// the errors they fail with.
+func testChannelRepository(t *testing.T, repo ChannelRepository) {
+	ctx := context.Background()
+	server := ulid.Make().String()
+
+	var channels []*models.Channel
+	for _, name := range []string{"general", "random"} {
+		channel := &models.Channel{ULID: ulid.Make().String(), ServerID: server, Name: name}
+		if err := repo.Create(ctx, channel); err != nil {
+			t.Fatal(err)
+		}
+		channels = append(channels, channel)
+	}
+	if err := repo.Create(ctx, &models.Channel{ULID: ulid.Make().String(), ServerID: ulid.Make().String(), Name: "elsewhere"}); err != nil {
+		t.Fatal(err)
+	}
+
+	again := &models.Channel{ULID: channels[0].ULID, ServerID: server, Name: "again"}
+	if err := repo.Create(ctx, again); !errors.Is(err, apperr.ErrConflict) {
+		t.Fatalf("creating a channel twice: %v; want a conflict", err)
+	}
+
+	found, err := repo.GetChannelByID(ctx, channels[1].ULID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == nil || found.Name != "random" || found.ServerID != server {
+		t.Fatalf("GetChannelByID = %+v; want random", found)
+	}
+	if missing, err := repo.GetChannelByID(ctx, ulid.Make().String()); err != nil || missing != nil {
+		t.Fatalf("GetChannelByID(unknown) = %+v, %v; want nil, nil", missing, err)
+	}
+
+	listed, err := repo.GetChannelsByServer(ctx, server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 2 || listed[0].Name != "general" || listed[1].Name != "random" {
+		t.Fatalf("GetChannelsByServer = %+v; want general then random", listed)
+	}
+}
+
+func TestInMemoryChannelRepository(t *testing.T) {
+	testChannelRepository(t, NewInMemoryChannelRepository(store.New()))
+}
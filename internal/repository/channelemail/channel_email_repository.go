@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+
+	"rio/internal/models"
+)
+
+type ChannelEmailRepository interface {
+	Create(ctx context.Context, email *models.ChannelEmail) error
+	GetChannelEmailByLocalPart(ctx context.Context, localPart string) (*models.ChannelEmail, error)
+	GetChannelEmailsByChannel(ctx context.Context, c_id string) ([]*models.ChannelEmail, error)
+	DeleteChannelEmail(ctx context.Context, c_id, ulid string) error
+}
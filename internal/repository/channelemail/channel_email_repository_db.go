@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"rio/internal/apperr"
+	"rio/internal/db"
+	"rio/internal/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+type DBChannelEmailRepository struct{}
+
+func NewDBChannelEmailRepository() *DBChannelEmailRepository {
+	return &DBChannelEmailRepository{}
+}
+
+func (r *DBChannelEmailRepository) Create(ctx context.Context, email *models.ChannelEmail) error {
+	err := db.WithContext(ctx).Create(email).Error
+	if db.IsUniqueViolation(err) {
+		return apperr.Conflict("email address already exists")
+	}
+	return err
+}
+
+func (r *DBChannelEmailRepository) GetChannelEmailByLocalPart(ctx context.Context, localPart string) (*models.ChannelEmail, error) {
+	var e models.ChannelEmail
+	err := db.WithContext(ctx).Where("local_part = ?", localPart).First(&e).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *DBChannelEmailRepository) GetChannelEmailsByChannel(ctx context.Context, c_id string) ([]*models.ChannelEmail, error) {
+	var emails []*models.ChannelEmail
+	err := db.WithContext(ctx).Where("channel_id = ?", c_id).Order("created_at").Find(&emails).Error
+	if err != nil {
+		return nil, err
+	}
+	return emails, nil
+}
+
+func (r *DBChannelEmailRepository) DeleteChannelEmail(ctx context.Context, c_id, ulid string) error {
+	result := db.WithContext(ctx).Where("channel_id = ? AND ul_id = ?", c_id, ulid).Delete(&models.ChannelEmail{})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.NotFound("email address not found")
+	}
+
+	return nil
+}
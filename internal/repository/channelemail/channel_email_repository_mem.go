@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+)
+
+type InMemoryChannelEmailRepository struct {
+	store *store.Store
+}
+
+func NewInMemoryChannelEmailRepository(s *store.Store) *InMemoryChannelEmailRepository {
+	return &InMemoryChannelEmailRepository{store: s}
+}
+
+func (r *InMemoryChannelEmailRepository) Create(ctx context.Context, email *models.ChannelEmail) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		existing := t.ChannelEmails.Find(func(e *models.ChannelEmail) bool {
+			return e.LocalPart == email.LocalPart
+		})
+		if t.ChannelEmails.Has(email.ULID) || existing != nil {
+			return apperr.Conflict("email address already exists")
+		}
+		t.Stamp(&email.Model)
+		t.ChannelEmails.Put(email.ULID, *email)
+		return nil
+	})
+}
+
+func (r *InMemoryChannelEmailRepository) GetChannelEmailByLocalPart(ctx context.Context, localPart string) (*models.ChannelEmail, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.ChannelEmail {
+		return t.ChannelEmails.Find(func(e *models.ChannelEmail) bool {
+			return e.LocalPart == localPart
+		})
+	})
+}
+
+func (r *InMemoryChannelEmailRepository) GetChannelEmailsByChannel(ctx context.Context, c_id string) ([]*models.ChannelEmail, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) []*models.ChannelEmail {
+		return t.ChannelEmails.Filter(func(e *models.ChannelEmail) bool {
+			return e.ChannelID == c_id
+		}, func(a, b *models.ChannelEmail) int {
+			return cmp.Compare(a.ID, b.ID)
+		})
+	})
+}
+
+func (r *InMemoryChannelEmailRepository) DeleteChannelEmail(ctx context.Context, c_id, ulid string) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		e, ok := t.ChannelEmails.Lookup(ulid)
+		if !ok || e.ChannelID != c_id {
+			return apperr.NotFound("email address not found")
+		}
+		t.ChannelEmails.Delete(ulid)
+		return nil
+	})
+}
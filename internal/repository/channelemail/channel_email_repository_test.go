@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// testChannelEmailRepository checks the behaviour both backends share, down
+// to the errors they fail with.
+func testChannelEmailRepository(t *testing.T, repo ChannelEmailRepository) {
+	ctx := context.Background()
+	channel, other, server := ulid.Make().String(), ulid.Make().String(), ulid.Make().String()
+
+	var emails []*models.ChannelEmail
+	for i, c := range []string{channel, other, channel} {
+		e := &models.ChannelEmail{ULID: ulid.Make().String(), ChannelID: c, ServerID: server, Name: "inbox", LocalPart: "inbox" + string(rune('a'+i)), MaxSize: 1024}
+		if err := repo.Create(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+		emails = append(emails, e)
+	}
+
+	taken := &models.ChannelEmail{ULID: ulid.Make().String(), ChannelID: channel, ServerID: server, Name: "again", LocalPart: emails[1].LocalPart}
+	if err := repo.Create(ctx, taken); !errors.Is(err, apperr.ErrConflict) {
+		t.Fatalf("creating an address whose local part is taken: %v; want a conflict", err)
+	}
+
+	found, err := repo.GetChannelEmailByLocalPart(ctx, emails[1].LocalPart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == nil || found.ULID != emails[1].ULID || found.ChannelID != other || found.MaxSize != 1024 {
+		t.Fatalf("GetChannelEmailByLocalPart = %+v; want %s", found, emails[1].ULID)
+	}
+	if missing, err := repo.GetChannelEmailByLocalPart(ctx, "nobody"); err != nil || missing != nil {
+		t.Fatalf("GetChannelEmailByLocalPart(unknown) = %+v, %v; want nil, nil", missing, err)
+	}
+
+	listed, err := repo.GetChannelEmailsByChannel(ctx, channel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 2 || listed[0].ULID != emails[0].ULID || listed[1].ULID != emails[2].ULID {
+		t.Fatalf("GetChannelEmailsByChannel = %+v; want the channel's two addresses in order", listed)
+	}
+
+	if err := repo.DeleteChannelEmail(ctx, channel, emails[1].ULID); !errors.Is(err, apperr.ErrNotFound) {
+		t.Fatalf("deleting another channel's address: %v; want not found", err)
+	}
+	if err := repo.DeleteChannelEmail(ctx, channel, emails[0].ULID); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteChannelEmail(ctx, channel, emails[0].ULID); !errors.Is(err, apperr.ErrNotFound) {
+		t.Fatalf("deleting an address twice: %v; want not found", err)
+	}
+	if gone, err := repo.GetChannelEmailByLocalPart(ctx, emails[0].LocalPart); err != nil || gone != nil {
+		t.Fatalf("GetChannelEmailByLocalPart after deleting = %+v, %v; want nil, nil", gone, err)
+	}
+}
+
+func TestInMemoryChannelEmailRepository(t *testing.T) {
+	testChannelEmailRepository(t, NewInMemoryChannelEmailRepository(store.New()))
+}
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+
+	"rio/internal/models"
+)
+
+type CommandRepository interface {
+	Create(ctx context.Context, command *models.ApplicationCommand) error
+	GetCommandByID(ctx context.Context, ulid string) (*models.ApplicationCommand, error)
+	GetCommandByName(ctx context.Context, s_id, name string) (*models.ApplicationCommand, error)
+	GetCommandsByServer(ctx context.Context, s_id string) ([]*models.ApplicationCommand, error)
+	UpdateCommand(ctx context.Context, command *models.ApplicationCommand) error
+	DeleteCommand(ctx context.Context, ulid string) error
+}
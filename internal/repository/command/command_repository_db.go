@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"rio/internal/apperr"
+	"rio/internal/db"
+	"rio/internal/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+type DBCommandRepository struct{}
+
+func NewDBCommandRepository() *DBCommandRepository {
+	return &DBCommandRepository{}
+}
+
+func (r *DBCommandRepository) Create(ctx context.Context, command *models.ApplicationCommand) error {
+	err := db.WithContext(ctx).Create(command).Error
+	if db.IsUniqueViolation(err) {
+		return apperr.Conflict("command already exists")
+	}
+	return err
+}
+
+func (r *DBCommandRepository) findOne(ctx context.Context, query string, args ...interface{}) (*models.ApplicationCommand, error) {
+	var command models.ApplicationCommand
+	err := db.WithContext(ctx).Where(query, args...).First(&command).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &command, nil
+}
+
+func (r *DBCommandRepository) GetCommandByID(ctx context.Context, ulid string) (*models.ApplicationCommand, error) {
+	return r.findOne(ctx, "ul_id = ?", ulid)
+}
+
+func (r *DBCommandRepository) GetCommandByName(ctx context.Context, s_id, name string) (*models.ApplicationCommand, error) {
+	return r.findOne(ctx, "server_id = ? AND name = ?", s_id, name)
+}
+
+func (r *DBCommandRepository) GetCommandsByServer(ctx context.Context, s_id string) ([]*models.ApplicationCommand, error) {
+	var commands []*models.ApplicationCommand
+	err := db.WithContext(ctx).Where("server_id = ?", s_id).Order("name").Find(&commands).Error
+	if err != nil {
+		return nil, err
+	}
+	return commands, nil
+}
+
+func (r *DBCommandRepository) UpdateCommand(ctx context.Context, command *models.ApplicationCommand) error {
+	result := db.WithContext(ctx).Model(&models.ApplicationCommand{}).Where("ul_id = ?", command.ULID).Updates(map[string]interface{}{
+		"description": command.Description,
+		"options":     command.Options,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return apperr.NotFound("command not found")
+	}
+	return nil
+}
+
+func (r *DBCommandRepository) DeleteCommand(ctx context.Context, ulid string) error {
+	result := db.WithContext(ctx).Where("ul_id = ?", ulid).Delete(&models.ApplicationCommand{})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.NotFound("command not found")
+	}
+
+	return nil
+}
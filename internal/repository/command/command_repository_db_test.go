@@ -0,0 +1,14 @@
+//go:build cgo
+
+package repository
+
+import (
+	"testing"
+
+	"rio/internal/db/dbtest"
+)
+
+func TestDBCommandRepository(t *testing.T) {
+	dbtest.Open(t)
+	testCommandRepository(t, NewDBCommandRepository())
+}
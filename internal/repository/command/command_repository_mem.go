@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+)
+
+type InMemoryCommandRepository struct {
+	store *store.Store
+}
+
+func NewInMemoryCommandRepository(s *store.Store) *InMemoryCommandRepository {
+	return &InMemoryCommandRepository{store: s}
+}
+
+func (r *InMemoryCommandRepository) Create(ctx context.Context, command *models.ApplicationCommand) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		existing := t.Commands.Find(func(c *models.ApplicationCommand) bool {
+			return c.ServerID == command.ServerID && c.Name == command.Name
+		})
+		if t.Commands.Has(command.ULID) || existing != nil {
+			return apperr.Conflict("command already exists")
+		}
+		t.Stamp(&command.Model)
+		t.Commands.Put(command.ULID, *command)
+		return nil
+	})
+}
+
+func (r *InMemoryCommandRepository) GetCommandByID(ctx context.Context, ulid string) (*models.ApplicationCommand, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.ApplicationCommand {
+		return t.Commands.Get(ulid)
+	})
+}
+
+func (r *InMemoryCommandRepository) GetCommandByName(ctx context.Context, s_id, name string) (*models.ApplicationCommand, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.ApplicationCommand {
+		return t.Commands.Find(func(c *models.ApplicationCommand) bool {
+			return c.ServerID == s_id && c.Name == name
+		})
+	})
+}
+
+func (r *InMemoryCommandRepository) GetCommandsByServer(ctx context.Context, s_id string) ([]*models.ApplicationCommand, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) []*models.ApplicationCommand {
+		return t.Commands.Filter(func(c *models.ApplicationCommand) bool {
+			return c.ServerID == s_id
+		}, func(a, b *models.ApplicationCommand) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+	})
+}
+
+func (r *InMemoryCommandRepository) UpdateCommand(ctx context.Context, command *models.ApplicationCommand) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		c, ok := t.Commands.Lookup(command.ULID)
+		if !ok {
+			return apperr.NotFound("command not found")
+		}
+		c.Description = command.Description
+		c.Options = command.Options
+		t.Commands.Put(command.ULID, c)
+		return nil
+	})
+}
+
+func (r *InMemoryCommandRepository) DeleteCommand(ctx context.Context, ulid string) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		if !t.Commands.Has(ulid) {
+			return apperr.NotFound("command not found")
+		}
+		t.Commands.Delete(ulid)
+		return nil
+	})
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// testCommandRepository checks the behaviour both backends share, down to
+// the errors they fail with. Only the memory backend refuses a second
+// command of the same name; CommandService looks the name up first.
+func testCommandRepository(t *testing.T, repo CommandRepository) {
+	ctx := context.Background()
+	server, other, app := ulid.Make().String(), ulid.Make().String(), ulid.Make().String()
+
+	var commands []*models.ApplicationCommand
+	for _, c := range []struct{ server, name string }{{server, "roll"}, {server, "echo"}, {other, "roll"}} {
+		command := &models.ApplicationCommand{ULID: ulid.Make().String(), ApplicationID: app, ServerID: c.server, Name: c.name, Description: "d", Options: "[]"}
+		if err := repo.Create(ctx, command); err != nil {
+			t.Fatal(err)
+		}
+		commands = append(commands, command)
+	}
+
+	again := &models.ApplicationCommand{ULID: commands[0].ULID, ApplicationID: app, ServerID: server, Name: "ping", Description: "d"}
+	if err := repo.Create(ctx, again); !errors.Is(err, apperr.ErrConflict) {
+		t.Fatalf("creating a command twice: %v; want a conflict", err)
+	}
+
+	found, err := repo.GetCommandByID(ctx, commands[2].ULID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == nil || found.ServerID != other || found.Name != "roll" {
+		t.Fatalf("GetCommandByID = %+v; want %s", found, commands[2].ULID)
+	}
+	if missing, err := repo.GetCommandByID(ctx, ulid.Make().String()); err != nil || missing != nil {
+		t.Fatalf("GetCommandByID(unknown) = %+v, %v; want nil, nil", missing, err)
+	}
+
+	byName, err := repo.GetCommandByName(ctx, server, "roll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byName == nil || byName.ULID != commands[0].ULID {
+		t.Fatalf("GetCommandByName(roll) = %+v; want the server's own roll, %s", byName, commands[0].ULID)
+	}
+	if missing, err := repo.GetCommandByName(ctx, other, "echo"); err != nil || missing != nil {
+		t.Fatalf("GetCommandByName(another server's command) = %+v, %v; want nil, nil", missing, err)
+	}
+
+	listed, err := repo.GetCommandsByServer(ctx, server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 2 || listed[0].Name != "echo" || listed[1].Name != "roll" {
+		t.Fatalf("GetCommandsByServer = %+v; want echo and roll, by name", listed)
+	}
+
+	update := &models.ApplicationCommand{ULID: commands[0].ULID, Name: "ignored", Description: "rolls dice", Options: `[{"name":"sides"}]`}
+	if err := repo.UpdateCommand(ctx, update); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := repo.GetCommandByID(ctx, commands[0].ULID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "roll" || updated.Description != "rolls dice" || updated.Options != `[{"name":"sides"}]` {
+		t.Fatalf("command after UpdateCommand = %+v; want only its description and options changed", updated)
+	}
+	if err := repo.UpdateCommand(ctx, &models.ApplicationCommand{ULID: ulid.Make().String(), Description: "d"}); !errors.Is(err, apperr.ErrNotFound) {
+		t.Fatalf("updating an unknown command: %v; want not found", err)
+	}
+
+	if err := repo.DeleteCommand(ctx, commands[0].ULID); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteCommand(ctx, commands[0].ULID); !errors.Is(err, apperr.ErrNotFound) {
+		t.Fatalf("deleting a command twice: %v; want not found", err)
+	}
+	if gone, err := repo.GetCommandByName(ctx, server, "roll"); err != nil || gone != nil {
+		t.Fatalf("GetCommandByName after deleting = %+v, %v; want nil, nil", gone, err)
+	}
+}
+
+func TestInMemoryCommandRepository(t *testing.T) {
+	testCommandRepository(t, NewInMemoryCommandRepository(store.New()))
+}
@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+
+	"rio/internal/models"
+)
+
+type IncomingWebhookRepository interface {
+	Create(ctx context.Context, webhook *models.IncomingWebhook) error
+	GetIncomingWebhookByID(ctx context.Context, ulid string) (*models.IncomingWebhook, error)
+	GetIncomingWebhooksByChannel(ctx context.Context, c_id string) ([]*models.IncomingWebhook, error)
+	DeleteIncomingWebhook(ctx context.Context, c_id, ulid string) error
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"rio/internal/apperr"
+	"rio/internal/db"
+	"rio/internal/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+type DBIncomingWebhookRepository struct{}
+
+func NewDBIncomingWebhookRepository() *DBIncomingWebhookRepository {
+	return &DBIncomingWebhookRepository{}
+}
+
+func (r *DBIncomingWebhookRepository) Create(ctx context.Context, webhook *models.IncomingWebhook) error {
+	err := db.WithContext(ctx).Create(webhook).Error
+	if db.IsUniqueViolation(err) {
+		return apperr.Conflict("webhook with this ULID already exists")
+	}
+	return err
+}
+
+func (r *DBIncomingWebhookRepository) GetIncomingWebhookByID(ctx context.Context, ulid string) (*models.IncomingWebhook, error) {
+	var w models.IncomingWebhook
+	err := db.WithContext(ctx).Where("ul_id = ?", ulid).First(&w).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (r *DBIncomingWebhookRepository) GetIncomingWebhooksByChannel(ctx context.Context, c_id string) ([]*models.IncomingWebhook, error) {
+	var webhooks []*models.IncomingWebhook
+	err := db.WithContext(ctx).Where("channel_id = ?", c_id).Order("created_at").Find(&webhooks).Error
+	if err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (r *DBIncomingWebhookRepository) DeleteIncomingWebhook(ctx context.Context, c_id, ulid string) error {
+	result := db.WithContext(ctx).Where("channel_id = ? AND ul_id = ?", c_id, ulid).Delete(&models.IncomingWebhook{})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.NotFound("webhook not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+)
+
+type InMemoryIncomingWebhookRepository struct {
+	store *store.Store
+}
+
+func NewInMemoryIncomingWebhookRepository(s *store.Store) *InMemoryIncomingWebhookRepository {
+	return &InMemoryIncomingWebhookRepository{store: s}
+}
+
+func (r *InMemoryIncomingWebhookRepository) Create(ctx context.Context, webhook *models.IncomingWebhook) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		if t.IncomingWebhooks.Has(webhook.ULID) {
+			return apperr.Conflict("webhook with this ULID already exists")
+		}
+		t.Stamp(&webhook.Model)
+		t.IncomingWebhooks.Put(webhook.ULID, *webhook)
+		return nil
+	})
+}
+
+func (r *InMemoryIncomingWebhookRepository) GetIncomingWebhookByID(ctx context.Context, ulid string) (*models.IncomingWebhook, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.IncomingWebhook {
+		return t.IncomingWebhooks.Get(ulid)
+	})
+}
+
+func (r *InMemoryIncomingWebhookRepository) GetIncomingWebhooksByChannel(ctx context.Context, c_id string) ([]*models.IncomingWebhook, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) []*models.IncomingWebhook {
+		return t.IncomingWebhooks.Filter(func(w *models.IncomingWebhook) bool {
+			return w.ChannelID == c_id
+		}, func(a, b *models.IncomingWebhook) int {
+			return cmp.Compare(a.ID, b.ID)
+		})
+	})
+}
+
+func (r *InMemoryIncomingWebhookRepository) DeleteIncomingWebhook(ctx context.Context, c_id, ulid string) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		w, ok := t.IncomingWebhooks.Lookup(ulid)
+		if !ok || w.ChannelID != c_id {
+			return apperr.NotFound("webhook not found")
+		}
+		t.IncomingWebhooks.Delete(ulid)
+		return nil
+	})
+}
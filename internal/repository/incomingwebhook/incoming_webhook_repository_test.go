@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// testIncomingWebhookRepository checks the behaviour both backends share,
+// down to the errors they fail with.
+func testIncomingWebhookRepository(t *testing.T, repo IncomingWebhookRepository) {
+	ctx := context.Background()
+	channel, other, server := ulid.Make().String(), ulid.Make().String(), ulid.Make().String()
+
+	var webhooks []*models.IncomingWebhook
+	for _, c := range []string{channel, other, channel} {
+		w := &models.IncomingWebhook{ULID: ulid.Make().String(), ChannelID: c, ServerID: server, Name: "ci", TokenHash: "hash"}
+		if err := repo.Create(ctx, w); err != nil {
+			t.Fatal(err)
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	again := &models.IncomingWebhook{ULID: webhooks[0].ULID, ChannelID: channel, ServerID: server, Name: "again", TokenHash: "hash"}
+	if err := repo.Create(ctx, again); !errors.Is(err, apperr.ErrConflict) {
+		t.Fatalf("creating a webhook twice: %v; want a conflict", err)
+	}
+
+	found, err := repo.GetIncomingWebhookByID(ctx, webhooks[1].ULID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == nil || found.ChannelID != other || found.TokenHash != "hash" {
+		t.Fatalf("GetIncomingWebhookByID = %+v; want %s", found, webhooks[1].ULID)
+	}
+	if missing, err := repo.GetIncomingWebhookByID(ctx, ulid.Make().String()); err != nil || missing != nil {
+		t.Fatalf("GetIncomingWebhookByID(unknown) = %+v, %v; want nil, nil", missing, err)
+	}
+
+	listed, err := repo.GetIncomingWebhooksByChannel(ctx, channel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 2 || listed[0].ULID != webhooks[0].ULID || listed[1].ULID != webhooks[2].ULID {
+		t.Fatalf("GetIncomingWebhooksByChannel = %+v; want the channel's two webhooks in order", listed)
+	}
+
+	if err := repo.DeleteIncomingWebhook(ctx, channel, webhooks[1].ULID); !errors.Is(err, apperr.ErrNotFound) {
+		t.Fatalf("deleting another channel's webhook: %v; want not found", err)
+	}
+	if err := repo.DeleteIncomingWebhook(ctx, channel, webhooks[0].ULID); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteIncomingWebhook(ctx, channel, webhooks[0].ULID); !errors.Is(err, apperr.ErrNotFound) {
+		t.Fatalf("deleting a webhook twice: %v; want not found", err)
+	}
+	if gone, err := repo.GetIncomingWebhookByID(ctx, webhooks[0].ULID); err != nil || gone != nil {
+		t.Fatalf("GetIncomingWebhookByID after deleting = %+v, %v; want nil, nil", gone, err)
+	}
+}
+
+func TestInMemoryIncomingWebhookRepository(t *testing.T) {
+	testIncomingWebhookRepository(t, NewInMemoryIncomingWebhookRepository(store.New()))
+}
@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+
+	"rio/internal/models"
+)
+
+type InteractionRepository interface {
+	Create(ctx context.Context, interaction *models.Interaction) error
+	GetInteractionByID(ctx context.Context, ulid string) (*models.Interaction, error)
+	// UpdateInteractionStatus moves the interaction to status if it is
+	// currently in one of from, and reports whether it did.
+	UpdateInteractionStatus(ctx context.Context, ulid string, from []string, status string) (bool, error)
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"rio/internal/apperr"
+	"rio/internal/db"
+	"rio/internal/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+type DBInteractionRepository struct{}
+
+func NewDBInteractionRepository() *DBInteractionRepository {
+	return &DBInteractionRepository{}
+}
+
+func (r *DBInteractionRepository) Create(ctx context.Context, interaction *models.Interaction) error {
+	err := db.WithContext(ctx).Create(interaction).Error
+	if db.IsUniqueViolation(err) {
+		return apperr.Conflict("interaction with this ULID already exists")
+	}
+	return err
+}
+
+func (r *DBInteractionRepository) GetInteractionByID(ctx context.Context, ulid string) (*models.Interaction, error) {
+	var interaction models.Interaction
+	err := db.WithContext(ctx).Where("ul_id = ?", ulid).First(&interaction).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &interaction, nil
+}
+
+func (r *DBInteractionRepository) UpdateInteractionStatus(ctx context.Context, ulid string, from []string, status string) (bool, error) {
+	result := db.WithContext(ctx).Model(&models.Interaction{}).
+		Where("ul_id = ? AND status IN (?)", ulid, from).
+		Update("status", status)
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
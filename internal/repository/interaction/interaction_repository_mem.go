@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"slices"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+)
+
+type InMemoryInteractionRepository struct {
+	store *store.Store
+}
+
+func NewInMemoryInteractionRepository(s *store.Store) *InMemoryInteractionRepository {
+	return &InMemoryInteractionRepository{store: s}
+}
+
+func (r *InMemoryInteractionRepository) Create(ctx context.Context, interaction *models.Interaction) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		if t.Interactions.Has(interaction.ULID) {
+			return apperr.Conflict("interaction with this ULID already exists")
+		}
+		t.Stamp(&interaction.Model)
+		t.Interactions.Put(interaction.ULID, *interaction)
+		return nil
+	})
+}
+
+func (r *InMemoryInteractionRepository) GetInteractionByID(ctx context.Context, ulid string) (*models.Interaction, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.Interaction {
+		return t.Interactions.Get(ulid)
+	})
+}
+
+func (r *InMemoryInteractionRepository) UpdateInteractionStatus(ctx context.Context, ulid string, from []string, status string) (bool, error) {
+	updated := false
+	err := r.store.Update(ctx, func(t *store.Tables) error {
+		i, ok := t.Interactions.Lookup(ulid)
+		if !ok || !slices.Contains(from, i.Status) {
+			return nil
+		}
+		i.Status = status
+		t.Interactions.Put(ulid, i)
+		updated = true
+		return nil
+	})
+	return updated, err
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// testInteractionRepository checks the behaviour both backends share, down
+// to the errors they fail with.
+func testInteractionRepository(t *testing.T, repo InteractionRepository) {
+	ctx := context.Background()
+	interaction := &models.Interaction{
+		ULID: ulid.Make().String(), ApplicationID: ulid.Make().String(), CommandID: ulid.Make().String(),
+		ServerID: ulid.Make().String(), ChannelID: ulid.Make().String(), UserID: ulid.Make().String(),
+		CommandName: "roll", Options: "{}", Status: models.InteractionPending,
+		ExpiresAt: time.Now().Add(time.Minute), Type: models.InteractionCommand,
+	}
+	if err := repo.Create(ctx, interaction); err != nil {
+		t.Fatal(err)
+	}
+	again := *interaction
+	again.ID = 0
+	if err := repo.Create(ctx, &again); !errors.Is(err, apperr.ErrConflict) {
+		t.Fatalf("creating an interaction twice: %v; want a conflict", err)
+	}
+
+	found, err := repo.GetInteractionByID(ctx, interaction.ULID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == nil || found.CommandName != "roll" || found.Status != models.InteractionPending {
+		t.Fatalf("GetInteractionByID = %+v; want the pending interaction", found)
+	}
+	if missing, err := repo.GetInteractionByID(ctx, ulid.Make().String()); err != nil || missing != nil {
+		t.Fatalf("GetInteractionByID(unknown) = %+v, %v; want nil, nil", missing, err)
+	}
+
+	for _, step := range []struct {
+		name   string
+		ulid   string
+		from   []string
+		status string
+		want   bool
+	}{
+		{"from pending", interaction.ULID, []string{models.InteractionPending}, models.InteractionDeferred, true},
+		{"from a state it has left", interaction.ULID, []string{models.InteractionPending}, models.InteractionFailed, false},
+		{"from one of several", interaction.ULID, []string{models.InteractionPending, models.InteractionDeferred}, models.InteractionResponded, true},
+		{"an unknown interaction", ulid.Make().String(), []string{models.InteractionPending}, models.InteractionFailed, false},
+	} {
+		updated, err := repo.UpdateInteractionStatus(ctx, step.ulid, step.from, step.status)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated != step.want {
+			t.Errorf("UpdateInteractionStatus %s = %v; want %v", step.name, updated, step.want)
+		}
+	}
+
+	found, err = repo.GetInteractionByID(ctx, interaction.ULID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Status != models.InteractionResponded {
+		t.Fatalf("status = %s; want %s", found.Status, models.InteractionResponded)
+	}
+}
+
+func TestInMemoryInteractionRepository(t *testing.T) {
+	testInteractionRepository(t, NewInMemoryInteractionRepository(store.New()))
+}
@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+	"rio/internal/models"
+	"time"
+)
+
+type InviteRepository interface {
+	Create(ctx context.Context, invite *models.Invite) error
+	GetInviteByCode(ctx context.Context, code string) (*models.Invite, error)
+	GetInvitesByServer(ctx context.Context, s_id string) ([]*models.Invite, error)
+	// UseInvite counts one use of the invite if it has not expired or run
+	// out of uses, and reports whether it did.
+	UseInvite(ctx context.Context, code string, now time.Time) (bool, error)
+	DeleteInvite(ctx context.Context, s_id, code string) error
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/db"
+	"rio/internal/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+type DBInviteRepository struct{}
+
+func NewDBInviteRepository() *DBInviteRepository {
+	return &DBInviteRepository{}
+}
+
+func (r *DBInviteRepository) Create(ctx context.Context, invite *models.Invite) error {
+	err := db.WithContext(ctx).Create(invite).Error
+	if db.IsUniqueViolation(err) {
+		return apperr.Conflict("invite with this code already exists")
+	}
+	return err
+}
+
+func (r *DBInviteRepository) GetInviteByCode(ctx context.Context, code string) (*models.Invite, error) {
+	var invite models.Invite
+	err := db.WithContext(ctx).Where("code = ?", code).First(&invite).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &invite, nil
+}
+
+func (r *DBInviteRepository) GetInvitesByServer(ctx context.Context, s_id string) ([]*models.Invite, error) {
+	var invites []*models.Invite
+	err := db.WithContext(ctx).Where("server_id = ?", s_id).Order("created_at").Find(&invites).Error
+	if err != nil {
+		return nil, err
+	}
+	return invites, nil
+}
+
+func (r *DBInviteRepository) UseInvite(ctx context.Context, code string, now time.Time) (bool, error) {
+	result := db.WithContext(ctx).Model(&models.Invite{}).
+		Where("code = ? AND (max_uses = 0 OR uses < max_uses) AND (expires_at IS NULL OR expires_at > ?)", code, now).
+		UpdateColumn("uses", gorm.Expr("uses + 1"))
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *DBInviteRepository) DeleteInvite(ctx context.Context, s_id, code string) error {
+	result := db.WithContext(ctx).Where("server_id = ? AND code = ?", s_id, code).Delete(&models.Invite{})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.NotFound("invite not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+)
+
+type InMemoryInviteRepository struct {
+	store *store.Store
+}
+
+func NewInMemoryInviteRepository(s *store.Store) *InMemoryInviteRepository {
+	return &InMemoryInviteRepository{store: s}
+}
+
+func (r *InMemoryInviteRepository) Create(ctx context.Context, invite *models.Invite) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		if t.Invites.Has(invite.Code) {
+			return apperr.Conflict("invite with this code already exists")
+		}
+		t.Stamp(&invite.Model)
+		t.Invites.Put(invite.Code, *invite)
+		return nil
+	})
+}
+
+func (r *InMemoryInviteRepository) GetInviteByCode(ctx context.Context, code string) (*models.Invite, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.Invite {
+		return t.Invites.Get(code)
+	})
+}
+
+func (r *InMemoryInviteRepository) GetInvitesByServer(ctx context.Context, s_id string) ([]*models.Invite, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) []*models.Invite {
+		return t.Invites.Filter(func(i *models.Invite) bool {
+			return i.ServerID == s_id
+		}, func(a, b *models.Invite) int {
+			return cmp.Compare(a.ID, b.ID)
+		})
+	})
+}
+
+func (r *InMemoryInviteRepository) UseInvite(ctx context.Context, code string, now time.Time) (bool, error) {
+	used := false
+	err := r.store.Update(ctx, func(t *store.Tables) error {
+		invite, ok := t.Invites.Lookup(code)
+		if !ok {
+			return nil
+		}
+		if invite.MaxUses > 0 && invite.Uses >= invite.MaxUses {
+			return nil
+		}
+		if invite.ExpiresAt != nil && !invite.ExpiresAt.After(now) {
+			return nil
+		}
+		invite.Uses++
+		t.Invites.Put(code, invite)
+		used = true
+		return nil
+	})
+	return used, err
+}
+
+func (r *InMemoryInviteRepository) DeleteInvite(ctx context.Context, s_id, code string) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		invite, ok := t.Invites.Lookup(code)
+		if !ok || invite.ServerID != s_id {
+			return apperr.NotFound("invite not found")
+		}
+		t.Invites.Delete(code)
+		return nil
+	})
+}
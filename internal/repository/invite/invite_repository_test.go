@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// testInviteRepository checks the behaviour both backends share, down to
+// the errors they fail with.
+func testInviteRepository(t *testing.T, repo InviteRepository) {
+	ctx := context.Background()
+	server := ulid.Make().String()
+	now := time.Now().Truncate(time.Second)
+	expiry := now.Add(time.Hour)
+
+	newInvite := func(code string, maxUses int, expiresAt *time.Time) *models.Invite {
+		invite := &models.Invite{Code: code, ServerID: server, CreatedBy: ulid.Make().String(), MaxUses: maxUses, ExpiresAt: expiresAt}
+		if err := repo.Create(ctx, invite); err != nil {
+			t.Fatal(err)
+		}
+		return invite
+	}
+	unlimited := newInvite("unlimited", 0, nil)
+	twice := newInvite("twice", 2, nil)
+	expiring := newInvite("expiring", 0, &expiry)
+
+	use := func(t *testing.T, code string, at time.Time, want bool) {
+		t.Helper()
+		used, err := repo.UseInvite(ctx, code, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if used != want {
+			t.Fatalf("UseInvite(%s) = %v; want %v", code, used, want)
+		}
+	}
+
+	t.Run("Create refuses a code twice", func(t *testing.T) {
+		again := &models.Invite{Code: "twice", ServerID: server, CreatedBy: ulid.Make().String()}
+		if err := repo.Create(ctx, again); !errors.Is(err, apperr.ErrConflict) {
+			t.Fatalf("creating an invite twice: %v; want a conflict", err)
+		}
+	})
+
+	t.Run("GetInvitesByServer", func(t *testing.T) {
+		invites, err := repo.GetInvitesByServer(ctx, server)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(invites) != 3 || invites[0].Code != unlimited.Code || invites[1].Code != twice.Code || invites[2].Code != expiring.Code {
+			t.Fatalf("GetInvitesByServer = %+v; want the three invites, oldest first", invites)
+		}
+		if missing, err := repo.GetInviteByCode(ctx, "unknown"); err != nil || missing != nil {
+			t.Fatalf("GetInviteByCode(unknown) = %+v, %v; want nil, nil", missing, err)
+		}
+	})
+
+	t.Run("UseInvite", func(t *testing.T) {
+		for range 3 {
+			use(t, "unlimited", now, true)
+		}
+		use(t, "twice", now, true)
+		use(t, "twice", now, true)
+		use(t, "twice", now, false)
+		use(t, "expiring", now, true)
+		use(t, "expiring", expiry, false)
+		use(t, "unknown", now, false)
+
+		found, err := repo.GetInviteByCode(ctx, "twice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found.Uses != 2 {
+			t.Fatalf("invite used %d times; want 2", found.Uses)
+		}
+	})
+
+	t.Run("DeleteInvite", func(t *testing.T) {
+		if err := repo.DeleteInvite(ctx, ulid.Make().String(), "twice"); !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("deleting another server's invite: %v; want not found", err)
+		}
+		if err := repo.DeleteInvite(ctx, server, "twice"); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.DeleteInvite(ctx, server, "twice"); !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("deleting an invite twice: %v; want not found", err)
+		}
+		if deleted, err := repo.GetInviteByCode(ctx, "twice"); err != nil || deleted != nil {
+			t.Fatalf("GetInviteByCode(deleted) = %+v, %v; want nil, nil", deleted, err)
+		}
+	})
+}
+
+func TestInMemoryInviteRepository(t *testing.T) {
+	testInviteRepository(t, NewInMemoryInviteRepository(store.New()))
+}
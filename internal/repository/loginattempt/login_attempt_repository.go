@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"rio/internal/models"
+)
+
+type LoginAttemptRepository interface {
+	Create(ctx context.Context, attempt *models.LoginAttempt) error
+	LastSuccessForUsername(ctx context.Context, username string) (*time.Time, error)
+	FailuresForUsernameSince(ctx context.Context, username string, since time.Time) (int, *time.Time, error)
+	FailuresForIPSince(ctx context.Context, ip string, since time.Time) (int, *time.Time, error)
+	GetAttemptsByUser(ctx context.Context, u_id string, limit int) ([]*models.LoginAttempt, error)
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"rio/internal/db"
+	"rio/internal/models"
+)
+
+type DBLoginAttemptRepository struct{}
+
+func NewDBLoginAttemptRepository() *DBLoginAttemptRepository {
+	return &DBLoginAttemptRepository{}
+}
+
+func (r *DBLoginAttemptRepository) Create(ctx context.Context, attempt *models.LoginAttempt) error {
+	return db.WithContext(ctx).Create(attempt).Error
+}
+
+func (r *DBLoginAttemptRepository) LastSuccessForUsername(ctx context.Context, username string) (*time.Time, error) {
+	var attempts []models.LoginAttempt
+	err := db.WithContext(ctx).
+		Where(db.EqualFold("username")+" AND success = ?", username, true).
+		Order("created_at DESC").
+		Limit(1).
+		Find(&attempts).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(attempts) == 0 {
+		return nil, nil
+	}
+	return &attempts[0].CreatedAt, nil
+}
+
+// failuresSince counts the failures matching cond, a condition taking
+// value.
+func (r *DBLoginAttemptRepository) failuresSince(ctx context.Context, cond, value string, since time.Time) (int, *time.Time, error) {
+	var stats struct {
+		Count int
+		Last  db.NullTime
+	}
+	err := db.WithContext(ctx).Model(&models.LoginAttempt{}).
+		Select("COUNT(*) AS count, MAX(created_at) AS last").
+		Where(cond+" AND success = ? AND reason <> ? AND created_at > ?", value, false, models.LoginReasonThrottled, since).
+		Scan(&stats).Error
+	if err != nil {
+		return 0, nil, err
+	}
+	return stats.Count, stats.Last.Ptr(), nil
+}
+
+func (r *DBLoginAttemptRepository) FailuresForUsernameSince(ctx context.Context, username string, since time.Time) (int, *time.Time, error) {
+	return r.failuresSince(ctx, db.EqualFold("username"), username, since)
+}
+
+func (r *DBLoginAttemptRepository) FailuresForIPSince(ctx context.Context, ip string, since time.Time) (int, *time.Time, error) {
+	return r.failuresSince(ctx, "ip = ?", ip, since)
+}
+
+func (r *DBLoginAttemptRepository) GetAttemptsByUser(ctx context.Context, u_id string, limit int) ([]*models.LoginAttempt, error) {
+	var attempts []*models.LoginAttempt
+	err := db.WithContext(ctx).
+		Where("user_id = ?", u_id).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&attempts).Error
+	if err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"strings"
+	"time"
+
+	"rio/internal/models"
+	"rio/internal/store"
+)
+
+type InMemoryLoginAttemptRepository struct {
+	store *store.Store
+}
+
+func NewInMemoryLoginAttemptRepository(s *store.Store) *InMemoryLoginAttemptRepository {
+	return &InMemoryLoginAttemptRepository{store: s}
+}
+
+func (r *InMemoryLoginAttemptRepository) Create(ctx context.Context, attempt *models.LoginAttempt) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		t.Stamp(&attempt.Model)
+		t.LoginAttempts.Put(attempt.ID, *attempt)
+		return nil
+	})
+}
+
+func (r *InMemoryLoginAttemptRepository) LastSuccessForUsername(ctx context.Context, username string) (*time.Time, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *time.Time {
+		var last *time.Time
+		for _, a := range t.LoginAttempts.All() {
+			if a.Success && strings.EqualFold(a.Username, username) && (last == nil || a.CreatedAt.After(*last)) {
+				last = &a.CreatedAt
+			}
+		}
+		return last
+	})
+}
+
+type failures struct {
+	count int
+	last  *time.Time
+}
+
+func (r *InMemoryLoginAttemptRepository) failuresSince(ctx context.Context, match func(a *models.LoginAttempt) bool, since time.Time) (int, *time.Time, error) {
+	f, err := store.Query(ctx, r.store, func(t *store.Tables) failures {
+		var f failures
+		for _, a := range t.LoginAttempts.All() {
+			if a.Success || a.Reason == models.LoginReasonThrottled || !a.CreatedAt.After(since) || !match(&a) {
+				continue
+			}
+			f.count++
+			if f.last == nil || a.CreatedAt.After(*f.last) {
+				f.last = &a.CreatedAt
+			}
+		}
+		return f
+	})
+	return f.count, f.last, err
+}
+
+func (r *InMemoryLoginAttemptRepository) FailuresForUsernameSince(ctx context.Context, username string, since time.Time) (int, *time.Time, error) {
+	return r.failuresSince(ctx, func(a *models.LoginAttempt) bool { return strings.EqualFold(a.Username, username) }, since)
+}
+
+func (r *InMemoryLoginAttemptRepository) FailuresForIPSince(ctx context.Context, ip string, since time.Time) (int, *time.Time, error) {
+	return r.failuresSince(ctx, func(a *models.LoginAttempt) bool { return a.IP == ip }, since)
+}
+
+func (r *InMemoryLoginAttemptRepository) GetAttemptsByUser(ctx context.Context, u_id string, limit int) ([]*models.LoginAttempt, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) []*models.LoginAttempt {
+		attempts := t.LoginAttempts.Filter(func(a *models.LoginAttempt) bool {
+			return a.UserID == u_id
+		}, func(a, b *models.LoginAttempt) int {
+			return cmp.Compare(b.ID, a.ID)
+		})
+		if len(attempts) > limit {
+			attempts = attempts[:limit]
+		}
+		return attempts
+	})
+}
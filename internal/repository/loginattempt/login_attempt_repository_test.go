@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"rio/internal/models"
+	"rio/internal/store"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// testLoginAttemptRepository checks the behaviour both backends share.
+func testLoginAttemptRepository(t *testing.T, repo LoginAttemptRepository) {
+	ctx := context.Background()
+	user := ulid.Make().String()
+	before := time.Now().Add(-time.Minute)
+
+	if last, err := repo.LastSuccessForUsername(ctx, "alice"); err != nil || last != nil {
+		t.Fatalf("LastSuccessForUsername with no attempts = %v, %v; want nil, nil", last, err)
+	}
+
+	attempts := []*models.LoginAttempt{
+		{UserID: user, Username: "alice", IP: "10.0.0.1", Success: true},
+		{UserID: user, Username: "Alice", IP: "10.0.0.1", Reason: models.LoginReasonBadPassword},
+		{UserID: user, Username: "alice", IP: "10.0.0.2", Reason: models.LoginReasonBadSecondFactor},
+		{UserID: user, Username: "ALICE", IP: "10.0.0.1", Reason: models.LoginReasonThrottled},
+		{Username: "bob", IP: "10.0.0.1", Reason: models.LoginReasonUnknownUser},
+	}
+	for _, a := range attempts {
+		a.Method = "password"
+		if err := repo.Create(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	last, err := repo.LastSuccessForUsername(ctx, "ALICE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last == nil || !last.Equal(attempts[0].CreatedAt) {
+		t.Fatalf("LastSuccessForUsername = %v; want %v, ignoring case", last, attempts[0].CreatedAt)
+	}
+
+	for _, c := range []struct {
+		name      string
+		failures  func(since time.Time) (int, *time.Time, error)
+		since     time.Time
+		wantCount int
+		wantLast  *models.LoginAttempt
+	}{
+		{"username", func(since time.Time) (int, *time.Time, error) {
+			return repo.FailuresForUsernameSince(ctx, "alice", since)
+		}, before, 2, attempts[2]},
+		{"IP", func(since time.Time) (int, *time.Time, error) {
+			return repo.FailuresForIPSince(ctx, "10.0.0.1", since)
+		}, before, 2, attempts[4]},
+		{"IP with no failures", func(since time.Time) (int, *time.Time, error) {
+			return repo.FailuresForIPSince(ctx, "10.0.0.3", since)
+		}, before, 0, nil},
+		{"username since the last failure", func(since time.Time) (int, *time.Time, error) {
+			return repo.FailuresForUsernameSince(ctx, "alice", since)
+		}, attempts[2].CreatedAt, 0, nil},
+	} {
+		count, last, err := c.failures(c.since)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != c.wantCount {
+			t.Errorf("failures by %s = %d; want %d", c.name, count, c.wantCount)
+		}
+		if c.wantLast == nil && last != nil || c.wantLast != nil && (last == nil || !last.Equal(c.wantLast.CreatedAt)) {
+			t.Errorf("last failure by %s = %v; want the one at %+v", c.name, last, c.wantLast)
+		}
+	}
+
+	listed, err := repo.GetAttemptsByUser(ctx, user, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 2 || listed[0].ID != attempts[3].ID || listed[1].ID != attempts[2].ID {
+		t.Fatalf("GetAttemptsByUser = %+v; want the user's last two attempts, newest first", listed)
+	}
+}
+
+func TestInMemoryLoginAttemptRepository(t *testing.T) {
+	testLoginAttemptRepository(t, NewInMemoryLoginAttemptRepository(store.New()))
+}
@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+
+	"rio/internal/models"
+)
+
+type MessageRepository interface {
+	Create(ctx context.Context, message *models.Message) error
+	GetMessageByID(ctx context.Context, ulid string) (*models.Message, error)
+	// GetMessagesByChannel returns up to limit messages older than the
+	// message ID before (or the newest ones when before is empty), newest
+	// first.
+	GetMessagesByChannel(ctx context.Context, c_id, before string, limit int) ([]*models.Message, error)
+	UpdateMessage(ctx context.Context, ulid, content, components string) error
+}
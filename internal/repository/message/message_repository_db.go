@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"rio/internal/apperr"
+	"rio/internal/db"
+	"rio/internal/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+type DBMessageRepository struct{}
+
+func NewDBMessageRepository() *DBMessageRepository {
+	return &DBMessageRepository{}
+}
+
+func (r *DBMessageRepository) Create(ctx context.Context, message *models.Message) error {
+	err := db.WithContext(ctx).Create(message).Error
+	if db.IsUniqueViolation(err) {
+		return apperr.Conflict("message with this ULID already exists")
+	}
+	return err
+}
+
+func (r *DBMessageRepository) GetMessageByID(ctx context.Context, ulid string) (*models.Message, error) {
+	var m models.Message
+	err := db.WithContext(ctx).Where("ul_id = ?", ulid).First(&m).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *DBMessageRepository) GetMessagesByChannel(ctx context.Context, c_id, before string, limit int) ([]*models.Message, error) {
+	query := db.WithContext(ctx).Where("channel_id = ?", c_id)
+	if before != "" {
+		// ULIDs sort by creation time, so they double as a cursor.
+		query = query.Where("ul_id < ?", before)
+	}
+
+	var messages []*models.Message
+	err := query.Order("ul_id DESC").Limit(limit).Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (r *DBMessageRepository) UpdateMessage(ctx context.Context, ulid, content, components string) error {
+	result := db.WithContext(ctx).Model(&models.Message{}).Where("ul_id = ?", ulid).Updates(map[string]interface{}{
+		"content":    content,
+		"components": components,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return apperr.NotFound("message not found")
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"slices"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+)
+
+type InMemoryMessageRepository struct {
+	store *store.Store
+}
+
+func NewInMemoryMessageRepository(s *store.Store) *InMemoryMessageRepository {
+	return &InMemoryMessageRepository{store: s}
+}
+
+func (r *InMemoryMessageRepository) Create(ctx context.Context, message *models.Message) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		if t.Messages.Has(message.ULID) {
+			return apperr.Conflict("message with this ULID already exists")
+		}
+		t.Stamp(&message.Model)
+		t.Messages.Put(message.ULID, *message)
+
+		// Keep the channel's index sorted by ULID. New messages almost
+		// always sort last; anything else is inserted into a copy, since a
+		// transaction's backup may share the old backing array.
+		ids, _ := t.MessagesByCh.Lookup(message.ChannelID)
+		i, _ := slices.BinarySearch(ids, message.ULID)
+		if i == len(ids) {
+			ids = append(ids, message.ULID)
+		} else {
+			ids = slices.Insert(slices.Clip(ids), i, message.ULID)
+		}
+		t.MessagesByCh.Put(message.ChannelID, ids)
+		return nil
+	})
+}
+
+func (r *InMemoryMessageRepository) GetMessageByID(ctx context.Context, ulid string) (*models.Message, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.Message {
+		return t.Messages.Get(ulid)
+	})
+}
+
+func (r *InMemoryMessageRepository) GetMessagesByChannel(ctx context.Context, c_id, before string, limit int) ([]*models.Message, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) []*models.Message {
+		ids, _ := t.MessagesByCh.Lookup(c_id)
+		end := len(ids)
+		if before != "" {
+			end, _ = slices.BinarySearch(ids, before)
+		}
+
+		var messages []*models.Message
+		for i := end - 1; i >= 0 && len(messages) < limit; i-- {
+			if m := t.Messages.Get(ids[i]); m != nil {
+				messages = append(messages, m)
+			}
+		}
+		return messages
+	})
+}
+
+func (r *InMemoryMessageRepository) UpdateMessage(ctx context.Context, ulid, content, components string) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		m, ok := t.Messages.Lookup(ulid)
+		if !ok {
+			return apperr.NotFound("message not found")
+		}
+		m.Content = content
+		m.ComponentsJSON = components
+		t.Messages.Put(ulid, m)
+		return nil
+	})
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// testMessageRepository checks the behaviour both backends share, down to
+// the errors they fail with.
+func testMessageRepository(t *testing.T, repo MessageRepository) {
+	ctx := context.Background()
+	channel := ulid.Make().String()
+
+	var ids []string
+	for i := range 5 {
+		m := &models.Message{ULID: ulid.Make().String(), ChannelID: channel, UserID: ulid.Make().String(), Content: strings.Repeat("x", i+1)}
+		if err := repo.Create(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, m.ULID)
+	}
+	if err := repo.Create(ctx, &models.Message{ULID: ulid.Make().String(), ChannelID: ulid.Make().String(), Content: "elsewhere"}); err != nil {
+		t.Fatal(err)
+	}
+
+	page := func(t *testing.T, before string, limit int) []string {
+		t.Helper()
+		messages, err := repo.GetMessagesByChannel(ctx, channel, before, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, m := range messages {
+			got = append(got, m.ULID)
+		}
+		return got
+	}
+
+	t.Run("Create refuses a message ID twice", func(t *testing.T) {
+		again := &models.Message{ULID: ids[0], ChannelID: channel, Content: "again"}
+		if err := repo.Create(ctx, again); !errors.Is(err, apperr.ErrConflict) {
+			t.Fatalf("creating a message twice: %v; want a conflict", err)
+		}
+	})
+
+	t.Run("GetMessagesByChannel pages back from the newest", func(t *testing.T) {
+		if got, want := page(t, "", 2), []string{ids[4], ids[3]}; !slices.Equal(got, want) {
+			t.Fatalf("newest page = %v; want %v", got, want)
+		}
+		if got, want := page(t, ids[3], 2), []string{ids[2], ids[1]}; !slices.Equal(got, want) {
+			t.Fatalf("page before %s = %v; want %v", ids[3], got, want)
+		}
+		if got, want := page(t, ids[1], 10), []string{ids[0]}; !slices.Equal(got, want) {
+			t.Fatalf("last page = %v; want %v", got, want)
+		}
+		if got := page(t, ids[0], 10); len(got) != 0 {
+			t.Fatalf("page before the first message = %v; want none", got)
+		}
+	})
+
+	t.Run("UpdateMessage", func(t *testing.T) {
+		// Longer than the varchar(255) the column started as.
+		content := strings.Repeat("long ", 400)
+		if err := repo.UpdateMessage(ctx, ids[0], content, `[{"type":1}]`); err != nil {
+			t.Fatal(err)
+		}
+		updated, err := repo.GetMessageByID(ctx, ids[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated.Content != content || updated.ComponentsJSON != `[{"type":1}]` {
+			t.Fatalf("updated message = %q, %q; want the new content and components", updated.Content, updated.ComponentsJSON)
+		}
+
+		if err := repo.UpdateMessage(ctx, ulid.Make().String(), "x", ""); !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("updating an unknown message: %v; want not found", err)
+		}
+		if missing, err := repo.GetMessageByID(ctx, ulid.Make().String()); err != nil || missing != nil {
+			t.Fatalf("GetMessageByID(unknown) = %+v, %v; want nil, nil", missing, err)
+		}
+	})
+}
+
+func TestInMemoryMessageRepository(t *testing.T) {
+	testMessageRepository(t, NewInMemoryMessageRepository(store.New()))
+}
@@ -0,0 +1,10 @@
+package repository
+
+import "context"
+
+type MFARepository interface {
+	ReplaceRecoveryCodes(ctx context.Context, u_id string, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, u_id, codeHash string) (bool, error)
+	CountUnusedRecoveryCodes(ctx context.Context, u_id string) (int, error)
+	DeleteRecoveryCodes(ctx context.Context, u_id string) error
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"rio/internal/db"
+	"rio/internal/models"
+)
+
+type DBMFARepository struct{}
+
+func NewDBMFARepository() *DBMFARepository {
+	return &DBMFARepository{}
+}
+
+func (r *DBMFARepository) ReplaceRecoveryCodes(ctx context.Context, u_id string, codeHashes []string) error {
+	return db.Transaction(ctx, func(ctx context.Context) error {
+		tx := db.WithContext(ctx)
+		if err := tx.Unscoped().Where("user_id = ?", u_id).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		for _, hash := range codeHashes {
+			code := models.RecoveryCode{UserID: u_id, CodeHash: hash}
+			if err := tx.Create(&code).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *DBMFARepository) UseRecoveryCode(ctx context.Context, u_id, codeHash string) (bool, error) {
+	result := db.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", u_id, codeHash).
+		Update("used_at", time.Now())
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func (r *DBMFARepository) CountUnusedRecoveryCodes(ctx context.Context, u_id string) (int, error) {
+	var count int
+	err := db.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", u_id).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *DBMFARepository) DeleteRecoveryCodes(ctx context.Context, u_id string) error {
+	return db.WithContext(ctx).Unscoped().Where("user_id = ?", u_id).Delete(&models.RecoveryCode{}).Error
+}
@@ -0,0 +1,14 @@
+//go:build cgo
+
+package repository
+
+import (
+	"testing"
+
+	"rio/internal/db/dbtest"
+)
+
+func TestDBMFARepository(t *testing.T) {
+	dbtest.Open(t)
+	testMFARepository(t, NewDBMFARepository())
+}
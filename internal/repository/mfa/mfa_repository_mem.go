@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"rio/internal/models"
+	"rio/internal/store"
+)
+
+type InMemoryMFARepository struct {
+	store *store.Store
+}
+
+func NewInMemoryMFARepository(s *store.Store) *InMemoryMFARepository {
+	return &InMemoryMFARepository{store: s}
+}
+
+func (r *InMemoryMFARepository) ReplaceRecoveryCodes(ctx context.Context, u_id string, codeHashes []string) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		deleteRecoveryCodes(t, u_id)
+		for _, hash := range codeHashes {
+			code := models.RecoveryCode{UserID: u_id, CodeHash: hash}
+			t.Stamp(&code.Model)
+			t.RecoveryCodes.Put(code.ID, code)
+		}
+		return nil
+	})
+}
+
+func (r *InMemoryMFARepository) UseRecoveryCode(ctx context.Context, u_id, codeHash string) (bool, error) {
+	used := false
+	err := r.store.Update(ctx, func(t *store.Tables) error {
+		c := t.RecoveryCodes.Find(func(c *models.RecoveryCode) bool {
+			return c.UserID == u_id && c.CodeHash == codeHash && c.UsedAt == nil
+		})
+		if c == nil {
+			return nil
+		}
+		now := time.Now()
+		c.UsedAt = &now
+		t.RecoveryCodes.Put(c.ID, *c)
+		used = true
+		return nil
+	})
+	return used, err
+}
+
+func (r *InMemoryMFARepository) CountUnusedRecoveryCodes(ctx context.Context, u_id string) (int, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) int {
+		count := 0
+		for _, c := range t.RecoveryCodes.All() {
+			if c.UserID == u_id && c.UsedAt == nil {
+				count++
+			}
+		}
+		return count
+	})
+}
+
+func (r *InMemoryMFARepository) DeleteRecoveryCodes(ctx context.Context, u_id string) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		deleteRecoveryCodes(t, u_id)
+		return nil
+	})
+}
+
+func deleteRecoveryCodes(t *store.Tables, userID string) {
+	for id, c := range t.RecoveryCodes.All() {
+		if c.UserID == userID {
+			t.RecoveryCodes.Delete(id)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"rio/internal/store"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// testMFARepository checks the behaviour both backends share.
+func testMFARepository(t *testing.T, repo MFARepository) {
+	ctx := context.Background()
+	alice, bob := ulid.Make().String(), ulid.Make().String()
+
+	unused := func(t *testing.T, user string, want int) {
+		t.Helper()
+		count, err := repo.CountUnusedRecoveryCodes(ctx, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != want {
+			t.Fatalf("%d unused recovery codes; want %d", count, want)
+		}
+	}
+	use := func(t *testing.T, user, hash string, want bool) {
+		t.Helper()
+		used, err := repo.UseRecoveryCode(ctx, user, hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if used != want {
+			t.Fatalf("UseRecoveryCode(%s) = %v; want %v", hash, used, want)
+		}
+	}
+
+	if err := repo.ReplaceRecoveryCodes(ctx, alice, []string{"a1", "a2", "a3"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.ReplaceRecoveryCodes(ctx, bob, []string{"b1"}); err != nil {
+		t.Fatal(err)
+	}
+	unused(t, alice, 3)
+
+	t.Run("a recovery code is used once", func(t *testing.T) {
+		use(t, alice, "a1", true)
+		use(t, alice, "a1", false)
+		use(t, alice, "b1", false)
+		unused(t, alice, 2)
+		unused(t, bob, 1)
+	})
+
+	t.Run("ReplaceRecoveryCodes drops the old codes", func(t *testing.T) {
+		if err := repo.ReplaceRecoveryCodes(ctx, alice, []string{"a4", "a5"}); err != nil {
+			t.Fatal(err)
+		}
+		unused(t, alice, 2)
+		use(t, alice, "a2", false)
+		use(t, alice, "a4", true)
+	})
+
+	t.Run("DeleteRecoveryCodes", func(t *testing.T) {
+		if err := repo.DeleteRecoveryCodes(ctx, alice); err != nil {
+			t.Fatal(err)
+		}
+		unused(t, alice, 0)
+		use(t, alice, "a5", false)
+		unused(t, bob, 1)
+	})
+}
+
+func TestInMemoryMFARepository(t *testing.T) {
+	testMFARepository(t, NewInMemoryMFARepository(store.New()))
+}
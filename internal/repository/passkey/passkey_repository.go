@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"rio/internal/models"
+)
+
+type PasskeyRepository interface {
+	Create(ctx context.Context, passkey *models.Passkey) error
+	GetByCredentialIDHash(ctx context.Context, hash string) (*models.Passkey, error)
+	GetPasskeysByUser(ctx context.Context, u_id string) ([]*models.Passkey, error)
+	RecordUse(ctx context.Context, ulid string, signCount uint32, usedAt time.Time) error
+	DeletePasskey(ctx context.Context, u_id, ulid string) error
+}
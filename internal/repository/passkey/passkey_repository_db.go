@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/db"
+	"rio/internal/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+type DBPasskeyRepository struct{}
+
+func NewDBPasskeyRepository() *DBPasskeyRepository {
+	return &DBPasskeyRepository{}
+}
+
+func (r *DBPasskeyRepository) Create(ctx context.Context, passkey *models.Passkey) error {
+	err := db.WithContext(ctx).Create(passkey).Error
+	if db.IsUniqueViolation(err) {
+		return apperr.Conflict("passkey is already registered")
+	}
+	return err
+}
+
+func (r *DBPasskeyRepository) GetByCredentialIDHash(ctx context.Context, hash string) (*models.Passkey, error) {
+	var p models.Passkey
+	err := db.WithContext(ctx).Where("credential_id_hash = ?", hash).First(&p).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *DBPasskeyRepository) GetPasskeysByUser(ctx context.Context, u_id string) ([]*models.Passkey, error) {
+	var passkeys []*models.Passkey
+	err := db.WithContext(ctx).Where("user_id = ?", u_id).Order("created_at").Find(&passkeys).Error
+	if err != nil {
+		return nil, err
+	}
+	return passkeys, nil
+}
+
+func (r *DBPasskeyRepository) RecordUse(ctx context.Context, ulid string, signCount uint32, usedAt time.Time) error {
+	return db.WithContext(ctx).Model(&models.Passkey{}).
+		Where("ul_id = ?", ulid).
+		Updates(map[string]interface{}{"sign_count": signCount, "last_used_at": usedAt}).Error
+}
+
+func (r *DBPasskeyRepository) DeletePasskey(ctx context.Context, u_id, ulid string) error {
+	result := db.WithContext(ctx).Unscoped().Where("user_id = ? AND ul_id = ?", u_id, ulid).Delete(&models.Passkey{})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.NotFound("passkey not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build cgo
+
+package repository
+
+import (
+	"testing"
+
+	"rio/internal/db/dbtest"
+)
+
+func TestDBPasskeyRepository(t *testing.T) {
+	dbtest.Open(t)
+	testPasskeyRepository(t, NewDBPasskeyRepository())
+}
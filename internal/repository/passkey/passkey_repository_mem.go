@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+)
+
+type InMemoryPasskeyRepository struct {
+	store *store.Store
+}
+
+func NewInMemoryPasskeyRepository(s *store.Store) *InMemoryPasskeyRepository {
+	return &InMemoryPasskeyRepository{store: s}
+}
+
+func (r *InMemoryPasskeyRepository) Create(ctx context.Context, passkey *models.Passkey) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		existing := t.Passkeys.Find(func(p *models.Passkey) bool {
+			return p.CredentialIDHash == passkey.CredentialIDHash
+		})
+		if existing != nil {
+			return apperr.Conflict("passkey is already registered")
+		}
+		t.Stamp(&passkey.Model)
+		t.Passkeys.Put(passkey.ULID, *passkey)
+		return nil
+	})
+}
+
+func (r *InMemoryPasskeyRepository) GetByCredentialIDHash(ctx context.Context, hash string) (*models.Passkey, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.Passkey {
+		return t.Passkeys.Find(func(p *models.Passkey) bool {
+			return p.CredentialIDHash == hash
+		})
+	})
+}
+
+func (r *InMemoryPasskeyRepository) GetPasskeysByUser(ctx context.Context, u_id string) ([]*models.Passkey, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) []*models.Passkey {
+		return t.Passkeys.Filter(func(p *models.Passkey) bool {
+			return p.UserID == u_id
+		}, func(a, b *models.Passkey) int {
+			return cmp.Compare(a.ID, b.ID)
+		})
+	})
+}
+
+func (r *InMemoryPasskeyRepository) RecordUse(ctx context.Context, ulid string, signCount uint32, usedAt time.Time) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		p, ok := t.Passkeys.Lookup(ulid)
+		if !ok {
+			return nil
+		}
+		p.SignCount = signCount
+		p.LastUsedAt = &usedAt
+		t.Passkeys.Put(ulid, p)
+		return nil
+	})
+}
+
+func (r *InMemoryPasskeyRepository) DeletePasskey(ctx context.Context, u_id, ulid string) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		p, ok := t.Passkeys.Lookup(ulid)
+		if !ok || p.UserID != u_id {
+			return apperr.NotFound("passkey not found")
+		}
+		t.Passkeys.Delete(ulid)
+		return nil
+	})
+}
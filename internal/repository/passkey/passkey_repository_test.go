@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// testPasskeyRepository checks the behaviour both backends share, down to
+// the errors they fail with.
+func testPasskeyRepository(t *testing.T, repo PasskeyRepository) {
+	ctx := context.Background()
+	user := ulid.Make().String()
+
+	newPasskey := func(userID, name, hash string) *models.Passkey {
+		return &models.Passkey{
+			ULID: ulid.Make().String(), UserID: userID, Name: name,
+			CredentialID: "credential-" + hash, CredentialIDHash: hash, PublicKey: []byte("key"),
+		}
+	}
+	laptop := newPasskey(user, "Laptop", "h1")
+	phone := newPasskey(user, "Phone", "h2")
+	for _, p := range []*models.Passkey{laptop, phone} {
+		if err := repo.Create(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("a credential is registered once", func(t *testing.T) {
+		again := newPasskey(ulid.Make().String(), "Stolen", "h1")
+		if err := repo.Create(ctx, again); !errors.Is(err, apperr.ErrConflict) {
+			t.Fatalf("registering a credential twice: %v; want a conflict", err)
+		}
+	})
+
+	t.Run("lookups", func(t *testing.T) {
+		found, err := repo.GetByCredentialIDHash(ctx, "h2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found == nil || found.ULID != phone.ULID || string(found.PublicKey) != "key" {
+			t.Fatalf("GetByCredentialIDHash(h2) = %+v; want the phone", found)
+		}
+		if missing, err := repo.GetByCredentialIDHash(ctx, "unknown"); err != nil || missing != nil {
+			t.Fatalf("GetByCredentialIDHash(unknown) = %+v, %v; want nil, nil", missing, err)
+		}
+
+		listed, err := repo.GetPasskeysByUser(ctx, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(listed) != 2 || listed[0].Name != "Laptop" || listed[1].Name != "Phone" {
+			t.Fatalf("GetPasskeysByUser = %+v; want the laptop then the phone", listed)
+		}
+	})
+
+	t.Run("RecordUse", func(t *testing.T) {
+		usedAt := time.Now().Truncate(time.Second)
+		if err := repo.RecordUse(ctx, laptop.ULID, 7, usedAt); err != nil {
+			t.Fatal(err)
+		}
+		used, err := repo.GetByCredentialIDHash(ctx, "h1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if used.SignCount != 7 || used.LastUsedAt == nil || !used.LastUsedAt.Equal(usedAt) {
+			t.Fatalf("passkey after use = %+v; want sign count 7 used at %s", used, usedAt)
+		}
+	})
+
+	t.Run("DeletePasskey", func(t *testing.T) {
+		if err := repo.DeletePasskey(ctx, ulid.Make().String(), laptop.ULID); !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("deleting another user's passkey: %v; want not found", err)
+		}
+		if err := repo.DeletePasskey(ctx, user, laptop.ULID); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.DeletePasskey(ctx, user, laptop.ULID); !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("deleting a passkey twice: %v; want not found", err)
+		}
+
+		// The credential can be registered again once its passkey is gone.
+		if err := repo.Create(ctx, newPasskey(user, "Laptop", "h1")); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestInMemoryPasskeyRepository(t *testing.T) {
+	testPasskeyRepository(t, NewInMemoryPasskeyRepository(store.New()))
+}
@@ -0,0 +1,13 @@
+package repository
+
+import (
+	"context"
+	"rio/internal/models"
+)
+
+type PasswordResetRepository interface {
+	Create(ctx context.Context, reset *models.PasswordReset) error
+	FindByHash(ctx context.Context, hash string) (*models.PasswordReset, error)
+	MarkUsed(ctx context.Context, ulid string) error
+	InvalidateForUser(ctx context.Context, u_id string) error
+}
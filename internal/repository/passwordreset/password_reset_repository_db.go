@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/db"
+	"rio/internal/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+type DBPasswordResetRepository struct{}
+
+func NewDBPasswordResetRepository() *DBPasswordResetRepository {
+	return &DBPasswordResetRepository{}
+}
+
+func (r *DBPasswordResetRepository) Create(ctx context.Context, reset *models.PasswordReset) error {
+	return db.WithContext(ctx).Create(reset).Error
+}
+
+func (r *DBPasswordResetRepository) FindByHash(ctx context.Context, hash string) (*models.PasswordReset, error) {
+	var reset models.PasswordReset
+	err := db.WithContext(ctx).Where("token_hash = ?", hash).First(&reset).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &reset, nil
+}
+
+func (r *DBPasswordResetRepository) MarkUsed(ctx context.Context, ulid string) error {
+	result := db.WithContext(ctx).Model(&models.PasswordReset{}).
+		Where("ul_id = ? AND used_at IS NULL", ulid).
+		Update("used_at", time.Now())
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.Invalid("reset token has already been used")
+	}
+
+	return nil
+}
+
+func (r *DBPasswordResetRepository) InvalidateForUser(ctx context.Context, u_id string) error {
+	return db.WithContext(ctx).Model(&models.PasswordReset{}).
+		Where("user_id = ? AND used_at IS NULL", u_id).
+		Update("used_at", time.Now()).Error
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+)
+
+type InMemoryPasswordResetRepository struct {
+	store *store.Store
+}
+
+func NewInMemoryPasswordResetRepository(s *store.Store) *InMemoryPasswordResetRepository {
+	return &InMemoryPasswordResetRepository{store: s}
+}
+
+func (r *InMemoryPasswordResetRepository) Create(ctx context.Context, reset *models.PasswordReset) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		t.Stamp(&reset.Model)
+		t.PasswordResets.Put(reset.ULID, *reset)
+		return nil
+	})
+}
+
+func (r *InMemoryPasswordResetRepository) FindByHash(ctx context.Context, hash string) (*models.PasswordReset, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.PasswordReset {
+		return t.PasswordResets.Find(func(reset *models.PasswordReset) bool {
+			return reset.TokenHash == hash
+		})
+	})
+}
+
+func (r *InMemoryPasswordResetRepository) MarkUsed(ctx context.Context, ulid string) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		reset, ok := t.PasswordResets.Lookup(ulid)
+		if !ok || reset.UsedAt != nil {
+			return apperr.Invalid("reset token has already been used")
+		}
+		now := time.Now()
+		reset.UsedAt = &now
+		t.PasswordResets.Put(ulid, reset)
+		return nil
+	})
+}
+
+func (r *InMemoryPasswordResetRepository) InvalidateForUser(ctx context.Context, u_id string) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		now := time.Now()
+		for id, reset := range t.PasswordResets.All() {
+			if reset.UserID == u_id && reset.UsedAt == nil {
+				reset.UsedAt = &now
+				t.PasswordResets.Put(id, reset)
+			}
+		}
+		return nil
+	})
+}
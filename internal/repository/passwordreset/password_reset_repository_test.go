@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// testPasswordResetRepository checks the behaviour both backends share,
+// down to the errors they fail with.
+func testPasswordResetRepository(t *testing.T, repo PasswordResetRepository) {
+	ctx := context.Background()
+	alice, bob := ulid.Make().String(), ulid.Make().String()
+
+	newReset := func(userID, hash string) *models.PasswordReset {
+		reset := &models.PasswordReset{ULID: ulid.Make().String(), UserID: userID, TokenHash: hash, ExpiresAt: time.Now().Add(time.Hour)}
+		if err := repo.Create(ctx, reset); err != nil {
+			t.Fatal(err)
+		}
+		return reset
+	}
+	used := func(t *testing.T, hash string) bool {
+		t.Helper()
+		reset, err := repo.FindByHash(ctx, hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reset == nil {
+			t.Fatalf("FindByHash(%s) found nothing", hash)
+		}
+		return reset.UsedAt != nil
+	}
+
+	first := newReset(alice, "a1")
+	newReset(alice, "a2")
+	newReset(bob, "b1")
+
+	if missing, err := repo.FindByHash(ctx, "unknown"); err != nil || missing != nil {
+		t.Fatalf("FindByHash(unknown) = %+v, %v; want nil, nil", missing, err)
+	}
+
+	t.Run("a reset token is used once", func(t *testing.T) {
+		if err := repo.MarkUsed(ctx, first.ULID); err != nil {
+			t.Fatal(err)
+		}
+		if !used(t, "a1") {
+			t.Fatal("a used reset token has no UsedAt")
+		}
+		if err := repo.MarkUsed(ctx, first.ULID); !errors.Is(err, apperr.ErrInvalid) {
+			t.Fatalf("using a reset token twice: %v; want it refused", err)
+		}
+		if err := repo.MarkUsed(ctx, ulid.Make().String()); !errors.Is(err, apperr.ErrInvalid) {
+			t.Fatalf("using an unknown reset token: %v; want it refused", err)
+		}
+	})
+
+	t.Run("InvalidateForUser", func(t *testing.T) {
+		if err := repo.InvalidateForUser(ctx, alice); err != nil {
+			t.Fatal(err)
+		}
+		if !used(t, "a2") {
+			t.Fatal("alice's other reset token is still usable")
+		}
+		if used(t, "b1") {
+			t.Fatal("invalidated bob's reset token")
+		}
+	})
+}
+
+func TestInMemoryPasswordResetRepository(t *testing.T) {
+	testPasswordResetRepository(t, NewInMemoryPasswordResetRepository(store.New()))
+}
@@ -1,17 +1,31 @@
 package repository
 
-import "rio/internal/models"
+import (
+	"context"
+	"rio/internal/models"
+	"time"
+)
 
 type ServerRepository interface {
-	Create(server *models.Server) error
-	CreateMembership(membership *models.UserServer) error
-	GetUserMembership(u_id, s_id string) (*models.UserServer, error)
-	GetServerByID(ulid string) (*models.Server, error)
-	GetServersByUser(u_id string) ([]*models.Server, error)
-	GetServerMembers(ulid string) ([]*models.User, error)
-	UpdateServer(ulid string, server *models.Server) error
-	DeleteServer(ulid string) error
-	AddUserToServer(userID, serverID, role string) error
-	RemoveUserFromServer(userID, serverID string) error
-	UpdateUserRoleInServer(userID, serverID, newRole string) error
+	Create(ctx context.Context, server *models.Server) error
+	CreateMembership(ctx context.Context, membership *models.UserServer) error
+	GetUserMembership(ctx context.Context, u_id, s_id string) (*models.UserServer, error)
+	GetServerByID(ctx context.Context, ulid string) (*models.Server, error)
+	GetServersByUser(ctx context.Context, u_id string) ([]*models.Server, error)
+	GetServerMembers(ctx context.Context, ulid string) ([]*models.User, error)
+	// GetMemberInstances returns the distinct remote instances that have
+	// users in the server.
+	GetMemberInstances(ctx context.Context, ulid string) ([]string, error)
+	UpdateServer(ctx context.Context, ulid string, server *models.Server) error
+	UpdateServerMFARequirement(ctx context.Context, ulid string, required bool) error
+	DeleteServer(ctx context.Context, ulid string) error
+	AddUserToServer(ctx context.Context, userID, serverID, role string) error
+	RemoveUserFromServer(ctx context.Context, userID, serverID string) error
+	UpdateUserRoleInServer(ctx context.Context, userID, serverID, newRole string) error
+	SetMemberTimeout(ctx context.Context, userID, serverID string, until *time.Time) error
+
+	CreateBan(ctx context.Context, ban *models.ServerBan) error
+	GetBan(ctx context.Context, userID, serverID string) (*models.ServerBan, error)
+	GetBansByServer(ctx context.Context, s_id string) ([]*models.ServerBan, error)
+	DeleteBan(ctx context.Context, userID, serverID string) error
 }
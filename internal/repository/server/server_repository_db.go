@@ -1,9 +1,12 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"rio/internal/apperr"
 	"rio/internal/db"
 	"rio/internal/models"
+	"time"
 
 	"github.com/jinzhu/gorm"
 )
@@ -14,20 +17,27 @@ func NewDBServerRepository() *DBServerRepository {
 	return &DBServerRepository{}
 }
 
-func (r *DBServerRepository) Create(server *models.Server) error {
+func (r *DBServerRepository) Create(ctx context.Context, server *models.Server) error {
 	if server.ULID == "" {
-		return errors.New("server ULID is empty")
+		return apperr.Invalid("server ULID is empty")
 	}
-	return db.DB.Create(server).Error
+	return db.WithContext(ctx).Create(server).Error
 }
 
-func (r *DBServerRepository) CreateMembership(membership *models.UserServer) error {
-	return db.DB.Create(membership).Error
+func (r *DBServerRepository) CreateMembership(ctx context.Context, membership *models.UserServer) error {
+	if membership.JoinedAt.IsZero() {
+		membership.JoinedAt = time.Now()
+	}
+	err := db.WithContext(ctx).Create(membership).Error
+	if db.IsUniqueViolation(err) {
+		return apperr.Conflict("user is already a member of this server")
+	}
+	return err
 }
 
-func (r *DBServerRepository) GetUserMembership(u_id, s_id string) (*models.UserServer, error) {
+func (r *DBServerRepository) GetUserMembership(ctx context.Context, u_id, s_id string) (*models.UserServer, error) {
 	var membership models.UserServer
-	err := db.DB.
+	err := db.WithContext(ctx).
 		Where("user_id = ? AND server_id = ?", u_id, s_id).
 		First(&membership).Error
 
@@ -41,9 +51,9 @@ func (r *DBServerRepository) GetUserMembership(u_id, s_id string) (*models.UserS
 	return &membership, nil
 }
 
-func (r *DBServerRepository) GetServerByID(ulid string) (*models.Server, error) {
+func (r *DBServerRepository) GetServerByID(ctx context.Context, ulid string) (*models.Server, error) {
 	var s models.Server
-	err := db.DB.Where("ul_id = ?", ulid).First(&s).Error
+	err := db.WithContext(ctx).Where("ul_id = ?", ulid).First(&s).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -55,10 +65,10 @@ func (r *DBServerRepository) GetServerByID(ulid string) (*models.Server, error)
 
 }
 
-func (r *DBServerRepository) GetServersByUser(u_id string) ([]*models.Server, error) {
+func (r *DBServerRepository) GetServersByUser(ctx context.Context, u_id string) ([]*models.Server, error) {
 	var servers []*models.Server
 
-	err := db.DB.
+	err := db.WithContext(ctx).
 		Joins("JOIN user_servers ON user_servers.server_id = servers.ul_id").
 		Where("user_servers.user_id = ?", u_id).
 		Find(&servers).Error
@@ -69,10 +79,10 @@ func (r *DBServerRepository) GetServersByUser(u_id string) ([]*models.Server, er
 	return servers, nil
 }
 
-func (r *DBServerRepository) GetServerMembers(ulid string) ([]*models.User, error) {
+func (r *DBServerRepository) GetServerMembers(ctx context.Context, ulid string) ([]*models.User, error) {
 	var members []*models.User
 
-	err := db.DB.
+	err := db.WithContext(ctx).
 		Joins("JOIN user_servers ON user_servers.user_id = users.ul_id").
 		Where("user_servers.server_id = ?", ulid).
 		Find(&members).Error
@@ -83,8 +93,22 @@ func (r *DBServerRepository) GetServerMembers(ulid string) ([]*models.User, erro
 	return members, nil
 }
 
-func (r *DBServerRepository) UpdateServer(ulid string, server *models.Server) error {
-	result := db.DB.Model(&models.Server{}).
+func (r *DBServerRepository) GetMemberInstances(ctx context.Context, ulid string) ([]string, error) {
+	var instances []string
+
+	err := db.WithContext(ctx).Model(&models.User{}).
+		Joins("JOIN user_servers ON user_servers.user_id = users.ul_id").
+		Where("user_servers.server_id = ? AND users.instance <> ''", ulid).
+		Pluck("DISTINCT users.instance", &instances).Error
+
+	if err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
+func (r *DBServerRepository) UpdateServer(ctx context.Context, ulid string, server *models.Server) error {
+	result := db.WithContext(ctx).Model(&models.Server{}).
 		Where("ul_id = ?", ulid).
 		Update("name", server.Name)
 
@@ -93,83 +117,192 @@ func (r *DBServerRepository) UpdateServer(ulid string, server *models.Server) er
 	}
 
 	if result.RowsAffected == 0 {
-		return errors.New("server not found or no changes applied")
+		return apperr.NotFound("server not found or no changes applied")
 	}
 
 	return nil
 }
 
-func (r *DBServerRepository) DeleteServer(ulid string) error {
-	result := db.DB.Where("ul_id = ?", ulid).Delete(&models.Server{})
+func (r *DBServerRepository) UpdateServerMFARequirement(ctx context.Context, ulid string, required bool) error {
+	result := db.WithContext(ctx).Model(&models.Server{}).
+		Where("ul_id = ?", ulid).
+		Update("require_mfa", required)
 
 	if result.Error != nil {
 		return result.Error
 	}
 
 	if result.RowsAffected == 0 {
-		return errors.New("server not found or already deleted")
+		return apperr.NotFound("server not found or no changes applied")
 	}
 
 	return nil
 }
 
-func (r *DBServerRepository) AddUserToServer(userID, serverID, role string) error {
-	var userCount, serverCount int64
-	db.DB.Model(&models.User{}).Where("ul_id = ?", userID).Count(&userCount)
-	db.DB.Model(&models.Server{}).Where("ul_id = ?", serverID).Count(&serverCount)
+// DeleteServer deletes the server along with everything that belongs to
+// it, in one transaction. The schema has no foreign keys to cascade the
+// delete, so each dependent table is cleared here, children before their
+// parents.
+func (r *DBServerRepository) DeleteServer(ctx context.Context, ulid string) error {
+	return db.Transaction(ctx, func(ctx context.Context) error {
+		tx := db.WithContext(ctx)
+
+		result := tx.Where("ul_id = ?", ulid).Delete(&models.Server{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apperr.NotFound("server not found or already deleted")
+		}
 
-	if userCount == 0 {
-		return errors.New("user not found")
+		const channels = "SELECT ul_id FROM channels WHERE server_id = ?"
+		dependents := []struct {
+			model interface{}
+			where string
+		}{
+			{&models.Attachment{}, "message_id IN (SELECT ul_id FROM messages WHERE channel_id IN (" + channels + "))"},
+			{&models.Message{}, "channel_id IN (" + channels + ")"},
+			{&models.WebhookDelivery{}, "webhook_id IN (SELECT ul_id FROM webhooks WHERE server_id = ?)"},
+			{&models.Channel{}, "server_id = ?"},
+			{&models.Webhook{}, "server_id = ?"},
+			{&models.IncomingWebhook{}, "server_id = ?"},
+			{&models.ChannelEmail{}, "server_id = ?"},
+			{&models.Invite{}, "server_id = ?"},
+			{&models.ApplicationCommand{}, "server_id = ?"},
+			{&models.Interaction{}, "server_id = ?"},
+			{&models.ServerBan{}, "server_id = ?"},
+			{&models.UserServer{}, "server_id = ?"},
+		}
+		for _, d := range dependents {
+			if err := tx.Unscoped().Where(d.where, ulid).Delete(d.model).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AddUserToServer checks the user and server exist in the same transaction
+// as it inserts the membership; the primary key refuses a second one.
+func (r *DBServerRepository) AddUserToServer(ctx context.Context, userID, serverID, role string) error {
+	return db.Transaction(ctx, func(ctx context.Context) error {
+		tx := db.WithContext(ctx)
+
+		var userCount, serverCount int64
+		if err := tx.Model(&models.User{}).Where("ul_id = ?", userID).Count(&userCount).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Server{}).Where("ul_id = ?", serverID).Count(&serverCount).Error; err != nil {
+			return err
+		}
+
+		if userCount == 0 {
+			return apperr.NotFound("user not found")
+		}
+		if serverCount == 0 {
+			return apperr.NotFound("server not found")
+		}
+
+		return r.CreateMembership(ctx, &models.UserServer{
+			UserID:   userID,
+			ServerID: serverID,
+			Role:     role,
+		})
+	})
+}
+
+func (r *DBServerRepository) RemoveUserFromServer(ctx context.Context, userID, serverID string) error {
+	result := db.WithContext(ctx).
+		Where("user_id = ? AND server_id = ?", userID, serverID).
+		Delete(&models.UserServer{})
+
+	if result.Error != nil {
+		return result.Error
 	}
-	if serverCount == 0 {
-		return errors.New("server not found")
+
+	if result.RowsAffected == 0 {
+		return apperr.NotFound("membership not found (user may not be a member of the server)")
 	}
 
-	var existingCount int64
-	db.DB.Model(&models.UserServer{}).
+	return nil
+}
+
+func (r *DBServerRepository) UpdateUserRoleInServer(ctx context.Context, userID, serverID, newRole string) error {
+	result := db.WithContext(ctx).Model(&models.UserServer{}).
 		Where("user_id = ? AND server_id = ?", userID, serverID).
-		Count(&existingCount)
+		Update("role", newRole)
 
-	if existingCount > 0 {
-		return errors.New("user is already a member of this server")
+	if result.Error != nil {
+		return result.Error
 	}
 
-	membership := models.UserServer{
-		UserID:   userID,
-		ServerID: serverID,
-		Role:     role,
+	if result.RowsAffected == 0 {
+		return apperr.NotFound("membership not found (user is not a member of this server, or user/server does not exist)")
 	}
 
-	return db.DB.Create(&membership).Error
+	return nil
 }
 
-func (r *DBServerRepository) RemoveUserFromServer(userID, serverID string) error {
-	result := db.DB.
+func (r *DBServerRepository) SetMemberTimeout(ctx context.Context, userID, serverID string, until *time.Time) error {
+	result := db.WithContext(ctx).Model(&models.UserServer{}).
 		Where("user_id = ? AND server_id = ?", userID, serverID).
-		Delete(&models.UserServer{})
+		Update("timeout_until", until)
 
 	if result.Error != nil {
 		return result.Error
 	}
 
 	if result.RowsAffected == 0 {
-		return errors.New("membership not found (user may not be a member of the server)")
+		return apperr.NotFound("membership not found (user is not a member of this server, or user/server does not exist)")
 	}
 
 	return nil
 }
 
-func (r *DBServerRepository) UpdateUserRoleInServer(userID, serverID, newRole string) error {
-	result := db.DB.Model(&models.UserServer{}).
+func (r *DBServerRepository) CreateBan(ctx context.Context, ban *models.ServerBan) error {
+	err := db.WithContext(ctx).Create(ban).Error
+	if db.IsUniqueViolation(err) {
+		return apperr.Conflict("user is already banned")
+	}
+	return err
+}
+
+func (r *DBServerRepository) GetBan(ctx context.Context, userID, serverID string) (*models.ServerBan, error) {
+	var ban models.ServerBan
+	err := db.WithContext(ctx).
 		Where("user_id = ? AND server_id = ?", userID, serverID).
-		Update("role", newRole)
+		First(&ban).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &ban, nil
+}
+
+func (r *DBServerRepository) GetBansByServer(ctx context.Context, s_id string) ([]*models.ServerBan, error) {
+	var bans []*models.ServerBan
+	err := db.WithContext(ctx).Where("server_id = ?", s_id).Order("created_at desc").Find(&bans).Error
+	if err != nil {
+		return nil, err
+	}
+	return bans, nil
+}
+
+func (r *DBServerRepository) DeleteBan(ctx context.Context, userID, serverID string) error {
+	result := db.WithContext(ctx).
+		Where("user_id = ? AND server_id = ?", userID, serverID).
+		Delete(&models.ServerBan{})
 
 	if result.Error != nil {
 		return result.Error
 	}
 
 	if result.RowsAffected == 0 {
-		return errors.New("membership not found (user is not a member of this server, or user/server does not exist)")
+		return apperr.NotFound("ban not found")
 	}
 
 	return nil
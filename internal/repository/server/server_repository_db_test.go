@@ -0,0 +1,93 @@
+//go:build cgo
+
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/db"
+	"rio/internal/db/dbtest"
+	"rio/internal/models"
+	userRepo "rio/internal/repository/user"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// populate gives the server one row in every table that refers to it.
+func populate(t *testing.T, server *models.Server, member *models.User) {
+	t.Helper()
+	id := func() string { return ulid.Make().String() }
+
+	channel := &models.Channel{ULID: id(), ServerID: server.ULID, Name: "general"}
+	message := &models.Message{ULID: id(), ChannelID: channel.ULID, UserID: member.ULID, Content: "hello"}
+	webhook := &models.Webhook{ULID: id(), ServerID: server.ULID, URL: "https://example.com", Secret: "s", Events: "*", Active: true}
+	records := []interface{}{
+		channel,
+		message,
+		&models.Attachment{ULID: id(), MessageID: message.ULID, Filename: "a.txt", ContentType: "text/plain", Data: []byte("a")},
+		webhook,
+		&models.WebhookDelivery{ULID: id(), WebhookID: webhook.ULID, EventID: id(), EventType: "message.created", Payload: "{}", Status: models.WebhookDeliveryPending},
+		&models.IncomingWebhook{ULID: id(), ChannelID: channel.ULID, ServerID: server.ULID, Name: "hook", TokenHash: id()},
+		&models.ChannelEmail{ULID: id(), ChannelID: channel.ULID, ServerID: server.ULID, Name: "mail", LocalPart: id()},
+		&models.Invite{Code: id()[10:], ServerID: server.ULID, CreatedBy: member.ULID, MaxUses: 1},
+		&models.ApplicationCommand{ULID: id(), ApplicationID: id(), ServerID: server.ULID, Name: "ping", Description: "ping"},
+		&models.Interaction{ULID: id(), ApplicationID: id(), CommandID: id(), ServerID: server.ULID, ChannelID: channel.ULID, UserID: member.ULID, CommandName: "ping", Status: models.InteractionPending, ExpiresAt: time.Now().Add(time.Minute)},
+		&models.ServerBan{UserID: id(), ServerID: server.ULID, BannedBy: member.ULID},
+	}
+	for _, r := range records {
+		if err := db.DB.Create(r).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDBServerRepository(t *testing.T) {
+	dbtest.Open(t)
+	testServerRepository(t, NewDBServerRepository(), userRepo.NewDBUserRepository())
+}
+
+// TestDBDeleteServer checks that DeleteServer takes every row of the
+// server with it, and only those.
+func TestDBDeleteServer(t *testing.T) {
+	dbtest.Open(t)
+	ctx := context.Background()
+	repo := NewDBServerRepository()
+
+	alice := createUser(t, userRepo.NewDBUserRepository(), "alice")
+	server := createServer(t, repo, alice, "Rio Dev")
+
+	doomed := createServer(t, repo, alice, "Doomed")
+	populate(t, doomed, alice)
+	populate(t, server, alice)
+
+	if err := repo.DeleteServer(ctx, doomed.ULID); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteServer(ctx, doomed.ULID); !errors.Is(err, apperr.ErrNotFound) {
+		t.Fatalf("deleting the server again: %v; want not found", err)
+	}
+
+	// Only the rows of the server that was kept are left.
+	for _, model := range []interface{}{
+		&models.UserServer{}, &models.ServerBan{}, &models.Channel{}, &models.Message{},
+		&models.Attachment{}, &models.Webhook{}, &models.WebhookDelivery{}, &models.IncomingWebhook{},
+		&models.ChannelEmail{}, &models.Invite{}, &models.ApplicationCommand{}, &models.Interaction{},
+	} {
+		var count int
+		if err := db.DB.Unscoped().Model(model).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Errorf("%T: %d rows left; want 1", model, count)
+		}
+	}
+
+	kept, err := repo.GetServerByID(ctx, server.ULID)
+	if err != nil || kept == nil {
+		t.Fatalf("GetServerByID(kept) = %+v, %v", kept, err)
+	}
+}
@@ -1,153 +1,301 @@
 package repository
 
 import (
-	"errors"
+	"cmp"
+	"context"
+	"rio/internal/apperr"
 	"rio/internal/models"
 	"rio/internal/store"
+	"slices"
+	"time"
 )
 
-type InMemoryServerRepository struct{}
+type InMemoryServerRepository struct {
+	store *store.Store
+}
 
-func NewInMemoryServerRepository() *InMemoryServerRepository {
-	return &InMemoryServerRepository{}
+func NewInMemoryServerRepository(s *store.Store) *InMemoryServerRepository {
+	return &InMemoryServerRepository{store: s}
 }
 
-func (r *InMemoryServerRepository) Create(server *models.Server) error {
-	for _, s := range store.Servers {
-		if s.ULID == server.ULID {
-			return errors.New("server with this ULID already exists")
-		}
+func (r *InMemoryServerRepository) Create(ctx context.Context, server *models.Server) error {
+	if server.ULID == "" {
+		return apperr.Invalid("server ULID is empty")
 	}
-	store.Servers = append(store.Servers, *server)
-	return nil
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		if t.Servers.Has(server.ULID) {
+			return apperr.Conflict("server with this ULID already exists")
+		}
+		t.Stamp(&server.Model)
+		t.Servers.Put(server.ULID, *server)
+		return nil
+	})
 }
 
-func (r *InMemoryServerRepository) GetServerByID(ulid string) (*models.Server, error) {
-	for i := range store.Servers {
-		if store.Servers[i].ULID == ulid {
-			return &store.Servers[i], nil
+func (r *InMemoryServerRepository) CreateMembership(ctx context.Context, membership *models.UserServer) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		key := store.Membership{ServerID: membership.ServerID, UserID: membership.UserID}
+		if t.Members.Has(key) {
+			return apperr.Conflict("user is already a member of this server")
 		}
-	}
-	return nil, errors.New("server not found")
+		if membership.JoinedAt.IsZero() {
+			membership.JoinedAt = time.Now()
+		}
+		t.Members.Put(key, *membership)
+		return nil
+	})
 }
 
-func (r *InMemoryServerRepository) GetServersByUser(u_id string) ([]*models.Server, error) {
-	var userServers []*models.Server
+func (r *InMemoryServerRepository) GetUserMembership(ctx context.Context, u_id, s_id string) (*models.UserServer, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.UserServer {
+		return t.Members.Get(store.Membership{ServerID: s_id, UserID: u_id})
+	})
+}
 
-	for _, us := range store.UserServers {
-		if us.UserID == u_id {
-			for i := range store.Servers {
-				if store.Servers[i].ULID == us.ServerID {
-					userServers = append(userServers, &store.Servers[i])
-					break
-				}
+func (r *InMemoryServerRepository) GetServerByID(ctx context.Context, ulid string) (*models.Server, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.Server {
+		return t.Servers.Get(ulid)
+	})
+}
+
+func (r *InMemoryServerRepository) GetServersByUser(ctx context.Context, u_id string) ([]*models.Server, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) []*models.Server {
+		var servers []*models.Server
+		for key := range t.Members.All() {
+			if key.UserID != u_id {
+				continue
+			}
+			if s := t.Servers.Get(key.ServerID); s != nil {
+				servers = append(servers, s)
 			}
 		}
-	}
+		slices.SortFunc(servers, func(a, b *models.Server) int {
+			return cmp.Compare(a.ID, b.ID)
+		})
+		return servers
+	})
+}
 
-	return userServers, nil
+func (r *InMemoryServerRepository) GetServerMembers(ctx context.Context, ulid string) ([]*models.User, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) []*models.User {
+		return serverMembers(t, ulid)
+	})
 }
 
-func (r *InMemoryServerRepository) GetServerMembers(ulid string) ([]*models.User, error) {
+func serverMembers(t *store.Tables, serverID string) []*models.User {
 	var members []*models.User
-
-	for _, us := range store.UserServers {
-		if us.ServerID == ulid {
-			for i := range store.Users {
-				if store.Users[i].ULID == us.UserID {
-					members = append(members, &store.Users[i])
-					break
-				}
-			}
+	for key := range t.Members.All() {
+		if key.ServerID != serverID {
+			continue
+		}
+		if u := t.Users.Get(key.UserID); u != nil {
+			members = append(members, u)
 		}
 	}
+	slices.SortFunc(members, func(a, b *models.User) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return members
+}
 
-	return members, nil
+func (r *InMemoryServerRepository) GetMemberInstances(ctx context.Context, ulid string) ([]string, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) []string {
+		var instances []string
+		for _, m := range serverMembers(t, ulid) {
+			if m.Instance != "" && !slices.Contains(instances, m.Instance) {
+				instances = append(instances, m.Instance)
+			}
+		}
+		return instances
+	})
 }
 
-func (r *InMemoryServerRepository) UpdateServer(ulid string, server *models.Server) error {
-	for i := range store.Servers {
-		if store.Servers[i].ULID == ulid {
-			store.Servers[i].Name = server.Name
-			return nil
+// updateServer applies fn to the stored server with the given ULID.
+func (r *InMemoryServerRepository) updateServer(ctx context.Context, ulid string, fn func(s *models.Server)) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		s, ok := t.Servers.Lookup(ulid)
+		if !ok {
+			return apperr.NotFound("server not found or no changes applied")
 		}
-	}
-	return errors.New("server not found or no changes applied")
+		fn(&s)
+		t.Servers.Put(ulid, s)
+		return nil
+	})
+}
+
+func (r *InMemoryServerRepository) UpdateServer(ctx context.Context, ulid string, server *models.Server) error {
+	return r.updateServer(ctx, ulid, func(s *models.Server) {
+		s.Name = server.Name
+	})
+}
+
+func (r *InMemoryServerRepository) UpdateServerMFARequirement(ctx context.Context, ulid string, required bool) error {
+	return r.updateServer(ctx, ulid, func(s *models.Server) {
+		s.RequireMFA = required
+	})
 }
 
-func (r *InMemoryServerRepository) DeleteServer(ulid string) error {
-	for i := range store.Servers {
-		if store.Servers[i].ULID == ulid {
-			store.Servers = append(store.Servers[:i], store.Servers[i+1:]...)
+func (r *InMemoryServerRepository) DeleteServer(ctx context.Context, ulid string) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		if !t.Servers.Has(ulid) {
+			return apperr.NotFound("server not found or already deleted")
+		}
+		t.Servers.Delete(ulid)
 
-			var newUserServers []models.UserServer
-			for _, us := range store.UserServers {
-				if us.ServerID != ulid {
-					newUserServers = append(newUserServers, us)
+		for key := range t.Members.All() {
+			if key.ServerID == ulid {
+				t.Members.Delete(key)
+			}
+		}
+		for key := range t.ServerBans.All() {
+			if key.ServerID == ulid {
+				t.ServerBans.Delete(key)
+			}
+		}
+
+		for id, c := range t.Channels.All() {
+			if c.ServerID != ulid {
+				continue
+			}
+			messages, _ := t.MessagesByCh.Lookup(id)
+			for _, m := range messages {
+				t.Messages.Delete(m)
+			}
+			for a, att := range t.Attachments.All() {
+				if slices.Contains(messages, att.MessageID) {
+					t.Attachments.Delete(a)
 				}
 			}
-			store.UserServers = newUserServers
+			t.MessagesByCh.Delete(id)
+			t.Channels.Delete(id)
+		}
 
-			return nil
+		for id, w := range t.Webhooks.All() {
+			if w.ServerID != ulid {
+				continue
+			}
+			for d, delivery := range t.WebhookDeliveries.All() {
+				if delivery.WebhookID == id {
+					t.WebhookDeliveries.Delete(d)
+				}
+			}
+			t.Webhooks.Delete(id)
 		}
-	}
-	return errors.New("server not found or already deleted")
+
+		deleteWhere(t.IncomingWebhooks, func(h *models.IncomingWebhook) bool { return h.ServerID == ulid })
+		deleteWhere(t.ChannelEmails, func(e *models.ChannelEmail) bool { return e.ServerID == ulid })
+		deleteWhere(t.Invites, func(i *models.Invite) bool { return i.ServerID == ulid })
+		deleteWhere(t.Commands, func(c *models.ApplicationCommand) bool { return c.ServerID == ulid })
+		deleteWhere(t.Interactions, func(i *models.Interaction) bool { return i.ServerID == ulid })
+		return nil
+	})
 }
 
-func (r *InMemoryServerRepository) AddUserToServer(userID, serverID, role string) error {
-	var userExists bool
-	for _, u := range store.Users {
-		if u.ULID == userID {
-			userExists = true
-			break
+// deleteWhere deletes the records of table that match.
+func deleteWhere[V any](table *store.Table[string, V], match func(v *V) bool) {
+	for key, v := range table.All() {
+		if match(&v) {
+			table.Delete(key)
 		}
 	}
-	if !userExists {
-		return errors.New("user not found")
-	}
+}
 
-	var serverExists bool
-	for _, s := range store.Servers {
-		if s.ULID == serverID {
-			serverExists = true
-			break
+func (r *InMemoryServerRepository) AddUserToServer(ctx context.Context, userID, serverID, role string) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		if !t.Users.Has(userID) {
+			return apperr.NotFound("user not found")
+		}
+		if !t.Servers.Has(serverID) {
+			return apperr.NotFound("server not found")
 		}
-	}
-	if !serverExists {
-		return errors.New("server not found")
-	}
 
-	for _, us := range store.UserServers {
-		if us.UserID == userID && us.ServerID == serverID {
-			return errors.New("user is already a member of this server")
+		key := store.Membership{ServerID: serverID, UserID: userID}
+		if t.Members.Has(key) {
+			return apperr.Conflict("user is already a member of this server")
 		}
-	}
 
-	store.UserServers = append(store.UserServers, models.UserServer{
-		UserID:   userID,
-		ServerID: serverID,
-		Role:     role,
+		t.Members.Put(key, models.UserServer{
+			UserID:   userID,
+			ServerID: serverID,
+			Role:     role,
+			JoinedAt: time.Now(),
+		})
+		return nil
 	})
+}
 
-	return nil
+func (r *InMemoryServerRepository) RemoveUserFromServer(ctx context.Context, userID, serverID string) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		key := store.Membership{ServerID: serverID, UserID: userID}
+		if !t.Members.Has(key) {
+			return apperr.NotFound("membership not found (user may not be a member of the server)")
+		}
+		t.Members.Delete(key)
+		return nil
+	})
 }
 
-func (r *InMemoryServerRepository) RemoveUserFromServer(userID, serverID string) error {
-	for i, us := range store.UserServers {
-		if us.UserID == userID && us.ServerID == serverID {
-			store.UserServers = append(store.UserServers[:i], store.UserServers[i+1:]...)
-			return nil
+// updateMembership applies fn to the stored membership of userID in serverID.
+func (r *InMemoryServerRepository) updateMembership(ctx context.Context, userID, serverID string, fn func(m *models.UserServer)) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		key := store.Membership{ServerID: serverID, UserID: userID}
+		m, ok := t.Members.Lookup(key)
+		if !ok {
+			return apperr.NotFound("membership not found (user is not a member of this server, or user/server does not exist)")
 		}
-	}
-	return errors.New("membership not found (user may not be a member of the server)")
+		fn(&m)
+		t.Members.Put(key, m)
+		return nil
+	})
+}
+
+func (r *InMemoryServerRepository) UpdateUserRoleInServer(ctx context.Context, userID, serverID, newRole string) error {
+	return r.updateMembership(ctx, userID, serverID, func(m *models.UserServer) {
+		m.Role = newRole
+	})
+}
+
+func (r *InMemoryServerRepository) SetMemberTimeout(ctx context.Context, userID, serverID string, until *time.Time) error {
+	return r.updateMembership(ctx, userID, serverID, func(m *models.UserServer) {
+		m.TimeoutUntil = until
+	})
 }
 
-func (r *InMemoryServerRepository) UpdateUserRoleInServer(userID, serverID, newRole string) error {
-	for i := range store.UserServers {
-		if store.UserServers[i].UserID == userID && store.UserServers[i].ServerID == serverID {
-			store.UserServers[i].Role = newRole
-			return nil
+func (r *InMemoryServerRepository) CreateBan(ctx context.Context, ban *models.ServerBan) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		key := store.Membership{ServerID: ban.ServerID, UserID: ban.UserID}
+		if t.ServerBans.Has(key) {
+			return apperr.Conflict("user is already banned")
 		}
-	}
-	return errors.New("membership not found (user is not a member of this server, or user/server does not exist)")
+		ban.CreatedAt = time.Now()
+		t.ServerBans.Put(key, *ban)
+		return nil
+	})
+}
+
+func (r *InMemoryServerRepository) GetBan(ctx context.Context, userID, serverID string) (*models.ServerBan, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.ServerBan {
+		return t.ServerBans.Get(store.Membership{ServerID: serverID, UserID: userID})
+	})
+}
+
+func (r *InMemoryServerRepository) GetBansByServer(ctx context.Context, s_id string) ([]*models.ServerBan, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) []*models.ServerBan {
+		return t.ServerBans.Filter(func(b *models.ServerBan) bool {
+			return b.ServerID == s_id
+		}, func(a, b *models.ServerBan) int {
+			return b.CreatedAt.Compare(a.CreatedAt)
+		})
+	})
+}
+
+func (r *InMemoryServerRepository) DeleteBan(ctx context.Context, userID, serverID string) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		key := store.Membership{ServerID: serverID, UserID: userID}
+		if !t.ServerBans.Has(key) {
+			return apperr.NotFound("ban not found")
+		}
+		t.ServerBans.Delete(key)
+		return nil
+	})
 }
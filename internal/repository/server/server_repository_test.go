@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	userRepo "rio/internal/repository/user"
+	"rio/internal/store"
+
+	"github.com/oklog/ulid/v2"
+)
+
+func createUser(t *testing.T, users userRepo.UserRepository, username string) *models.User {
+	t.Helper()
+	user := &models.User{ULID: ulid.Make().String(), Username: username, Password: "hash"}
+	if err := users.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func createServer(t *testing.T, repo ServerRepository, owner *models.User, name string) *models.Server {
+	t.Helper()
+	server := &models.Server{ULID: ulid.Make().String(), Name: name, OwnerID: owner.ULID}
+	if err := repo.Create(context.Background(), server); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AddUserToServer(context.Background(), owner.ULID, server.ULID, "owner"); err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+// testServerRepository checks the behaviour both backends share, down to
+// the errors they fail with. users must see the same records as repo.
+func testServerRepository(t *testing.T, repo ServerRepository, users userRepo.UserRepository) {
+	ctx := context.Background()
+	alice := createUser(t, users, "alice")
+	bob := createUser(t, users, "bob")
+	server := createServer(t, repo, alice, "Rio Dev")
+
+	t.Run("memberships", func(t *testing.T) {
+		if err := repo.AddUserToServer(ctx, bob.ULID, server.ULID, "member"); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.AddUserToServer(ctx, bob.ULID, server.ULID, "member"); !errors.Is(err, apperr.ErrConflict) {
+			t.Fatalf("adding bob twice: %v; want a conflict", err)
+		}
+		if err := repo.AddUserToServer(ctx, ulid.Make().String(), server.ULID, "member"); !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("adding an unknown user: %v; want not found", err)
+		}
+
+		members, err := repo.GetServerMembers(ctx, server.ULID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(members) != 2 {
+			t.Fatalf("server has %d members; want alice and bob", len(members))
+		}
+
+		servers, err := repo.GetServersByUser(ctx, bob.ULID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(servers) != 1 || servers[0].ULID != server.ULID {
+			t.Fatalf("bob's servers = %+v; want Rio Dev", servers)
+		}
+
+		if err := repo.RemoveUserFromServer(ctx, bob.ULID, server.ULID); err != nil {
+			t.Fatal(err)
+		}
+		membership, err := repo.GetUserMembership(ctx, bob.ULID, server.ULID)
+		if err != nil || membership != nil {
+			t.Fatalf("bob's membership after removal = %+v, %v; want none", membership, err)
+		}
+	})
+
+	t.Run("bans", func(t *testing.T) {
+		ban := &models.ServerBan{UserID: bob.ULID, ServerID: server.ULID, BannedBy: alice.ULID}
+		if err := repo.CreateBan(ctx, ban); err != nil {
+			t.Fatal(err)
+		}
+		again := &models.ServerBan{UserID: bob.ULID, ServerID: server.ULID, BannedBy: alice.ULID}
+		if err := repo.CreateBan(ctx, again); !errors.Is(err, apperr.ErrConflict) {
+			t.Fatalf("banning bob twice: %v; want a conflict", err)
+		}
+		if err := repo.DeleteBan(ctx, bob.ULID, server.ULID); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+}
+
+func TestInMemoryServerRepository(t *testing.T) {
+	s := store.New()
+	testServerRepository(t, NewInMemoryServerRepository(s), userRepo.NewInMemoryUserRepository(s))
+}
@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"rio/internal/models"
+)
+
+type SessionRepository interface {
+	Create(ctx context.Context, session *models.Session) error
+	GetSessionByID(ctx context.Context, ulid string) (*models.Session, error)
+	GetActiveSessionsByUser(ctx context.Context, u_id string) ([]*models.Session, error)
+	RevokeSession(ctx context.Context, ulid string) error
+	RevokeUserSessionsExcept(ctx context.Context, u_id, keepID string) ([]string, error)
+	TouchSession(ctx context.Context, ulid string, seenAt time.Time) error
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/db"
+	"rio/internal/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+type DBSessionRepository struct{}
+
+func NewDBSessionRepository() *DBSessionRepository {
+	return &DBSessionRepository{}
+}
+
+func (r *DBSessionRepository) Create(ctx context.Context, session *models.Session) error {
+	err := db.WithContext(ctx).Create(session).Error
+	if db.IsUniqueViolation(err) {
+		return apperr.Conflict("session with this ULID already exists")
+	}
+	return err
+}
+
+func (r *DBSessionRepository) GetSessionByID(ctx context.Context, ulid string) (*models.Session, error) {
+	var s models.Session
+	err := db.WithContext(ctx).Where("ul_id = ?", ulid).First(&s).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *DBSessionRepository) GetActiveSessionsByUser(ctx context.Context, u_id string) ([]*models.Session, error) {
+	var sessions []*models.Session
+	err := db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL", u_id).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (r *DBSessionRepository) RevokeSession(ctx context.Context, ulid string) error {
+	result := db.WithContext(ctx).Model(&models.Session{}).
+		Where("ul_id = ? AND revoked_at IS NULL", ulid).
+		Update("revoked_at", time.Now())
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.NotFound("session not found or already revoked")
+	}
+
+	return nil
+}
+
+func (r *DBSessionRepository) RevokeUserSessionsExcept(ctx context.Context, u_id, keepID string) ([]string, error) {
+	var ids []string
+	err := db.WithContext(ctx).Model(&models.Session{}).
+		Where("user_id = ? AND ul_id <> ? AND revoked_at IS NULL", u_id, keepID).
+		Pluck("ul_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return ids, nil
+	}
+
+	err = db.WithContext(ctx).Model(&models.Session{}).
+		Where("ul_id IN (?)", ids).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *DBSessionRepository) TouchSession(ctx context.Context, ulid string, seenAt time.Time) error {
+	return db.WithContext(ctx).Model(&models.Session{}).
+		Where("ul_id = ?", ulid).
+		UpdateColumn("last_seen_at", seenAt).Error
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+)
+
+type InMemorySessionRepository struct {
+	store *store.Store
+}
+
+func NewInMemorySessionRepository(s *store.Store) *InMemorySessionRepository {
+	return &InMemorySessionRepository{store: s}
+}
+
+func (r *InMemorySessionRepository) Create(ctx context.Context, session *models.Session) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		if t.Sessions.Has(session.ULID) {
+			return apperr.Conflict("session with this ULID already exists")
+		}
+		t.Stamp(&session.Model)
+		t.Sessions.Put(session.ULID, *session)
+		return nil
+	})
+}
+
+func (r *InMemorySessionRepository) GetSessionByID(ctx context.Context, ulid string) (*models.Session, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.Session {
+		return t.Sessions.Get(ulid)
+	})
+}
+
+func (r *InMemorySessionRepository) GetActiveSessionsByUser(ctx context.Context, u_id string) ([]*models.Session, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) []*models.Session {
+		return t.Sessions.Filter(func(s *models.Session) bool {
+			return s.UserID == u_id && s.RevokedAt == nil
+		}, func(a, b *models.Session) int {
+			return b.LastSeenAt.Compare(a.LastSeenAt)
+		})
+	})
+}
+
+func (r *InMemorySessionRepository) RevokeSession(ctx context.Context, ulid string) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		s, ok := t.Sessions.Lookup(ulid)
+		if !ok || s.RevokedAt != nil {
+			return apperr.NotFound("session not found or already revoked")
+		}
+		now := time.Now()
+		s.RevokedAt = &now
+		t.Sessions.Put(ulid, s)
+		return nil
+	})
+}
+
+func (r *InMemorySessionRepository) RevokeUserSessionsExcept(ctx context.Context, u_id, keepID string) ([]string, error) {
+	var ids []string
+	err := r.store.Update(ctx, func(t *store.Tables) error {
+		now := time.Now()
+		for id, s := range t.Sessions.All() {
+			if s.UserID == u_id && s.ULID != keepID && s.RevokedAt == nil {
+				s.RevokedAt = &now
+				t.Sessions.Put(id, s)
+				ids = append(ids, s.ULID)
+			}
+		}
+		return nil
+	})
+	return ids, err
+}
+
+func (r *InMemorySessionRepository) TouchSession(ctx context.Context, ulid string, seenAt time.Time) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		if s, ok := t.Sessions.Lookup(ulid); ok {
+			s.LastSeenAt = seenAt
+			t.Sessions.Put(ulid, s)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// testSessionRepository checks the behaviour both backends share, down to
+// the errors they fail with.
+func testSessionRepository(t *testing.T, repo SessionRepository) {
+	ctx := context.Background()
+	user := ulid.Make().String()
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	var sessions []*models.Session
+	for i := range 3 {
+		s := &models.Session{ULID: ulid.Make().String(), UserID: user, DeviceName: "laptop", LastSeenAt: start.Add(time.Duration(i) * time.Minute)}
+		if err := repo.Create(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, s)
+	}
+	other := &models.Session{ULID: ulid.Make().String(), UserID: ulid.Make().String(), LastSeenAt: start}
+	if err := repo.Create(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+
+	// active returns the IDs of the user's active sessions, most recently
+	// seen first.
+	active := func(t *testing.T) []string {
+		t.Helper()
+		found, err := repo.GetActiveSessionsByUser(ctx, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, s := range found {
+			ids = append(ids, s.ULID)
+		}
+		return ids
+	}
+
+	t.Run("Create refuses a session ID twice", func(t *testing.T) {
+		again := &models.Session{ULID: sessions[0].ULID, UserID: user}
+		if err := repo.Create(ctx, again); !errors.Is(err, apperr.ErrConflict) {
+			t.Fatalf("creating a session twice: %v; want a conflict", err)
+		}
+	})
+
+	t.Run("GetSessionByID", func(t *testing.T) {
+		found, err := repo.GetSessionByID(ctx, sessions[0].ULID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found == nil || found.UserID != user || found.DeviceName != "laptop" {
+			t.Fatalf("GetSessionByID = %+v; want the first session", found)
+		}
+
+		missing, err := repo.GetSessionByID(ctx, ulid.Make().String())
+		if err != nil || missing != nil {
+			t.Fatalf("GetSessionByID(unknown) = %+v, %v; want nil, nil", missing, err)
+		}
+	})
+
+	t.Run("TouchSession reorders the active sessions", func(t *testing.T) {
+		want := []string{sessions[2].ULID, sessions[1].ULID, sessions[0].ULID}
+		if got := active(t); !slices.Equal(got, want) {
+			t.Fatalf("active sessions = %v; want %v", got, want)
+		}
+
+		if err := repo.TouchSession(ctx, sessions[0].ULID, start.Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		want = []string{sessions[0].ULID, sessions[2].ULID, sessions[1].ULID}
+		if got := active(t); !slices.Equal(got, want) {
+			t.Fatalf("active sessions after a touch = %v; want %v", got, want)
+		}
+
+		if err := repo.TouchSession(ctx, ulid.Make().String(), start); err != nil {
+			t.Fatalf("touching an unknown session: %v", err)
+		}
+	})
+
+	t.Run("RevokeSession", func(t *testing.T) {
+		if err := repo.RevokeSession(ctx, sessions[1].ULID); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.RevokeSession(ctx, sessions[1].ULID); !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("revoking a session twice: %v; want not found", err)
+		}
+		revoked, err := repo.GetSessionByID(ctx, sessions[1].ULID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if revoked.RevokedAt == nil {
+			t.Fatal("a revoked session has no RevokedAt")
+		}
+	})
+
+	t.Run("RevokeUserSessionsExcept", func(t *testing.T) {
+		ids, err := repo.RevokeUserSessionsExcept(ctx, user, sessions[0].ULID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(ids, []string{sessions[2].ULID}) {
+			t.Fatalf("revoked %v; want only the other active session", ids)
+		}
+		if got := active(t); !slices.Equal(got, []string{sessions[0].ULID}) {
+			t.Fatalf("active sessions = %v; want only the one kept", got)
+		}
+
+		kept, err := repo.GetSessionByID(ctx, other.ULID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kept.RevokedAt != nil {
+			t.Fatal("revoked another user's session")
+		}
+
+		if ids, err := repo.RevokeUserSessionsExcept(ctx, user, sessions[0].ULID); err != nil || len(ids) != 0 {
+			t.Fatalf("revoking again = %v, %v; want nothing", ids, err)
+		}
+	})
+}
+
+func TestInMemorySessionRepository(t *testing.T) {
+	testSessionRepository(t, NewInMemorySessionRepository(store.New()))
+}
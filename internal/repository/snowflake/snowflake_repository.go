@@ -0,0 +1,12 @@
+package repository
+
+import (
+	"context"
+
+	"rio/internal/models"
+)
+
+type SnowflakeRepository interface {
+	Create(ctx context.Context, snowflake *models.Snowflake) error
+	GetBySnowflake(ctx context.Context, snowflake string) (*models.Snowflake, error)
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"rio/internal/apperr"
+	"rio/internal/db"
+	"rio/internal/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+type DBSnowflakeRepository struct{}
+
+func NewDBSnowflakeRepository() *DBSnowflakeRepository {
+	return &DBSnowflakeRepository{}
+}
+
+func (r *DBSnowflakeRepository) Create(ctx context.Context, snowflake *models.Snowflake) error {
+	err := db.WithContext(ctx).Create(snowflake).Error
+	if db.IsUniqueViolation(err) {
+		return apperr.Conflict("snowflake already exists")
+	}
+	return err
+}
+
+func (r *DBSnowflakeRepository) GetBySnowflake(ctx context.Context, snowflake string) (*models.Snowflake, error) {
+	var s models.Snowflake
+	err := db.WithContext(ctx).Where("snowflake = ?", snowflake).First(&s).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &s, nil
+}
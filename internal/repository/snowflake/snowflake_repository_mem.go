@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+)
+
+type InMemorySnowflakeRepository struct {
+	store *store.Store
+}
+
+func NewInMemorySnowflakeRepository(s *store.Store) *InMemorySnowflakeRepository {
+	return &InMemorySnowflakeRepository{store: s}
+}
+
+func (r *InMemorySnowflakeRepository) Create(ctx context.Context, snowflake *models.Snowflake) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		existing := t.Snowflakes.Find(func(s *models.Snowflake) bool {
+			return s.ULID == snowflake.ULID
+		})
+		if t.Snowflakes.Has(snowflake.Snowflake) || existing != nil {
+			return apperr.Conflict("snowflake already exists")
+		}
+		t.Stamp(&snowflake.Model)
+		t.Snowflakes.Put(snowflake.Snowflake, *snowflake)
+		return nil
+	})
+}
+
+func (r *InMemorySnowflakeRepository) GetBySnowflake(ctx context.Context, snowflake string) (*models.Snowflake, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.Snowflake {
+		return t.Snowflakes.Get(snowflake)
+	})
+}
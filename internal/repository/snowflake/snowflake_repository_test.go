@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// testSnowflakeRepository checks the behaviour both backends share, down
+// to the errors they fail with.
+func testSnowflakeRepository(t *testing.T, repo SnowflakeRepository) {
+	ctx := context.Background()
+	id := ulid.Make().String()
+
+	if err := repo.Create(ctx, &models.Snowflake{Snowflake: "1", ULID: id}); err != nil {
+		t.Fatal(err)
+	}
+	for name, s := range map[string]*models.Snowflake{
+		"the same snowflake": {Snowflake: "1", ULID: ulid.Make().String()},
+		"the same ULID":      {Snowflake: "2", ULID: id},
+	} {
+		if err := repo.Create(ctx, s); !errors.Is(err, apperr.ErrConflict) {
+			t.Errorf("recording %s twice: %v; want a conflict", name, err)
+		}
+	}
+
+	found, err := repo.GetBySnowflake(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == nil || found.ULID != id {
+		t.Fatalf("GetBySnowflake(1) = %+v; want %s", found, id)
+	}
+	if missing, err := repo.GetBySnowflake(ctx, "2"); err != nil || missing != nil {
+		t.Fatalf("GetBySnowflake(unknown) = %+v, %v; want nil, nil", missing, err)
+	}
+}
+
+func TestInMemorySnowflakeRepository(t *testing.T) {
+	testSnowflakeRepository(t, NewInMemorySnowflakeRepository(store.New()))
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/db"
+	"rio/internal/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+var ErrRefreshTokenRevoked = apperr.Unauthorized("refresh token has already been used or revoked")
+
+type DBTokenRepository struct{}
+
+func NewDBTokenRepository() *DBTokenRepository {
+	return &DBTokenRepository{}
+}
+
+func (r *DBTokenRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	return db.WithContext(ctx).Create(token).Error
+}
+
+func (r *DBTokenRepository) FindRefreshTokenByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	var t models.RefreshToken
+	err := db.WithContext(ctx).Where("token_hash = ?", hash).First(&t).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *DBTokenRepository) RotateRefreshToken(ctx context.Context, old *models.RefreshToken, next *models.RefreshToken) error {
+	return db.Transaction(ctx, func(ctx context.Context) error {
+		tx := db.WithContext(ctx)
+		now := time.Now()
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", old.ID).
+			Updates(map[string]interface{}{"revoked_at": now, "replaced_by": next.ULID})
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		// Another request rotated this token first; treat it as a replay.
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenRevoked
+		}
+
+		return tx.Create(next).Error
+	})
+}
+
+func (r *DBTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	return db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAccessToken records jti as revoked. A token revoked already, even
+// by a concurrent call, stays revoked, so the insert that loses to the
+// primary key succeeds too.
+func (r *DBTokenRepository) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	err := db.WithContext(ctx).Create(&models.RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+	if db.IsUniqueViolation(err) {
+		return nil
+	}
+	return err
+}
+
+func (r *DBTokenRepository) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var count int64
+	err := db.WithContext(ctx).Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *DBTokenRepository) PurgeExpiredRevocations(ctx context.Context, now time.Time) error {
+	return db.WithContext(ctx).Where("expires_at < ?", now).Delete(&models.RevokedToken{}).Error
+}
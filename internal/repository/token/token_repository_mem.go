@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"rio/internal/models"
+	"rio/internal/store"
+)
+
+type InMemoryTokenRepository struct {
+	store *store.Store
+}
+
+func NewInMemoryTokenRepository(s *store.Store) *InMemoryTokenRepository {
+	return &InMemoryTokenRepository{store: s}
+}
+
+func (r *InMemoryTokenRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		t.Stamp(&token.Model)
+		t.RefreshTokens.Put(token.ULID, *token)
+		return nil
+	})
+}
+
+func (r *InMemoryTokenRepository) FindRefreshTokenByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.RefreshToken {
+		return t.RefreshTokens.Find(func(rt *models.RefreshToken) bool {
+			return rt.TokenHash == hash
+		})
+	})
+}
+
+func (r *InMemoryTokenRepository) RotateRefreshToken(ctx context.Context, old *models.RefreshToken, next *models.RefreshToken) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		current, ok := t.RefreshTokens.Lookup(old.ULID)
+		if !ok || current.RevokedAt != nil {
+			return ErrRefreshTokenRevoked
+		}
+		now := time.Now()
+		current.RevokedAt = &now
+		current.ReplacedBy = next.ULID
+		t.RefreshTokens.Put(old.ULID, current)
+
+		t.Stamp(&next.Model)
+		t.RefreshTokens.Put(next.ULID, *next)
+		return nil
+	})
+}
+
+func (r *InMemoryTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		now := time.Now()
+		for id, rt := range t.RefreshTokens.All() {
+			if rt.FamilyID == familyID && rt.RevokedAt == nil {
+				rt.RevokedAt = &now
+				t.RefreshTokens.Put(id, rt)
+			}
+		}
+		return nil
+	})
+}
+
+func (r *InMemoryTokenRepository) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		if !t.RevokedTokens.Has(jti) {
+			t.RevokedTokens.Put(jti, models.RevokedToken{JTI: jti, ExpiresAt: expiresAt})
+		}
+		return nil
+	})
+}
+
+func (r *InMemoryTokenRepository) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) bool {
+		return t.RevokedTokens.Has(jti)
+	})
+}
+
+func (r *InMemoryTokenRepository) PurgeExpiredRevocations(ctx context.Context, now time.Time) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		for jti, rt := range t.RevokedTokens.All() {
+			if rt.ExpiresAt.Before(now) {
+				t.RevokedTokens.Delete(jti)
+			}
+		}
+		return nil
+	})
+}
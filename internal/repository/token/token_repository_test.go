@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// testTokenRepository checks the behaviour both backends share, down to
+// the errors they fail with.
+func testTokenRepository(t *testing.T, repo TokenRepository) {
+	ctx := context.Background()
+	family := ulid.Make().String()
+	newToken := func(hash string) *models.RefreshToken {
+		return &models.RefreshToken{
+			ULID: ulid.Make().String(), UserID: ulid.Make().String(), FamilyID: family,
+			TokenHash: hash, ExpiresAt: time.Now().Add(time.Hour),
+		}
+	}
+
+	first := newToken("first")
+	if err := repo.CreateRefreshToken(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("FindRefreshTokenByHash", func(t *testing.T) {
+		found, err := repo.FindRefreshTokenByHash(ctx, "first")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found == nil || found.ULID != first.ULID || found.RevokedAt != nil {
+			t.Fatalf("FindRefreshTokenByHash(first) = %+v; want the live first token", found)
+		}
+
+		missing, err := repo.FindRefreshTokenByHash(ctx, "unknown")
+		if err != nil || missing != nil {
+			t.Fatalf("FindRefreshTokenByHash(unknown) = %+v, %v; want nil, nil", missing, err)
+		}
+	})
+
+	second := newToken("second")
+	t.Run("a refresh token rotates once", func(t *testing.T) {
+		if err := repo.RotateRefreshToken(ctx, first, second); err != nil {
+			t.Fatal(err)
+		}
+		rotated, err := repo.FindRefreshTokenByHash(ctx, "first")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rotated.RevokedAt == nil || rotated.ReplacedBy != second.ULID {
+			t.Fatalf("rotated token = %+v; want it revoked and replaced by %s", rotated, second.ULID)
+		}
+
+		err = repo.RotateRefreshToken(ctx, first, newToken("replayed"))
+		if !errors.Is(err, ErrRefreshTokenRevoked) || !errors.Is(err, apperr.ErrUnauthorized) {
+			t.Fatalf("rotating the first token again: %v; want ErrRefreshTokenRevoked", err)
+		}
+		if replayed, _ := repo.FindRefreshTokenByHash(ctx, "replayed"); replayed != nil {
+			t.Fatalf("a refused rotation stored its new token %+v", replayed)
+		}
+	})
+
+	t.Run("RevokeFamily", func(t *testing.T) {
+		if err := repo.RevokeFamily(ctx, family); err != nil {
+			t.Fatal(err)
+		}
+		revoked, err := repo.FindRefreshTokenByHash(ctx, "second")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if revoked.RevokedAt == nil {
+			t.Fatal("the family's live token was not revoked")
+		}
+		if err := repo.RotateRefreshToken(ctx, second, newToken("third")); !errors.Is(err, ErrRefreshTokenRevoked) {
+			t.Fatalf("rotating a revoked token: %v; want ErrRefreshTokenRevoked", err)
+		}
+	})
+
+	t.Run("access token revocations", func(t *testing.T) {
+		now := time.Now()
+		for range 2 {
+			if err := repo.RevokeAccessToken(ctx, "expired", now.Add(-time.Minute)); err != nil {
+				t.Fatalf("revoking a token twice: %v", err)
+			}
+		}
+		if err := repo.RevokeAccessToken(ctx, "live", now.Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := repo.PurgeExpiredRevocations(ctx, now); err != nil {
+			t.Fatal(err)
+		}
+		for jti, want := range map[string]bool{"live": true, "expired": false, "unknown": false} {
+			revoked, err := repo.IsAccessTokenRevoked(ctx, jti)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if revoked != want {
+				t.Errorf("IsAccessTokenRevoked(%s) = %v; want %v", jti, revoked, want)
+			}
+		}
+	})
+}
+
+func TestInMemoryTokenRepository(t *testing.T) {
+	testTokenRepository(t, NewInMemoryTokenRepository(store.New()))
+}
@@ -0,0 +1,11 @@
+package repository
+
+import "context"
+
+// UnitOfWork runs several repository operations atomically. Do calls fn
+// with a context the operations must be given: if fn returns an error,
+// everything they changed is undone. Calls to Do inside fn join the
+// enclosing unit of work.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
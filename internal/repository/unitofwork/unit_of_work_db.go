@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+
+	"rio/internal/db"
+)
+
+// DBUnitOfWork runs units of work in a database transaction. Only
+// repositories that query through db.WithContext take part in it.
+type DBUnitOfWork struct{}
+
+func NewDBUnitOfWork() *DBUnitOfWork {
+	return &DBUnitOfWork{}
+}
+
+func (u *DBUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return db.Transaction(ctx, fn)
+}
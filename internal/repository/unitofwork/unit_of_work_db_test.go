@@ -0,0 +1,15 @@
+//go:build cgo
+
+package repository
+
+import (
+	"testing"
+
+	"rio/internal/db/dbtest"
+	tokenRepo "rio/internal/repository/token"
+)
+
+func TestDBUnitOfWork(t *testing.T) {
+	dbtest.Open(t)
+	testUnitOfWork(t, NewDBUnitOfWork(), tokenRepo.NewDBTokenRepository())
+}
@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+
+	"rio/internal/store"
+)
+
+// InMemoryUnitOfWork runs units of work as transactions on the store,
+// which puts the tables back as they were if one fails. The store is
+// locked for the whole unit of work, so fn must reach it only through
+// repositories given the context it is called with.
+type InMemoryUnitOfWork struct {
+	store *store.Store
+}
+
+func NewInMemoryUnitOfWork(s *store.Store) *InMemoryUnitOfWork {
+	return &InMemoryUnitOfWork{store: s}
+}
+
+func (u *InMemoryUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return u.store.Transaction(ctx, fn)
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	tokenRepo "rio/internal/repository/token"
+	"rio/internal/store"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// testUnitOfWork checks that both backends keep what a unit of work did
+// when it succeeds and undo it when it fails, tokens being one of the
+// repositories taking part.
+func testUnitOfWork(t *testing.T, uow UnitOfWork, tokens tokenRepo.TokenRepository) {
+	ctx := context.Background()
+	failed := errors.New("failed")
+	expires := time.Now().Add(time.Hour)
+
+	revoked := func(t *testing.T, jti string) bool {
+		t.Helper()
+		revoked, err := tokens.IsAccessTokenRevoked(ctx, jti)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return revoked
+	}
+
+	t.Run("commits", func(t *testing.T) {
+		first, second := ulid.Make().String(), ulid.Make().String()
+		err := uow.Do(ctx, func(ctx context.Context) error {
+			if err := tokens.RevokeAccessToken(ctx, first, expires); err != nil {
+				return err
+			}
+			return tokens.RevokeAccessToken(ctx, second, expires)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !revoked(t, first) || !revoked(t, second) {
+			t.Fatal("a unit of work that succeeded lost its changes")
+		}
+	})
+
+	t.Run("rolls back", func(t *testing.T) {
+		jti := ulid.Make().String()
+		err := uow.Do(ctx, func(ctx context.Context) error {
+			if err := tokens.RevokeAccessToken(ctx, jti, expires); err != nil {
+				return err
+			}
+			return failed
+		})
+		if !errors.Is(err, failed) {
+			t.Fatalf("Do = %v; want its error", err)
+		}
+		if revoked(t, jti) {
+			t.Fatal("a unit of work that failed kept its changes")
+		}
+	})
+
+	t.Run("nested units join the enclosing one", func(t *testing.T) {
+		outer, inner := ulid.Make().String(), ulid.Make().String()
+		err := uow.Do(ctx, func(ctx context.Context) error {
+			if err := tokens.RevokeAccessToken(ctx, outer, expires); err != nil {
+				return err
+			}
+			if err := uow.Do(ctx, func(ctx context.Context) error {
+				return tokens.RevokeAccessToken(ctx, inner, expires)
+			}); err != nil {
+				return err
+			}
+			return failed
+		})
+		if !errors.Is(err, failed) {
+			t.Fatalf("Do = %v; want its error", err)
+		}
+		if revoked(t, outer) || revoked(t, inner) {
+			t.Fatal("a nested unit of work outlived the one it joined")
+		}
+	})
+}
+
+func TestInMemoryUnitOfWork(t *testing.T) {
+	s := store.New()
+	testUnitOfWork(t, NewInMemoryUnitOfWork(s), tokenRepo.NewInMemoryTokenRepository(s))
+}
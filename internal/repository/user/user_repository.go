@@ -1,10 +1,18 @@
 package repository
 
-import "rio/internal/models"
+import (
+	"context"
+	"rio/internal/models"
+)
 
 type UserRepository interface {
-	Create(user *models.User) error
-	FindByUsername(username string) (*models.User, error)
-	FindAll() ([]models.User, error)
-	GetUserByID(id string) (*models.User, error)
+	Create(ctx context.Context, user *models.User) error
+	FindByUsername(ctx context.Context, username string) (*models.User, error)
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	FindAll(ctx context.Context) ([]models.User, error)
+	GetUserByID(ctx context.Context, id string) (*models.User, error)
+	GetRemoteUser(ctx context.Context, instance, remoteID string) (*models.User, error)
+	UpdatePassword(ctx context.Context, id, hashedPassword string) error
+	UpdateTOTP(ctx context.Context, id, secret string, enabled bool) error
+	AdvanceTOTPStep(ctx context.Context, id string, step int64) error
 }
@@ -1,7 +1,9 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"rio/internal/apperr"
 	"rio/internal/db"
 	"rio/internal/models"
 
@@ -14,13 +16,29 @@ func NewDBUserRepository() *DBUserRepository {
 	return &DBUserRepository{}
 }
 
-func (r *DBUserRepository) Create(user *models.User) error {
-	return db.DB.Create(user).Error
+func (r *DBUserRepository) Create(ctx context.Context, user *models.User) error {
+	err := db.WithContext(ctx).Create(user).Error
+	if db.IsUniqueViolation(err) {
+		return apperr.Conflict("username already taken")
+	}
+	return err
+}
+
+func (r *DBUserRepository) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+	var user models.User
+	err := db.WithContext(ctx).Where(db.EqualFold("username"), username).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
 }
 
-func (r *DBUserRepository) FindByUsername(username string) (*models.User, error) {
+func (r *DBUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
-	err := db.DB.Where("username = ?", username).First(&user).Error
+	err := db.WithContext(ctx).Where(db.EqualFold("email"), email).First(&user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -30,15 +48,15 @@ func (r *DBUserRepository) FindByUsername(username string) (*models.User, error)
 	return &user, nil
 }
 
-func (r *DBUserRepository) FindAll() ([]models.User, error) {
+func (r *DBUserRepository) FindAll(ctx context.Context) ([]models.User, error) {
 	var users []models.User
-	err := db.DB.Find(&users).Error
+	err := db.WithContext(ctx).Find(&users).Error
 	return users, err
 }
 
-func (r *DBUserRepository) GetUserByID(id string) (*models.User, error) {
+func (r *DBUserRepository) GetUserByID(ctx context.Context, id string) (*models.User, error) {
 	var u models.User
-	err := db.DB.Where("ul_id = ?", id).First(&u).Error
+	err := db.WithContext(ctx).Where("ul_id = ?", id).First(&u).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -50,3 +68,70 @@ func (r *DBUserRepository) GetUserByID(id string) (*models.User, error) {
 
 	return &u, nil
 }
+
+func (r *DBUserRepository) GetRemoteUser(ctx context.Context, instance, remoteID string) (*models.User, error) {
+	var u models.User
+	err := db.WithContext(ctx).Where("instance = ? AND remote_id = ?", instance, remoteID).First(&u).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *DBUserRepository) UpdatePassword(ctx context.Context, id, hashedPassword string) error {
+	result := db.WithContext(ctx).Model(&models.User{}).
+		Where("ul_id = ?", id).
+		Update("password", hashedPassword)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.NotFound("user not found")
+	}
+
+	return nil
+}
+
+func (r *DBUserRepository) UpdateTOTP(ctx context.Context, id, secret string, enabled bool) error {
+	result := db.WithContext(ctx).Model(&models.User{}).
+		Where("ul_id = ?", id).
+		Updates(map[string]interface{}{
+			"totp_secret":    secret,
+			"totp_enabled":   enabled,
+			"totp_last_step": 0,
+		})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.NotFound("user not found")
+	}
+
+	return nil
+}
+
+// AdvanceTOTPStep records step as the last accepted TOTP step. It fails if
+// that step (or a later one) was already used, which stops a code from being
+// replayed within its validity window.
+func (r *DBUserRepository) AdvanceTOTPStep(ctx context.Context, id string, step int64) error {
+	result := db.WithContext(ctx).Model(&models.User{}).
+		Where("ul_id = ? AND totp_last_step < ?", id, step).
+		Update("totp_last_step", step)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.Invalid("code has already been used")
+	}
+
+	return nil
+}
@@ -1,40 +1,120 @@
 package repository
 
 import (
-	"errors"
+	"cmp"
+	"context"
+	"rio/internal/apperr"
 	"rio/internal/models"
 	"rio/internal/store"
+	"slices"
 	"strings"
 )
 
-type InMemoryUserRepository struct{}
+type InMemoryUserRepository struct {
+	store *store.Store
+}
 
-func NewInMemoryUserRepository() *InMemoryUserRepository {
-	return &InMemoryUserRepository{}
+func NewInMemoryUserRepository(s *store.Store) *InMemoryUserRepository {
+	return &InMemoryUserRepository{store: s}
 }
 
-func (r *InMemoryUserRepository) Create(user *models.User) error {
-	for _, u := range store.Users {
-		if strings.EqualFold(u.Username, user.Username) {
-			return errors.New("username already taken")
+func (r *InMemoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		name := strings.ToLower(user.Username)
+		if t.UsersByName.Has(name) {
+			return apperr.Conflict("username already taken")
 		}
-		if u.ULID == user.ULID {
-			return errors.New("user ID already exists")
+		if t.Users.Has(user.ULID) {
+			return apperr.Conflict("user ID already exists")
 		}
-	}
-	store.Users = append(store.Users, *user)
-	return nil
+		t.Stamp(&user.Model)
+		t.Users.Put(user.ULID, *user)
+		t.UsersByName.Put(name, user.ULID)
+		return nil
+	})
+}
+
+func (r *InMemoryUserRepository) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.User {
+		id, ok := t.UsersByName.Lookup(strings.ToLower(username))
+		if !ok {
+			return nil
+		}
+		return t.Users.Get(id)
+	})
+}
+
+func (r *InMemoryUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.User {
+		return t.Users.Find(func(u *models.User) bool {
+			return u.Email != "" && strings.EqualFold(u.Email, email)
+		})
+	})
+}
+
+func (r *InMemoryUserRepository) FindAll(ctx context.Context) ([]models.User, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) []models.User {
+		return slices.SortedFunc(t.Users.Values(), func(a, b models.User) int {
+			return cmp.Compare(a.ID, b.ID)
+		})
+	})
 }
 
-func (r *InMemoryUserRepository) FindByUsername(username string) (*models.User, error) {
-	for _, u := range store.Users {
-		if strings.EqualFold(u.Username, username) {
-			return &u, nil
+func (r *InMemoryUserRepository) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.User {
+		u := t.Users.Get(id)
+		if u != nil {
+			u.Password = ""
 		}
-	}
-	return nil, nil
+		return u
+	})
+}
+
+func (r *InMemoryUserRepository) GetRemoteUser(ctx context.Context, instance, remoteID string) (*models.User, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.User {
+		return t.Users.Find(func(u *models.User) bool {
+			return u.Instance == instance && u.RemoteID == remoteID
+		})
+	})
 }
 
-func (r *InMemoryUserRepository) FindAll() ([]models.User, error) {
-	return store.Users, nil
+// update applies fn to the stored user with the given ID.
+func (r *InMemoryUserRepository) update(ctx context.Context, id string, fn func(u *models.User) error) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		u, ok := t.Users.Lookup(id)
+		if !ok {
+			return apperr.NotFound("user not found")
+		}
+		if err := fn(&u); err != nil {
+			return err
+		}
+		t.Users.Put(id, u)
+		return nil
+	})
+}
+
+func (r *InMemoryUserRepository) UpdatePassword(ctx context.Context, id, hashedPassword string) error {
+	return r.update(ctx, id, func(u *models.User) error {
+		u.Password = hashedPassword
+		return nil
+	})
+}
+
+func (r *InMemoryUserRepository) UpdateTOTP(ctx context.Context, id, secret string, enabled bool) error {
+	return r.update(ctx, id, func(u *models.User) error {
+		u.TOTPSecret = secret
+		u.TOTPEnabled = enabled
+		u.TOTPLastStep = 0
+		return nil
+	})
+}
+
+func (r *InMemoryUserRepository) AdvanceTOTPStep(ctx context.Context, id string, step int64) error {
+	return r.update(ctx, id, func(u *models.User) error {
+		if u.TOTPLastStep >= step {
+			return apperr.Invalid("code has already been used")
+		}
+		u.TOTPLastStep = step
+		return nil
+	})
 }
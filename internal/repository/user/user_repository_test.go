@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// testUserRepository checks the behaviour both backends share, down to the
+// errors they fail with.
+func testUserRepository(t *testing.T, repo UserRepository) {
+	ctx := context.Background()
+
+	alice := &models.User{ULID: ulid.Make().String(), Username: "alice", Password: "hash"}
+	if err := repo.Create(ctx, alice); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("usernames are unique ignoring case", func(t *testing.T) {
+		err := repo.Create(ctx, &models.User{ULID: ulid.Make().String(), Username: "Alice", Password: "hash"})
+		if !errors.Is(err, apperr.ErrConflict) {
+			t.Fatalf("creating Alice next to alice: %v; want a conflict", err)
+		}
+
+		found, err := repo.FindByUsername(ctx, "ALICE")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found == nil || found.ULID != alice.ULID {
+			t.Fatalf("FindByUsername(ALICE) = %+v; want alice", found)
+		}
+	})
+
+	t.Run("GetUserByID leaves out the password", func(t *testing.T) {
+		found, err := repo.GetUserByID(ctx, alice.ULID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found == nil || found.Username != "alice" || found.Password != "" {
+			t.Fatalf("GetUserByID = %+v; want alice without her password", found)
+		}
+
+		missing, err := repo.GetUserByID(ctx, ulid.Make().String())
+		if err != nil || missing != nil {
+			t.Fatalf("GetUserByID(unknown) = %+v, %v; want nil, nil", missing, err)
+		}
+	})
+
+	t.Run("UpdatePassword", func(t *testing.T) {
+		if err := repo.UpdatePassword(ctx, alice.ULID, "new hash"); err != nil {
+			t.Fatal(err)
+		}
+		found, err := repo.FindByUsername(ctx, "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found.Password != "new hash" {
+			t.Fatalf("password = %q after UpdatePassword; want %q", found.Password, "new hash")
+		}
+
+		if err := repo.UpdatePassword(ctx, ulid.Make().String(), "hash"); !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("UpdatePassword(unknown) = %v; want not found", err)
+		}
+	})
+
+	t.Run("TOTP steps cannot be replayed", func(t *testing.T) {
+		if err := repo.UpdateTOTP(ctx, alice.ULID, "secret", true); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.AdvanceTOTPStep(ctx, alice.ULID, 10); err != nil {
+			t.Fatal(err)
+		}
+		for _, step := range []int64{10, 9} {
+			if err := repo.AdvanceTOTPStep(ctx, alice.ULID, step); !errors.Is(err, apperr.ErrInvalid) {
+				t.Errorf("AdvanceTOTPStep(%d) after 10 = %v; want it refused", step, err)
+			}
+		}
+		if err := repo.AdvanceTOTPStep(ctx, alice.ULID, 11); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestInMemoryUserRepository(t *testing.T) {
+	testUserRepository(t, NewInMemoryUserRepository(store.New()))
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"rio/internal/models"
+)
+
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, webhook *models.Webhook) error
+	GetWebhookByID(ctx context.Context, ulid string) (*models.Webhook, error)
+	GetWebhooksByServer(ctx context.Context, s_id string) ([]*models.Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook *models.Webhook) error
+	DeleteWebhook(ctx context.Context, s_id, ulid string) error
+
+	CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	GetDeliveryByID(ctx context.Context, ulid string) (*models.WebhookDelivery, error)
+	// GetDeliveriesByWebhook returns the newest deliveries first. An empty
+	// status matches every delivery.
+	GetDeliveriesByWebhook(ctx context.Context, w_id, status string, limit int) ([]*models.WebhookDelivery, error)
+	GetDueDeliveries(ctx context.Context, now time.Time, limit int) ([]*models.WebhookDelivery, error)
+	// ClaimDelivery pushes a due delivery's next attempt out to leaseUntil
+	// so no other worker picks it up, and reports whether this caller won
+	// it.
+	ClaimDelivery(ctx context.Context, ulid string, now, leaseUntil time.Time) (bool, error)
+	UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/db"
+	"rio/internal/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+type DBWebhookRepository struct{}
+
+func NewDBWebhookRepository() *DBWebhookRepository {
+	return &DBWebhookRepository{}
+}
+
+func (r *DBWebhookRepository) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	err := db.WithContext(ctx).Create(webhook).Error
+	if db.IsUniqueViolation(err) {
+		return apperr.Conflict("webhook with this ULID already exists")
+	}
+	return err
+}
+
+func (r *DBWebhookRepository) GetWebhookByID(ctx context.Context, ulid string) (*models.Webhook, error) {
+	var w models.Webhook
+	err := db.WithContext(ctx).Where("ul_id = ?", ulid).First(&w).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (r *DBWebhookRepository) GetWebhooksByServer(ctx context.Context, s_id string) ([]*models.Webhook, error) {
+	var webhooks []*models.Webhook
+	err := db.WithContext(ctx).Where("server_id = ?", s_id).Order("created_at").Find(&webhooks).Error
+	if err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (r *DBWebhookRepository) UpdateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	result := db.WithContext(ctx).Model(&models.Webhook{}).
+		Where("ul_id = ?", webhook.ULID).
+		Updates(map[string]interface{}{
+			"url":    webhook.URL,
+			"events": webhook.Events,
+			"active": webhook.Active,
+		})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.NotFound("webhook not found")
+	}
+
+	return nil
+}
+
+func (r *DBWebhookRepository) DeleteWebhook(ctx context.Context, s_id, ulid string) error {
+	result := db.WithContext(ctx).Where("server_id = ? AND ul_id = ?", s_id, ulid).Delete(&models.Webhook{})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return apperr.NotFound("webhook not found")
+	}
+
+	return nil
+}
+
+func (r *DBWebhookRepository) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	return db.WithContext(ctx).Create(delivery).Error
+}
+
+func (r *DBWebhookRepository) GetDeliveryByID(ctx context.Context, ulid string) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	err := db.WithContext(ctx).Where("ul_id = ?", ulid).First(&d).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *DBWebhookRepository) GetDeliveriesByWebhook(ctx context.Context, w_id, status string, limit int) ([]*models.WebhookDelivery, error) {
+	query := db.WithContext(ctx).Where("webhook_id = ?", w_id)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var deliveries []*models.WebhookDelivery
+	err := query.Order("ul_id DESC").Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *DBWebhookRepository) GetDueDeliveries(ctx context.Context, now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	var deliveries []*models.WebhookDelivery
+	err := db.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, now).
+		Order("next_attempt_at").
+		Limit(limit).
+		Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *DBWebhookRepository) ClaimDelivery(ctx context.Context, ulid string, now, leaseUntil time.Time) (bool, error) {
+	result := db.WithContext(ctx).Model(&models.WebhookDelivery{}).
+		Where("ul_id = ? AND status = ? AND next_attempt_at <= ?", ulid, models.WebhookDeliveryPending, now).
+		Update("next_attempt_at", leaseUntil)
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *DBWebhookRepository) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	return db.WithContext(ctx).Model(&models.WebhookDelivery{}).
+		Where("ul_id = ?", delivery.ULID).
+		Updates(map[string]interface{}{
+			"status":           delivery.Status,
+			"attempts":         delivery.Attempts,
+			"next_attempt_at":  delivery.NextAttemptAt,
+			"last_status_code": delivery.LastStatusCode,
+			"last_error":       delivery.LastError,
+			"delivered_at":     delivery.DeliveredAt,
+		}).Error
+}
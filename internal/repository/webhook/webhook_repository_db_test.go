@@ -0,0 +1,14 @@
+//go:build cgo
+
+package repository
+
+import (
+	"testing"
+
+	"rio/internal/db/dbtest"
+)
+
+func TestDBWebhookRepository(t *testing.T) {
+	dbtest.Open(t)
+	testWebhookRepository(t, NewDBWebhookRepository())
+}
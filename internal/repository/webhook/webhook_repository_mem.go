@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"strings"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+)
+
+type InMemoryWebhookRepository struct {
+	store *store.Store
+}
+
+func NewInMemoryWebhookRepository(s *store.Store) *InMemoryWebhookRepository {
+	return &InMemoryWebhookRepository{store: s}
+}
+
+func (r *InMemoryWebhookRepository) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		if t.Webhooks.Has(webhook.ULID) {
+			return apperr.Conflict("webhook with this ULID already exists")
+		}
+		t.Stamp(&webhook.Model)
+		t.Webhooks.Put(webhook.ULID, *webhook)
+		return nil
+	})
+}
+
+func (r *InMemoryWebhookRepository) GetWebhookByID(ctx context.Context, ulid string) (*models.Webhook, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.Webhook {
+		return t.Webhooks.Get(ulid)
+	})
+}
+
+func (r *InMemoryWebhookRepository) GetWebhooksByServer(ctx context.Context, s_id string) ([]*models.Webhook, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) []*models.Webhook {
+		return t.Webhooks.Filter(func(w *models.Webhook) bool {
+			return w.ServerID == s_id
+		}, func(a, b *models.Webhook) int {
+			return cmp.Compare(a.ID, b.ID)
+		})
+	})
+}
+
+func (r *InMemoryWebhookRepository) UpdateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		w, ok := t.Webhooks.Lookup(webhook.ULID)
+		if !ok {
+			return apperr.NotFound("webhook not found")
+		}
+		w.URL = webhook.URL
+		w.Events = webhook.Events
+		w.Active = webhook.Active
+		t.Webhooks.Put(webhook.ULID, w)
+		return nil
+	})
+}
+
+func (r *InMemoryWebhookRepository) DeleteWebhook(ctx context.Context, s_id, ulid string) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		w, ok := t.Webhooks.Lookup(ulid)
+		if !ok || w.ServerID != s_id {
+			return apperr.NotFound("webhook not found")
+		}
+		t.Webhooks.Delete(ulid)
+		return nil
+	})
+}
+
+func (r *InMemoryWebhookRepository) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		t.Stamp(&delivery.Model)
+		t.WebhookDeliveries.Put(delivery.ULID, *delivery)
+		return nil
+	})
+}
+
+func (r *InMemoryWebhookRepository) GetDeliveryByID(ctx context.Context, ulid string) (*models.WebhookDelivery, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) *models.WebhookDelivery {
+		return t.WebhookDeliveries.Get(ulid)
+	})
+}
+
+func (r *InMemoryWebhookRepository) GetDeliveriesByWebhook(ctx context.Context, w_id, status string, limit int) ([]*models.WebhookDelivery, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) []*models.WebhookDelivery {
+		deliveries := t.WebhookDeliveries.Filter(func(d *models.WebhookDelivery) bool {
+			return d.WebhookID == w_id && (status == "" || d.Status == status)
+		}, func(a, b *models.WebhookDelivery) int {
+			return strings.Compare(b.ULID, a.ULID)
+		})
+		if len(deliveries) > limit {
+			deliveries = deliveries[:limit]
+		}
+		return deliveries
+	})
+}
+
+func (r *InMemoryWebhookRepository) GetDueDeliveries(ctx context.Context, now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	return store.Query(ctx, r.store, func(t *store.Tables) []*models.WebhookDelivery {
+		deliveries := t.WebhookDeliveries.Filter(func(d *models.WebhookDelivery) bool {
+			return d.Status == models.WebhookDeliveryPending && d.NextAttemptAt != nil && !d.NextAttemptAt.After(now)
+		}, func(a, b *models.WebhookDelivery) int {
+			return a.NextAttemptAt.Compare(*b.NextAttemptAt)
+		})
+		if len(deliveries) > limit {
+			deliveries = deliveries[:limit]
+		}
+		return deliveries
+	})
+}
+
+func (r *InMemoryWebhookRepository) ClaimDelivery(ctx context.Context, ulid string, now, leaseUntil time.Time) (bool, error) {
+	claimed := false
+	err := r.store.Update(ctx, func(t *store.Tables) error {
+		d, ok := t.WebhookDeliveries.Lookup(ulid)
+		if !ok || d.Status != models.WebhookDeliveryPending || d.NextAttemptAt == nil || d.NextAttemptAt.After(now) {
+			return nil
+		}
+		d.NextAttemptAt = &leaseUntil
+		t.WebhookDeliveries.Put(ulid, d)
+		claimed = true
+		return nil
+	})
+	return claimed, err
+}
+
+func (r *InMemoryWebhookRepository) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	return r.store.Update(ctx, func(t *store.Tables) error {
+		d, ok := t.WebhookDeliveries.Lookup(delivery.ULID)
+		if !ok {
+			return apperr.NotFound("delivery not found")
+		}
+		d.Status = delivery.Status
+		d.Attempts = delivery.Attempts
+		d.NextAttemptAt = delivery.NextAttemptAt
+		d.LastStatusCode = delivery.LastStatusCode
+		d.LastError = delivery.LastError
+		d.DeliveredAt = delivery.DeliveredAt
+		t.WebhookDeliveries.Put(delivery.ULID, d)
+		return nil
+	})
+}
@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/store"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// testWebhookRepository checks the behaviour both backends share, down to
+// the errors they fail with.
+func testWebhookRepository(t *testing.T, repo WebhookRepository) {
+	ctx := context.Background()
+	server := ulid.Make().String()
+
+	webhook := &models.Webhook{ULID: ulid.Make().String(), ServerID: server, URL: "https://example.com/a", Secret: "s", Events: "*", Active: true}
+	if err := repo.CreateWebhook(ctx, webhook); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("webhooks", func(t *testing.T) {
+		again := *webhook
+		again.ID = 0
+		if err := repo.CreateWebhook(ctx, &again); !errors.Is(err, apperr.ErrConflict) {
+			t.Fatalf("creating a webhook twice: %v; want a conflict", err)
+		}
+
+		second := &models.Webhook{ULID: ulid.Make().String(), ServerID: server, URL: "https://example.com/b", Secret: "s", Events: "*"}
+		if err := repo.CreateWebhook(ctx, second); err != nil {
+			t.Fatal(err)
+		}
+		listed, err := repo.GetWebhooksByServer(ctx, server)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(listed) != 2 || listed[0].ULID != webhook.ULID || listed[1].ULID != second.ULID {
+			t.Fatalf("GetWebhooksByServer = %+v; want both webhooks, oldest first", listed)
+		}
+
+		update := *second
+		update.URL, update.Events, update.Active = "https://example.com/c", "message.created", true
+		if err := repo.UpdateWebhook(ctx, &update); err != nil {
+			t.Fatal(err)
+		}
+		updated, err := repo.GetWebhookByID(ctx, second.ULID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated.URL != update.URL || updated.Events != update.Events || !updated.Active || updated.Secret != "s" {
+			t.Fatalf("updated webhook = %+v; want the new URL, events and active flag", updated)
+		}
+		missing := &models.Webhook{ULID: ulid.Make().String(), URL: "https://example.com"}
+		if err := repo.UpdateWebhook(ctx, missing); !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("updating an unknown webhook: %v; want not found", err)
+		}
+
+		if err := repo.DeleteWebhook(ctx, ulid.Make().String(), second.ULID); !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("deleting a webhook from another server: %v; want not found", err)
+		}
+		if err := repo.DeleteWebhook(ctx, server, second.ULID); err != nil {
+			t.Fatal(err)
+		}
+		if deleted, err := repo.GetWebhookByID(ctx, second.ULID); err != nil || deleted != nil {
+			t.Fatalf("GetWebhookByID(deleted) = %+v, %v; want nil, nil", deleted, err)
+		}
+		if err := repo.DeleteWebhook(ctx, server, second.ULID); !errors.Is(err, apperr.ErrNotFound) {
+			t.Fatalf("deleting a webhook twice: %v; want not found", err)
+		}
+	})
+
+	now := time.Now().Truncate(time.Second)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	newDelivery := func(status string, next *time.Time) *models.WebhookDelivery {
+		d := &models.WebhookDelivery{
+			ULID: ulid.Make().String(), WebhookID: webhook.ULID, EventID: ulid.Make().String(),
+			EventType: "message.created", Payload: "{}", Status: status, NextAttemptAt: next,
+		}
+		if err := repo.CreateDelivery(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	later := newDelivery(models.WebhookDeliveryPending, at(-time.Minute))
+	sooner := newDelivery(models.WebhookDeliveryPending, at(-time.Hour))
+	future := newDelivery(models.WebhookDeliveryPending, at(time.Hour))
+	dead := newDelivery(models.WebhookDeliveryDead, nil)
+
+	ids := func(deliveries []*models.WebhookDelivery) []string {
+		var ids []string
+		for _, d := range deliveries {
+			ids = append(ids, d.ULID)
+		}
+		return ids
+	}
+
+	t.Run("GetDeliveriesByWebhook lists the newest first", func(t *testing.T) {
+		all, err := repo.GetDeliveriesByWebhook(ctx, webhook.ULID, "", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{dead.ULID, future.ULID, sooner.ULID, later.ULID}; !slices.Equal(ids(all), want) {
+			t.Fatalf("deliveries = %v; want %v", ids(all), want)
+		}
+
+		limited, err := repo.GetDeliveriesByWebhook(ctx, webhook.ULID, models.WebhookDeliveryPending, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{future.ULID, sooner.ULID}; !slices.Equal(ids(limited), want) {
+			t.Fatalf("two pending deliveries = %v; want %v", ids(limited), want)
+		}
+	})
+
+	t.Run("a due delivery is claimed once", func(t *testing.T) {
+		due, err := repo.GetDueDeliveries(ctx, now, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{sooner.ULID, later.ULID}; !slices.Equal(ids(due), want) {
+			t.Fatalf("due deliveries = %v; want %v", ids(due), want)
+		}
+
+		for _, d := range []*models.WebhookDelivery{future, dead} {
+			if claimed, err := repo.ClaimDelivery(ctx, d.ULID, now, now.Add(time.Minute)); err != nil || claimed {
+				t.Fatalf("claiming %s delivery = %v, %v; want it refused", d.Status, claimed, err)
+			}
+		}
+		if claimed, err := repo.ClaimDelivery(ctx, sooner.ULID, now, now.Add(time.Minute)); err != nil || !claimed {
+			t.Fatalf("claiming a due delivery = %v, %v; want it claimed", claimed, err)
+		}
+		if claimed, err := repo.ClaimDelivery(ctx, sooner.ULID, now, now.Add(time.Minute)); err != nil || claimed {
+			t.Fatalf("claiming a delivery twice = %v, %v; want it refused", claimed, err)
+		}
+
+		due, err = repo.GetDueDeliveries(ctx, now, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{later.ULID}; !slices.Equal(ids(due), want) {
+			t.Fatalf("due deliveries after a claim = %v; want %v", ids(due), want)
+		}
+	})
+
+	t.Run("UpdateDelivery", func(t *testing.T) {
+		update := *later
+		update.Status = models.WebhookDeliverySucceeded
+		update.Attempts = 1
+		update.NextAttemptAt = nil
+		update.LastStatusCode = 204
+		update.DeliveredAt = at(0)
+		update.Payload = "changed"
+		if err := repo.UpdateDelivery(ctx, &update); err != nil {
+			t.Fatal(err)
+		}
+
+		updated, err := repo.GetDeliveryByID(ctx, later.ULID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated.Status != models.WebhookDeliverySucceeded || updated.Attempts != 1 || updated.NextAttemptAt != nil ||
+			updated.LastStatusCode != 204 || updated.DeliveredAt == nil || updated.Payload != "{}" {
+			t.Fatalf("updated delivery = %+v; want the outcome recorded and the payload kept", updated)
+		}
+
+		if missing, err := repo.GetDeliveryByID(ctx, ulid.Make().String()); err != nil || missing != nil {
+			t.Fatalf("GetDeliveryByID(unknown) = %+v, %v; want nil, nil", missing, err)
+		}
+	})
+}
+
+func TestInMemoryWebhookRepository(t *testing.T) {
+	testWebhookRepository(t, NewInMemoryWebhookRepository(store.New()))
+}
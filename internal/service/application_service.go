@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"html"
+	"strings"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	appRepo "rio/internal/repository/application"
+	unitOfWork "rio/internal/repository/unitofwork"
+	userRepo "rio/internal/repository/user"
+	"rio/utils/token"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// ApplicationWithToken is returned when an application is created or its
+// token is reset; it is the only time the token is shown.
+type ApplicationWithToken struct {
+	*models.Application
+	Token string `json:"token"`
+}
+
+type ApplicationService struct {
+	repo     appRepo.ApplicationRepository
+	userRepo userRepo.UserRepository
+	uow      unitOfWork.UnitOfWork
+}
+
+func NewApplicationService(
+	repo appRepo.ApplicationRepository,
+	uRepo userRepo.UserRepository,
+	uow unitOfWork.UnitOfWork,
+) *ApplicationService {
+	return &ApplicationService{
+		repo:     repo,
+		userRepo: uRepo,
+		uow:      uow,
+	}
+}
+
+func (s *ApplicationService) CreateApplication(ctx context.Context, currentUserID, name, description string, public bool) (*ApplicationWithToken, error) {
+	owner, err := s.userRepo.GetUserByID(ctx, currentUserID)
+	if err != nil {
+		return nil, err
+	}
+	if owner == nil {
+		return nil, apperr.NotFound("user not found")
+	}
+	if owner.IsBot {
+		return nil, apperr.Forbidden("bots cannot create applications")
+	}
+
+	// The application name doubles as its bot's username.
+	if err := validateUsername(&name); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.userRepo.FindByUsername(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, apperr.Conflict("username already taken")
+	}
+
+	description = html.EscapeString(strings.TrimSpace(description))
+	if len(description) > 400 {
+		return nil, apperr.Invalid("description must be at most 400 characters")
+	}
+
+	apiToken, err := token.GenerateAPIToken()
+	if err != nil {
+		return nil, err
+	}
+
+	bot := &models.User{
+		ULID:     ulid.Make().String(),
+		Username: name,
+		IsBot:    true,
+		OwnerID:  currentUserID,
+	}
+	app := &models.Application{
+		ULID:        ulid.Make().String(),
+		Name:        name,
+		Description: description,
+		OwnerID:     currentUserID,
+		BotID:       bot.ULID,
+		Public:      public,
+		TokenHash:   token.HashToken(apiToken),
+	}
+
+	// A bot user is never left without its application.
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.Create(ctx, bot); err != nil {
+			return err
+		}
+		return s.repo.Create(ctx, app)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ApplicationWithToken{Application: app, Token: apiToken}, nil
+}
+
+func (s *ApplicationService) ListApplications(ctx context.Context, currentUserID string) ([]*models.Application, error) {
+	apps, err := s.repo.GetApplicationsByOwner(ctx, currentUserID)
+	if err != nil {
+		return nil, err
+	}
+	if apps == nil {
+		apps = []*models.Application{}
+	}
+	return apps, nil
+}
+
+func (s *ApplicationService) GetApplication(ctx context.Context, currentUserID, applicationID string) (*models.Application, error) {
+	app, err := s.repo.GetApplicationByID(ctx, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	if app == nil || app.OwnerID != currentUserID {
+		return nil, apperr.NotFound("application not found")
+	}
+	return app, nil
+}
+
+// ResetToken replaces the application's API token. The old token stops
+// working immediately.
+func (s *ApplicationService) ResetToken(ctx context.Context, currentUserID, applicationID string) (*ApplicationWithToken, error) {
+	app, err := s.GetApplication(ctx, currentUserID, applicationID)
+	if err != nil {
+		return nil, err
+	}
+
+	apiToken, err := token.GenerateAPIToken()
+	if err != nil {
+		return nil, err
+	}
+
+	app.TokenHash = token.HashToken(apiToken)
+	if err := s.repo.UpdateTokenHash(ctx, app.ULID, app.TokenHash); err != nil {
+		return nil, err
+	}
+
+	return &ApplicationWithToken{Application: app, Token: apiToken}, nil
+}
+
+// AuthenticateBot resolves a bot API token to the bot's user ID.
+func (s *ApplicationService) AuthenticateBot(ctx context.Context, apiToken string) (string, error) {
+	app, err := s.repo.GetApplicationByTokenHash(ctx, token.HashToken(apiToken))
+	if err != nil {
+		return "", err
+	}
+	if app == nil {
+		return "", apperr.Unauthorized("invalid bot token")
+	}
+	return app.BotID, nil
+}
@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"html"
+	"net/mail"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	channelRepo "rio/internal/repository/channel"
+	emailRepo "rio/internal/repository/channelemail"
+	serverRepo "rio/internal/repository/server"
+
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	// MaxEmailSize is the largest email any channel address accepts, and
+	// the most the SMTP listener reads.
+	MaxEmailSize        = 10 << 20
+	defaultEmailMaxSize = 1 << 20
+	maxAllowedSenders   = 50
+)
+
+var localPartEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// ErrEmailTooLarge is returned by Deliver for emails over the address's
+// size limit.
+var ErrEmailTooLarge = apperr.Invalid("email is too large")
+
+type ChannelEmailService struct {
+	repo        emailRepo.ChannelEmailRepository
+	channelRepo channelRepo.ChannelRepository
+	serverRepo  serverRepo.ServerRepository
+	messages    *MessageService
+	domain      string
+}
+
+// NewChannelEmailService hands out addresses at domain. When domain is
+// empty email ingestion is disabled and no addresses can be created.
+func NewChannelEmailService(
+	repo emailRepo.ChannelEmailRepository,
+	cRepo channelRepo.ChannelRepository,
+	sRepo serverRepo.ServerRepository,
+	messages *MessageService,
+	domain string,
+) *ChannelEmailService {
+	return &ChannelEmailService{
+		repo:        repo,
+		channelRepo: cRepo,
+		serverRepo:  sRepo,
+		messages:    messages,
+		domain:      strings.ToLower(strings.TrimSpace(domain)),
+	}
+}
+
+// Domain returns the domain channel addresses are at.
+func (s *ChannelEmailService) Domain() string {
+	return s.domain
+}
+
+// channelForAdmin returns the channel if currentUserID is an owner or admin
+// of its server.
+func (s *ChannelEmailService) channelForAdmin(ctx context.Context, currentUserID, channelID string) (*models.Channel, error) {
+	channel, err := s.channelRepo.GetChannelByID(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if channel == nil {
+		return nil, ErrChannelNotFound
+	}
+
+	membership, err := s.serverRepo.GetUserMembership(ctx, currentUserID, channel.ServerID)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil {
+		return nil, ErrNotMember
+	}
+	if membership.Role != "owner" && membership.Role != "admin" {
+		return nil, apperr.Forbidden("insufficient permissions: only the server owner or an admin can manage email addresses")
+	}
+	return channel, nil
+}
+
+func (s *ChannelEmailService) withAddress(e *models.ChannelEmail) *models.ChannelEmail {
+	e.Address = e.LocalPart + "@" + s.domain
+	e.SenderList = []string{}
+	if e.AllowedSenders != "" {
+		e.SenderList = strings.Split(e.AllowedSenders, ",")
+	}
+	return e
+}
+
+// normalizeSenders validates an allowlist. Entries are either full
+// addresses or domains, written as example.com or @example.com; domains
+// are stored with the leading '@'.
+func normalizeSenders(senders []string) ([]string, error) {
+	if len(senders) > maxAllowedSenders {
+		return nil, apperr.Invalid("at most %d allowed senders can be listed", maxAllowedSenders)
+	}
+
+	normalized := []string{}
+	for _, sender := range senders {
+		sender = strings.ToLower(strings.TrimSpace(sender))
+		if sender == "" {
+			continue
+		}
+
+		domain, isDomain := strings.CutPrefix(sender, "@")
+		if !isDomain && !strings.Contains(sender, "@") {
+			domain, isDomain = sender, true
+		}
+
+		if isDomain {
+			if domain == "" || strings.ContainsAny(domain, "@,<> ") || !strings.Contains(domain, ".") {
+				return nil, apperr.Invalid("invalid sender domain %q", sender)
+			}
+			sender = "@" + domain
+		} else if addr, err := mail.ParseAddress(sender); err != nil || addr.Address != sender || strings.Contains(sender, ",") {
+			return nil, apperr.Invalid("invalid sender address %q", sender)
+		}
+
+		if !slices.Contains(normalized, sender) {
+			normalized = append(normalized, sender)
+		}
+	}
+	return normalized, nil
+}
+
+func senderAllowed(allowed []string, sender string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	sender = strings.ToLower(sender)
+	_, domain, _ := strings.Cut(sender, "@")
+	return slices.Contains(allowed, sender) || slices.Contains(allowed, "@"+domain)
+}
+
+func generateLocalPart() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return strings.ToLower(localPartEncoding.EncodeToString(buf)), nil
+}
+
+func (s *ChannelEmailService) CreateChannelEmail(ctx context.Context, currentUserID, channelID, name string, allowedSenders []string, maxSize int) (*models.ChannelEmail, error) {
+	if s.domain == "" {
+		return nil, apperr.NotImplemented("email ingestion is not enabled")
+	}
+
+	channel, err := s.channelForAdmin(ctx, currentUserID, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	name = html.EscapeString(strings.TrimSpace(name))
+	if name == "" || len(name) > maxWebhookNameLength {
+		return nil, apperr.Invalid("name must be between 1 and 80 characters")
+	}
+
+	senders, err := normalizeSenders(allowedSenders)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxSize == 0 {
+		maxSize = defaultEmailMaxSize
+	}
+	if maxSize < 0 || maxSize > MaxEmailSize {
+		return nil, apperr.Invalid("max_size must be between 1 and %d bytes", MaxEmailSize)
+	}
+
+	localPart, err := generateLocalPart()
+	if err != nil {
+		return nil, err
+	}
+
+	email := &models.ChannelEmail{
+		ULID:           ulid.Make().String(),
+		ChannelID:      channel.ULID,
+		ServerID:       channel.ServerID,
+		Name:           name,
+		LocalPart:      localPart,
+		AllowedSenders: strings.Join(senders, ","),
+		MaxSize:        maxSize,
+		CreatedBy:      currentUserID,
+	}
+	if err := s.repo.Create(ctx, email); err != nil {
+		return nil, err
+	}
+	return s.withAddress(email), nil
+}
+
+func (s *ChannelEmailService) ListChannelEmails(ctx context.Context, currentUserID, channelID string) ([]*models.ChannelEmail, error) {
+	if _, err := s.channelForAdmin(ctx, currentUserID, channelID); err != nil {
+		return nil, err
+	}
+
+	emails, err := s.repo.GetChannelEmailsByChannel(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if emails == nil {
+		emails = []*models.ChannelEmail{}
+	}
+	for _, e := range emails {
+		s.withAddress(e)
+	}
+	return emails, nil
+}
+
+func (s *ChannelEmailService) DeleteChannelEmail(ctx context.Context, currentUserID, channelID, emailID string) error {
+	if _, err := s.channelForAdmin(ctx, currentUserID, channelID); err != nil {
+		return err
+	}
+	return s.repo.DeleteChannelEmail(ctx, channelID, emailID)
+}
+
+// Recipient returns the channel address mail to address is for. A
+// "+tag" suffix on the local part is ignored.
+func (s *ChannelEmailService) Recipient(ctx context.Context, address string) (*models.ChannelEmail, error) {
+	local, domain, ok := strings.Cut(strings.ToLower(address), "@")
+	if !ok || s.domain == "" || domain != s.domain {
+		return nil, apperr.NotFound("email address not found")
+	}
+	local, _, _ = strings.Cut(local, "+")
+
+	email, err := s.repo.GetChannelEmailByLocalPart(ctx, local)
+	if err != nil {
+		return nil, err
+	}
+	if email == nil {
+		return nil, apperr.NotFound("email address not found")
+	}
+	return s.withAddress(email), nil
+}
+
+// Deliver posts a received email into the address's channel. The sender
+// is checked against the allowlist by the From header, which is what
+// people see; the channel address is the message's author.
+func (s *ChannelEmailService) Deliver(ctx context.Context, address *models.ChannelEmail, raw []byte) (*models.Message, error) {
+	if len(raw) > address.MaxSize {
+		return nil, apperr.Invalid("%w: this address accepts at most %d bytes", ErrEmailTooLarge, address.MaxSize)
+	}
+
+	email, err := parseEmail(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if !senderAllowed(address.SenderList, email.From.Address) {
+		return nil, apperr.Forbidden("sender not allowed for this address")
+	}
+
+	return s.messages.PostEmailMessage(ctx, address, emailContent(email), address.Name, email.Attachments)
+}
+
+// emailContent renders an email as message content: the subject in bold,
+// the sender, then the body, cut to the message length limit.
+func emailContent(email *parsedEmail) string {
+	var b strings.Builder
+	if email.Subject != "" {
+		b.WriteString("**" + email.Subject + "**\n")
+	}
+	from := email.From.Address
+	if email.From.Name != "" {
+		from = email.From.Name + " <" + from + ">"
+	}
+	b.WriteString("From: " + from)
+	if email.Text != "" {
+		b.WriteString("\n\n" + email.Text)
+	}
+
+	content := b.String()
+	if len(content) > maxMessageLength {
+		const marker = "\n… (truncated)"
+		cut := maxMessageLength - len(marker)
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		content = content[:cut] + marker
+	}
+	return content
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"html"
+	"strings"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	channelRepo "rio/internal/repository/channel"
+	serverRepo "rio/internal/repository/server"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// ErrChannelNotFound is returned for channels that do not exist.
+var ErrChannelNotFound = apperr.NotFound("channel not found")
+
+type ChannelService struct {
+	channelRepo channelRepo.ChannelRepository
+	serverRepo  serverRepo.ServerRepository
+}
+
+func NewChannelService(
+	cRepo channelRepo.ChannelRepository,
+	sRepo serverRepo.ServerRepository,
+) *ChannelService {
+	return &ChannelService{
+		channelRepo: cRepo,
+		serverRepo:  sRepo,
+	}
+}
+
+func (s *ChannelService) CreateChannel(ctx context.Context, currentUserID, serverID, name string) (*models.Channel, error) {
+	name = html.EscapeString(strings.TrimSpace(name))
+	if name == "" {
+		return nil, apperr.Invalid("channel name cannot be empty")
+	}
+	if len(name) > 100 {
+		return nil, apperr.Invalid("channel name must be at most 100 characters")
+	}
+
+	membership, err := s.serverRepo.GetUserMembership(ctx, currentUserID, serverID)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil {
+		return nil, ErrNotMember
+	}
+
+	if membership.Role != "owner" && membership.Role != "admin" {
+		return nil, apperr.Forbidden("insufficient permissions: only the server owner or an admin can create channels")
+	}
+
+	channel := &models.Channel{
+		ULID:     ulid.Make().String(),
+		ServerID: serverID,
+		Name:     name,
+	}
+
+	if err := s.channelRepo.Create(ctx, channel); err != nil {
+		return nil, err
+	}
+
+	return channel, nil
+}
+
+func (s *ChannelService) ListChannels(ctx context.Context, currentUserID, serverID string) ([]*models.Channel, error) {
+	membership, err := s.serverRepo.GetUserMembership(ctx, currentUserID, serverID)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil {
+		return nil, ErrNotMember
+	}
+
+	channels, err := s.channelRepo.GetChannelsByServer(ctx, serverID)
+	if err != nil {
+		return nil, err
+	}
+	if channels == nil {
+		channels = []*models.Channel{}
+	}
+	return channels, nil
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+)
+
+// defaultInviteAge applies when /invite is run without expires_in.
+const defaultInviteAge = 24 * time.Hour
+
+// builtinCommand is a command every server has. Built-ins act through
+// ServerService, so they are subject to the same permission checks as the
+// REST endpoints.
+type builtinCommand struct {
+	name        string
+	description string
+	options     []models.CommandOption
+	run         func(ctx context.Context, s *CommandService, currentUserID, serverID string, options map[string]interface{}) (string, error)
+}
+
+func (b builtinCommand) command() *models.ApplicationCommand {
+	return &models.ApplicationCommand{
+		Name:        b.name,
+		Description: b.description,
+		OptionList:  b.options,
+		BuiltIn:     true,
+	}
+}
+
+var builtinCommands = []builtinCommand{
+	{
+		name:        "kick",
+		description: "Remove a member from the server",
+		options: []models.CommandOption{
+			{Name: "user", Description: "Member to remove", Type: models.CommandOptionUser, Required: true},
+		},
+		run: runKick,
+	},
+	{
+		name:        "ban",
+		description: "Remove a user from the server and keep them out",
+		options: []models.CommandOption{
+			{Name: "user", Description: "User to ban", Type: models.CommandOptionUser, Required: true},
+			{Name: "reason", Description: "Why they were banned", Type: models.CommandOptionString},
+		},
+		run: runBan,
+	},
+	{
+		name:        "timeout",
+		description: "Stop a member from posting for a while",
+		options: []models.CommandOption{
+			{Name: "user", Description: "Member to time out", Type: models.CommandOptionUser, Required: true},
+			{Name: "minutes", Description: "How long, in minutes; 0 lifts the timeout", Type: models.CommandOptionInteger, Required: true},
+		},
+		run: runTimeout,
+	},
+	{
+		name:        "invite",
+		description: "Create an invite to this server",
+		options: []models.CommandOption{
+			{Name: "max_uses", Description: "How many times it can be used; 0 for unlimited", Type: models.CommandOptionInteger},
+			{Name: "expires_in", Description: "Minutes until it expires; 0 for never (default one day)", Type: models.CommandOptionInteger},
+		},
+		run: runInvite,
+	},
+}
+
+func builtinByName(name string) (builtinCommand, bool) {
+	for _, b := range builtinCommands {
+		if b.name == name {
+			return b, true
+		}
+	}
+	return builtinCommand{}, false
+}
+
+// displayName returns the username of userID, or the ID if it has none.
+func (s *CommandService) displayName(ctx context.Context, userID string) string {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil || user == nil {
+		return userID
+	}
+	return user.Username
+}
+
+func runKick(ctx context.Context, s *CommandService, currentUserID, serverID string, options map[string]interface{}) (string, error) {
+	target := options["user"].(string)
+	if err := s.servers.RemoveMember(ctx, currentUserID, serverID, target); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Kicked %s.", s.displayName(ctx, target)), nil
+}
+
+func runBan(ctx context.Context, s *CommandService, currentUserID, serverID string, options map[string]interface{}) (string, error) {
+	target := options["user"].(string)
+	reason, _ := options["reason"].(string)
+	if err := s.servers.BanMember(ctx, currentUserID, serverID, target, reason); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Banned %s.", s.displayName(ctx, target)), nil
+}
+
+func runTimeout(ctx context.Context, s *CommandService, currentUserID, serverID string, options map[string]interface{}) (string, error) {
+	target := options["user"].(string)
+	minutes := options["minutes"].(int64)
+	if minutes < 0 || time.Duration(minutes)*time.Minute > maxTimeout {
+		return "", apperr.Invalid("minutes must be between 0 and %d", int64(maxTimeout/time.Minute))
+	}
+
+	if err := s.servers.TimeoutMember(ctx, currentUserID, serverID, target, time.Duration(minutes)*time.Minute); err != nil {
+		return "", err
+	}
+	if minutes == 0 {
+		return fmt.Sprintf("Lifted the timeout of %s.", s.displayName(ctx, target)), nil
+	}
+	return fmt.Sprintf("Timed out %s for %d minutes.", s.displayName(ctx, target), minutes), nil
+}
+
+func runInvite(ctx context.Context, s *CommandService, currentUserID, serverID string, options map[string]interface{}) (string, error) {
+	maxUses, _ := options["max_uses"].(int64)
+
+	maxAge := defaultInviteAge
+	if minutes, ok := options["expires_in"].(int64); ok {
+		if minutes < 0 || time.Duration(minutes)*time.Minute > maxInviteAge {
+			return "", apperr.Invalid("expires_in must be between 0 and %d minutes", int64(maxInviteAge/time.Minute))
+		}
+		maxAge = time.Duration(minutes) * time.Minute
+	}
+
+	invite, err := s.servers.CreateInvite(ctx, currentUserID, serverID, int(maxUses), maxAge)
+	if err != nil {
+		return "", err
+	}
+	if invite.ExpiresAt == nil {
+		return fmt.Sprintf("Created invite %s.", invite.Code), nil
+	}
+	return fmt.Sprintf("Created invite %s, valid until %s.", invite.Code, invite.ExpiresAt.Format(time.RFC3339)), nil
+}
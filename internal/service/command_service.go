@@ -0,0 +1,828 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"html"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/events"
+	"rio/internal/models"
+	appRepo "rio/internal/repository/application"
+	channelRepo "rio/internal/repository/channel"
+	commandRepo "rio/internal/repository/command"
+	interactionRepo "rio/internal/repository/interaction"
+	serverRepo "rio/internal/repository/server"
+	userRepo "rio/internal/repository/user"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// An application has interactionTimeout to answer an interaction POSTed to
+// its URL, and interactionLifetime to send a response after deferring one
+// or after receiving it over the gateway.
+const (
+	interactionTimeout     = 3 * time.Second
+	interactionLifetime    = 15 * time.Minute
+	maxCommandsPerApp      = 50
+	maxCommandOptions      = 25
+	maxCommandOptionLength = 1000
+)
+
+// InteractionHeader carries the interaction ID on requests to an
+// application's interactions URL. They are signed like webhook deliveries,
+// with the application's interactions secret.
+const InteractionHeader = "X-Rio-Interaction"
+
+// Interaction callback and response types. A message answers the
+// interaction; deferred acknowledges it and promises a message later;
+// update edits the message whose component was clicked.
+const (
+	InteractionCallbackMessage  = "message"
+	InteractionCallbackDeferred = "deferred"
+	InteractionCallbackUpdate   = "update"
+)
+
+var commandNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+var commandOptionTypes = []string{
+	models.CommandOptionString,
+	models.CommandOptionInteger,
+	models.CommandOptionBoolean,
+	models.CommandOptionUser,
+	models.CommandOptionChannel,
+}
+
+// CommandDefinition is what an application registers.
+type CommandDefinition struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Options     []models.CommandOption `json:"options"`
+}
+
+// InteractionPayload is sent to the application when one of its commands
+// is invoked or a component on one of its bot's messages is clicked,
+// either POSTed to its interactions URL or as an interaction.created
+// gateway event.
+type InteractionPayload struct {
+	ID            string                 `json:"id"`
+	Type          string                 `json:"type"`
+	ApplicationID string                 `json:"application_id"`
+	ServerID      string                 `json:"server_id"`
+	ChannelID     string                 `json:"channel_id"`
+	UserID        string                 `json:"user_id"`
+	CommandID     string                 `json:"command_id,omitempty"`
+	CommandName   string                 `json:"command_name,omitempty"`
+	Options       map[string]interface{} `json:"options,omitempty"`
+	MessageID     string                 `json:"message_id,omitempty"`
+	CustomID      string                 `json:"custom_id,omitempty"`
+	Values        []string               `json:"values,omitempty"`
+	ExpiresAt     time.Time              `json:"expires_at"`
+}
+
+// InteractionCallback is an application's answer to an interaction. An
+// ephemeral message is shown only to the user who invoked the command;
+// it cannot carry components, since nobody else could click them.
+type InteractionCallback struct {
+	Type       string             `json:"type"`
+	Content    string             `json:"content"`
+	Ephemeral  bool               `json:"ephemeral"`
+	Components []models.ActionRow `json:"components"`
+}
+
+// InteractionResponse is what the invoking user sees: the answer itself,
+// or a deferred placeholder followed later by an interaction.responded
+// gateway event.
+type InteractionResponse struct {
+	InteractionID string          `json:"interaction_id,omitempty"`
+	Type          string          `json:"type"`
+	Content       string          `json:"content,omitempty"`
+	Ephemeral     bool            `json:"ephemeral,omitempty"`
+	Message       *models.Message `json:"message,omitempty"`
+}
+
+// InteractionsEndpoint is returned when an application's interactions URL
+// is set; it is the only time the signing secret is shown.
+type InteractionsEndpoint struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+}
+
+type CommandService struct {
+	commandRepo     commandRepo.CommandRepository
+	interactionRepo interactionRepo.InteractionRepository
+	appRepo         appRepo.ApplicationRepository
+	channelRepo     channelRepo.ChannelRepository
+	serverRepo      serverRepo.ServerRepository
+	userRepo        userRepo.UserRepository
+	servers         *ServerService
+	messages        *MessageService
+	gateway         *GatewayService
+	client          *http.Client
+	insecure        bool
+}
+
+// NewCommandService wires the command registry to the services built-in
+// commands act through. allowInsecure has the same meaning as for
+// outgoing webhooks.
+func NewCommandService(
+	cmdRepo commandRepo.CommandRepository,
+	iRepo interactionRepo.InteractionRepository,
+	aRepo appRepo.ApplicationRepository,
+	cRepo channelRepo.ChannelRepository,
+	sRepo serverRepo.ServerRepository,
+	uRepo userRepo.UserRepository,
+	servers *ServerService,
+	messages *MessageService,
+	gateway *GatewayService,
+	allowInsecure bool,
+) *CommandService {
+	client := newOutboundClient(allowInsecure)
+	client.Timeout = interactionTimeout
+
+	return &CommandService{
+		commandRepo:     cmdRepo,
+		interactionRepo: iRepo,
+		appRepo:         aRepo,
+		channelRepo:     cRepo,
+		serverRepo:      sRepo,
+		userRepo:        uRepo,
+		servers:         servers,
+		messages:        messages,
+		gateway:         gateway,
+		client:          client,
+		insecure:        allowInsecure,
+	}
+}
+
+// SetInteractionsEndpoint points an application's interactions at url and
+// issues a new signing secret. An empty url switches the application back
+// to receiving interactions over the gateway.
+func (s *CommandService) SetInteractionsEndpoint(ctx context.Context, currentUserID, applicationID, rawURL string) (*InteractionsEndpoint, error) {
+	app, err := s.appRepo.GetApplicationByID(ctx, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	if app == nil || app.OwnerID != currentUserID {
+		return nil, apperr.NotFound("application not found")
+	}
+
+	if strings.TrimSpace(rawURL) == "" {
+		if err := s.appRepo.UpdateInteractionsEndpoint(ctx, app.ULID, "", ""); err != nil {
+			return nil, err
+		}
+		return &InteractionsEndpoint{}, nil
+	}
+
+	target, err := validateEndpointURL("interactions", rawURL, s.insecure)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	secret := hex.EncodeToString(buf)
+
+	if err := s.appRepo.UpdateInteractionsEndpoint(ctx, app.ULID, target, secret); err != nil {
+		return nil, err
+	}
+	return &InteractionsEndpoint{URL: target, Secret: secret}, nil
+}
+
+func withOptionList(c *models.ApplicationCommand) *models.ApplicationCommand {
+	c.OptionList = []models.CommandOption{}
+	if c.Options != "" {
+		if err := json.Unmarshal([]byte(c.Options), &c.OptionList); err != nil {
+			log.Printf("commands: cannot decode options of %s: %v", c.ULID, err)
+		}
+	}
+	return c
+}
+
+func validateCommandOptions(options []models.CommandOption) (string, error) {
+	if len(options) > maxCommandOptions {
+		return "", apperr.Invalid("a command can have at most %d options", maxCommandOptions)
+	}
+
+	var names []string
+	optional := false
+	for i := range options {
+		o := &options[i]
+		o.Name = strings.TrimSpace(o.Name)
+		o.Description = html.EscapeString(strings.TrimSpace(o.Description))
+
+		if !commandNamePattern.MatchString(o.Name) {
+			return "", apperr.Invalid("option name %q must be 1-32 lowercase letters, digits, dashes or underscores", o.Name)
+		}
+		if slices.Contains(names, o.Name) {
+			return "", apperr.Invalid("duplicate option %q", o.Name)
+		}
+		names = append(names, o.Name)
+
+		if o.Description == "" || len(o.Description) > 100 {
+			return "", apperr.Invalid("option %q needs a description of at most 100 characters", o.Name)
+		}
+		if !slices.Contains(commandOptionTypes, o.Type) {
+			return "", apperr.Invalid("option %q has unknown type %q; must be one of: %s", o.Name, o.Type, strings.Join(commandOptionTypes, ", "))
+		}
+
+		if !o.Required {
+			optional = true
+		} else if optional {
+			return "", apperr.Invalid("required options must come before optional ones")
+		}
+	}
+
+	encoded, err := json.Marshal(options)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// applicationForBot returns the application currentUserID is the bot of.
+func (s *CommandService) applicationForBot(ctx context.Context, currentUserID string) (*models.Application, error) {
+	app, err := s.appRepo.GetApplicationByBotID(ctx, currentUserID)
+	if err != nil {
+		return nil, err
+	}
+	if app == nil {
+		return nil, apperr.Forbidden("insufficient permissions: only bots can manage commands and answer interactions")
+	}
+	return app, nil
+}
+
+// RegisterCommand creates or replaces one of the calling bot's commands in
+// a server it belongs to. Command names are unique within a server and
+// cannot shadow a built-in.
+func (s *CommandService) RegisterCommand(ctx context.Context, currentUserID, serverID string, def CommandDefinition) (*models.ApplicationCommand, error) {
+	app, err := s.applicationForBot(ctx, currentUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	membership, err := s.serverRepo.GetUserMembership(ctx, currentUserID, serverID)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil {
+		return nil, ErrNotMember
+	}
+
+	name := strings.ToLower(strings.TrimSpace(def.Name))
+	if !commandNamePattern.MatchString(name) {
+		return nil, apperr.Invalid("command name must be 1-32 lowercase letters, digits, dashes or underscores")
+	}
+	if _, ok := builtinByName(name); ok {
+		return nil, apperr.Invalid("/%s is a built-in command", name)
+	}
+
+	description := html.EscapeString(strings.TrimSpace(def.Description))
+	if description == "" || len(description) > 100 {
+		return nil, apperr.Invalid("command description must be between 1 and 100 characters")
+	}
+
+	options, err := validateCommandOptions(def.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.commandRepo.GetCommandByName(ctx, serverID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil {
+		if existing.ApplicationID != app.ULID {
+			return nil, apperr.Conflict("another application already registered /%s in this server", name)
+		}
+		existing.Description = description
+		existing.Options = options
+		if err := s.commandRepo.UpdateCommand(ctx, existing); err != nil {
+			return nil, err
+		}
+		return withOptionList(existing), nil
+	}
+
+	commands, err := s.commandRepo.GetCommandsByServer(ctx, serverID)
+	if err != nil {
+		return nil, err
+	}
+	count := 0
+	for _, c := range commands {
+		if c.ApplicationID == app.ULID {
+			count++
+		}
+	}
+	if count >= maxCommandsPerApp {
+		return nil, apperr.Invalid("an application can register at most %d commands per server", maxCommandsPerApp)
+	}
+
+	command := &models.ApplicationCommand{
+		ULID:          ulid.Make().String(),
+		ApplicationID: app.ULID,
+		ServerID:      serverID,
+		Name:          name,
+		Description:   description,
+		Options:       options,
+	}
+	if err := s.commandRepo.Create(ctx, command); err != nil {
+		return nil, err
+	}
+	return withOptionList(command), nil
+}
+
+func (s *CommandService) DeleteCommand(ctx context.Context, currentUserID, serverID, commandID string) error {
+	app, err := s.applicationForBot(ctx, currentUserID)
+	if err != nil {
+		return err
+	}
+
+	command, err := s.commandRepo.GetCommandByID(ctx, commandID)
+	if err != nil {
+		return err
+	}
+	if command == nil || command.ServerID != serverID || command.ApplicationID != app.ULID {
+		return apperr.NotFound("command not found")
+	}
+
+	return s.commandRepo.DeleteCommand(ctx, command.ULID)
+}
+
+// ListCommands returns the commands members of a server can invoke: the
+// built-ins, then those of applications whose bot is still a member.
+func (s *CommandService) ListCommands(ctx context.Context, currentUserID, serverID string) ([]*models.ApplicationCommand, error) {
+	membership, err := s.serverRepo.GetUserMembership(ctx, currentUserID, serverID)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil {
+		return nil, ErrNotMember
+	}
+
+	var list []*models.ApplicationCommand
+	for _, b := range builtinCommands {
+		list = append(list, b.command())
+	}
+
+	registered, err := s.commandRepo.GetCommandsByServer(ctx, serverID)
+	if err != nil {
+		return nil, err
+	}
+
+	installed := map[string]bool{}
+	for _, c := range registered {
+		ok, seen := installed[c.ApplicationID]
+		if !seen {
+			app, err := s.installedApplication(ctx, c.ApplicationID, serverID)
+			if err != nil {
+				return nil, err
+			}
+			ok = app != nil
+			installed[c.ApplicationID] = ok
+		}
+		if ok {
+			list = append(list, withOptionList(c))
+		}
+	}
+	return list, nil
+}
+
+// installedApplication returns the application if its bot is a member of
+// the server, and nil otherwise.
+func (s *CommandService) installedApplication(ctx context.Context, applicationID, serverID string) (*models.Application, error) {
+	app, err := s.appRepo.GetApplicationByID(ctx, applicationID)
+	if err != nil || app == nil {
+		return nil, err
+	}
+
+	membership, err := s.serverRepo.GetUserMembership(ctx, app.BotID, serverID)
+	if err != nil || membership == nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// parseOptions checks the values a user supplied against a command's
+// option definitions and returns them normalized: integers as int64,
+// users and channels as IDs that exist.
+func (s *CommandService) parseOptions(ctx context.Context, serverID string, defs []models.CommandOption, given map[string]interface{}) (map[string]interface{}, error) {
+	for name := range given {
+		if !slices.ContainsFunc(defs, func(d models.CommandOption) bool { return d.Name == name }) {
+			return nil, apperr.Invalid("unknown option %q", name)
+		}
+	}
+
+	values := make(map[string]interface{}, len(given))
+	for _, def := range defs {
+		v, ok := given[def.Name]
+		if !ok || v == nil {
+			if def.Required {
+				return nil, apperr.Invalid("option %q is required", def.Name)
+			}
+			continue
+		}
+
+		switch def.Type {
+		case models.CommandOptionInteger:
+			f, ok := v.(float64)
+			if !ok || f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+				return nil, apperr.Invalid("option %q must be an integer", def.Name)
+			}
+			values[def.Name] = int64(f)
+
+		case models.CommandOptionBoolean:
+			b, ok := v.(bool)
+			if !ok {
+				return nil, apperr.Invalid("option %q must be true or false", def.Name)
+			}
+			values[def.Name] = b
+
+		default:
+			str, ok := v.(string)
+			str = strings.TrimSpace(str)
+			if !ok || str == "" {
+				return nil, apperr.Invalid("option %q must be a non-empty string", def.Name)
+			}
+			if len(str) > maxCommandOptionLength {
+				return nil, apperr.Invalid("option %q must be at most %d characters", def.Name, maxCommandOptionLength)
+			}
+
+			switch def.Type {
+			case models.CommandOptionUser:
+				user, err := s.userRepo.GetUserByID(ctx, str)
+				if err != nil {
+					return nil, err
+				}
+				if user == nil {
+					return nil, apperr.NotFound("option %q: user not found", def.Name)
+				}
+			case models.CommandOptionChannel:
+				channel, err := s.channelRepo.GetChannelByID(ctx, str)
+				if err != nil {
+					return nil, err
+				}
+				if channel == nil || channel.ServerID != serverID {
+					return nil, apperr.NotFound("option %q: channel not found", def.Name)
+				}
+			}
+			values[def.Name] = str
+		}
+	}
+	return values, nil
+}
+
+// Invoke runs a command in a channel. Built-ins run immediately and answer
+// ephemerally; application commands are dispatched to their application.
+func (s *CommandService) Invoke(ctx context.Context, currentUserID, channelID, name string, options map[string]interface{}) (*InteractionResponse, error) {
+	channel, err := s.channelRepo.GetChannelByID(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if channel == nil {
+		return nil, ErrChannelNotFound
+	}
+
+	membership, err := s.serverRepo.GetUserMembership(ctx, currentUserID, channel.ServerID)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil {
+		return nil, ErrNotMember
+	}
+	if err := checkTimeout(membership); err != nil {
+		return nil, err
+	}
+
+	name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "/"))
+
+	if b, ok := builtinByName(name); ok {
+		values, err := s.parseOptions(ctx, channel.ServerID, b.options, options)
+		if err != nil {
+			return nil, err
+		}
+		content, err := b.run(ctx, s, currentUserID, channel.ServerID, values)
+		if err != nil {
+			return nil, err
+		}
+		return &InteractionResponse{Type: InteractionCallbackMessage, Content: content, Ephemeral: true}, nil
+	}
+
+	command, err := s.commandRepo.GetCommandByName(ctx, channel.ServerID, name)
+	if err != nil {
+		return nil, err
+	}
+	var app *models.Application
+	if command != nil {
+		app, err = s.installedApplication(ctx, command.ApplicationID, channel.ServerID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if app == nil {
+		return nil, apperr.NotFound("command not found")
+	}
+
+	values, err := s.parseOptions(ctx, channel.ServerID, withOptionList(command).OptionList, options)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction := &models.Interaction{
+		ULID:          ulid.Make().String(),
+		Type:          models.InteractionCommand,
+		ApplicationID: app.ULID,
+		CommandID:     command.ULID,
+		ServerID:      channel.ServerID,
+		ChannelID:     channel.ULID,
+		UserID:        currentUserID,
+		CommandName:   command.Name,
+		Options:       string(encoded),
+		Status:        models.InteractionPending,
+		ExpiresAt:     time.Now().Add(interactionLifetime).UTC(),
+	}
+	if err := s.interactionRepo.Create(ctx, interaction); err != nil {
+		return nil, err
+	}
+
+	return s.dispatch(ctx, app, interaction, &InteractionPayload{
+		ID:            interaction.ULID,
+		Type:          interaction.Type,
+		ApplicationID: app.ULID,
+		ServerID:      interaction.ServerID,
+		ChannelID:     interaction.ChannelID,
+		UserID:        currentUserID,
+		CommandID:     command.ULID,
+		CommandName:   command.Name,
+		Options:       values,
+		ExpiresAt:     interaction.ExpiresAt,
+	})
+}
+
+// Click handles a click on a message component. The interaction goes to
+// the application whose bot posted the message, which can reply or update
+// the message.
+func (s *CommandService) Click(ctx context.Context, currentUserID, messageID, customID string, values []string) (*InteractionResponse, error) {
+	message, channel, membership, err := s.messages.GetMessageForMember(ctx, currentUserID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTimeout(membership); err != nil {
+		return nil, err
+	}
+
+	component := findComponent(message.Components, customID)
+	if component == nil {
+		return nil, apperr.NotFound("component not found")
+	}
+	if component.Disabled {
+		return nil, apperr.Invalid("component is disabled")
+	}
+	if values == nil {
+		values = []string{}
+	}
+	if err := validateComponentValues(component, values); err != nil {
+		return nil, err
+	}
+
+	app, err := s.appRepo.GetApplicationByBotID(ctx, message.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if app != nil {
+		app, err = s.installedApplication(ctx, app.ULID, channel.ServerID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if app == nil {
+		return nil, apperr.NotFound("the application that posted this message is no longer in this server")
+	}
+
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction := &models.Interaction{
+		ULID:          ulid.Make().String(),
+		Type:          models.InteractionComponent,
+		ApplicationID: app.ULID,
+		ServerID:      channel.ServerID,
+		ChannelID:     channel.ULID,
+		UserID:        currentUserID,
+		Options:       string(encoded),
+		Status:        models.InteractionPending,
+		ExpiresAt:     time.Now().Add(interactionLifetime).UTC(),
+		MessageID:     message.ULID,
+		CustomID:      customID,
+	}
+	if err := s.interactionRepo.Create(ctx, interaction); err != nil {
+		return nil, err
+	}
+
+	return s.dispatch(ctx, app, interaction, &InteractionPayload{
+		ID:            interaction.ULID,
+		Type:          interaction.Type,
+		ApplicationID: app.ULID,
+		ServerID:      interaction.ServerID,
+		ChannelID:     interaction.ChannelID,
+		UserID:        currentUserID,
+		MessageID:     message.ULID,
+		CustomID:      customID,
+		Values:        values,
+		ExpiresAt:     interaction.ExpiresAt,
+	})
+}
+
+// dispatch hands a new interaction to its application: POSTed to the
+// interactions URL and answered with the reply, or sent to the bot over
+// the gateway and answered later.
+func (s *CommandService) dispatch(ctx context.Context, app *models.Application, interaction *models.Interaction, payload *InteractionPayload) (*InteractionResponse, error) {
+	if app.InteractionsURL != "" {
+		callback, err := s.post(app, payload)
+		if err != nil {
+			log.Printf("commands: interaction %s to %s failed: %v", interaction.ULID, app.ULID, err)
+			s.fail(ctx, interaction)
+			return nil, apperr.Unavailable("the application did not respond to the interaction")
+		}
+
+		resp, err := s.acknowledge(ctx, interaction, app, callback)
+		if err != nil {
+			s.fail(ctx, interaction)
+			return nil, apperr.Unavailable("the application sent an invalid response: %w", err)
+		}
+		return resp, nil
+	}
+
+	if !s.gateway.SendToUser(app.BotID, events.New(events.InteractionCreated, interaction.ServerID, payload)) {
+		s.fail(ctx, interaction)
+		return nil, apperr.Unavailable("the application is not connected")
+	}
+	return &InteractionResponse{InteractionID: interaction.ULID, Type: InteractionCallbackDeferred}, nil
+}
+
+// fail marks the interaction failed even if the request that started it
+// has been cancelled meanwhile.
+func (s *CommandService) fail(ctx context.Context, interaction *models.Interaction) {
+	ctx = context.WithoutCancel(ctx)
+	from := []string{models.InteractionPending, models.InteractionDeferred}
+	if _, err := s.interactionRepo.UpdateInteractionStatus(ctx, interaction.ULID, from, models.InteractionFailed); err != nil {
+		log.Printf("commands: cannot mark interaction %s failed: %v", interaction.ULID, err)
+	}
+}
+
+func (s *CommandService) post(app *models.Application, payload *InteractionPayload) (*InteractionCallback, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, app.InteractionsURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "rio-interactions/1")
+	req.Header.Set(InteractionHeader, payload.ID)
+	req.Header.Set(WebhookSignatureHeader, signWebhookPayload(app.InteractionsSecret, time.Now().Unix(), body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, apperr.Unavailable("endpoint responded %s", resp.Status)
+	}
+
+	var callback InteractionCallback
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&callback); err != nil {
+		return nil, apperr.Unavailable("cannot decode response: %w", err)
+	}
+	return &callback, nil
+}
+
+// acknowledge applies an application's callback to a pending or deferred
+// interaction. Public messages are posted to the channel as the bot;
+// ephemeral ones are only returned. Updates edit the clicked message.
+func (s *CommandService) acknowledge(ctx context.Context, interaction *models.Interaction, app *models.Application, callback *InteractionCallback) (*InteractionResponse, error) {
+	from := []string{models.InteractionPending, models.InteractionDeferred}
+
+	switch callback.Type {
+	case InteractionCallbackDeferred:
+		ok, err := s.interactionRepo.UpdateInteractionStatus(ctx, interaction.ULID, []string{models.InteractionPending}, models.InteractionDeferred)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, apperr.Conflict("interaction has already been acknowledged")
+		}
+		return &InteractionResponse{InteractionID: interaction.ULID, Type: InteractionCallbackDeferred}, nil
+
+	case InteractionCallbackUpdate:
+		if interaction.Type != models.InteractionComponent {
+			return nil, apperr.Invalid("only component interactions can update a message")
+		}
+
+		ok, err := s.interactionRepo.UpdateInteractionStatus(ctx, interaction.ULID, from, models.InteractionResponded)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, apperr.Conflict("interaction has already been responded to")
+		}
+
+		message, err := s.messages.EditMessage(ctx, app.BotID, interaction.MessageID, callback.Content, callback.Components)
+		if err != nil {
+			return nil, err
+		}
+		return &InteractionResponse{InteractionID: interaction.ULID, Type: InteractionCallbackUpdate, Message: message}, nil
+
+	case InteractionCallbackMessage:
+		content := strings.TrimSpace(callback.Content)
+		if content == "" {
+			return nil, ErrEmptyMessage
+		}
+		if len(content) > maxMessageLength {
+			return nil, apperr.Invalid("message content must be at most 4000 characters")
+		}
+		if callback.Ephemeral && len(callback.Components) > 0 {
+			return nil, apperr.Invalid("ephemeral messages cannot have components")
+		}
+
+		ok, err := s.interactionRepo.UpdateInteractionStatus(ctx, interaction.ULID, from, models.InteractionResponded)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, apperr.Conflict("interaction has already been responded to")
+		}
+
+		resp := &InteractionResponse{InteractionID: interaction.ULID, Type: InteractionCallbackMessage}
+		if callback.Ephemeral {
+			resp.Content = content
+			resp.Ephemeral = true
+			return resp, nil
+		}
+
+		message, err := s.messages.SendMessage(ctx, app.BotID, interaction.ChannelID, content, callback.Components)
+		if err != nil {
+			return nil, err
+		}
+		resp.Message = message
+		return resp, nil
+
+	default:
+		return nil, apperr.Invalid("unknown response type %q; must be message, deferred or update", callback.Type)
+	}
+}
+
+// Respond answers an interaction the calling bot received over the
+// gateway or deferred. The invoking user gets the answer as an
+// interaction.responded gateway event.
+func (s *CommandService) Respond(ctx context.Context, currentUserID, interactionID string, callback *InteractionCallback) (*InteractionResponse, error) {
+	app, err := s.applicationForBot(ctx, currentUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction, err := s.interactionRepo.GetInteractionByID(ctx, interactionID)
+	if err != nil {
+		return nil, err
+	}
+	if interaction == nil || interaction.ApplicationID != app.ULID {
+		return nil, apperr.NotFound("interaction not found")
+	}
+	if time.Now().After(interaction.ExpiresAt) {
+		return nil, apperr.Gone("interaction has expired")
+	}
+
+	resp, err := s.acknowledge(ctx, interaction, app, callback)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Type != InteractionCallbackDeferred {
+		s.gateway.SendToUser(interaction.UserID, events.New(events.InteractionResponded, interaction.ServerID, resp))
+	}
+	return resp, nil
+}
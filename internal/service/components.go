@@ -0,0 +1,213 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+)
+
+const (
+	maxActionRows     = 5
+	maxRowButtons     = 5
+	maxSelectOptions  = 25
+	maxCustomIDLength = 100
+)
+
+var buttonStyles = []string{
+	models.ButtonPrimary,
+	models.ButtonSecondary,
+	models.ButtonSuccess,
+	models.ButtonDanger,
+	models.ButtonLink,
+}
+
+// validateComponents checks a message's action rows and returns them
+// encoded for storage. No rows encode to the empty string.
+func validateComponents(rows []models.ActionRow) (string, error) {
+	if len(rows) == 0 {
+		return "", nil
+	}
+	if len(rows) > maxActionRows {
+		return "", apperr.Invalid("a message can have at most %d action rows", maxActionRows)
+	}
+
+	var customIDs []string
+	for i := range rows {
+		row := rows[i].Components
+		switch {
+		case len(row) == 0:
+			return "", apperr.Invalid("action row %d is empty", i+1)
+		case len(row) > maxRowButtons:
+			return "", apperr.Invalid("action row %d has more than %d components", i+1, maxRowButtons)
+		}
+
+		for j := range row {
+			c := &row[j]
+			var err error
+			switch c.Type {
+			case models.ComponentButton:
+				err = validateButton(c)
+			case models.ComponentSelect:
+				if len(row) != 1 {
+					err = apperr.Invalid("a select menu must be alone in its action row")
+				} else {
+					err = validateSelect(c)
+				}
+			default:
+				err = apperr.Invalid("unknown component type %q; must be button or select", c.Type)
+			}
+			if err != nil {
+				return "", fmt.Errorf("action row %d, component %d: %w", i+1, j+1, err)
+			}
+
+			if c.CustomID != "" {
+				if slices.Contains(customIDs, c.CustomID) {
+					return "", apperr.Invalid("duplicate custom_id %q", c.CustomID)
+				}
+				customIDs = append(customIDs, c.CustomID)
+			}
+		}
+	}
+
+	encoded, err := json.Marshal(rows)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+func validateCustomID(id string) error {
+	if id == "" || len(id) > maxCustomIDLength {
+		return apperr.Invalid("custom_id must be between 1 and %d characters", maxCustomIDLength)
+	}
+	return nil
+}
+
+func validateButton(c *models.Component) error {
+	c.Label = strings.TrimSpace(c.Label)
+	if c.Label == "" || len(c.Label) > 80 {
+		return apperr.Invalid("button label must be between 1 and 80 characters")
+	}
+	if c.Style == "" {
+		c.Style = models.ButtonSecondary
+	}
+	if !slices.Contains(buttonStyles, c.Style) {
+		return apperr.Invalid("unknown button style %q; must be one of: %s", c.Style, strings.Join(buttonStyles, ", "))
+	}
+	if c.Placeholder != "" || c.MinValues != 0 || c.MaxValues != 0 || len(c.Options) > 0 {
+		return apperr.Invalid("buttons cannot have select menu fields")
+	}
+
+	if c.Style == models.ButtonLink {
+		if c.CustomID != "" {
+			return apperr.Invalid("link buttons cannot have a custom_id")
+		}
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || len(c.URL) > 512 {
+			return apperr.Invalid("link buttons need an http or https URL of at most 512 characters")
+		}
+		return nil
+	}
+
+	if c.URL != "" {
+		return apperr.Invalid("only link buttons can have a URL")
+	}
+	return validateCustomID(c.CustomID)
+}
+
+func validateSelect(c *models.Component) error {
+	if err := validateCustomID(c.CustomID); err != nil {
+		return err
+	}
+	if c.Label != "" || c.Style != "" || c.URL != "" {
+		return apperr.Invalid("select menus cannot have button fields")
+	}
+	if len(c.Placeholder) > 150 {
+		return apperr.Invalid("placeholder must be at most 150 characters")
+	}
+
+	if len(c.Options) == 0 || len(c.Options) > maxSelectOptions {
+		return apperr.Invalid("a select menu needs between 1 and %d options", maxSelectOptions)
+	}
+	var values []string
+	for i := range c.Options {
+		o := &c.Options[i]
+		o.Label = strings.TrimSpace(o.Label)
+		if o.Label == "" || len(o.Label) > 100 {
+			return apperr.Invalid("option labels must be between 1 and 100 characters")
+		}
+		if o.Value == "" || len(o.Value) > 100 {
+			return apperr.Invalid("option values must be between 1 and 100 characters")
+		}
+		if len(o.Description) > 100 {
+			return apperr.Invalid("option descriptions must be at most 100 characters")
+		}
+		if slices.Contains(values, o.Value) {
+			return apperr.Invalid("duplicate option value %q", o.Value)
+		}
+		values = append(values, o.Value)
+	}
+
+	if c.MaxValues == 0 {
+		c.MaxValues = 1
+	}
+	if c.MinValues < 0 || c.MinValues > c.MaxValues || c.MaxValues > len(c.Options) {
+		return apperr.Invalid("min_values and max_values must satisfy 0 <= min_values <= max_values <= number of options")
+	}
+	return nil
+}
+
+// withComponents decodes the message's stored components.
+func withComponents(m *models.Message) *models.Message {
+	if m.ComponentsJSON != "" {
+		if err := json.Unmarshal([]byte(m.ComponentsJSON), &m.Components); err != nil {
+			log.Printf("messages: cannot decode components of %s: %v", m.ULID, err)
+		}
+	}
+	return m
+}
+
+// findComponent returns the interactive component with customID.
+func findComponent(rows []models.ActionRow, customID string) *models.Component {
+	for _, row := range rows {
+		for i := range row.Components {
+			if row.Components[i].CustomID == customID {
+				return &row.Components[i]
+			}
+		}
+	}
+	return nil
+}
+
+// validateComponentValues checks the values submitted with a click: none
+// for a button, and between MinValues and MaxValues of the menu's own
+// values for a select menu.
+func validateComponentValues(c *models.Component, values []string) error {
+	if c.Type == models.ComponentButton {
+		if len(values) > 0 {
+			return apperr.Invalid("buttons do not take values")
+		}
+		return nil
+	}
+
+	if len(values) < c.MinValues || len(values) > c.MaxValues {
+		return apperr.Invalid("select between %d and %d values", c.MinValues, c.MaxValues)
+	}
+	var seen []string
+	for _, v := range values {
+		if !slices.ContainsFunc(c.Options, func(o models.SelectOption) bool { return o.Value == v }) {
+			return apperr.Invalid("unknown value %q", v)
+		}
+		if slices.Contains(seen, v) {
+			return apperr.Invalid("duplicate value %q", v)
+		}
+		seen = append(seen, v)
+	}
+	return nil
+}
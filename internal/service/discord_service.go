@@ -0,0 +1,718 @@
+package service
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	serverRepo "rio/internal/repository/server"
+	snowflakeRepo "rio/internal/repository/snowflake"
+	userRepo "rio/internal/repository/user"
+	"rio/utils/snowflake"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// Discord permission bits granted by the roles rio's member roles map to.
+const (
+	discordCreateInvite   = 1 << 0
+	discordKickMembers    = 1 << 1
+	discordBanMembers     = 1 << 2
+	discordAdministrator  = 1 << 3
+	discordManageChannels = 1 << 4
+	discordManageGuild    = 1 << 5
+	discordViewChannel    = 1 << 10
+	discordSendMessages   = 1 << 11
+	discordManageMessages = 1 << 13
+	discordReadHistory    = 1 << 16
+	discordUseCommands    = 1 << 31
+	discordModerate       = 1 << 40
+
+	maxDiscordMemberPage = 1000
+)
+
+// discordRoles are the roles every guild has. rio's member roles are fixed,
+// so each guild gets @everyone, whose ID is the guild's own as on Discord,
+// plus one role per rank above member. Owners have no role; the guild's
+// owner_id identifies them.
+var discordRoles = []struct {
+	role        string
+	name        string
+	permissions uint64
+}{
+	{"member", "@everyone", discordCreateInvite | discordViewChannel | discordSendMessages | discordReadHistory | discordUseCommands},
+	{"moderator", "Moderator", discordKickMembers | discordBanMembers | discordManageMessages | discordModerate},
+	{"admin", "Admin", discordAdministrator | discordManageChannels | discordManageGuild},
+}
+
+// Errors for IDs that name no object of their kind. Each has its own
+// Discord error code.
+var (
+	ErrUnknownGuild   = apperr.NotFound("unknown guild")
+	ErrUnknownChannel = apperr.NotFound("unknown channel")
+	ErrUnknownMessage = apperr.NotFound("unknown message")
+	ErrUnknownUser    = apperr.NotFound("unknown user")
+	ErrUnknownMember  = apperr.NotFound("unknown member")
+	ErrUnknownRole    = apperr.NotFound("unknown role")
+)
+
+// DiscordService serves the subset of Discord's HTTP API that bots use most,
+// on top of the regular services, so existing bot libraries can talk to rio
+// by changing their base URL. IDs are snowflakes derived from ULIDs; every
+// ID handed out is recorded so it can be resolved when it comes back. ULIDs
+// are accepted wherever a snowflake is.
+type DiscordService struct {
+	snowflakeRepo snowflakeRepo.SnowflakeRepository
+	userRepo      userRepo.UserRepository
+	serverRepo    serverRepo.ServerRepository
+	servers       *ServerService
+	channels      *ChannelService
+	messages      *MessageService
+	publicURL     string
+
+	mu    sync.RWMutex
+	known map[string]string
+}
+
+func NewDiscordService(
+	sfRepo snowflakeRepo.SnowflakeRepository,
+	uRepo userRepo.UserRepository,
+	sRepo serverRepo.ServerRepository,
+	servers *ServerService,
+	channels *ChannelService,
+	messages *MessageService,
+	publicURL string,
+) *DiscordService {
+	return &DiscordService{
+		snowflakeRepo: sfRepo,
+		userRepo:      uRepo,
+		serverRepo:    sRepo,
+		servers:       servers,
+		channels:      channels,
+		messages:      messages,
+		publicURL:     strings.TrimRight(publicURL, "/"),
+		known:         map[string]string{},
+	}
+}
+
+// snowflake returns the snowflake of a ULID, recording it the first time.
+func (s *DiscordService) snowflake(ctx context.Context, id string) string {
+	sf, err := snowflake.FromULID(id, "")
+	if err != nil {
+		return id
+	}
+
+	s.mu.RLock()
+	_, ok := s.known[sf]
+	s.mu.RUnlock()
+	if ok {
+		return sf
+	}
+
+	existing, err := s.snowflakeRepo.GetBySnowflake(ctx, sf)
+	switch {
+	case err != nil:
+		log.Printf("discord: cannot look up snowflake %s: %v", sf, err)
+		return sf
+	case existing == nil:
+		if err := s.snowflakeRepo.Create(ctx, &models.Snowflake{Snowflake: sf, ULID: id}); err != nil {
+			log.Printf("discord: cannot record snowflake %s: %v", sf, err)
+			return sf
+		}
+	case existing.ULID != id:
+		log.Printf("discord: snowflake %s of %s collides with %s", sf, id, existing.ULID)
+		return sf
+	}
+
+	s.mu.Lock()
+	s.known[sf] = id
+	s.mu.Unlock()
+	return sf
+}
+
+// resolve maps an ID from a request back to a ULID, returning unknown if
+// the ID is not one rio handed out.
+func (s *DiscordService) resolve(ctx context.Context, id string, unknown error) (string, error) {
+	if _, err := ulid.ParseStrict(id); err == nil {
+		return id, nil
+	}
+	if !snowflake.Valid(id) {
+		return "", unknown
+	}
+
+	s.mu.RLock()
+	known, ok := s.known[id]
+	s.mu.RUnlock()
+	if ok {
+		return known, nil
+	}
+
+	existing, err := s.snowflakeRepo.GetBySnowflake(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if existing == nil {
+		return "", unknown
+	}
+
+	s.mu.Lock()
+	s.known[id] = existing.ULID
+	s.mu.Unlock()
+	return existing.ULID, nil
+}
+
+func (s *DiscordService) roleID(ctx context.Context, serverID, role string) string {
+	if role == "member" {
+		return s.snowflake(ctx, serverID)
+	}
+	sf, err := snowflake.FromULID(serverID, "role:"+role)
+	if err != nil {
+		return serverID + ":" + role
+	}
+	return sf
+}
+
+// roleFor returns the rio role a set of Discord roles amounts to: the
+// highest of them, or member without any.
+func (s *DiscordService) roleFor(ctx context.Context, serverID string, roleIDs []string) (string, error) {
+	rank := 0
+	for _, id := range roleIDs {
+		found := false
+		for i, r := range discordRoles {
+			if s.roleID(ctx, serverID, r.role) == id {
+				rank, found = max(rank, i), true
+				break
+			}
+		}
+		if !found {
+			return "", ErrUnknownRole
+		}
+	}
+	return discordRoles[rank].role, nil
+}
+
+func (s *DiscordService) memberRoles(ctx context.Context, serverID, role string) []string {
+	if role == "member" || role == "owner" {
+		return []string{}
+	}
+	return []string{s.roleID(ctx, serverID, role)}
+}
+
+func discordTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000+00:00")
+}
+
+func (s *DiscordService) user(ctx context.Context, u *models.User) *DiscordUser {
+	return &DiscordUser{
+		ID:            s.snowflake(ctx, u.ULID),
+		Username:      u.Username,
+		Discriminator: "0",
+		GlobalName:    &u.Username,
+		Bot:           u.IsBot,
+		MFAEnabled:    u.TOTPEnabled,
+	}
+}
+
+func (s *DiscordService) channel(ctx context.Context, c *models.Channel, position int) *DiscordChannel {
+	return &DiscordChannel{
+		ID:                   s.snowflake(ctx, c.ULID),
+		Type:                 discordGuildText,
+		GuildID:              s.snowflake(ctx, c.ServerID),
+		Name:                 c.Name,
+		Position:             position,
+		PermissionOverwrites: []struct{}{},
+	}
+}
+
+func (s *DiscordService) member(ctx context.Context, u *models.User, m *models.UserServer) *DiscordMember {
+	member := &DiscordMember{
+		User:     s.user(ctx, u),
+		Roles:    s.memberRoles(ctx, m.ServerID, m.Role),
+		JoinedAt: discordTime(m.JoinedAt),
+	}
+	if m.TimeoutUntil != nil && m.TimeoutUntil.After(time.Now()) {
+		until := discordTime(*m.TimeoutUntil)
+		member.CommunicationDisabledUntil = &until
+	}
+	return member
+}
+
+// message converts a message; authors are looked up through users, which
+// caches them across a page.
+func (s *DiscordService) message(ctx context.Context, m *models.Message, serverID string, users map[string]*DiscordUser) *DiscordMessage {
+	msg := &DiscordMessage{
+		ID:           s.snowflake(ctx, m.ULID),
+		ChannelID:    s.snowflake(ctx, m.ChannelID),
+		GuildID:      s.snowflake(ctx, serverID),
+		Content:      m.Content,
+		Timestamp:    discordTime(m.CreatedAt),
+		Mentions:     []DiscordUser{},
+		MentionRoles: []string{},
+		Attachments:  []DiscordAttachment{},
+		Embeds:       []struct{}{},
+	}
+	if m.UpdatedAt.After(m.CreatedAt) {
+		edited := discordTime(m.UpdatedAt)
+		msg.EditedTimestamp = &edited
+	}
+
+	if m.UserID == "" {
+		// Webhook and email messages are authored by their source, as
+		// webhook messages are on Discord.
+		msg.WebhookID = s.snowflake(ctx, m.WebhookID)
+		msg.Author = &DiscordUser{ID: msg.WebhookID, Username: m.DisplayName, Discriminator: "0000", Bot: true}
+	} else if author, ok := users[m.UserID]; ok {
+		msg.Author = author
+	} else {
+		u, err := s.userRepo.GetUserByID(ctx, m.UserID)
+		if err != nil || u == nil {
+			u = &models.User{ULID: m.UserID, Username: "unknown-user"}
+		}
+		msg.Author = s.user(ctx, u)
+		users[m.UserID] = msg.Author
+	}
+
+	for _, a := range m.Attachments {
+		url := s.publicURL + "/api/attachments/" + a.ULID
+		msg.Attachments = append(msg.Attachments, DiscordAttachment{
+			ID:          s.snowflake(ctx, a.ULID),
+			Filename:    a.Filename,
+			ContentType: a.ContentType,
+			Size:        a.Size,
+			URL:         url,
+			ProxyURL:    url,
+		})
+	}
+	return msg
+}
+
+func (s *DiscordService) CurrentUser(ctx context.Context, currentUserID string) (*DiscordUser, error) {
+	u, err := s.userRepo.GetUserByID(ctx, currentUserID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, ErrUnknownUser
+	}
+	return s.user(ctx, u), nil
+}
+
+func (s *DiscordService) GetUser(ctx context.Context, userID string) (*DiscordUser, error) {
+	id, err := s.resolve(ctx, userID, ErrUnknownUser)
+	if err != nil {
+		return nil, err
+	}
+	u, err := s.userRepo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, ErrUnknownUser
+	}
+	return s.user(ctx, u), nil
+}
+
+func (s *DiscordService) GetGuild(ctx context.Context, currentUserID, guildID string) (*DiscordGuild, error) {
+	serverID, err := s.resolve(ctx, guildID, ErrUnknownGuild)
+	if err != nil {
+		return nil, err
+	}
+	server, err := s.servers.GetServer(ctx, currentUserID, serverID)
+	if err != nil {
+		return nil, err
+	}
+	if server == nil {
+		return nil, ErrUnknownGuild
+	}
+
+	guild := &DiscordGuild{
+		ID:              s.snowflake(ctx, server.ULID),
+		Name:            server.Name,
+		OwnerID:         s.snowflake(ctx, server.OwnerID),
+		Roles:           s.roles(ctx, server.ULID),
+		Emojis:          []struct{}{},
+		Features:        []string{},
+		PreferredLocale: "en-US",
+		Stickers:        []struct{}{},
+	}
+	if server.RequireMFA {
+		guild.MFALevel = 1
+	}
+	return guild, nil
+}
+
+func (s *DiscordService) roles(ctx context.Context, serverID string) []DiscordRole {
+	roles := make([]DiscordRole, 0, len(discordRoles))
+	for i, r := range discordRoles {
+		roles = append(roles, DiscordRole{
+			ID:          s.roleID(ctx, serverID, r.role),
+			Name:        r.name,
+			Position:    i,
+			Permissions: strconv.FormatUint(r.permissions, 10),
+		})
+	}
+	return roles
+}
+
+func (s *DiscordService) GetRoles(ctx context.Context, currentUserID, guildID string) ([]DiscordRole, error) {
+	serverID, err := s.resolve(ctx, guildID, ErrUnknownGuild)
+	if err != nil {
+		return nil, err
+	}
+	isMember, err := s.servers.IsUserMember(ctx, currentUserID, serverID)
+	if err != nil {
+		return nil, err
+	}
+	if !isMember {
+		return nil, ErrNotMember
+	}
+	return s.roles(ctx, serverID), nil
+}
+
+func (s *DiscordService) GetGuildChannels(ctx context.Context, currentUserID, guildID string) ([]*DiscordChannel, error) {
+	serverID, err := s.resolve(ctx, guildID, ErrUnknownGuild)
+	if err != nil {
+		return nil, err
+	}
+	channels, err := s.channels.ListChannels(ctx, currentUserID, serverID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*DiscordChannel, 0, len(channels))
+	for i, c := range channels {
+		result = append(result, s.channel(ctx, c, i))
+	}
+	return result, nil
+}
+
+func (s *DiscordService) GetChannel(ctx context.Context, currentUserID, channelID string) (*DiscordChannel, error) {
+	id, err := s.resolve(ctx, channelID, ErrUnknownChannel)
+	if err != nil {
+		return nil, err
+	}
+	channel, _, err := s.messages.channelForMember(ctx, currentUserID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.channel(ctx, channel, 0), nil
+}
+
+func (s *DiscordService) GetMessages(ctx context.Context, currentUserID, channelID, before string, limit int) ([]*DiscordMessage, error) {
+	id, err := s.resolve(ctx, channelID, ErrUnknownChannel)
+	if err != nil {
+		return nil, err
+	}
+	channel, _, err := s.messages.channelForMember(ctx, currentUserID, id)
+	if err != nil {
+		return nil, err
+	}
+	if before != "" {
+		if before, err = s.resolve(ctx, before, ErrUnknownMessage); err != nil {
+			return nil, err
+		}
+	}
+
+	messages, err := s.messages.GetMessages(ctx, currentUserID, id, before, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	users := map[string]*DiscordUser{}
+	result := make([]*DiscordMessage, 0, len(messages))
+	for _, m := range messages {
+		result = append(result, s.message(ctx, m, channel.ServerID, users))
+	}
+	return result, nil
+}
+
+// messageInChannel resolves a message and checks it is in the channel the
+// request names, as Discord's routes nest messages under their channel.
+func (s *DiscordService) messageInChannel(ctx context.Context, currentUserID, channelID, messageID string) (*models.Message, *models.Channel, error) {
+	cID, err := s.resolve(ctx, channelID, ErrUnknownChannel)
+	if err != nil {
+		return nil, nil, err
+	}
+	mID, err := s.resolve(ctx, messageID, ErrUnknownMessage)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	message, channel, _, err := s.messages.GetMessageForMember(ctx, currentUserID, mID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if channel.ULID != cID {
+		return nil, nil, ErrUnknownMessage
+	}
+	return message, channel, nil
+}
+
+func (s *DiscordService) GetMessage(ctx context.Context, currentUserID, channelID, messageID string) (*DiscordMessage, error) {
+	message, channel, err := s.messageInChannel(ctx, currentUserID, channelID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.messages.withAttachments(ctx, []*models.Message{message}); err != nil {
+		return nil, err
+	}
+	return s.message(ctx, message, channel.ServerID, map[string]*DiscordUser{}), nil
+}
+
+func (s *DiscordService) CreateMessage(ctx context.Context, currentUserID, channelID, content string) (*DiscordMessage, error) {
+	id, err := s.resolve(ctx, channelID, ErrUnknownChannel)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(content) == "" {
+		return nil, ErrEmptyMessage
+	}
+
+	message, err := s.messages.SendMessage(ctx, currentUserID, id, content, nil)
+	if err != nil {
+		return nil, err
+	}
+	channel, _, err := s.messages.channelForMember(ctx, currentUserID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.message(ctx, message, channel.ServerID, map[string]*DiscordUser{}), nil
+}
+
+func (s *DiscordService) EditMessage(ctx context.Context, currentUserID, channelID, messageID, content string) (*DiscordMessage, error) {
+	message, channel, err := s.messageInChannel(ctx, currentUserID, channelID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	edited, err := s.messages.EditMessage(ctx, currentUserID, message.ULID, content, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.message(ctx, edited, channel.ServerID, map[string]*DiscordUser{}), nil
+}
+
+// guildMember resolves a guild and one of its members for a caller who is a
+// member too.
+func (s *DiscordService) guildMember(ctx context.Context, currentUserID, guildID, userID string) (string, *models.User, *models.UserServer, error) {
+	serverID, err := s.resolve(ctx, guildID, ErrUnknownGuild)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	isMember, err := s.servers.IsUserMember(ctx, currentUserID, serverID)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if !isMember {
+		return "", nil, nil, ErrNotMember
+	}
+
+	targetID, err := s.resolve(ctx, userID, ErrUnknownUser)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	user, err := s.userRepo.GetUserByID(ctx, targetID)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if user == nil {
+		return "", nil, nil, ErrUnknownUser
+	}
+
+	membership, err := s.serverRepo.GetUserMembership(ctx, targetID, serverID)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return serverID, user, membership, nil
+}
+
+func (s *DiscordService) GetMember(ctx context.Context, currentUserID, guildID, userID string) (*DiscordMember, error) {
+	_, user, membership, err := s.guildMember(ctx, currentUserID, guildID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil {
+		return nil, ErrUnknownMember
+	}
+	return s.member(ctx, user, membership), nil
+}
+
+// ListMembers pages through a guild's members ordered by ID, starting after
+// the given user ID.
+func (s *DiscordService) ListMembers(ctx context.Context, currentUserID, guildID, after string, limit int) ([]*DiscordMember, error) {
+	serverID, err := s.resolve(ctx, guildID, ErrUnknownGuild)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = 1
+	}
+	if limit > maxDiscordMemberPage {
+		limit = maxDiscordMemberPage
+	}
+	var afterID uint64
+	if after != "" {
+		if afterID, err = strconv.ParseUint(after, 10, 64); err != nil {
+			return nil, apperr.Invalid("after must be a snowflake")
+		}
+	}
+
+	users, err := s.servers.ListMembers(ctx, currentUserID, serverID)
+	if err != nil {
+		return nil, err
+	}
+
+	type entry struct {
+		id   uint64
+		user *models.User
+	}
+	var entries []entry
+	for _, u := range users {
+		id, err := strconv.ParseUint(s.snowflake(ctx, u.ULID), 10, 64)
+		if err != nil || id <= afterID {
+			continue
+		}
+		entries = append(entries, entry{id, u})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].id < entries[j].id })
+
+	members := []*DiscordMember{}
+	for _, e := range entries {
+		if len(members) == limit {
+			break
+		}
+		membership, err := s.serverRepo.GetUserMembership(ctx, e.user.ULID, serverID)
+		if err != nil {
+			return nil, err
+		}
+		if membership != nil {
+			members = append(members, s.member(ctx, e.user, membership))
+		}
+	}
+	return members, nil
+}
+
+// AddMember adds a user to a guild with the given roles. It reports false,
+// and changes nothing, if the user is a member already.
+func (s *DiscordService) AddMember(ctx context.Context, currentUserID, guildID, userID string, roleIDs []string) (*DiscordMember, bool, error) {
+	serverID, user, membership, err := s.guildMember(ctx, currentUserID, guildID, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	if membership != nil {
+		return s.member(ctx, user, membership), false, nil
+	}
+
+	role, err := s.roleFor(ctx, serverID, roleIDs)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := s.servers.AddMember(ctx, currentUserID, serverID, user.ULID, role); err != nil {
+		return nil, false, err
+	}
+
+	membership, err = s.serverRepo.GetUserMembership(ctx, user.ULID, serverID)
+	if err != nil {
+		return nil, false, err
+	}
+	if membership == nil {
+		return nil, false, ErrUnknownMember
+	}
+	return s.member(ctx, user, membership), true, nil
+}
+
+func (s *DiscordService) RemoveMember(ctx context.Context, currentUserID, guildID, userID string) error {
+	serverID, user, membership, err := s.guildMember(ctx, currentUserID, guildID, userID)
+	if err != nil {
+		return err
+	}
+	if membership == nil {
+		return ErrUnknownMember
+	}
+	return s.servers.RemoveMember(ctx, currentUserID, serverID, user.ULID)
+}
+
+// setRole gives a member the rio role a new set of Discord roles amounts
+// to. The owner keeps their role as long as no role is asked for.
+func (s *DiscordService) setRole(ctx context.Context, currentUserID, serverID string, membership *models.UserServer, roleIDs []string) error {
+	role, err := s.roleFor(ctx, serverID, roleIDs)
+	if err != nil {
+		return err
+	}
+	if membership.Role == "owner" && len(roleIDs) == 0 {
+		return nil
+	}
+	if role == membership.Role {
+		return nil
+	}
+	return s.servers.ChangeMemberRole(ctx, currentUserID, serverID, membership.UserID, role)
+}
+
+// ModifyMember changes a member's roles and timeout. A nil roles keeps the
+// roles; a nil timeout keeps the timeout, and a zero one lifts it.
+func (s *DiscordService) ModifyMember(ctx context.Context, currentUserID, guildID, userID string, roleIDs []string, timeoutUntil *time.Time) (*DiscordMember, error) {
+	serverID, user, membership, err := s.guildMember(ctx, currentUserID, guildID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil {
+		return nil, ErrUnknownMember
+	}
+
+	if roleIDs != nil {
+		if err := s.setRole(ctx, currentUserID, serverID, membership, roleIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	if timeoutUntil != nil {
+		var duration time.Duration
+		if !timeoutUntil.IsZero() {
+			if duration = time.Until(*timeoutUntil); duration <= 0 {
+				return nil, apperr.Invalid("communication_disabled_until must be in the future")
+			}
+		}
+		if err := s.servers.TimeoutMember(ctx, currentUserID, serverID, user.ULID, duration); err != nil {
+			return nil, err
+		}
+	}
+
+	membership, err = s.serverRepo.GetUserMembership(ctx, user.ULID, serverID)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil {
+		return nil, ErrUnknownMember
+	}
+	return s.member(ctx, user, membership), nil
+}
+
+// SetMemberRole adds or removes one role of a member.
+func (s *DiscordService) SetMemberRole(ctx context.Context, currentUserID, guildID, userID, roleID string, add bool) error {
+	serverID, _, membership, err := s.guildMember(ctx, currentUserID, guildID, userID)
+	if err != nil {
+		return err
+	}
+	if membership == nil {
+		return ErrUnknownMember
+	}
+	if _, err := s.roleFor(ctx, serverID, []string{roleID}); err != nil {
+		return err
+	}
+
+	roles := s.memberRoles(ctx, serverID, membership.Role)
+	if add && !slices.Contains(roles, roleID) {
+		roles = append(roles, roleID)
+	}
+	if !add {
+		roles = slices.DeleteFunc(roles, func(id string) bool { return id == roleID })
+	}
+	return s.setRole(ctx, currentUserID, serverID, membership, roles)
+}
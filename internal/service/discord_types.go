@@ -0,0 +1,122 @@
+package service
+
+// Object shapes of Discord's HTTP API (v10) that the compatibility API
+// returns. Only the fields rio has a meaning for are filled in; the rest
+// carry Discord's defaults so client libraries can parse them.
+
+type DiscordUser struct {
+	ID            string  `json:"id"`
+	Username      string  `json:"username"`
+	Discriminator string  `json:"discriminator"`
+	GlobalName    *string `json:"global_name"`
+	Avatar        *string `json:"avatar"`
+	Bot           bool    `json:"bot,omitempty"`
+	System        bool    `json:"system,omitempty"`
+	MFAEnabled    bool    `json:"mfa_enabled,omitempty"`
+	Flags         int     `json:"flags"`
+	PublicFlags   int     `json:"public_flags"`
+}
+
+type DiscordRole struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Color       int     `json:"color"`
+	Hoist       bool    `json:"hoist"`
+	Icon        *string `json:"icon"`
+	Position    int     `json:"position"`
+	Permissions string  `json:"permissions"`
+	Managed     bool    `json:"managed"`
+	Mentionable bool    `json:"mentionable"`
+	Flags       int     `json:"flags"`
+}
+
+type DiscordGuild struct {
+	ID                          string        `json:"id"`
+	Name                        string        `json:"name"`
+	Icon                        *string       `json:"icon"`
+	Splash                      *string       `json:"splash"`
+	DiscoverySplash             *string       `json:"discovery_splash"`
+	OwnerID                     string        `json:"owner_id"`
+	AFKChannelID                *string       `json:"afk_channel_id"`
+	AFKTimeout                  int           `json:"afk_timeout"`
+	VerificationLevel           int           `json:"verification_level"`
+	DefaultMessageNotifications int           `json:"default_message_notifications"`
+	ExplicitContentFilter       int           `json:"explicit_content_filter"`
+	Roles                       []DiscordRole `json:"roles"`
+	Emojis                      []struct{}    `json:"emojis"`
+	Features                    []string      `json:"features"`
+	MFALevel                    int           `json:"mfa_level"`
+	SystemChannelID             *string       `json:"system_channel_id"`
+	SystemChannelFlags          int           `json:"system_channel_flags"`
+	RulesChannelID              *string       `json:"rules_channel_id"`
+	VanityURLCode               *string       `json:"vanity_url_code"`
+	Description                 *string       `json:"description"`
+	Banner                      *string       `json:"banner"`
+	PremiumTier                 int           `json:"premium_tier"`
+	PreferredLocale             string        `json:"preferred_locale"`
+	PublicUpdatesChannelID      *string       `json:"public_updates_channel_id"`
+	NSFWLevel                   int           `json:"nsfw_level"`
+	Stickers                    []struct{}    `json:"stickers"`
+	PremiumProgressBarEnabled   bool          `json:"premium_progress_bar_enabled"`
+}
+
+// Discord channel types rio channels map to.
+const discordGuildText = 0
+
+type DiscordChannel struct {
+	ID                   string     `json:"id"`
+	Type                 int        `json:"type"`
+	GuildID              string     `json:"guild_id"`
+	Name                 string     `json:"name"`
+	Position             int        `json:"position"`
+	PermissionOverwrites []struct{} `json:"permission_overwrites"`
+	Topic                *string    `json:"topic"`
+	NSFW                 bool       `json:"nsfw"`
+	LastMessageID        *string    `json:"last_message_id"`
+	RateLimitPerUser     int        `json:"rate_limit_per_user"`
+	ParentID             *string    `json:"parent_id"`
+	Flags                int        `json:"flags"`
+}
+
+type DiscordMember struct {
+	User                       *DiscordUser `json:"user"`
+	Nick                       *string      `json:"nick"`
+	Avatar                     *string      `json:"avatar"`
+	Roles                      []string     `json:"roles"`
+	JoinedAt                   string       `json:"joined_at"`
+	PremiumSince               *string      `json:"premium_since"`
+	Deaf                       bool         `json:"deaf"`
+	Mute                       bool         `json:"mute"`
+	Flags                      int          `json:"flags"`
+	Pending                    bool         `json:"pending"`
+	CommunicationDisabledUntil *string      `json:"communication_disabled_until"`
+}
+
+type DiscordAttachment struct {
+	ID          string `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	URL         string `json:"url"`
+	ProxyURL    string `json:"proxy_url"`
+}
+
+type DiscordMessage struct {
+	ID              string              `json:"id"`
+	Type            int                 `json:"type"`
+	ChannelID       string              `json:"channel_id"`
+	GuildID         string              `json:"guild_id,omitempty"`
+	Author          *DiscordUser        `json:"author"`
+	Content         string              `json:"content"`
+	Timestamp       string              `json:"timestamp"`
+	EditedTimestamp *string             `json:"edited_timestamp"`
+	TTS             bool                `json:"tts"`
+	MentionEveryone bool                `json:"mention_everyone"`
+	Mentions        []DiscordUser       `json:"mentions"`
+	MentionRoles    []string            `json:"mention_roles"`
+	Attachments     []DiscordAttachment `json:"attachments"`
+	Embeds          []struct{}          `json:"embeds"`
+	Pinned          bool                `json:"pinned"`
+	WebhookID       string              `json:"webhook_id,omitempty"`
+	Flags           int                 `json:"flags"`
+}
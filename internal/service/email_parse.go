@@ -0,0 +1,256 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+
+	"golang.org/x/net/html"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+const (
+	maxEmailAttachments = 10
+	maxMIMEDepth        = 10
+)
+
+// parsedEmail is the part of a received email that becomes a message.
+type parsedEmail struct {
+	From        *mail.Address
+	Subject     string
+	Text        string
+	Attachments []*models.Attachment
+}
+
+// emailParts collects the parts of a MIME tree.
+type emailParts struct {
+	plain       string
+	html        string
+	attachments []*models.Attachment
+}
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+// parseEmail extracts the sender, subject, body and attachments of an
+// RFC 5322 message. The first text/plain part is the body; without one the
+// first text/html part is used with its markup stripped. Parts marked as
+// attachments, or that are not text, become attachments.
+func parseEmail(raw []byte) (*parsedEmail, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, apperr.Invalid("invalid email: %w", err)
+	}
+
+	header := textproto.MIMEHeader(msg.Header)
+	from, err := (&mail.AddressParser{WordDecoder: wordDecoder}).Parse(header.Get("From"))
+	if err != nil {
+		return nil, apperr.Invalid("invalid email: bad From header: %w", err)
+	}
+
+	subject, err := wordDecoder.DecodeHeader(header.Get("Subject"))
+	if err != nil {
+		subject = header.Get("Subject")
+	}
+
+	var parts emailParts
+	if err := parts.walk(header, msg.Body, 0); err != nil {
+		return nil, apperr.Invalid("invalid email: %w", err)
+	}
+
+	text := parts.plain
+	if strings.TrimSpace(text) == "" {
+		text = stripHTML(parts.html)
+	}
+
+	return &parsedEmail{
+		From:        from,
+		Subject:     strings.TrimSpace(subject),
+		Text:        strings.TrimSpace(text),
+		Attachments: parts.attachments,
+	}, nil
+}
+
+func (p *emailParts) walk(header textproto.MIMEHeader, body io.Reader, depth int) error {
+	if depth > maxMIMEDepth {
+		return apperr.Invalid("MIME parts are nested too deeply")
+	}
+
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := p.walk(part.Header, part, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := io.ReadAll(transferDecoder(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return err
+	}
+
+	disposition, dispParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := dispParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+	isText := mediaType == "text/plain" || mediaType == "text/html"
+
+	switch {
+	case isText && disposition != "attachment" && filename == "":
+		text := decodeCharset(data, params["charset"])
+		if mediaType == "text/plain" && p.plain == "" {
+			p.plain = text
+		} else if mediaType == "text/html" && p.html == "" {
+			p.html = text
+		}
+	case len(data) > 0:
+		if len(p.attachments) >= maxEmailAttachments {
+			return apperr.Invalid("an email can have at most %d attachments", maxEmailAttachments)
+		}
+		p.attachments = append(p.attachments, &models.Attachment{
+			Filename:    attachmentFilename(filename, mediaType, len(p.attachments)+1),
+			ContentType: mediaType,
+			Data:        data,
+		})
+	}
+	return nil
+}
+
+func transferDecoder(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		// Encoded lines end in CRLF, which the base64 decoder skips.
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	default:
+		return r
+	}
+}
+
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, err
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
+// decodeCharset converts text to UTF-8. Unknown charsets are treated as
+// UTF-8, with invalid sequences replaced.
+func decodeCharset(data []byte, charset string) string {
+	if charset != "" && !strings.EqualFold(charset, "utf-8") {
+		if enc, err := htmlindex.Get(charset); err == nil {
+			if decoded, err := enc.NewDecoder().Bytes(data); err == nil {
+				data = decoded
+			}
+		}
+	}
+	return strings.ToValidUTF8(strings.ReplaceAll(string(data), "\r\n", "\n"), "�")
+}
+
+// attachmentFilename returns a safe name for an attachment, making one up
+// from its type when the email gave none.
+func attachmentFilename(name, mediaType string, n int) string {
+	if decoded, err := wordDecoder.DecodeHeader(name); err == nil {
+		name = decoded
+	}
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '"' || r == '\\' {
+			return -1
+		}
+		return r
+	}, path.Base(strings.ReplaceAll(name, "\\", "/")))
+
+	if name == "" || name == "." || name == "/" {
+		ext := ""
+		if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+			ext = exts[0]
+		}
+		name = fmt.Sprintf("attachment-%d%s", n, ext)
+	}
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// stripHTML returns the text of an HTML document, breaking lines at block
+// elements and dropping scripts and styles.
+func stripHTML(doc string) string {
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(doc))
+	skip := 0
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return collapseBlankLines(b.String())
+		case html.TextToken:
+			if skip == 0 {
+				b.WriteString(strings.ReplaceAll(string(z.Text()), "\n", " "))
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "script", "style", "head":
+				skip++
+			case "br", "p", "div", "tr", "li", "h1", "h2", "h3", "h4", "h5", "h6", "table", "blockquote":
+				b.WriteByte('\n')
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "script", "style", "head":
+				if skip > 0 {
+					skip--
+				}
+			case "p", "div", "tr", "li", "h1", "h2", "h3", "h4", "h5", "h6", "table", "blockquote":
+				b.WriteByte('\n')
+			}
+		}
+	}
+}
+
+func collapseBlankLines(s string) string {
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			if !blank && len(lines) > 0 {
+				lines = append(lines, "")
+			}
+			blank = true
+			continue
+		}
+		lines = append(lines, line)
+		blank = false
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
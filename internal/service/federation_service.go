@@ -0,0 +1,792 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/events"
+	"rio/internal/models"
+	serverRepo "rio/internal/repository/server"
+	unitOfWork "rio/internal/repository/unitofwork"
+	userRepo "rio/internal/repository/user"
+	"rio/utils/httpsig"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// Federation lets users of one rio instance join servers hosted on another.
+// Instances are named by the host[:port] they are reachable at and sign
+// every request to each other with an Ed25519 key published at
+// FederationDocumentPath.
+//
+// The hosting instance keeps a shadow user for every remote member, named
+// user@instance, and remote members act through it: channels, messages and
+// permissions stay on the host. The member's own instance keeps a mirror
+// of the server (a Server row with Instance set) holding its local
+// memberships, proxies reads and writes to the host, and receives the
+// server's events, which it publishes to its local members.
+const (
+	FederationDocumentPath = "/.well-known/rio-federation"
+	FederationAPIPrefix    = "/api/federation/v1"
+
+	// MaxFederationBody bounds the body of requests between instances.
+	MaxFederationBody = 1 << 20
+
+	peerKeyTTL    = 10 * time.Minute
+	relayAttempts = 3
+)
+
+// FederationDocument is served at FederationDocumentPath.
+type FederationDocument struct {
+	Name      string `json:"name"`
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"public_key"`
+}
+
+// RelayedEvent is an event of a hosted server, sent to every instance with
+// members in it. User is the instance-qualified handle of the member that
+// member events are about.
+type RelayedEvent struct {
+	Type     string          `json:"type"`
+	ServerID string          `json:"server_id"`
+	User     string          `json:"user,omitempty"`
+	Data     json.RawMessage `json:"data"`
+}
+
+type remoteJoinRequest struct {
+	Username string `json:"username"`
+}
+
+type remoteMessageRequest struct {
+	Content string `json:"content"`
+}
+
+type peerKey struct {
+	key       ed25519.PublicKey
+	fetchedAt time.Time
+}
+
+type FederationService struct {
+	name          string
+	key           ed25519.PrivateKey
+	allowed       []string
+	allowInsecure bool
+	client        *http.Client
+
+	userRepo   userRepo.UserRepository
+	serverRepo serverRepo.ServerRepository
+	uow        unitOfWork.UnitOfWork
+	servers    *ServerService
+	channels   *ChannelService
+	messages   *MessageService
+	events     *events.Bus
+
+	mu       sync.Mutex
+	peerKeys map[string]peerKey
+	nonces   map[string]time.Time
+}
+
+// NewFederationService federates as the instance name, signing with key.
+// When name is empty federation is disabled. allowedInstances restricts
+// which instances may federate with this one; empty allows any.
+// allowInsecure talks plain HTTP to peers and lets them resolve to private
+// addresses, for running instances side by side in development.
+func NewFederationService(
+	name string,
+	key ed25519.PrivateKey,
+	allowedInstances []string,
+	allowInsecure bool,
+	uRepo userRepo.UserRepository,
+	sRepo serverRepo.ServerRepository,
+	uow unitOfWork.UnitOfWork,
+	servers *ServerService,
+	channels *ChannelService,
+	messages *MessageService,
+	bus *events.Bus,
+) *FederationService {
+	s := &FederationService{
+		name:          strings.ToLower(strings.TrimSpace(name)),
+		key:           key,
+		allowInsecure: allowInsecure,
+		client:        newOutboundClient(allowInsecure),
+		userRepo:      uRepo,
+		serverRepo:    sRepo,
+		uow:           uow,
+		servers:       servers,
+		channels:      channels,
+		messages:      messages,
+		events:        bus,
+		peerKeys:      map[string]peerKey{},
+		nonces:        map[string]time.Time{},
+	}
+	for _, instance := range allowedInstances {
+		if instance = strings.ToLower(strings.TrimSpace(instance)); instance != "" {
+			s.allowed = append(s.allowed, instance)
+		}
+	}
+	if s.name != "" {
+		bus.Subscribe(s.relay)
+	}
+	return s
+}
+
+// Name returns the instance name, or "" when federation is disabled.
+func (s *FederationService) Name() string {
+	return s.name
+}
+
+func (s *FederationService) enabled() error {
+	if s.name == "" {
+		return apperr.NotImplemented("federation is not enabled")
+	}
+	return nil
+}
+
+// Document returns this instance's discovery document.
+func (s *FederationService) Document() (*FederationDocument, error) {
+	if err := s.enabled(); err != nil {
+		return nil, err
+	}
+	return &FederationDocument{
+		Name:      s.name,
+		Algorithm: "Ed25519",
+		PublicKey: base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey)),
+	}, nil
+}
+
+// checkInstance normalizes the name of a peer and checks this instance may
+// federate with it.
+func (s *FederationService) checkInstance(instance string) (string, error) {
+	instance = strings.ToLower(strings.TrimSpace(instance))
+	u, err := url.Parse("//" + instance)
+	if err != nil || instance == "" || u.Host != instance || u.User != nil || u.Path != "" || u.RawQuery != "" {
+		return "", apperr.Invalid("invalid instance name: expected host or host:port")
+	}
+	if instance == s.name {
+		return "", apperr.Invalid("invalid instance name: that is this instance")
+	}
+	if len(s.allowed) > 0 && !slices.Contains(s.allowed, instance) {
+		return "", apperr.Forbidden("instance %s is not allowed to federate with this one", instance)
+	}
+	return instance, nil
+}
+
+func (s *FederationService) baseURL(instance string) string {
+	if s.allowInsecure {
+		return "http://" + instance
+	}
+	return "https://" + instance
+}
+
+// peerKey returns the public key of instance from its discovery document,
+// cached for peerKeyTTL.
+func (s *FederationService) peerKey(instance string) (ed25519.PublicKey, error) {
+	instance, err := s.checkInstance(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	cached, ok := s.peerKeys[instance]
+	s.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < peerKeyTTL {
+		return cached.key, nil
+	}
+
+	resp, err := s.client.Get(s.baseURL(instance) + FederationDocumentPath)
+	if err != nil {
+		return nil, apperr.Unavailable("cannot reach %s: %w", instance, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, apperr.Unavailable("cannot reach %s: discovery returned status %d", instance, resp.StatusCode)
+	}
+
+	var doc FederationDocument
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&doc); err != nil {
+		return nil, apperr.Unavailable("invalid discovery document from %s: %w", instance, err)
+	}
+	if doc.Name != instance || doc.Algorithm != "Ed25519" {
+		return nil, apperr.Unavailable("invalid discovery document from %s", instance)
+	}
+	raw, err := base64.StdEncoding.DecodeString(doc.PublicKey)
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, apperr.Unavailable("invalid public key from %s", instance)
+	}
+
+	key := ed25519.PublicKey(raw)
+	s.mu.Lock()
+	s.peerKeys[instance] = peerKey{key: key, fetchedAt: time.Now()}
+	s.mu.Unlock()
+	return key, nil
+}
+
+// useNonce records a request nonce, reporting false if it was seen before.
+// Nonces are kept for twice the allowed clock skew, after which the
+// request's date alone rejects it.
+func (s *FederationService) useNonce(origin, nonce string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if len(s.nonces) >= 1024 {
+		for k, expires := range s.nonces {
+			if now.After(expires) {
+				delete(s.nonces, k)
+			}
+		}
+	}
+
+	k := origin + " " + nonce
+	if expires, ok := s.nonces[k]; ok && now.Before(expires) {
+		return false
+	}
+	s.nonces[k] = now.Add(2 * httpsig.MaxSkew)
+	return true
+}
+
+// Authenticate verifies a signed request from another instance, returning
+// the instance and the user there the request acts for.
+func (s *FederationService) Authenticate(req *http.Request, body []byte) (string, string, error) {
+	if err := s.enabled(); err != nil {
+		return "", "", err
+	}
+
+	signed, err := httpsig.Verify(req, body, s.name, s.peerKey)
+	if err != nil {
+		return "", "", err
+	}
+	if !s.useNonce(signed.Origin, signed.Nonce) {
+		return "", "", apperr.Unauthorized("request has already been received")
+	}
+	return signed.Origin, signed.UserID, nil
+}
+
+// call sends a signed request to instance's federation API, acting for the
+// local user userID, and decodes the response into out. Errors reported by
+// the peer are returned with their message intact.
+func (s *FederationService) call(method, instance, path, userID string, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, s.baseURL(instance)+FederationAPIPrefix+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	httpsig.Sign(req, body, s.name, instance, userID, s.key)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return apperr.Unavailable("cannot reach %s: %w", instance, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxFederationBody))
+	if err != nil {
+		return apperr.Unavailable("cannot reach %s: %w", instance, err)
+	}
+
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Error != "" {
+			return apperr.FromStatus(resp.StatusCode, "%s: %s", instance, e.Error)
+		}
+		return apperr.Unavailable("cannot reach %s: status %d", instance, resp.StatusCode)
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return apperr.Unavailable("invalid response from %s: %w", instance, err)
+	}
+	return nil
+}
+
+// handle returns a user's instance-qualified name.
+func (s *FederationService) handle(user *models.User) string {
+	if user.Instance != "" {
+		return user.Username
+	}
+	return user.Username + "@" + s.name
+}
+
+// labelAuthors sets the display name of user-authored messages to their
+// author's handle, so another instance can show who wrote them.
+func (s *FederationService) labelAuthors(ctx context.Context, messages ...*models.Message) {
+	handles := map[string]string{}
+	for _, m := range messages {
+		if m.UserID == "" || m.DisplayName != "" {
+			continue
+		}
+		h, ok := handles[m.UserID]
+		if !ok {
+			if user, err := s.userRepo.GetUserByID(ctx, m.UserID); err == nil && user != nil {
+				h = s.handle(user)
+			}
+			handles[m.UserID] = h
+		}
+		m.DisplayName = h
+	}
+}
+
+// remoteAuthors replaces the authors of messages read from origin with
+// identities scoped to it. Their user IDs are origin's and could name a
+// user of this instance, so they are dropped, and the messages are shown
+// under the handle origin labelled them with, the way webhook messages are
+// shown under the webhook's name. A handle naming no instance is taken to
+// be one of origin's users.
+func remoteAuthors(origin string, messages ...*models.Message) {
+	for _, m := range messages {
+		handle := html.EscapeString(html.UnescapeString(strings.TrimSpace(m.DisplayName)))
+		if handle == "" {
+			handle = "unknown"
+		}
+		if !strings.Contains(handle, "@") {
+			handle += "@" + origin
+		}
+		m.UserID = ""
+		m.WebhookID = ""
+		m.DisplayName = handle
+	}
+}
+
+// remoteServerName checks the name of a server hosted on another instance.
+// The host stores names escaped, so the name is unescaped before it is
+// validated like a local one, which escapes it again.
+func remoteServerName(name string) (string, error) {
+	return validateServerName(html.UnescapeString(name))
+}
+
+// Hosting: requests from other instances on behalf of their users.
+
+// remoteUser returns the shadow user of remoteID at origin, creating it
+// named username@origin if it does not exist yet.
+func (s *FederationService) remoteUser(ctx context.Context, origin, remoteID, username string) (*models.User, error) {
+	user, err := s.userRepo.GetRemoteUser(ctx, origin, remoteID)
+	if err != nil || user != nil {
+		return user, err
+	}
+
+	if err := validateUsername(&username); err != nil {
+		return nil, err
+	}
+	user = &models.User{
+		ULID:     ulid.Make().String(),
+		Username: username + "@" + origin,
+		Instance: origin,
+		RemoteID: remoteID,
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// shadowUser returns the existing shadow user of remoteID at origin.
+func (s *FederationService) shadowUser(ctx context.Context, origin, remoteID string) (*models.User, error) {
+	if remoteID == "" {
+		return nil, apperr.Invalid("request does not act for a user")
+	}
+	user, err := s.userRepo.GetRemoteUser(ctx, origin, remoteID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrNotMember
+	}
+	return user, nil
+}
+
+// ServeJoin joins a remote user to a server hosted here through an invite.
+func (s *FederationService) ServeJoin(ctx context.Context, origin, remoteID, username, code string) (*models.Server, error) {
+	if remoteID == "" {
+		return nil, apperr.Invalid("request does not act for a user")
+	}
+	user, err := s.remoteUser(ctx, origin, remoteID, username)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.servers.JoinInvite(ctx, user.ULID, code)
+}
+
+func (s *FederationService) ServeLeave(ctx context.Context, origin, remoteID, serverID string) error {
+	user, err := s.shadowUser(ctx, origin, remoteID)
+	if err != nil {
+		return err
+	}
+	return s.servers.RemoveMember(ctx, user.ULID, serverID, user.ULID)
+}
+
+func (s *FederationService) ServeChannels(ctx context.Context, origin, remoteID, serverID string) ([]*models.Channel, error) {
+	user, err := s.shadowUser(ctx, origin, remoteID)
+	if err != nil {
+		return nil, err
+	}
+	return s.channels.ListChannels(ctx, user.ULID, serverID)
+}
+
+func (s *FederationService) ServeMessages(ctx context.Context, origin, remoteID, channelID, before string, limit int) ([]*models.Message, error) {
+	user, err := s.shadowUser(ctx, origin, remoteID)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := s.messages.GetMessages(ctx, user.ULID, channelID, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	s.labelAuthors(ctx, messages...)
+	return messages, nil
+}
+
+func (s *FederationService) ServeSendMessage(ctx context.Context, origin, remoteID, channelID, content string) (*models.Message, error) {
+	user, err := s.shadowUser(ctx, origin, remoteID)
+	if err != nil {
+		return nil, err
+	}
+	message, err := s.messages.SendMessage(ctx, user.ULID, channelID, content, nil)
+	if err != nil {
+		return nil, err
+	}
+	// The message was published too, and is being relayed from another
+	// goroutine; label a copy.
+	labelled := *message
+	s.labelAuthors(ctx, &labelled)
+	return &labelled, nil
+}
+
+// relay forwards events of hosted servers to the instances of their remote
+// members. It runs on the publishing goroutine, so delivery is handed off.
+func (s *FederationService) relay(e events.Event) {
+	if e.ServerID == "" || !slices.Contains(events.Types, e.Type) {
+		return
+	}
+	go s.relayEvent(e)
+}
+
+func (s *FederationService) relayEvent(e events.Event) {
+	// The publishing request may be over by now; the relay is not bound to it.
+	ctx := context.Background()
+	server, err := s.serverRepo.GetServerByID(ctx, e.ServerID)
+	if err != nil || server == nil || server.Instance != "" {
+		return
+	}
+
+	instances, err := s.serverRepo.GetMemberInstances(ctx, e.ServerID)
+	if err != nil {
+		log.Printf("federation: cannot load member instances of %s: %v", e.ServerID, err)
+		return
+	}
+
+	relayed := RelayedEvent{Type: e.Type, ServerID: e.ServerID}
+	data := e.Data
+	switch d := e.Data.(type) {
+	case *models.Message:
+		copied := *d
+		s.labelAuthors(ctx, &copied)
+		data = &copied
+	case events.MemberData:
+		user, err := s.userRepo.GetUserByID(ctx, d.UserID)
+		if err == nil && user != nil {
+			relayed.User = s.handle(user)
+			// A member who left is no longer counted, but their
+			// instance still needs to drop its mirror membership.
+			if e.Type == events.MemberLeft && user.Instance != "" && !slices.Contains(instances, user.Instance) {
+				instances = append(instances, user.Instance)
+			}
+		}
+	}
+	if len(instances) == 0 {
+		return
+	}
+
+	if relayed.Data, err = json.Marshal(data); err != nil {
+		log.Printf("federation: cannot encode %s event: %v", e.Type, err)
+		return
+	}
+
+	for _, instance := range instances {
+		s.deliver(instance, relayed)
+	}
+}
+
+func (s *FederationService) deliver(instance string, e RelayedEvent) {
+	var err error
+	for attempt := 0; attempt < relayAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt*attempt) * time.Second)
+		}
+		if err = s.call(http.MethodPost, instance, "/events", "", e, nil); err == nil {
+			return
+		}
+	}
+	log.Printf("federation: cannot relay %s event to %s: %v", e.Type, instance, err)
+}
+
+// Membership: local users in servers hosted on other instances.
+
+// localUser resolves a handle of a user of this instance.
+func (s *FederationService) localUser(ctx context.Context, handle string) (*models.User, error) {
+	i := strings.LastIndex(handle, "@")
+	if i < 0 || handle[i+1:] != s.name {
+		return nil, nil
+	}
+	return s.userRepo.FindByUsername(ctx, handle[:i])
+}
+
+// ReceiveEvent publishes an event relayed by the instance hosting one of
+// the mirrored servers, so it reaches local members.
+func (s *FederationService) ReceiveEvent(ctx context.Context, origin string, e *RelayedEvent) error {
+	server, err := s.serverRepo.GetServerByID(ctx, e.ServerID)
+	if err != nil {
+		return err
+	}
+	if server == nil || server.Instance != origin {
+		return apperr.NotFound("server not found")
+	}
+
+	switch e.Type {
+	case events.MessageCreated, events.MessageUpdated:
+		var message models.Message
+		if err := json.Unmarshal(e.Data, &message); err != nil {
+			return apperr.Invalid("invalid event data: %w", err)
+		}
+		remoteAuthors(origin, &message)
+		s.events.Publish(e.Type, server.ULID, &message)
+
+	case events.ServerUpdated:
+		var data events.ServerData
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return apperr.Invalid("invalid event data: %w", err)
+		}
+		data.ID = server.ULID
+		if data.Name == "" {
+			data.Name = server.Name
+		} else if data.Name, err = remoteServerName(data.Name); err != nil {
+			return err
+		}
+		if data.Name != server.Name {
+			if err := s.serverRepo.UpdateServer(ctx, server.ULID, &models.Server{Name: data.Name}); err != nil {
+				return err
+			}
+		}
+		s.events.Publish(e.Type, server.ULID, data)
+
+	case events.MemberJoined, events.MemberLeft, events.MemberRoleChanged:
+		var data events.MemberData
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return apperr.Invalid("invalid event data: %w", err)
+		}
+		s.events.Publish(e.Type, server.ULID, data)
+
+		// Drop the mirror membership after publishing, so the member
+		// still hears they were removed.
+		if e.Type == events.MemberLeft {
+			user, err := s.localUser(ctx, e.User)
+			if err != nil {
+				return err
+			}
+			if user != nil {
+				if err := s.serverRepo.RemoveUserFromServer(ctx, user.ULID, server.ULID); err != nil && !errors.Is(err, apperr.ErrNotFound) {
+					return err
+				}
+			}
+		}
+
+	default:
+		return apperr.Invalid("unknown event type %q", e.Type)
+	}
+	return nil
+}
+
+// mirrorForMember returns the mirror of a remote server currentUserID is a
+// member of.
+func (s *FederationService) mirrorForMember(ctx context.Context, currentUserID, serverID string) (*models.Server, error) {
+	if err := s.enabled(); err != nil {
+		return nil, err
+	}
+
+	server, err := s.serverRepo.GetServerByID(ctx, serverID)
+	if err != nil {
+		return nil, err
+	}
+	if server == nil {
+		return nil, apperr.NotFound("server not found")
+	}
+	if server.Instance == "" {
+		return nil, apperr.Forbidden("server is hosted on this instance; use the regular endpoints")
+	}
+
+	membership, err := s.serverRepo.GetUserMembership(ctx, currentUserID, serverID)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil {
+		return nil, ErrNotMember
+	}
+	return server, nil
+}
+
+// JoinRemoteServer joins currentUserID to a server on another instance
+// with an invite code from there, and mirrors the server here.
+func (s *FederationService) JoinRemoteServer(ctx context.Context, currentUserID, instance, code string) (*models.Server, error) {
+	if err := s.enabled(); err != nil {
+		return nil, err
+	}
+	instance, err := s.checkInstance(instance)
+	if err != nil {
+		return nil, err
+	}
+	if code = strings.TrimSpace(code); code == "" {
+		return nil, apperr.Invalid("invite code is required")
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, currentUserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, apperr.NotFound("user not found")
+	}
+	if user.IsBot || user.Instance != "" {
+		return nil, apperr.Forbidden("only local users can join servers on other instances")
+	}
+
+	var remote models.Server
+	err = s.call(http.MethodPost, instance, "/invites/"+url.PathEscape(code)+"/join", currentUserID, remoteJoinRequest{Username: user.Username}, &remote)
+	if err != nil {
+		return nil, err
+	}
+	if remote.Name, err = remoteServerName(remote.Name); err != nil {
+		return nil, apperr.Unavailable("invalid response from %s: %w", instance, err)
+	}
+
+	var mirror *models.Server
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		mirror, err = s.serverRepo.GetServerByID(ctx, remote.ULID)
+		if err != nil {
+			return err
+		}
+		if mirror == nil {
+			mirror = &models.Server{ULID: remote.ULID, Name: remote.Name, Instance: instance}
+			if err := s.serverRepo.Create(ctx, mirror); err != nil {
+				return err
+			}
+		} else if mirror.Instance != instance {
+			return apperr.Conflict("server %s conflicts with a server on this instance", remote.ULID)
+		}
+
+		existing, err := s.serverRepo.GetUserMembership(ctx, currentUserID, mirror.ULID)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return s.serverRepo.AddUserToServer(ctx, currentUserID, mirror.ULID, "member")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mirror, nil
+}
+
+// LeaveRemoteServer leaves a server on another instance. The local
+// membership is dropped even if the host no longer counts the user as a
+// member.
+func (s *FederationService) LeaveRemoteServer(ctx context.Context, currentUserID, serverID string) error {
+	mirror, err := s.mirrorForMember(ctx, currentUserID, serverID)
+	if err != nil {
+		return err
+	}
+
+	err = s.call(http.MethodPost, mirror.Instance, "/servers/"+url.PathEscape(serverID)+"/leave", currentUserID, nil, nil)
+	if err != nil && !errors.Is(err, apperr.ErrForbidden) {
+		return err
+	}
+
+	// The host relays member.left, which may have dropped the membership
+	// already.
+	if err := s.serverRepo.RemoveUserFromServer(ctx, currentUserID, serverID); err != nil && !errors.Is(err, apperr.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+func (s *FederationService) ListRemoteChannels(ctx context.Context, currentUserID, serverID string) ([]*models.Channel, error) {
+	mirror, err := s.mirrorForMember(ctx, currentUserID, serverID)
+	if err != nil {
+		return nil, err
+	}
+
+	channels := []*models.Channel{}
+	err = s.call(http.MethodGet, mirror.Instance, "/servers/"+url.PathEscape(serverID)+"/channels", currentUserID, nil, &channels)
+	return channels, err
+}
+
+func (s *FederationService) GetRemoteMessages(ctx context.Context, currentUserID, serverID, channelID, before string, limit int) ([]*models.Message, error) {
+	mirror, err := s.mirrorForMember(ctx, currentUserID, serverID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	if before != "" {
+		query.Set("before", before)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	path := "/channels/" + url.PathEscape(channelID) + "/messages"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	messages := []*models.Message{}
+	if err := s.call(http.MethodGet, mirror.Instance, path, currentUserID, nil, &messages); err != nil {
+		return nil, err
+	}
+	remoteAuthors(mirror.Instance, messages...)
+	return messages, nil
+}
+
+func (s *FederationService) SendRemoteMessage(ctx context.Context, currentUserID, serverID, channelID, content string) (*models.Message, error) {
+	mirror, err := s.mirrorForMember(ctx, currentUserID, serverID)
+	if err != nil {
+		return nil, err
+	}
+	var message models.Message
+	err = s.call(http.MethodPost, mirror.Instance, "/channels/"+url.PathEscape(channelID)+"/messages", currentUserID, remoteMessageRequest{Content: content}, &message)
+	if err != nil {
+		return nil, err
+	}
+	remoteAuthors(mirror.Instance, &message)
+	return &message, nil
+}
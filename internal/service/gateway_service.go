@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"rio/internal/events"
+	serverRepo "rio/internal/repository/server"
+)
+
+// gatewayBuffer is how many events a connection may fall behind by before
+// it is dropped; clients are expected to reconnect and catch up over REST.
+const gatewayBuffer = 64
+
+// GatewayConnection is one real-time connection. Events is closed when the
+// connection is dropped.
+type GatewayConnection struct {
+	UserID string
+	Events <-chan events.Event
+
+	events chan events.Event
+}
+
+// GatewayService keeps the open real-time connections and forwards bus
+// events to the members of the server they belong to.
+type GatewayService struct {
+	serverRepo serverRepo.ServerRepository
+
+	mu    sync.Mutex
+	conns map[string]map[*GatewayConnection]struct{}
+}
+
+func NewGatewayService(
+	sRepo serverRepo.ServerRepository,
+	bus *events.Bus,
+) *GatewayService {
+	s := &GatewayService{
+		serverRepo: sRepo,
+		conns:      make(map[string]map[*GatewayConnection]struct{}),
+	}
+	bus.Subscribe(s.broadcast)
+	return s
+}
+
+func (s *GatewayService) Connect(userID string) *GatewayConnection {
+	ch := make(chan events.Event, gatewayBuffer)
+	conn := &GatewayConnection{UserID: userID, Events: ch, events: ch}
+
+	s.mu.Lock()
+	if s.conns[userID] == nil {
+		s.conns[userID] = make(map[*GatewayConnection]struct{})
+	}
+	s.conns[userID][conn] = struct{}{}
+	s.mu.Unlock()
+
+	return conn
+}
+
+func (s *GatewayService) Disconnect(conn *GatewayConnection) {
+	s.mu.Lock()
+	s.drop(conn)
+	s.mu.Unlock()
+}
+
+// drop must be called with s.mu held.
+func (s *GatewayService) drop(conn *GatewayConnection) {
+	userConns := s.conns[conn.UserID]
+	if _, ok := userConns[conn]; !ok {
+		return
+	}
+	delete(userConns, conn)
+	if len(userConns) == 0 {
+		delete(s.conns, conn.UserID)
+	}
+	close(conn.events)
+}
+
+// SendToUser delivers e to every connection of userID and reports whether
+// the user had any.
+func (s *GatewayService) SendToUser(userID string, e events.Event) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delivered := false
+	for conn := range s.conns[userID] {
+		select {
+		case conn.events <- e:
+			delivered = true
+		default:
+			log.Printf("gateway: dropping connection of %s, it fell %d events behind", userID, gatewayBuffer)
+			s.drop(conn)
+		}
+	}
+	return delivered
+}
+
+func (s *GatewayService) broadcast(e events.Event) {
+	if e.ServerID == "" {
+		return
+	}
+
+	s.mu.Lock()
+	idle := len(s.conns) == 0
+	s.mu.Unlock()
+	if idle {
+		return
+	}
+
+	members, err := s.serverRepo.GetServerMembers(context.Background(), e.ServerID)
+	if err != nil {
+		log.Printf("gateway: cannot load members of %s: %v", e.ServerID, err)
+		return
+	}
+	for _, m := range members {
+		s.SendToUser(m.ULID, e)
+	}
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+)
+
+// IncomingWebhookPayload is the body of an incoming webhook call. It accepts
+// rio's native shape ({"content", "username", "avatar_url"}) as well as the
+// parts of Slack's incoming-webhook shape that map onto a plain message:
+// text, icon_url, section/header/context/divider blocks and attachments.
+// Slack payloads are flattened into the message content.
+type IncomingWebhookPayload struct {
+	Content   string `json:"content"`
+	Username  string `json:"username"`
+	AvatarURL string `json:"avatar_url"`
+
+	Text        string            `json:"text"`
+	IconURL     string            `json:"icon_url"`
+	Blocks      []SlackBlock      `json:"blocks"`
+	Attachments []SlackAttachment `json:"attachments"`
+}
+
+type SlackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type SlackBlock struct {
+	Type     string      `json:"type"`
+	Text     *SlackText  `json:"text"`
+	Fields   []SlackText `json:"fields"`
+	Elements []SlackText `json:"elements"`
+}
+
+type SlackAttachmentField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type SlackAttachment struct {
+	Fallback  string                 `json:"fallback"`
+	Pretext   string                 `json:"pretext"`
+	Title     string                 `json:"title"`
+	TitleLink string                 `json:"title_link"`
+	Text      string                 `json:"text"`
+	Fields    []SlackAttachmentField `json:"fields"`
+	Footer    string                 `json:"footer"`
+}
+
+// IsSlack reports whether the payload uses Slack's shape rather than the
+// native one.
+func (p *IncomingWebhookPayload) IsSlack() bool {
+	return p.Content == "" && (p.Text != "" || len(p.Blocks) > 0 || len(p.Attachments) > 0)
+}
+
+func (p *IncomingWebhookPayload) avatar() string {
+	if p.AvatarURL != "" {
+		return p.AvatarURL
+	}
+	return p.IconURL
+}
+
+// Render returns the message content the payload describes.
+func (p *IncomingWebhookPayload) Render() string {
+	if !p.IsSlack() {
+		return p.Content
+	}
+
+	var parts []string
+
+	// Slack shows blocks instead of text when both are present; text is
+	// then only the notification fallback.
+	if blocks := renderSlackBlocks(p.Blocks); blocks != "" {
+		parts = append(parts, blocks)
+	} else if p.Text != "" {
+		parts = append(parts, p.Text)
+	}
+
+	for _, a := range p.Attachments {
+		if rendered := renderSlackAttachment(a); rendered != "" {
+			parts = append(parts, rendered)
+		}
+	}
+
+	return slackToPlain(strings.Join(parts, "\n"))
+}
+
+func renderSlackBlocks(blocks []SlackBlock) string {
+	var lines []string
+	for _, b := range blocks {
+		switch b.Type {
+		case "header":
+			if b.Text != nil && b.Text.Text != "" {
+				lines = append(lines, "**"+b.Text.Text+"**")
+			}
+		case "section":
+			if b.Text != nil && b.Text.Text != "" {
+				lines = append(lines, b.Text.Text)
+			}
+			for _, f := range b.Fields {
+				if f.Text != "" {
+					lines = append(lines, f.Text)
+				}
+			}
+		case "context":
+			var texts []string
+			for _, e := range b.Elements {
+				if e.Text != "" {
+					texts = append(texts, e.Text)
+				}
+			}
+			if len(texts) > 0 {
+				lines = append(lines, strings.Join(texts, " "))
+			}
+		case "divider":
+			lines = append(lines, "---")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func renderSlackAttachment(a SlackAttachment) string {
+	var lines []string
+	if a.Pretext != "" {
+		lines = append(lines, a.Pretext)
+	}
+	switch {
+	case a.Title != "" && a.TitleLink != "":
+		lines = append(lines, "**"+a.Title+"** ("+a.TitleLink+")")
+	case a.Title != "":
+		lines = append(lines, "**"+a.Title+"**")
+	}
+	if a.Text != "" {
+		lines = append(lines, a.Text)
+	}
+	for _, f := range a.Fields {
+		switch {
+		case f.Title != "" && f.Value != "":
+			lines = append(lines, f.Title+": "+f.Value)
+		case f.Value != "":
+			lines = append(lines, f.Value)
+		}
+	}
+	if a.Footer != "" {
+		lines = append(lines, a.Footer)
+	}
+
+	if len(lines) == 0 {
+		return a.Fallback
+	}
+	return strings.Join(lines, "\n")
+}
+
+var (
+	slackLabelledLink = regexp.MustCompile(`<((?:https?|mailto):[^|>]+)\|([^>]+)>`)
+	slackBareLink     = regexp.MustCompile(`<((?:https?|mailto):[^|>]+)>`)
+	slackSpecial      = regexp.MustCompile(`<!(here|channel|everyone)(?:\|[^>]*)?>`)
+	slackEntities     = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&")
+)
+
+// slackToPlain rewrites Slack's mrkdwn link and mention syntax and undoes
+// its HTML-style escaping.
+func slackToPlain(s string) string {
+	s = slackLabelledLink.ReplaceAllString(s, "$2 ($1)")
+	s = slackBareLink.ReplaceAllString(s, "$1")
+	s = slackSpecial.ReplaceAllString(s, "@$1")
+	return slackEntities.Replace(s)
+}
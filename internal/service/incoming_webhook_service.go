@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"html"
+	"net/url"
+	"strings"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	channelRepo "rio/internal/repository/channel"
+	hookRepo "rio/internal/repository/incomingwebhook"
+	serverRepo "rio/internal/repository/server"
+	"rio/utils/token"
+
+	"github.com/oklog/ulid/v2"
+)
+
+const maxWebhookNameLength = 80
+
+// IncomingWebhookWithURL is returned when an incoming webhook is created;
+// it is the only time its secret URL is shown.
+type IncomingWebhookWithURL struct {
+	*models.IncomingWebhook
+	URL string `json:"url"`
+}
+
+type IncomingWebhookService struct {
+	repo        hookRepo.IncomingWebhookRepository
+	channelRepo channelRepo.ChannelRepository
+	serverRepo  serverRepo.ServerRepository
+	messages    *MessageService
+	publicURL   string
+}
+
+// NewIncomingWebhookService builds webhook URLs under publicURL, the
+// externally visible base URL of the API (e.g. https://chat.example.com).
+// When it is empty the URLs are relative.
+func NewIncomingWebhookService(
+	repo hookRepo.IncomingWebhookRepository,
+	cRepo channelRepo.ChannelRepository,
+	sRepo serverRepo.ServerRepository,
+	messages *MessageService,
+	publicURL string,
+) *IncomingWebhookService {
+	return &IncomingWebhookService{
+		repo:        repo,
+		channelRepo: cRepo,
+		serverRepo:  sRepo,
+		messages:    messages,
+		publicURL:   strings.TrimSuffix(publicURL, "/"),
+	}
+}
+
+// channelForAdmin returns the channel if currentUserID is an owner or admin
+// of its server.
+func (s *IncomingWebhookService) channelForAdmin(ctx context.Context, currentUserID, channelID string) (*models.Channel, error) {
+	channel, err := s.channelRepo.GetChannelByID(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if channel == nil {
+		return nil, ErrChannelNotFound
+	}
+
+	membership, err := s.serverRepo.GetUserMembership(ctx, currentUserID, channel.ServerID)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil {
+		return nil, ErrNotMember
+	}
+	if membership.Role != "owner" && membership.Role != "admin" {
+		return nil, apperr.Forbidden("insufficient permissions: only the server owner or an admin can manage webhooks")
+	}
+	return channel, nil
+}
+
+func validateWebhookName(name string) (string, error) {
+	name = html.EscapeString(strings.TrimSpace(name))
+	if name == "" {
+		return "", apperr.Invalid("webhook name cannot be empty")
+	}
+	if len(name) > maxWebhookNameLength {
+		return "", apperr.Invalid("webhook name must be at most 80 characters")
+	}
+	return name, nil
+}
+
+func validateAvatarURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return "", apperr.Invalid("avatar URL must be an absolute http or https URL")
+	}
+	if len(raw) > 2048 {
+		return "", apperr.Invalid("avatar URL must be at most 2048 characters")
+	}
+	return raw, nil
+}
+
+func (s *IncomingWebhookService) CreateIncomingWebhook(ctx context.Context, currentUserID, channelID, name, avatarURL string) (*IncomingWebhookWithURL, error) {
+	channel, err := s.channelForAdmin(ctx, currentUserID, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	if name, err = validateWebhookName(name); err != nil {
+		return nil, err
+	}
+	if avatarURL, err = validateAvatarURL(avatarURL); err != nil {
+		return nil, err
+	}
+
+	secret, err := token.GenerateAPIToken()
+	if err != nil {
+		return nil, err
+	}
+
+	hook := &models.IncomingWebhook{
+		ULID:      ulid.Make().String(),
+		ChannelID: channel.ULID,
+		ServerID:  channel.ServerID,
+		Name:      name,
+		AvatarURL: avatarURL,
+		TokenHash: token.HashToken(secret),
+		CreatedBy: currentUserID,
+	}
+	if err := s.repo.Create(ctx, hook); err != nil {
+		return nil, err
+	}
+
+	return &IncomingWebhookWithURL{
+		IncomingWebhook: hook,
+		URL:             s.publicURL + "/api/webhooks/" + hook.ULID + "/" + secret,
+	}, nil
+}
+
+func (s *IncomingWebhookService) ListIncomingWebhooks(ctx context.Context, currentUserID, channelID string) ([]*models.IncomingWebhook, error) {
+	if _, err := s.channelForAdmin(ctx, currentUserID, channelID); err != nil {
+		return nil, err
+	}
+
+	hooks, err := s.repo.GetIncomingWebhooksByChannel(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if hooks == nil {
+		hooks = []*models.IncomingWebhook{}
+	}
+	return hooks, nil
+}
+
+func (s *IncomingWebhookService) DeleteIncomingWebhook(ctx context.Context, currentUserID, channelID, webhookID string) error {
+	if _, err := s.channelForAdmin(ctx, currentUserID, channelID); err != nil {
+		return err
+	}
+	return s.repo.DeleteIncomingWebhook(ctx, channelID, webhookID)
+}
+
+// Execute posts the payload into the webhook's channel. An unknown webhook
+// and a wrong secret produce the same error.
+func (s *IncomingWebhookService) Execute(ctx context.Context, webhookID, secret string, payload *IncomingWebhookPayload) (*models.Message, error) {
+	hook, err := s.repo.GetIncomingWebhookByID(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if hook == nil || subtle.ConstantTimeCompare([]byte(hook.TokenHash), []byte(token.HashToken(secret))) != 1 {
+		return nil, apperr.NotFound("webhook not found")
+	}
+
+	displayName := hook.Name
+	if payload.Username != "" {
+		if displayName, err = validateWebhookName(payload.Username); err != nil {
+			return nil, err
+		}
+	}
+
+	avatarURL := hook.AvatarURL
+	if override := payload.avatar(); override != "" {
+		if avatarURL, err = validateAvatarURL(override); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.messages.PostWebhookMessage(ctx, hook, payload.Render(), displayName, avatarURL)
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	repository "rio/internal/repository/loginattempt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Failed logins are counted per username (since its last successful login)
+// and per client IP over loginFailureWindow. Past a small allowance each
+// further failure doubles the wait before the next attempt is accepted, and
+// past the lockout threshold the username or IP is refused outright for
+// loginLockoutDuration. IPs get a larger allowance than usernames because
+// many users can share one address.
+const (
+	loginFailureWindow   = 15 * time.Minute
+	loginBackoffBase     = time.Second
+	loginBackoffMax      = 5 * time.Minute
+	loginLockoutDuration = 15 * time.Minute
+
+	usernameBackoffAfter = 3
+	usernameLockoutAfter = 10
+	ipBackoffAfter       = 15
+	ipLockoutAfter       = 50
+
+	securityLogLimit = 100
+)
+
+// Login methods recorded in the security log.
+const (
+	LoginMethodPassword = "password"
+	LoginMethodMFA      = "mfa"
+	LoginMethodPasskey  = "passkey"
+)
+
+var ErrInvalidCredentials = apperr.Unauthorized("invalid username or password")
+
+// LoginThrottledError is returned when a login is refused because of
+// earlier failures. RetryAfter is how long the client must wait.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return "too many failed login attempts; try again later"
+}
+
+// dummyPasswordHash is compared against when the username does not exist,
+// so that unknown and known usernames take the same time to reject.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("rio-dummy-password"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+type LoginGuard struct {
+	repo repository.LoginAttemptRepository
+}
+
+func NewLoginGuard(repo repository.LoginAttemptRepository) *LoginGuard {
+	// Hash up front so the first unknown-username login is not slower
+	// than the rest.
+	dummyPasswordHash()
+	return &LoginGuard{repo: repo}
+}
+
+func normalizeLoginName(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// throttleDelay returns how long after the last failure the next attempt
+// must wait, given the number of failures so far.
+func throttleDelay(failures, backoffAfter, lockoutAfter int) time.Duration {
+	if failures >= lockoutAfter {
+		return loginLockoutDuration
+	}
+	if failures < backoffAfter {
+		return 0
+	}
+
+	delay := loginBackoffBase << (failures - backoffAfter)
+	if delay > loginBackoffMax || delay <= 0 {
+		delay = loginBackoffMax
+	}
+	return delay
+}
+
+// Check returns a *LoginThrottledError when username or the client's IP
+// must wait before trying again.
+func (g *LoginGuard) Check(ctx context.Context, username string, client ClientInfo) error {
+	username = normalizeLoginName(username)
+	now := time.Now()
+
+	since := now.Add(-loginFailureWindow)
+	lastSuccess, err := g.repo.LastSuccessForUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+	if lastSuccess != nil && lastSuccess.After(since) {
+		since = *lastSuccess
+	}
+
+	var retryAfter time.Duration
+
+	failures, last, err := g.repo.FailuresForUsernameSince(ctx, username, since)
+	if err != nil {
+		return err
+	}
+	if last != nil {
+		if wait := last.Add(throttleDelay(failures, usernameBackoffAfter, usernameLockoutAfter)).Sub(now); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+
+	if client.IP != "" {
+		failures, last, err = g.repo.FailuresForIPSince(ctx, client.IP, now.Add(-loginFailureWindow))
+		if err != nil {
+			return err
+		}
+		if last != nil {
+			if wait := last.Add(throttleDelay(failures, ipBackoffAfter, ipLockoutAfter)).Sub(now); wait > retryAfter {
+				retryAfter = wait
+			}
+		}
+	}
+
+	if retryAfter <= 0 {
+		return nil
+	}
+	return &LoginThrottledError{RetryAfter: retryAfter}
+}
+
+// Record adds an attempt to the security log. userID is empty when the
+// username did not resolve to an account.
+func (g *LoginGuard) Record(ctx context.Context, userID, username, method string, client ClientInfo, success bool, reason string) error {
+	return g.repo.Create(ctx, &models.LoginAttempt{
+		UserID:    userID,
+		Username:  normalizeLoginName(username),
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Method:    method,
+		Success:   success,
+		Reason:    reason,
+	})
+}
+
+// SecurityLog returns the most recent login attempts against userID's
+// account, newest first.
+func (g *LoginGuard) SecurityLog(ctx context.Context, userID string) ([]*models.LoginAttempt, error) {
+	attempts, err := g.repo.GetAttemptsByUser(ctx, userID, securityLogLimit)
+	if err != nil {
+		return nil, err
+	}
+	if attempts == nil {
+		attempts = []*models.LoginAttempt{}
+	}
+	return attempts, nil
+}
@@ -0,0 +1,336 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"rio/internal/apperr"
+	"rio/internal/events"
+	"rio/internal/models"
+	attachmentRepo "rio/internal/repository/attachment"
+	channelRepo "rio/internal/repository/channel"
+	messageRepo "rio/internal/repository/message"
+	serverRepo "rio/internal/repository/server"
+	unitOfWork "rio/internal/repository/unitofwork"
+	userRepo "rio/internal/repository/user"
+
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	maxMessageLength    = 4000
+	defaultMessagePage  = 50
+	maxMessagePageLimit = 100
+)
+
+var (
+	// ErrEmptyMessage is returned for messages without content.
+	ErrEmptyMessage = apperr.Invalid("message content must not be empty")
+	// ErrMessageNotFound is returned for messages that do not exist.
+	ErrMessageNotFound = apperr.NotFound("message not found")
+)
+
+type MessageService struct {
+	messageRepo messageRepo.MessageRepository
+	channelRepo channelRepo.ChannelRepository
+	serverRepo  serverRepo.ServerRepository
+	userRepo    userRepo.UserRepository
+	attachments attachmentRepo.AttachmentRepository
+	uow         unitOfWork.UnitOfWork
+	events      *events.Bus
+}
+
+func NewMessageService(
+	mRepo messageRepo.MessageRepository,
+	cRepo channelRepo.ChannelRepository,
+	sRepo serverRepo.ServerRepository,
+	uRepo userRepo.UserRepository,
+	attRepo attachmentRepo.AttachmentRepository,
+	uow unitOfWork.UnitOfWork,
+	bus *events.Bus,
+) *MessageService {
+	return &MessageService{
+		messageRepo: mRepo,
+		channelRepo: cRepo,
+		serverRepo:  sRepo,
+		userRepo:    uRepo,
+		attachments: attRepo,
+		uow:         uow,
+		events:      bus,
+	}
+}
+
+// channelForMember returns the channel and the caller's membership if
+// currentUserID belongs to its server.
+func (s *MessageService) channelForMember(ctx context.Context, currentUserID, channelID string) (*models.Channel, *models.UserServer, error) {
+	channel, err := s.channelRepo.GetChannelByID(ctx, channelID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if channel == nil {
+		return nil, nil, ErrChannelNotFound
+	}
+
+	membership, err := s.serverRepo.GetUserMembership(ctx, currentUserID, channel.ServerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if membership == nil {
+		return nil, nil, ErrNotMember
+	}
+	return channel, membership, nil
+}
+
+// encodeComponents validates components for a message by currentUserID;
+// only bots can attach them.
+func (s *MessageService) encodeComponents(ctx context.Context, currentUserID string, components []models.ActionRow) (string, error) {
+	if len(components) == 0 {
+		return "", nil
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, currentUserID)
+	if err != nil {
+		return "", err
+	}
+	if user == nil || !user.IsBot {
+		return "", apperr.Forbidden("insufficient permissions: only bots can attach components to messages")
+	}
+	return validateComponents(components)
+}
+
+func (s *MessageService) SendMessage(ctx context.Context, currentUserID, channelID, content string, components []models.ActionRow) (*models.Message, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, ErrEmptyMessage
+	}
+	if len(content) > maxMessageLength {
+		return nil, apperr.Invalid("message content must be at most 4000 characters")
+	}
+
+	channel, membership, err := s.channelForMember(ctx, currentUserID, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkTimeout(membership); err != nil {
+		return nil, err
+	}
+
+	encoded, err := s.encodeComponents(ctx, currentUserID, components)
+	if err != nil {
+		return nil, err
+	}
+
+	message := &models.Message{
+		ULID:           ulid.Make().String(),
+		ChannelID:      channel.ULID,
+		UserID:         currentUserID,
+		Content:        content,
+		ComponentsJSON: encoded,
+	}
+
+	if err := s.messageRepo.Create(ctx, message); err != nil {
+		return nil, err
+	}
+
+	withComponents(message)
+	s.events.Publish(events.MessageCreated, channel.ServerID, message)
+	return message, nil
+}
+
+// GetMessageForMember returns a message along with its channel and the
+// caller's membership, if currentUserID belongs to the channel's server.
+func (s *MessageService) GetMessageForMember(ctx context.Context, currentUserID, messageID string) (*models.Message, *models.Channel, *models.UserServer, error) {
+	message, err := s.messageRepo.GetMessageByID(ctx, messageID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if message == nil {
+		return nil, nil, nil, ErrMessageNotFound
+	}
+
+	channel, membership, err := s.channelForMember(ctx, currentUserID, message.ChannelID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return withComponents(message), channel, membership, nil
+}
+
+// EditMessage changes the content and components of the caller's own
+// message. Empty content keeps the current content and nil components
+// keep the current components; an empty list removes them.
+func (s *MessageService) EditMessage(ctx context.Context, currentUserID, messageID, content string, components []models.ActionRow) (*models.Message, error) {
+	message, channel, membership, err := s.GetMessageForMember(ctx, currentUserID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.UserID != currentUserID {
+		return nil, apperr.Forbidden("insufficient permissions: you can only edit your own messages")
+	}
+	if err := checkTimeout(membership); err != nil {
+		return nil, err
+	}
+
+	if content = strings.TrimSpace(content); content != "" {
+		if len(content) > maxMessageLength {
+			return nil, apperr.Invalid("message content must be at most 4000 characters")
+		}
+		message.Content = content
+	}
+
+	if components != nil {
+		encoded, err := s.encodeComponents(ctx, currentUserID, components)
+		if err != nil {
+			return nil, err
+		}
+		message.ComponentsJSON = encoded
+		message.Components = nil
+	}
+
+	if err := s.messageRepo.UpdateMessage(ctx, message.ULID, message.Content, message.ComponentsJSON); err != nil {
+		return nil, err
+	}
+
+	withComponents(message)
+	s.events.Publish(events.MessageUpdated, channel.ServerID, message)
+	return message, nil
+}
+
+// PostWebhookMessage creates a message on behalf of an incoming webhook,
+// shown under displayName and avatarURL instead of a user.
+func (s *MessageService) PostWebhookMessage(ctx context.Context, hook *models.IncomingWebhook, content, displayName, avatarURL string) (*models.Message, error) {
+	return s.postAs(ctx, hook.ChannelID, hook.ULID, content, displayName, avatarURL, nil)
+}
+
+// PostEmailMessage creates a message for mail received at a channel email
+// address, shown under displayName, with the mail's attachments.
+func (s *MessageService) PostEmailMessage(ctx context.Context, address *models.ChannelEmail, content, displayName string, attachments []*models.Attachment) (*models.Message, error) {
+	return s.postAs(ctx, address.ChannelID, address.ULID, content, displayName, "", attachments)
+}
+
+// postAs creates a message posted by something other than a user, such as
+// an incoming webhook; sourceID identifies it. The message and its
+// attachments are stored together or not at all.
+func (s *MessageService) postAs(ctx context.Context, channelID, sourceID, content, displayName, avatarURL string, attachments []*models.Attachment) (*models.Message, error) {
+	content = strings.TrimSpace(content)
+	if content == "" && len(attachments) == 0 {
+		return nil, ErrEmptyMessage
+	}
+	if len(content) > maxMessageLength {
+		return nil, apperr.Invalid("message content must be at most 4000 characters")
+	}
+
+	channel, err := s.channelRepo.GetChannelByID(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if channel == nil {
+		return nil, ErrChannelNotFound
+	}
+
+	message := &models.Message{
+		ULID:        ulid.Make().String(),
+		ChannelID:   channel.ULID,
+		Content:     content,
+		WebhookID:   sourceID,
+		DisplayName: displayName,
+		AvatarURL:   avatarURL,
+	}
+
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.messageRepo.Create(ctx, message); err != nil {
+			return err
+		}
+		for _, a := range attachments {
+			a.ULID = ulid.Make().String()
+			a.MessageID = message.ULID
+			a.Size = len(a.Data)
+			if err := s.attachments.Create(ctx, a); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range attachments {
+		listed := *a
+		listed.Data = nil
+		message.Attachments = append(message.Attachments, listed)
+	}
+
+	s.events.Publish(events.MessageCreated, channel.ServerID, message)
+	return message, nil
+}
+
+// withAttachments fills in the attachments of messages.
+func (s *MessageService) withAttachments(ctx context.Context, messages []*models.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(messages))
+	byID := make(map[string]*models.Message, len(messages))
+	for i, m := range messages {
+		ids[i] = m.ULID
+		byID[m.ULID] = m
+	}
+
+	attachments, err := s.attachments.GetAttachmentsByMessages(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, a := range attachments {
+		if m := byID[a.MessageID]; m != nil {
+			m.Attachments = append(m.Attachments, *a)
+		}
+	}
+	return nil
+}
+
+// GetAttachment returns an attachment, with its data, if currentUserID
+// belongs to the server its message was posted in.
+func (s *MessageService) GetAttachment(ctx context.Context, currentUserID, attachmentID string) (*models.Attachment, error) {
+	attachment, err := s.attachments.GetAttachmentByID(ctx, attachmentID)
+	if err != nil {
+		return nil, err
+	}
+	if attachment == nil {
+		return nil, apperr.NotFound("attachment not found")
+	}
+
+	if _, _, _, err := s.GetMessageForMember(ctx, currentUserID, attachment.MessageID); err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
+func (s *MessageService) GetMessages(ctx context.Context, currentUserID, channelID, before string, limit int) ([]*models.Message, error) {
+	if _, _, err := s.channelForMember(ctx, currentUserID, channelID); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = defaultMessagePage
+	}
+	if limit > maxMessagePageLimit {
+		limit = maxMessagePageLimit
+	}
+
+	messages, err := s.messageRepo.GetMessagesByChannel(ctx, channelID, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	if messages == nil {
+		messages = []*models.Message{}
+	}
+	for _, m := range messages {
+		withComponents(m)
+	}
+	if err := s.withAttachments(ctx, messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
@@ -0,0 +1,331 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	mfaRepo "rio/internal/repository/mfa"
+	unitOfWork "rio/internal/repository/unitofwork"
+	userRepo "rio/internal/repository/user"
+	"rio/utils/token"
+	"rio/utils/totp"
+)
+
+const (
+	totpIssuer          = "rio"
+	recoveryCodeCount   = 10
+	maxMFATicketAttempt = 5
+)
+
+type LoginResult struct {
+	*TokenPair
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFATicket   string `json:"mfa_ticket,omitempty"`
+}
+
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type MFAStatus struct {
+	Enabled                bool `json:"enabled"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+type MFAService struct {
+	userRepo userRepo.UserRepository
+	mfaRepo  mfaRepo.MFARepository
+	uow      unitOfWork.UnitOfWork
+	sessions *SessionService
+	tokens   *TokenService
+	guard    *LoginGuard
+
+	mu       sync.Mutex
+	attempts map[string]*ticketAttempts
+}
+
+type ticketAttempts struct {
+	count     int
+	expiresAt time.Time
+}
+
+func NewMFAService(
+	uRepo userRepo.UserRepository,
+	mRepo mfaRepo.MFARepository,
+	uow unitOfWork.UnitOfWork,
+	sessions *SessionService,
+	tokens *TokenService,
+	guard *LoginGuard,
+) *MFAService {
+	return &MFAService{
+		userRepo: uRepo,
+		mfaRepo:  mRepo,
+		uow:      uow,
+		sessions: sessions,
+		tokens:   tokens,
+		guard:    guard,
+		attempts: make(map[string]*ticketAttempts),
+	}
+}
+
+func (s *MFAService) getUser(ctx context.Context, userID string) (*models.User, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, apperr.NotFound("user not found")
+	}
+	return user, nil
+}
+
+func (s *MFAService) Status(ctx context.Context, currentUserID string) (*MFAStatus, error) {
+	user, err := s.getUser(ctx, currentUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := 0
+	if user.TOTPEnabled {
+		remaining, err = s.mfaRepo.CountUnusedRecoveryCodes(ctx, currentUserID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &MFAStatus{Enabled: user.TOTPEnabled, RecoveryCodesRemaining: remaining}, nil
+}
+
+// BeginEnrollment generates a new TOTP secret for the user. It stays inactive
+// until ConfirmEnrollment proves the user's authenticator produces codes.
+func (s *MFAService) BeginEnrollment(ctx context.Context, currentUserID string) (*TOTPEnrollment, error) {
+	user, err := s.getUser(ctx, currentUserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, apperr.Conflict("two-factor authentication is already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.UpdateTOTP(ctx, currentUserID, secret, false); err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(totpIssuer, user.Username, secret),
+	}, nil
+}
+
+// ConfirmEnrollment turns on two-factor authentication and returns a fresh
+// set of recovery codes. They are only ever shown here.
+func (s *MFAService) ConfirmEnrollment(ctx context.Context, currentUserID, code string) ([]string, error) {
+	user, err := s.getUser(ctx, currentUserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, apperr.Conflict("two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return nil, apperr.Invalid("start enrollment before confirming it")
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return nil, apperr.Invalid("invalid authentication code")
+	}
+
+	var codes []string
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.UpdateTOTP(ctx, currentUserID, user.TOTPSecret, true); err != nil {
+			return err
+		}
+		if err := s.userRepo.AdvanceTOTPStep(ctx, currentUserID, step); err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = s.newRecoveryCodes(ctx, currentUserID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *MFAService) Disable(ctx context.Context, currentUserID, code string) error {
+	user, err := s.getUser(ctx, currentUserID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return apperr.Invalid("two-factor authentication is not enabled")
+	}
+
+	if err := s.verifySecondFactor(ctx, user, code); err != nil {
+		return err
+	}
+
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.UpdateTOTP(ctx, currentUserID, "", false); err != nil {
+			return err
+		}
+		return s.mfaRepo.DeleteRecoveryCodes(ctx, currentUserID)
+	})
+}
+
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, currentUserID, code string) ([]string, error) {
+	user, err := s.getUser(ctx, currentUserID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, apperr.Invalid("two-factor authentication is not enabled")
+	}
+
+	if err := s.verifySecondFactor(ctx, user, code); err != nil {
+		return nil, err
+	}
+
+	return s.newRecoveryCodes(ctx, currentUserID)
+}
+
+func (s *MFAService) newRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for i := range codes {
+		buf := make([]byte, 7)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		raw := enc.EncodeToString(buf)[:10]
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = token.HashToken(raw)
+	}
+
+	if err := s.mfaRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// verifySecondFactor accepts either a current TOTP code or an unused
+// recovery code, consuming whichever was presented.
+func (s *MFAService) verifySecondFactor(ctx context.Context, user *models.User, code string) error {
+	code = strings.TrimSpace(code)
+
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return apperr.Invalid("invalid authentication code")
+		}
+		// A stale or replayed step is refused as Invalid, which reads the
+		// same as a wrong code; anything else is a real failure.
+		if err := s.userRepo.AdvanceTOTPStep(ctx, user.ULID, step); err != nil {
+			if errors.Is(err, apperr.ErrInvalid) {
+				return apperr.Invalid("invalid authentication code")
+			}
+			return err
+		}
+		return nil
+	}
+
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	used, err := s.mfaRepo.UseRecoveryCode(ctx, user.ULID, token.HashToken(normalized))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return apperr.Invalid("invalid authentication code")
+	}
+	return nil
+}
+
+// CompleteLogin exchanges an MFA ticket from LoginCheck plus a second factor
+// for a new session. Each ticket allows a handful of attempts.
+func (s *MFAService) CompleteLogin(ctx context.Context, ticket, code string, client ClientInfo) (*TokenPair, error) {
+	claims, err := token.ParseMFATicket(ticket)
+	if err != nil {
+		return nil, apperr.Unauthorized("invalid or expired MFA ticket")
+	}
+
+	if !s.recordAttempt(claims.ID, claims.ExpiresAt.Time) {
+		return nil, apperr.Unauthorized("too many attempts; log in again")
+	}
+
+	user, err := s.getUser(ctx, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, apperr.Unauthorized("invalid or expired MFA ticket")
+	}
+
+	if err := s.guard.Check(ctx, user.Username, client); err != nil {
+		return nil, err
+	}
+
+	if err := s.verifySecondFactor(ctx, user, code); err != nil {
+		if err := s.guard.Record(ctx, user.ULID, user.Username, LoginMethodMFA, client, false, models.LoginReasonBadSecondFactor); err != nil {
+			return nil, err
+		}
+		if errors.Is(err, apperr.ErrInvalid) {
+			return nil, apperr.Unauthorized("%w", err)
+		}
+		return nil, err
+	}
+
+	if err := s.guard.Record(ctx, user.ULID, user.Username, LoginMethodMFA, client, true, ""); err != nil {
+		return nil, err
+	}
+
+	// A ticket is single-use once it has produced a session.
+	s.mu.Lock()
+	if a, ok := s.attempts[claims.ID]; ok {
+		a.count = maxMFATicketAttempt
+	}
+	s.mu.Unlock()
+
+	return s.tokens.StartSession(ctx, user.ULID, client)
+}
+
+func (s *MFAService) recordAttempt(ticketID string, expiresAt time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, a := range s.attempts {
+		if now.After(a.expiresAt) {
+			delete(s.attempts, id)
+		}
+	}
+
+	a, ok := s.attempts[ticketID]
+	if !ok {
+		a = &ticketAttempts{expiresAt: expiresAt}
+		s.attempts[ticketID] = a
+	}
+
+	if a.count >= maxMFATicketAttempt {
+		return false
+	}
+	a.count++
+	return true
+}
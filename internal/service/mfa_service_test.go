@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	mfaRepo "rio/internal/repository/mfa"
+	userRepo "rio/internal/repository/user"
+	"rio/internal/store"
+	"rio/utils/totp"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// failingSteps fails AdvanceTOTPStep with err instead of recording the step.
+type failingSteps struct {
+	userRepo.UserRepository
+	err error
+}
+
+func (r *failingSteps) AdvanceTOTPStep(ctx context.Context, id string, step int64) error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.UserRepository.AdvanceTOTPStep(ctx, id, step)
+}
+
+func TestVerifySecondFactor(t *testing.T) {
+	s := store.New()
+	users := &failingSteps{UserRepository: userRepo.NewInMemoryUserRepository(s)}
+	mfa := NewMFAService(users, mfaRepo.NewInMemoryMFARepository(s), nil, nil, nil, nil)
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{ULID: ulid.Make().String(), Username: "alice", Password: "unused", TOTPSecret: secret, TOTPEnabled: true}
+	if err := users.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	code, err := totp.CodeAt(secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unavailable := errors.New("database is down")
+	users.err = unavailable
+	if err := mfa.verifySecondFactor(context.Background(), user, code); !errors.Is(err, unavailable) {
+		t.Fatalf("with the database down: %v; want the database error", err)
+	}
+
+	users.err = nil
+	if err := mfa.verifySecondFactor(context.Background(), user, code); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfa.verifySecondFactor(context.Background(), user, code); !errors.Is(err, apperr.ErrInvalid) {
+		t.Fatalf("replaying the code: %v; want it refused as invalid", err)
+	}
+}
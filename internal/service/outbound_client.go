@@ -0,0 +1,58 @@
+package service
+
+import (
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"rio/internal/apperr"
+)
+
+// outboundTimeout bounds a request to another host, unless the caller
+// sets a timeout of its own.
+const outboundTimeout = 10 * time.Second
+
+// newOutboundClient returns the client rio calls other hosts with: webhook
+// endpoints, application interaction endpoints and federation peers.
+// Unless allowInsecure, it only dials public addresses.
+func newOutboundClient(allowInsecure bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		// Check the address actually dialled, after DNS resolution, so a
+		// hostname cannot be pointed at an internal service.
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if allowInsecure {
+				return nil
+			}
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !isPublicIP(ip) {
+				return apperr.Invalid("%s is not a public address", host)
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: outboundTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        20,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"html"
+	"strings"
+	"sync"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	passkeyRepo "rio/internal/repository/passkey"
+	userRepo "rio/internal/repository/user"
+	"rio/utils/token"
+	"rio/utils/webauthn"
+
+	"github.com/oklog/ulid/v2"
+)
+
+const ceremonyLifespan = webauthn.Timeout * time.Millisecond
+
+// ceremony is the server-side half of an in-flight WebAuthn ceremony. They
+// are kept in memory, so a client must finish a ceremony on the instance
+// that started it.
+type ceremony struct {
+	challenge string
+	userID    string
+	expiresAt time.Time
+}
+
+type PasskeyRegistration struct {
+	CeremonyID string                   `json:"ceremony_id"`
+	PublicKey  webauthn.CreationOptions `json:"publicKey"`
+}
+
+type PasskeyLogin struct {
+	CeremonyID string                  `json:"ceremony_id"`
+	PublicKey  webauthn.RequestOptions `json:"publicKey"`
+}
+
+type PasskeyService struct {
+	repo     passkeyRepo.PasskeyRepository
+	userRepo userRepo.UserRepository
+	sessions *SessionService
+	tokens   *TokenService
+	guard    *LoginGuard
+	rp       *webauthn.RelyingParty
+
+	mu         sync.Mutex
+	ceremonies map[string]*ceremony
+}
+
+func NewPasskeyService(
+	repo passkeyRepo.PasskeyRepository,
+	uRepo userRepo.UserRepository,
+	sessions *SessionService,
+	tokens *TokenService,
+	guard *LoginGuard,
+	rp *webauthn.RelyingParty,
+) *PasskeyService {
+	return &PasskeyService{
+		repo:       repo,
+		userRepo:   uRepo,
+		sessions:   sessions,
+		tokens:     tokens,
+		guard:      guard,
+		rp:         rp,
+		ceremonies: make(map[string]*ceremony),
+	}
+}
+
+func (s *PasskeyService) startCeremony(userID string) (string, string, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return "", "", err
+	}
+
+	id := ulid.Make().String()
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, c := range s.ceremonies {
+		if now.After(c.expiresAt) {
+			delete(s.ceremonies, key)
+		}
+	}
+	s.ceremonies[id] = &ceremony{challenge: challenge, userID: userID, expiresAt: now.Add(ceremonyLifespan)}
+
+	return id, challenge, nil
+}
+
+// takeCeremony removes and returns a ceremony so each challenge can be
+// answered only once.
+func (s *PasskeyService) takeCeremony(id, userID string) (*ceremony, error) {
+	s.mu.Lock()
+	c, ok := s.ceremonies[id]
+	delete(s.ceremonies, id)
+	s.mu.Unlock()
+
+	if !ok || time.Now().After(c.expiresAt) || c.userID != userID {
+		return nil, apperr.NotFound("passkey ceremony not found or expired")
+	}
+	return c, nil
+}
+
+func credentialIDHash(credentialID string) (string, error) {
+	raw, err := webauthn.DecodeID(credentialID)
+	if err != nil {
+		return "", apperr.Invalid("malformed credential ID")
+	}
+	return token.HashToken(string(raw)), nil
+}
+
+func (s *PasskeyService) BeginRegistration(ctx context.Context, currentUserID string) (*PasskeyRegistration, error) {
+	user, err := s.userRepo.GetUserByID(ctx, currentUserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, apperr.NotFound("user not found")
+	}
+
+	existing, err := s.repo.GetPasskeysByUser(ctx, currentUserID)
+	if err != nil {
+		return nil, err
+	}
+	exclude := make([][]byte, 0, len(existing))
+	for _, p := range existing {
+		if raw, err := webauthn.DecodeID(p.CredentialID); err == nil {
+			exclude = append(exclude, raw)
+		}
+	}
+
+	id, challenge, err := s.startCeremony(currentUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PasskeyRegistration{
+		CeremonyID: id,
+		PublicKey:  s.rp.CreationOptions(challenge, []byte(user.ULID), user.Username, user.Username, exclude),
+	}, nil
+}
+
+func (s *PasskeyService) FinishRegistration(ctx context.Context, currentUserID, ceremonyID, name string, resp webauthn.RegistrationResponse) (*models.Passkey, error) {
+	name = html.EscapeString(strings.TrimSpace(name))
+	if name == "" {
+		name = "Passkey"
+	}
+	if len(name) > 100 {
+		return nil, apperr.Invalid("passkey name must be at most 100 characters")
+	}
+
+	c, err := s.takeCeremony(ceremonyID, currentUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	cred, err := s.rp.VerifyRegistration(c.challenge, resp)
+	if err != nil {
+		return nil, apperr.Invalid("%w", err)
+	}
+
+	credentialID := webauthn.EncodeID(cred.ID)
+	hash := token.HashToken(string(cred.ID))
+
+	existing, err := s.repo.GetByCredentialIDHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, apperr.Conflict("passkey is already registered")
+	}
+
+	passkey := &models.Passkey{
+		ULID:             ulid.Make().String(),
+		UserID:           currentUserID,
+		Name:             name,
+		CredentialID:     credentialID,
+		CredentialIDHash: hash,
+		PublicKey:        cred.PublicKey,
+		SignCount:        cred.SignCount,
+		AAGUID:           formatAAGUID(cred.AAGUID),
+		Transports:       strings.Join(cred.Transports, ","),
+	}
+
+	if err := s.repo.Create(ctx, passkey); err != nil {
+		return nil, err
+	}
+	return passkey, nil
+}
+
+func formatAAGUID(b []byte) string {
+	if len(b) != 16 {
+		return ""
+	}
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+func (s *PasskeyService) ListPasskeys(ctx context.Context, currentUserID string) ([]*models.Passkey, error) {
+	return s.repo.GetPasskeysByUser(ctx, currentUserID)
+}
+
+func (s *PasskeyService) RemovePasskey(ctx context.Context, currentUserID, passkeyID string) error {
+	return s.repo.DeletePasskey(ctx, currentUserID, passkeyID)
+}
+
+// BeginLogin starts a passwordless login. No user is named up front: the
+// authenticator offers any discoverable passkey it holds for rio.
+func (s *PasskeyService) BeginLogin() (*PasskeyLogin, error) {
+	id, challenge, err := s.startCeremony("")
+	if err != nil {
+		return nil, err
+	}
+
+	return &PasskeyLogin{
+		CeremonyID: id,
+		PublicKey:  s.rp.RequestOptions(challenge, nil),
+	}, nil
+}
+
+// FinishLogin verifies the assertion and opens a session exactly as a
+// password login would.
+func (s *PasskeyService) FinishLogin(ctx context.Context, ceremonyID string, resp webauthn.AssertionResponse, client ClientInfo) (*LoginResult, error) {
+	c, err := s.takeCeremony(ceremonyID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := credentialIDHash(resp.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	passkey, err := s.repo.GetByCredentialIDHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if passkey == nil {
+		return nil, apperr.Unauthorized("unknown passkey")
+	}
+
+	if resp.Response.UserHandle != "" {
+		handle, err := webauthn.DecodeID(resp.Response.UserHandle)
+		if err != nil || string(handle) != passkey.UserID {
+			return nil, apperr.Unauthorized("passkey does not belong to this user")
+		}
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, passkey.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, apperr.Unauthorized("unknown passkey")
+	}
+
+	signCount, err := s.rp.VerifyAssertion(c.challenge, resp, passkey.PublicKey, passkey.SignCount)
+	if err != nil {
+		if err := s.guard.Record(ctx, user.ULID, user.Username, LoginMethodPasskey, client, false, models.LoginReasonBadPasskey); err != nil {
+			return nil, err
+		}
+		return nil, apperr.Unauthorized("%w", err)
+	}
+
+	if err := s.repo.RecordUse(ctx, passkey.ULID, signCount, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := s.guard.Record(ctx, user.ULID, user.Username, LoginMethodPasskey, client, true, ""); err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.tokens.StartSession(ctx, passkey.UserID, client)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{TokenPair: tokens}, nil
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"rio/internal/models"
+	loginAttemptRepo "rio/internal/repository/loginattempt"
+	passkeyRepo "rio/internal/repository/passkey"
+	sessionRepo "rio/internal/repository/session"
+	tokenRepo "rio/internal/repository/token"
+	unitOfWork "rio/internal/repository/unitofwork"
+	userRepo "rio/internal/repository/user"
+	"rio/internal/store"
+	"rio/utils/token"
+	"rio/utils/webauthn"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// TestPasskeyCeremonies registers a passkey held by a software
+// authenticator and logs in with it without a password.
+func TestPasskeyCeremonies(t *testing.T) {
+	if err := token.Configure(token.Settings{Alg: "HS256", Secret: "test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := store.New()
+	users := userRepo.NewInMemoryUserRepository(s)
+	tokens := tokenRepo.NewInMemoryTokenRepository(s)
+	sessions := NewSessionService(sessionRepo.NewInMemorySessionRepository(s), tokens)
+	rp := &webauthn.RelyingParty{ID: "localhost", Name: "rio", Origins: []string{"http://localhost:8080"}}
+	passkeys := NewPasskeyService(
+		passkeyRepo.NewInMemoryPasskeyRepository(s),
+		users,
+		sessions,
+		NewTokenService(tokens, sessions, unitOfWork.NewInMemoryUnitOfWork(s)),
+		NewLoginGuard(loginAttemptRepo.NewInMemoryLoginAttemptRepository(s)),
+		rp,
+	)
+
+	user := &models.User{ULID: ulid.Make().String(), Username: "alice", Password: "unused"}
+	if err := users.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator := webauthn.NewSoftAuthenticator("http://localhost:8080")
+
+	registration, err := passkeys.BeginRegistration(context.Background(), user.ULID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := authenticator.Create(registration.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passkey, err := passkeys.FinishRegistration(context.Background(), user.ULID, registration.CeremonyID, "Laptop", created)
+	if err != nil {
+		t.Fatalf("registration: %v", err)
+	}
+	if passkey.UserID != user.ULID || passkey.CredentialID != created.ID {
+		t.Fatalf("registered %+v for credential %s", passkey, created.ID)
+	}
+
+	// A second passkey on the same authenticator is refused.
+	again, err := passkeys.BeginRegistration(context.Background(), user.ULID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authenticator.Create(again.PublicKey); err == nil {
+		t.Fatal("authenticator registered an excluded credential")
+	}
+
+	login, err := passkeys.BeginLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertion, err := authenticator.Get(login.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := passkeys.FinishLogin(context.Background(), login.CeremonyID, assertion, ClientInfo{UserAgent: "test", IP: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if result.TokenPair == nil || result.AccessToken == "" {
+		t.Fatal("login issued no tokens")
+	}
+
+	// Each challenge can be answered once.
+	if _, err := passkeys.FinishLogin(context.Background(), login.CeremonyID, assertion, ClientInfo{}); err == nil {
+		t.Fatal("replayed assertion was accepted")
+	}
+
+	// An assertion signed over another challenge is refused.
+	stale, err := passkeys.BeginLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := passkeys.FinishLogin(context.Background(), stale.CeremonyID, assertion, ClientInfo{}); err == nil {
+		t.Fatal("assertion for another challenge was accepted")
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	_ "embed"
+	"fmt"
+	"rio/internal/apperr"
+	"strings"
+	"unicode"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+var commonPasswords = func() map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, line := range strings.Split(commonPasswordList, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		set[strings.ToLower(line)] = struct{}{}
+	}
+	return set
+}()
+
+// bcrypt ignores everything past 72 bytes, so longer passwords would give a
+// false sense of strength.
+const maxPasswordBytes = 72
+
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	DenyCommon    bool
+}
+
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:  10,
+		DenyCommon: true,
+	}
+}
+
+// Check reports whether the policy itself is usable.
+func (p PasswordPolicy) Check() error {
+	if p.MinLength < 1 || p.MinLength > maxPasswordBytes {
+		return fmt.Errorf("minimum password length must be between 1 and %d", maxPasswordBytes)
+	}
+	return nil
+}
+
+func (p PasswordPolicy) Validate(password, username string) error {
+	if len([]rune(password)) < p.MinLength {
+		return apperr.Invalid("password must be at least %d characters", p.MinLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return apperr.Invalid("password must be at most %d bytes", maxPasswordBytes)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, ch := range password {
+		switch {
+		case unicode.IsUpper(ch):
+			upper = true
+		case unicode.IsLower(ch):
+			lower = true
+		case unicode.IsDigit(ch):
+			digit = true
+		case unicode.IsPunct(ch) || unicode.IsSymbol(ch) || unicode.IsSpace(ch):
+			symbol = true
+		}
+	}
+
+	if p.RequireUpper && !upper {
+		return apperr.Invalid("password must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		return apperr.Invalid("password must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		return apperr.Invalid("password must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		return apperr.Invalid("password must contain a symbol")
+	}
+
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return apperr.Invalid("password must not contain the username")
+	}
+
+	if p.DenyCommon {
+		if _, ok := commonPasswords[strings.ToLower(password)]; ok {
+			return apperr.Invalid("password is too common")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/mailer"
+	"rio/internal/models"
+	resetRepo "rio/internal/repository/passwordreset"
+	unitOfWork "rio/internal/repository/unitofwork"
+	userRepo "rio/internal/repository/user"
+	"rio/utils/token"
+
+	"github.com/oklog/ulid/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultResetLifespan is how long a password reset token lasts unless
+// configured otherwise.
+const DefaultResetLifespan = 30 * time.Minute
+
+type PasswordService struct {
+	userRepo  userRepo.UserRepository
+	resetRepo resetRepo.PasswordResetRepository
+	uow       unitOfWork.UnitOfWork
+	sessions  *SessionService
+	mailer    mailer.Mailer
+	policy    PasswordPolicy
+	// resetURL is the page reset emails link to, with the token appended;
+	// without it they carry the bare token.
+	resetURL      string
+	resetLifespan time.Duration
+}
+
+func NewPasswordService(
+	uRepo userRepo.UserRepository,
+	rRepo resetRepo.PasswordResetRepository,
+	uow unitOfWork.UnitOfWork,
+	sessions *SessionService,
+	m mailer.Mailer,
+	policy PasswordPolicy,
+	resetURL string,
+	resetLifespan time.Duration,
+) *PasswordService {
+	if resetLifespan <= 0 {
+		resetLifespan = DefaultResetLifespan
+	}
+	return &PasswordService{
+		userRepo:      uRepo,
+		resetRepo:     rRepo,
+		uow:           uow,
+		sessions:      sessions,
+		mailer:        m,
+		policy:        policy,
+		resetURL:      resetURL,
+		resetLifespan: resetLifespan,
+	}
+}
+
+// ChangePassword replaces the user's password after checking the current one
+// and signs out every other session.
+func (s *PasswordService) ChangePassword(ctx context.Context, currentUserID, currentSessionID, currentPassword, newPassword string) error {
+	user, err := s.userRepo.GetUserByID(ctx, currentUserID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return apperr.NotFound("user not found")
+	}
+
+	// GetUserByID strips the hash, so look the user up again to verify.
+	withHash, err := s.userRepo.FindByUsername(ctx, user.Username)
+	if err != nil {
+		return err
+	}
+	if withHash == nil || VerifyPassword(currentPassword, withHash.Password) != nil {
+		return apperr.Invalid("current password is incorrect")
+	}
+
+	if currentPassword == newPassword {
+		return apperr.Invalid("new password must differ from the current password")
+	}
+
+	if err := s.setPassword(ctx, user, newPassword); err != nil {
+		return err
+	}
+
+	return s.sessions.RevokeOtherSessions(ctx, currentUserID, currentSessionID)
+}
+
+func (s *PasswordService) setPassword(ctx context.Context, user *models.User, newPassword string) error {
+	if err := s.policy.Validate(newPassword, user.Username); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.UpdatePassword(ctx, user.ULID, string(hashedPassword)); err != nil {
+			return err
+		}
+		return s.resetRepo.InvalidateForUser(ctx, user.ULID)
+	})
+}
+
+// RequestReset mails a single-use reset token to the account matching
+// identifier (a username or an email address). It reports success whether
+// or not an account matched so it cannot be used to probe for accounts;
+// the token is stored and mailed in the background so that the response
+// takes as long either way.
+func (s *PasswordService) RequestReset(ctx context.Context, identifier string) error {
+	identifier = strings.TrimSpace(identifier)
+	if identifier == "" {
+		return apperr.Invalid("username or email is required")
+	}
+
+	var user *models.User
+	var err error
+	if strings.Contains(identifier, "@") {
+		user, err = s.userRepo.FindByEmail(ctx, identifier)
+	} else {
+		user, err = s.userRepo.FindByUsername(ctx, identifier)
+	}
+	if err != nil {
+		return err
+	}
+	if user == nil || user.Email == "" {
+		return nil
+	}
+
+	// The request is answered before the mail is sent, which must not
+	// cancel it.
+	go s.sendReset(context.WithoutCancel(ctx), user)
+	return nil
+}
+
+// sendReset stores a new reset token for user and mails it. Failures are
+// only logged; reporting them would reveal that the account exists.
+func (s *PasswordService) sendReset(ctx context.Context, user *models.User) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		log.Printf("password reset for user %s failed: %v", user.ULID, err)
+		return
+	}
+	rawToken := base64.RawURLEncoding.EncodeToString(buf)
+
+	lifespan := s.resetLifespan
+	reset := &models.PasswordReset{
+		ULID:      ulid.Make().String(),
+		UserID:    user.ULID,
+		TokenHash: token.HashToken(rawToken),
+		ExpiresAt: time.Now().Add(lifespan),
+	}
+	if err := s.resetRepo.Create(ctx, reset); err != nil {
+		log.Printf("password reset for user %s failed: %v", user.ULID, err)
+		return
+	}
+
+	body := fmt.Sprintf("Someone asked to reset the password for your rio account %q.\n\n", user.Username)
+	if base := s.resetURL; base != "" {
+		body += fmt.Sprintf("Open this link to choose a new password:\n%s?token=%s\n\n", base, url.QueryEscape(rawToken))
+	} else {
+		body += fmt.Sprintf("Use this reset token to choose a new password:\n%s\n\n", rawToken)
+	}
+	body += fmt.Sprintf("It expires in %d minutes and can only be used once. If you did not ask for this, ignore this email.", int(lifespan.Minutes()))
+
+	err := s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your rio password",
+		Body:    body,
+	})
+	if err != nil {
+		log.Printf("password reset mail for user %s failed: %v", user.ULID, err)
+	}
+}
+
+// ResetPassword consumes a reset token and sets a new password, signing the
+// user out everywhere.
+func (s *PasswordService) ResetPassword(ctx context.Context, rawToken, newPassword string) error {
+	reset, err := s.resetRepo.FindByHash(ctx, token.HashToken(rawToken))
+	if err != nil {
+		return err
+	}
+	if reset == nil || reset.UsedAt != nil || time.Now().After(reset.ExpiresAt) {
+		return apperr.Invalid("reset token is invalid or has expired")
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, reset.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return apperr.Invalid("reset token is invalid or has expired")
+	}
+
+	if err := s.policy.Validate(newPassword, user.Username); err != nil {
+		return err
+	}
+
+	// The token is only spent if the password changes.
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.resetRepo.MarkUsed(ctx, reset.ULID); err != nil {
+			return apperr.Invalid("reset token is invalid or has expired")
+		}
+		return s.setPassword(ctx, user, newPassword)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.sessions.RevokeOtherSessions(ctx, user.ULID, "")
+}
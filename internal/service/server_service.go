@@ -1,33 +1,94 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"html"
+	"rio/internal/apperr"
+	"rio/internal/events"
 	"rio/internal/models"
+	appRepo "rio/internal/repository/application"
+	inviteRepo "rio/internal/repository/invite"
 	serverRepo "rio/internal/repository/server"
+	unitOfWork "rio/internal/repository/unitofwork"
 	userRepo "rio/internal/repository/user"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/oklog/ulid/v2"
 )
 
+const (
+	maxTimeout       = 28 * 24 * time.Hour
+	maxInviteAge     = 7 * 24 * time.Hour
+	inviteCodeLength = 8
+)
+
+// ErrNotMember is returned to callers acting on a server they do not
+// belong to.
+var ErrNotMember = apperr.Forbidden("you are not a member of this server")
+
 type ServerService struct {
 	serverRepo serverRepo.ServerRepository
 	userRepo   userRepo.UserRepository
+	appRepo    appRepo.ApplicationRepository
+	inviteRepo inviteRepo.InviteRepository
+	uow        unitOfWork.UnitOfWork
+	events     *events.Bus
 }
 
 func NewServerService(
 	sRepo serverRepo.ServerRepository,
 	uRepo userRepo.UserRepository,
+	aRepo appRepo.ApplicationRepository,
+	iRepo inviteRepo.InviteRepository,
+	uow unitOfWork.UnitOfWork,
+	bus *events.Bus,
 ) *ServerService {
 	return &ServerService{
 		serverRepo: sRepo,
 		userRepo:   uRepo,
+		appRepo:    aRepo,
+		inviteRepo: iRepo,
+		uow:        uow,
+		events:     bus,
 	}
 }
 
+// publishServerUpdated announces the server's current settings.
+func (s *ServerService) publishServerUpdated(ctx context.Context, serverID string) error {
+	server, err := s.serverRepo.GetServerByID(ctx, serverID)
+	if err != nil {
+		return err
+	}
+	if server == nil {
+		return nil
+	}
+
+	s.events.Publish(events.ServerUpdated, serverID, events.ServerData{
+		ID:         server.ULID,
+		Name:       server.Name,
+		RequireMFA: server.RequireMFA,
+	})
+	return nil
+}
+
+// checkHostedHere rejects servers mirrored from another instance, whose
+// members are managed by the instance hosting them.
+func (s *ServerService) checkHostedHere(ctx context.Context, serverID string) error {
+	server, err := s.serverRepo.GetServerByID(ctx, serverID)
+	if err != nil {
+		return err
+	}
+	if server != nil && server.Instance != "" {
+		return apperr.Forbidden("this server is hosted on %s; manage its members there", server.Instance)
+	}
+	return nil
+}
+
 func validPermissions(userMembership, targetMembership models.UserServer) bool {
 	if userMembership.UserID == targetMembership.UserID {
 		return true
@@ -52,12 +113,37 @@ func validPermissions(userMembership, targetMembership models.UserServer) bool {
 	}
 }
 
-func (s *ServerService) ListUserServers(currentUserID string) ([]*models.Server, error) {
+// checkMFARequirement enforces a server's RequireMFA setting: members with a
+// role above "member" must have two-factor authentication enabled to act.
+func (s *ServerService) checkMFARequirement(ctx context.Context, membership *models.UserServer) error {
+	if membership.Role == "member" {
+		return nil
+	}
+
+	server, err := s.serverRepo.GetServerByID(ctx, membership.ServerID)
+	if err != nil {
+		return err
+	}
+	if server == nil || !server.RequireMFA {
+		return nil
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, membership.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil || !user.TOTPEnabled {
+		return apperr.Forbidden("insufficient permissions: this server requires two-factor authentication for moderators and above")
+	}
+	return nil
+}
+
+func (s *ServerService) ListUserServers(ctx context.Context, currentUserID string) ([]*models.Server, error) {
 	if currentUserID == "" {
-		return nil, errors.New("current user ID is required")
+		return nil, apperr.Invalid("current user ID is required")
 	}
 
-	servers, err := s.serverRepo.GetServersByUser(currentUserID)
+	servers, err := s.serverRepo.GetServersByUser(ctx, currentUserID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve user servers: %w", err)
 	}
@@ -65,19 +151,28 @@ func (s *ServerService) ListUserServers(currentUserID string) ([]*models.Server,
 	return servers, nil
 }
 
-func (s *ServerService) CreateServer(currentUserID, name string) (*models.Server, error) {
-
+// validateServerName returns a server name as it is stored: trimmed and
+// HTML-escaped.
+func validateServerName(name string) (string, error) {
 	name = html.EscapeString(strings.TrimSpace(name))
-
 	if name == "" {
-		return nil, errors.New("server name cannot be empty")
+		return "", apperr.Invalid("server name cannot be empty")
 	}
 	if len(name) < 3 || len(name) > 100 {
-		return nil, errors.New("server name must be between 3 and 100 characters")
+		return "", apperr.Invalid("server name must be between 3 and 100 characters")
+	}
+	return name, nil
+}
+
+func (s *ServerService) CreateServer(ctx context.Context, currentUserID, name string) (*models.Server, error) {
+
+	name, err := validateServerName(name)
+	if err != nil {
+		return nil, err
 	}
-	u, err := s.userRepo.GetUserByID(currentUserID)
+	u, err := s.userRepo.GetUserByID(ctx, currentUserID)
 	if err != nil {
-		return nil, errors.New("user does not exist (contact dev)")
+		return nil, apperr.NotFound("user does not exist (contact dev)")
 	}
 
 	users := make([]models.User, 1)
@@ -93,237 +188,722 @@ func (s *ServerService) CreateServer(currentUserID, name string) (*models.Server
 		return nil, errors.New("failed to generate ULID")
 	}
 
-	if err := s.serverRepo.Create(&newServer); err != nil {
-		return nil, fmt.Errorf("failed to create server: %w, called by user: %v", err, currentUserID)
-	}
+	// A server is never left without its owner.
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.serverRepo.Create(ctx, &newServer); err != nil {
+			return fmt.Errorf("failed to create server: %w, called by user: %v", err, currentUserID)
+		}
 
-	membership := models.UserServer{
-		UserID:   currentUserID,
-		ServerID: newServer.ULID,
-		Role:     "owner",
-	}
+		membership := models.UserServer{
+			UserID:   currentUserID,
+			ServerID: newServer.ULID,
+			Role:     "owner",
+		}
 
-	if err := s.serverRepo.CreateMembership(&membership); err != nil {
-		return nil, fmt.Errorf("failed to assign owner role: %w for sID: %v, uID: %v", err, newServer.ULID, currentUserID)
+		if err := s.serverRepo.CreateMembership(ctx, &membership); err != nil {
+			return fmt.Errorf("failed to assign owner role: %w for sID: %v, uID: %v", err, newServer.ULID, currentUserID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &newServer, nil
 }
 
-func (s *ServerService) GetServer(currentUserID, serverID string) (*models.Server, error) {
-	server, err := s.serverRepo.GetServerByID(serverID)
+func (s *ServerService) GetServer(ctx context.Context, currentUserID, serverID string) (*models.Server, error) {
+	server, err := s.serverRepo.GetServerByID(ctx, serverID)
 	if err != nil {
 		return nil, err
 	}
 
-	isMember, err := s.IsUserMember(currentUserID, serverID)
+	isMember, err := s.IsUserMember(ctx, currentUserID, serverID)
 	if err != nil {
 		return nil, err
 	}
 	if !isMember {
-		return nil, errors.New("user is not a member of server")
+		return nil, ErrNotMember
 	}
 	return server, nil
 }
 
-func (s *ServerService) IsUserMember(currentUserID, serverID string) (bool, error) {
-	membership, err := s.serverRepo.GetUserMembership(currentUserID, serverID)
+// ListMembers returns the members of a server the caller belongs to.
+func (s *ServerService) ListMembers(ctx context.Context, currentUserID, serverID string) ([]*models.User, error) {
+	isMember, err := s.IsUserMember(ctx, currentUserID, serverID)
+	if err != nil {
+		return nil, err
+	}
+	if !isMember {
+		return nil, ErrNotMember
+	}
+
+	members, err := s.serverRepo.GetServerMembers(ctx, serverID)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		m.Password = ""
+	}
+	return members, nil
+}
+
+func (s *ServerService) IsUserMember(ctx context.Context, currentUserID, serverID string) (bool, error) {
+	membership, err := s.serverRepo.GetUserMembership(ctx, currentUserID, serverID)
 	if err != nil {
 		return false, err
 	}
 	return membership != nil, nil
 }
 
-func (s *ServerService) UpdateServerName(currentUserID, serverID, newName string) error {
-	newName = html.EscapeString(strings.TrimSpace(newName))
-	if newName == "" {
-		return errors.New("server name cannot be empty")
-	}
-	if len(newName) < 3 || len(newName) > 100 {
-		return errors.New("server name must be between 3 and 100 characters")
+func (s *ServerService) UpdateServerName(ctx context.Context, currentUserID, serverID, newName string) error {
+	newName, err := validateServerName(newName)
+	if err != nil {
+		return err
 	}
 
-	_, err := s.serverRepo.GetServerByID(serverID)
+	_, err = s.serverRepo.GetServerByID(ctx, serverID)
 	if err != nil {
 		return err
 	}
 
-	membership, err := s.serverRepo.GetUserMembership(currentUserID, serverID)
+	membership, err := s.serverRepo.GetUserMembership(ctx, currentUserID, serverID)
 	if err != nil {
 		return err
 	}
 	if membership == nil {
-		return errors.New("you are not a member of this server")
+		return ErrNotMember
 	}
 
 	if membership.Role != "owner" && membership.Role != "admin" {
-		return errors.New("only server owner or admin can update the server name")
+		return apperr.Forbidden("only server owner or admin can update the server name")
 	}
 
-	err = s.serverRepo.UpdateServer(serverID, &models.Server{Name: newName})
+	if err := s.checkMFARequirement(ctx, membership); err != nil {
+		return err
+	}
+
+	err = s.serverRepo.UpdateServer(ctx, serverID, &models.Server{Name: newName})
 	if err != nil {
 		return err
 	}
-	return nil
+	return s.publishServerUpdated(ctx, serverID)
 }
 
-func (s *ServerService) DeleteServer(currentUserID, serverID string) error {
-	membership, err := s.serverRepo.GetUserMembership(currentUserID, serverID)
+func (s *ServerService) DeleteServer(ctx context.Context, currentUserID, serverID string) error {
+	membership, err := s.serverRepo.GetUserMembership(ctx, currentUserID, serverID)
 	if err != nil {
 		return err
 	}
 	if membership == nil {
-		return errors.New("user is not a member of server")
+		return ErrNotMember
 	}
 
 	if membership.Role != "owner" {
-		return errors.New("insufficient permissions")
+		return apperr.Forbidden("insufficient permissions")
 	}
 
-	err = s.serverRepo.DeleteServer(serverID)
+	if err := s.checkMFARequirement(ctx, membership); err != nil {
+		return err
+	}
+
+	err = s.serverRepo.DeleteServer(ctx, serverID)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s *ServerService) AddMember(currentUserID, serverID, targetUserID, role string) error {
-	callerMembership, err := s.serverRepo.GetUserMembership(currentUserID, serverID)
+func (s *ServerService) AddMember(ctx context.Context, currentUserID, serverID, targetUserID, role string) error {
+	callerMembership, err := s.serverRepo.GetUserMembership(ctx, currentUserID, serverID)
 	if err != nil {
 		return err
 	}
 	if callerMembership == nil {
-		return errors.New("you are not a member of this server")
+		return ErrNotMember
+	}
+
+	if err := s.checkMFARequirement(ctx, callerMembership); err != nil {
+		return err
 	}
 
-	targetUser, err := s.userRepo.GetUserByID(targetUserID)
+	if err := s.checkHostedHere(ctx, serverID); err != nil {
+		return err
+	}
+
+	targetUser, err := s.userRepo.GetUserByID(ctx, targetUserID)
 	if err != nil {
 		return err
 	}
 	if targetUser == nil {
-		return errors.New("target user not found")
+		return apperr.NotFound("target user not found")
+	}
+
+	if targetUser.IsBot {
+		return apperr.Invalid("bots must be added through the bot authorization endpoint")
+	}
+
+	if targetUser.Instance != "" {
+		return apperr.Invalid("users of other instances must join through an invite")
+	}
+
+	ban, err := s.serverRepo.GetBan(ctx, targetUserID, serverID)
+	if err != nil {
+		return err
+	}
+	if ban != nil {
+		return apperr.Forbidden("target user is banned from this server")
 	}
 
 	if currentUserID == targetUserID {
-		return errors.New("cannot add yourself as a member")
+		return apperr.Invalid("cannot add yourself as a member")
 	}
 
 	if role == "owner" {
-		return errors.New("there can only be one owner")
+		return apperr.Invalid("there can only be one owner")
 	}
 
 	validRoles := []string{"admin", "moderator", "member"}
 	if !slices.Contains(validRoles, role) {
-		return errors.New("invalid role; must be one of: admin, moderator, member")
+		return apperr.Invalid("invalid role; must be one of: admin, moderator, member")
 	}
 
-	targetMembership, err := s.serverRepo.GetUserMembership(targetUserID, serverID)
+	targetMembership, err := s.serverRepo.GetUserMembership(ctx, targetUserID, serverID)
 	if err != nil {
 		return err
 	}
 
 	if targetMembership != nil {
 		if !validPermissions(*callerMembership, *targetMembership) {
-			return errors.New("insufficient permissions to add or promote this user")
+			return apperr.Forbidden("insufficient permissions to add or promote this user")
 		}
 	} else {
 		if role == "admin" && callerMembership.Role != "owner" {
-			return errors.New("only the server owner can invite admins")
+			return apperr.Forbidden("only the server owner can invite admins")
 		}
 	}
 
-	err = s.serverRepo.AddUserToServer(targetUserID, serverID, role)
+	err = s.serverRepo.AddUserToServer(ctx, targetUserID, serverID, role)
 	if err != nil {
 		return err
 	}
 
+	if targetMembership != nil {
+		s.events.Publish(events.MemberRoleChanged, serverID, events.MemberData{
+			UserID:       targetUserID,
+			Role:         role,
+			PreviousRole: targetMembership.Role,
+		})
+	} else {
+		s.events.Publish(events.MemberJoined, serverID, events.MemberData{UserID: targetUserID, Role: role})
+	}
+
 	return nil
 }
 
-func (s *ServerService) RemoveMember(currentUserID, serverID, targetUserID string) error {
-	callerMembership, err := s.serverRepo.GetUserMembership(currentUserID, serverID)
+func (s *ServerService) RemoveMember(ctx context.Context, currentUserID, serverID, targetUserID string) error {
+	callerMembership, err := s.serverRepo.GetUserMembership(ctx, currentUserID, serverID)
 	if err != nil {
 		return err
 	}
 	if callerMembership == nil {
-		return errors.New("you are not a member of this server")
+		return ErrNotMember
 	}
 
-	targetUser, err := s.userRepo.GetUserByID(targetUserID)
+	targetUser, err := s.userRepo.GetUserByID(ctx, targetUserID)
 	if err != nil {
 		return err
 	}
 	if targetUser == nil {
-		return errors.New("target user not found")
+		return apperr.NotFound("target user not found")
 	}
 
-	targetMembership, err := s.serverRepo.GetUserMembership(targetUserID, serverID)
+	targetMembership, err := s.serverRepo.GetUserMembership(ctx, targetUserID, serverID)
 	if err != nil {
 		return err
 	}
 
 	if targetMembership == nil {
-		return errors.New("target user is not a member of this server")
+		return apperr.NotFound("target user is not a member of this server")
 	}
 
 	if targetMembership.Role == "owner" {
-		return errors.New("the server owner cannot be removed")
+		return apperr.Invalid("the server owner cannot be removed")
 	}
 
 	if currentUserID == targetUserID && callerMembership.Role == "owner" {
-		return errors.New("the server owner cannot remove themselves")
+		return apperr.Invalid("the server owner cannot remove themselves")
 	}
 
 	if !validPermissions(*callerMembership, *targetMembership) {
-		return errors.New("insufficient permissions to remove this member")
+		return apperr.Forbidden("insufficient permissions to remove this member")
+	}
+
+	if currentUserID != targetUserID {
+		if err := s.checkMFARequirement(ctx, callerMembership); err != nil {
+			return err
+		}
 	}
 
-	err = s.serverRepo.RemoveUserFromServer(targetUserID, serverID)
+	err = s.serverRepo.RemoveUserFromServer(ctx, targetUserID, serverID)
 	if err != nil {
 		return err
 	}
 
+	s.events.Publish(events.MemberLeft, serverID, events.MemberData{
+		UserID: targetUserID,
+		Role:   targetMembership.Role,
+		Bot:    targetUser.IsBot,
+	})
+
 	return nil
 }
 
-func (s *ServerService) ChangeMemberRole(currentUserID, serverID, targetUserID, role string) error {
-	callerMembership, err := s.serverRepo.GetUserMembership(currentUserID, serverID)
+func (s *ServerService) ChangeMemberRole(ctx context.Context, currentUserID, serverID, targetUserID, role string) error {
+	callerMembership, err := s.serverRepo.GetUserMembership(ctx, currentUserID, serverID)
 	if err != nil {
 		return err
 	}
 	if callerMembership == nil {
-		return errors.New("you are not a member of this server")
+		return ErrNotMember
 	}
 
-	targetMembership, err := s.serverRepo.GetUserMembership(targetUserID, serverID)
+	targetMembership, err := s.serverRepo.GetUserMembership(ctx, targetUserID, serverID)
 	if err != nil {
 		return err
 	}
 	if targetMembership == nil {
-		return errors.New("target user is not a member of this server")
+		return apperr.NotFound("target user is not a member of this server")
 	}
 
 	if currentUserID == targetUserID && callerMembership.Role == "owner" {
-		return errors.New("the server owner cannot change their own role through this endpoint")
+		return apperr.Invalid("the server owner cannot change their own role through this endpoint")
 	}
 
 	if !validPermissions(*callerMembership, *targetMembership) {
-		return errors.New("insufficient permissions to change this user's role")
+		return apperr.Forbidden("insufficient permissions to change this user's role")
+	}
+
+	if err := s.checkMFARequirement(ctx, callerMembership); err != nil {
+		return err
 	}
 
 	if role == "owner" {
-		return errors.New("ownership cannot be delegated through role changes; use a dedicated transfer endpoint")
+		return apperr.Invalid("ownership cannot be delegated through role changes; use a dedicated transfer endpoint")
+	}
+
+	validRoles := []string{"admin", "moderator", "member"}
+	if !slices.Contains(validRoles, role) {
+		return apperr.Invalid("invalid role; must be one of: admin, moderator, member")
+	}
+
+	err = s.serverRepo.UpdateUserRoleInServer(ctx, targetUserID, serverID, role)
+	if err != nil {
+		return err
+	}
+
+	s.events.Publish(events.MemberRoleChanged, serverID, events.MemberData{
+		UserID:       targetUserID,
+		Role:         role,
+		PreviousRole: targetMembership.Role,
+	})
+
+	return nil
+}
+
+// AuthorizeBot adds an application's bot to a server with the role the
+// caller chooses. Only owners and admins may add bots, only the owner may
+// grant admin, and private applications can only be added by their owner.
+func (s *ServerService) AuthorizeBot(ctx context.Context, currentUserID, serverID, applicationID, role string) error {
+	callerMembership, err := s.serverRepo.GetUserMembership(ctx, currentUserID, serverID)
+	if err != nil {
+		return err
+	}
+	if callerMembership == nil {
+		return ErrNotMember
+	}
+
+	if callerMembership.Role != "owner" && callerMembership.Role != "admin" {
+		return apperr.Forbidden("insufficient permissions: only the server owner or an admin can add bots")
+	}
+
+	if err := s.checkMFARequirement(ctx, callerMembership); err != nil {
+		return err
+	}
+
+	if err := s.checkHostedHere(ctx, serverID); err != nil {
+		return err
+	}
+
+	app, err := s.appRepo.GetApplicationByID(ctx, applicationID)
+	if err != nil {
+		return err
+	}
+	if app == nil || (!app.Public && app.OwnerID != currentUserID) {
+		return apperr.NotFound("application not found")
 	}
 
 	validRoles := []string{"admin", "moderator", "member"}
 	if !slices.Contains(validRoles, role) {
-		return errors.New("invalid role; must be one of: admin, moderator, member")
+		return apperr.Invalid("invalid role; must be one of: admin, moderator, member")
+	}
+
+	if role == "admin" && callerMembership.Role != "owner" {
+		return apperr.Forbidden("insufficient permissions: only the server owner can grant a bot admin")
+	}
+
+	existing, err := s.serverRepo.GetUserMembership(ctx, app.BotID, serverID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return apperr.Conflict("bot is already a member of this server")
+	}
+
+	ban, err := s.serverRepo.GetBan(ctx, app.BotID, serverID)
+	if err != nil {
+		return err
+	}
+	if ban != nil {
+		return apperr.Forbidden("bot is banned from this server")
+	}
+
+	if err := s.serverRepo.AddUserToServer(ctx, app.BotID, serverID, role); err != nil {
+		return err
+	}
+
+	s.events.Publish(events.MemberJoined, serverID, events.MemberData{UserID: app.BotID, Role: role, Bot: true})
+	return nil
+}
+
+// SetMFARequirement lets the owner require two-factor authentication for
+// the server's moderators, admins and owner.
+func (s *ServerService) SetMFARequirement(ctx context.Context, currentUserID, serverID string, required bool) error {
+	membership, err := s.serverRepo.GetUserMembership(ctx, currentUserID, serverID)
+	if err != nil {
+		return err
+	}
+	if membership == nil {
+		return ErrNotMember
+	}
+
+	if membership.Role != "owner" {
+		return apperr.Forbidden("insufficient permissions: only the server owner can change the two-factor requirement")
+	}
+
+	if required {
+		owner, err := s.userRepo.GetUserByID(ctx, currentUserID)
+		if err != nil {
+			return err
+		}
+		if owner == nil || !owner.TOTPEnabled {
+			return apperr.Invalid("enable two-factor authentication on your account before requiring it for the server")
+		}
+	}
+
+	if err := s.serverRepo.UpdateServerMFARequirement(ctx, serverID, required); err != nil {
+		return err
+	}
+	return s.publishServerUpdated(ctx, serverID)
+}
+
+// BanMember removes a user from the server and keeps them from rejoining.
+// Moderators and above can ban anyone they could remove, and can also ban
+// users who are not members yet.
+func (s *ServerService) BanMember(ctx context.Context, currentUserID, serverID, targetUserID, reason string) error {
+	callerMembership, err := s.serverRepo.GetUserMembership(ctx, currentUserID, serverID)
+	if err != nil {
+		return err
+	}
+	if callerMembership == nil {
+		return ErrNotMember
+	}
+
+	if callerMembership.Role == "member" {
+		return apperr.Forbidden("insufficient permissions to ban members")
+	}
+
+	if currentUserID == targetUserID {
+		return apperr.Invalid("cannot ban yourself")
 	}
 
-	err = s.serverRepo.UpdateUserRoleInServer(targetUserID, serverID, role)
+	reason = html.EscapeString(strings.TrimSpace(reason))
+	if len(reason) > 512 {
+		return apperr.Invalid("ban reason must be at most 512 characters")
+	}
+
+	targetUser, err := s.userRepo.GetUserByID(ctx, targetUserID)
+	if err != nil {
+		return err
+	}
+	if targetUser == nil {
+		return apperr.NotFound("target user not found")
+	}
+
+	targetMembership, err := s.serverRepo.GetUserMembership(ctx, targetUserID, serverID)
 	if err != nil {
 		return err
 	}
 
+	if targetMembership != nil {
+		if targetMembership.Role == "owner" {
+			return apperr.Invalid("the server owner cannot be banned")
+		}
+		if !validPermissions(*callerMembership, *targetMembership) {
+			return apperr.Forbidden("insufficient permissions to ban this member")
+		}
+	}
+
+	if err := s.checkMFARequirement(ctx, callerMembership); err != nil {
+		return err
+	}
+
+	existing, err := s.serverRepo.GetBan(ctx, targetUserID, serverID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return apperr.Conflict("user is already banned from this server")
+	}
+
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		if targetMembership != nil {
+			if err := s.serverRepo.RemoveUserFromServer(ctx, targetUserID, serverID); err != nil {
+				return err
+			}
+		}
+
+		return s.serverRepo.CreateBan(ctx, &models.ServerBan{
+			UserID:   targetUserID,
+			ServerID: serverID,
+			BannedBy: currentUserID,
+			Reason:   reason,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	if targetMembership != nil {
+		s.events.Publish(events.MemberLeft, serverID, events.MemberData{
+			UserID: targetUserID,
+			Role:   targetMembership.Role,
+			Bot:    targetUser.IsBot,
+		})
+	}
 	return nil
 }
+
+func (s *ServerService) UnbanMember(ctx context.Context, currentUserID, serverID, targetUserID string) error {
+	callerMembership, err := s.serverRepo.GetUserMembership(ctx, currentUserID, serverID)
+	if err != nil {
+		return err
+	}
+	if callerMembership == nil {
+		return ErrNotMember
+	}
+
+	if callerMembership.Role == "member" {
+		return apperr.Forbidden("insufficient permissions to lift bans")
+	}
+
+	if err := s.checkMFARequirement(ctx, callerMembership); err != nil {
+		return err
+	}
+
+	return s.serverRepo.DeleteBan(ctx, targetUserID, serverID)
+}
+
+func (s *ServerService) ListBans(ctx context.Context, currentUserID, serverID string) ([]*models.ServerBan, error) {
+	callerMembership, err := s.serverRepo.GetUserMembership(ctx, currentUserID, serverID)
+	if err != nil {
+		return nil, err
+	}
+	if callerMembership == nil {
+		return nil, ErrNotMember
+	}
+
+	if callerMembership.Role == "member" {
+		return nil, apperr.Forbidden("insufficient permissions to view bans")
+	}
+
+	bans, err := s.serverRepo.GetBansByServer(ctx, serverID)
+	if err != nil {
+		return nil, err
+	}
+	if bans == nil {
+		bans = []*models.ServerBan{}
+	}
+	return bans, nil
+}
+
+// TimeoutMember stops a member from posting for duration. A zero duration
+// lifts an existing timeout.
+func (s *ServerService) TimeoutMember(ctx context.Context, currentUserID, serverID, targetUserID string, duration time.Duration) error {
+	if duration < 0 || duration > maxTimeout {
+		return apperr.Invalid("timeout must be between 0 and 28 days")
+	}
+
+	callerMembership, err := s.serverRepo.GetUserMembership(ctx, currentUserID, serverID)
+	if err != nil {
+		return err
+	}
+	if callerMembership == nil {
+		return ErrNotMember
+	}
+
+	if currentUserID == targetUserID {
+		return apperr.Invalid("cannot time out yourself")
+	}
+
+	targetMembership, err := s.serverRepo.GetUserMembership(ctx, targetUserID, serverID)
+	if err != nil {
+		return err
+	}
+	if targetMembership == nil {
+		return apperr.NotFound("target user is not a member of this server")
+	}
+
+	if targetMembership.Role == "owner" {
+		return apperr.Invalid("the server owner cannot be timed out")
+	}
+
+	if !validPermissions(*callerMembership, *targetMembership) {
+		return apperr.Forbidden("insufficient permissions to time out this member")
+	}
+
+	if err := s.checkMFARequirement(ctx, callerMembership); err != nil {
+		return err
+	}
+
+	var until *time.Time
+	if duration > 0 {
+		t := time.Now().Add(duration).UTC()
+		until = &t
+	}
+
+	return s.serverRepo.SetMemberTimeout(ctx, targetUserID, serverID, until)
+}
+
+// checkTimeout rejects members whose timeout has not run out yet.
+func checkTimeout(membership *models.UserServer) error {
+	if membership.TimeoutUntil != nil && membership.TimeoutUntil.After(time.Now()) {
+		return apperr.Forbidden("insufficient permissions: you are timed out in this server until %s", membership.TimeoutUntil.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+const inviteAlphabet = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+func generateInviteCode() (string, error) {
+	buf := make([]byte, inviteCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i, b := range buf {
+		buf[i] = inviteAlphabet[int(b)%len(inviteAlphabet)]
+	}
+	return string(buf), nil
+}
+
+// CreateInvite creates an invite any member can hand out. maxUses of zero
+// means unlimited; maxAge of zero means the invite never expires.
+func (s *ServerService) CreateInvite(ctx context.Context, currentUserID, serverID string, maxUses int, maxAge time.Duration) (*models.Invite, error) {
+	membership, err := s.serverRepo.GetUserMembership(ctx, currentUserID, serverID)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil {
+		return nil, ErrNotMember
+	}
+
+	if err := s.checkHostedHere(ctx, serverID); err != nil {
+		return nil, err
+	}
+
+	if maxUses < 0 || maxUses > 100 {
+		return nil, apperr.Invalid("max uses must be between 0 and 100")
+	}
+	if maxAge < 0 || maxAge > maxInviteAge {
+		return nil, apperr.Invalid("invite lifetime must be between 0 and 7 days")
+	}
+
+	code, err := generateInviteCode()
+	if err != nil {
+		return nil, err
+	}
+
+	invite := &models.Invite{
+		Code:      code,
+		ServerID:  serverID,
+		CreatedBy: currentUserID,
+		MaxUses:   maxUses,
+	}
+	if maxAge > 0 {
+		expires := time.Now().Add(maxAge).UTC()
+		invite.ExpiresAt = &expires
+	}
+
+	if err := s.inviteRepo.Create(ctx, invite); err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+// JoinInvite adds the current user to the invite's server as a member.
+func (s *ServerService) JoinInvite(ctx context.Context, currentUserID, code string) (*models.Server, error) {
+	invite, err := s.inviteRepo.GetInviteByCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if invite == nil {
+		return nil, apperr.NotFound("invite not found")
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, currentUserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, apperr.NotFound("user not found")
+	}
+	if user.IsBot {
+		return nil, apperr.Invalid("bots must be added through the bot authorization endpoint")
+	}
+
+	existing, err := s.serverRepo.GetUserMembership(ctx, currentUserID, invite.ServerID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, apperr.Conflict("you are already a member of this server")
+	}
+
+	ban, err := s.serverRepo.GetBan(ctx, currentUserID, invite.ServerID)
+	if err != nil {
+		return nil, err
+	}
+	if ban != nil {
+		return nil, apperr.Forbidden("you are banned from this server")
+	}
+
+	// A use of the invite only counts if the user joins.
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		used, err := s.inviteRepo.UseInvite(ctx, code, time.Now())
+		if err != nil {
+			return err
+		}
+		if !used {
+			return apperr.NotFound("invite not found or expired")
+		}
+
+		return s.serverRepo.AddUserToServer(ctx, currentUserID, invite.ServerID, "member")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.events.Publish(events.MemberJoined, invite.ServerID, events.MemberData{UserID: currentUserID, Role: "member"})
+
+	return s.serverRepo.GetServerByID(ctx, invite.ServerID)
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	sessionRepo "rio/internal/repository/session"
+	tokenRepo "rio/internal/repository/token"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// sessionCheckInterval bounds how often an active session is re-read from
+// the repository and how often its last-seen timestamp is written back.
+// Sessions revoked through this instance are evicted from the cache
+// immediately; revocations made by another instance take effect within
+// one interval.
+const sessionCheckInterval = 30 * time.Second
+
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+type SessionService struct {
+	repo      sessionRepo.SessionRepository
+	tokenRepo tokenRepo.TokenRepository
+
+	mu      sync.Mutex
+	checked map[string]time.Time
+}
+
+func NewSessionService(
+	repo sessionRepo.SessionRepository,
+	tRepo tokenRepo.TokenRepository,
+) *SessionService {
+	return &SessionService{
+		repo:      repo,
+		tokenRepo: tRepo,
+		checked:   make(map[string]time.Time),
+	}
+}
+
+func deviceNameFromUserAgent(ua string) string {
+	if strings.TrimSpace(ua) == "" {
+		return "Unknown device"
+	}
+
+	browser := ""
+	switch {
+	case strings.Contains(ua, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(ua, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "Safari/"):
+		browser = "Safari"
+	case strings.HasPrefix(ua, "curl/"):
+		browser = "curl"
+	}
+
+	platform := ""
+	switch {
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"):
+		platform = "iOS"
+	case strings.Contains(ua, "Android"):
+		platform = "Android"
+	case strings.Contains(ua, "Windows"):
+		platform = "Windows"
+	case strings.Contains(ua, "Mac OS X"), strings.Contains(ua, "Macintosh"):
+		platform = "macOS"
+	case strings.Contains(ua, "Linux"):
+		platform = "Linux"
+	}
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	}
+
+	if len(ua) > 64 {
+		return ua[:64]
+	}
+	return ua
+}
+
+func (s *SessionService) Start(ctx context.Context, userID string, client ClientInfo) (*models.Session, error) {
+	userAgent := client.UserAgent
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+
+	session := &models.Session{
+		ULID:       ulid.Make().String(),
+		UserID:     userID,
+		DeviceName: deviceNameFromUserAgent(client.UserAgent),
+		UserAgent:  userAgent,
+		IP:         client.IP,
+		LastSeenAt: time.Now(),
+	}
+
+	if err := s.repo.Create(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (s *SessionService) ListSessions(ctx context.Context, currentUserID, currentSessionID string) ([]*models.Session, error) {
+	sessions, err := s.repo.GetActiveSessionsByUser(ctx, currentUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, session := range sessions {
+		session.Current = session.ULID == currentSessionID
+	}
+	return sessions, nil
+}
+
+// RevokeSession ends one of the user's sessions, including every refresh
+// token issued for it.
+func (s *SessionService) RevokeSession(ctx context.Context, currentUserID, sessionID string) error {
+	session, err := s.repo.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.UserID != currentUserID {
+		return apperr.NotFound("session not found")
+	}
+
+	return s.revoke(ctx, sessionID)
+}
+
+func (s *SessionService) revoke(ctx context.Context, sessionID string) error {
+	if err := s.repo.RevokeSession(ctx, sessionID); err != nil {
+		return err
+	}
+	s.forget(sessionID)
+
+	return s.tokenRepo.RevokeFamily(ctx, sessionID)
+}
+
+// RevokeOtherSessions logs the user out everywhere except the session making
+// the request.
+func (s *SessionService) RevokeOtherSessions(ctx context.Context, currentUserID, currentSessionID string) error {
+	ids, err := s.repo.RevokeUserSessionsExcept(ctx, currentUserID, currentSessionID)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		s.forget(id)
+		if err := s.tokenRepo.RevokeFamily(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckSession reports whether the session is still active and records
+// activity on it. Repository reads and last-seen writes are rate limited by
+// sessionCheckInterval so the hot path is usually a map lookup.
+func (s *SessionService) CheckSession(ctx context.Context, sessionID string) error {
+	if sessionID == "" {
+		return apperr.Unauthorized("token is not bound to a session")
+	}
+
+	now := time.Now()
+
+	s.mu.Lock()
+	last, ok := s.checked[sessionID]
+	s.mu.Unlock()
+
+	if ok && now.Sub(last) < sessionCheckInterval {
+		return nil
+	}
+
+	session, err := s.repo.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.RevokedAt != nil {
+		s.forget(sessionID)
+		return apperr.Unauthorized("session has been revoked")
+	}
+
+	if err := s.repo.TouchSession(ctx, sessionID, now); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if len(s.checked) > 10000 {
+		for id, at := range s.checked {
+			if now.Sub(at) >= sessionCheckInterval {
+				delete(s.checked, id)
+			}
+		}
+	}
+	s.checked[sessionID] = now
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *SessionService) forget(sessionID string) {
+	s.mu.Lock()
+	delete(s.checked, sessionID)
+	s.mu.Unlock()
+}
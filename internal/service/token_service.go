@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	tokenRepo "rio/internal/repository/token"
+	unitOfWork "rio/internal/repository/unitofwork"
+	"rio/utils/token"
+
+	"github.com/oklog/ulid/v2"
+)
+
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TokenService struct {
+	repo     tokenRepo.TokenRepository
+	sessions *SessionService
+	uow      unitOfWork.UnitOfWork
+}
+
+func NewTokenService(repo tokenRepo.TokenRepository, sessions *SessionService, uow unitOfWork.UnitOfWork) *TokenService {
+	return &TokenService{repo: repo, sessions: sessions, uow: uow}
+}
+
+// StartSession signs userID in: it starts a session for client and issues
+// its first token pair. Either both are stored or neither is, so a failed
+// login leaves no session without tokens behind.
+func (s *TokenService) StartSession(ctx context.Context, userID string, client ClientInfo) (*TokenPair, error) {
+	var pair *TokenPair
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		session, err := s.sessions.Start(ctx, userID, client)
+		if err != nil {
+			return err
+		}
+		pair, err = s.IssueTokens(ctx, userID, session.ULID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// IssueTokens starts a new refresh token family for a freshly created
+// session. The family ID is the session ID, so revoking the session revokes
+// every refresh token issued for it.
+func (s *TokenService) IssueTokens(ctx context.Context, userID, sessionID string) (*TokenPair, error) {
+	return s.issue(ctx, userID, sessionID, nil)
+}
+
+func (s *TokenService) issue(ctx context.Context, userID, sessionID string, previous *models.RefreshToken) (*TokenPair, error) {
+	accessToken, err := token.GenerateToken(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	rawRefresh, expiresAt, err := token.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	refresh := &models.RefreshToken{
+		ULID:      ulid.Make().String(),
+		UserID:    userID,
+		FamilyID:  sessionID,
+		TokenHash: token.HashToken(rawRefresh),
+		ExpiresAt: expiresAt,
+	}
+
+	if previous == nil {
+		err = s.repo.CreateRefreshToken(ctx, refresh)
+	} else {
+		err = s.repo.RotateRefreshToken(ctx, previous, refresh)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{AccessToken: accessToken, RefreshToken: rawRefresh}, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair. Presenting a token
+// that was already rotated ends its session, since either the client or an
+// attacker is holding a stolen copy.
+func (s *TokenService) Refresh(ctx context.Context, rawRefresh string) (*TokenPair, error) {
+	current, err := s.repo.FindRefreshTokenByHash(ctx, token.HashToken(rawRefresh))
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, apperr.Unauthorized("invalid refresh token")
+	}
+
+	if current.RevokedAt != nil {
+		if current.ReplacedBy == "" {
+			return nil, apperr.Unauthorized("refresh token has been revoked")
+		}
+		return nil, s.reuseDetected(ctx, current)
+	}
+
+	if time.Now().After(current.ExpiresAt) {
+		return nil, apperr.Unauthorized("refresh token has expired")
+	}
+
+	if err := s.sessions.CheckSession(ctx, current.FamilyID); err != nil {
+		return nil, err
+	}
+
+	pair, err := s.issue(ctx, current.UserID, current.FamilyID, current)
+	if errors.Is(err, tokenRepo.ErrRefreshTokenRevoked) {
+		return nil, s.reuseDetected(ctx, current)
+	}
+	return pair, err
+}
+
+func (s *TokenService) reuseDetected(ctx context.Context, replayed *models.RefreshToken) error {
+	if err := s.sessions.revoke(ctx, replayed.FamilyID); err != nil {
+		if err := s.repo.RevokeFamily(ctx, replayed.FamilyID); err != nil {
+			return err
+		}
+	}
+	return apperr.Unauthorized("refresh token reuse detected; the session has been revoked")
+}
+
+// Logout ends the session the access token belongs to and denylists the
+// token itself until it would have expired anyway.
+func (s *TokenService) Logout(ctx context.Context, claims *token.Claims) error {
+	if claims.ID != "" && claims.ExpiresAt != nil {
+		if err := s.repo.RevokeAccessToken(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+			return err
+		}
+	}
+
+	if claims.SessionID != "" {
+		if err := s.sessions.RevokeSession(ctx, claims.Subject, claims.SessionID); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.PurgeExpiredRevocations(ctx, time.Now())
+}
+
+func (s *TokenService) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	if jti == "" {
+		return false, nil
+	}
+	return s.repo.IsAccessTokenRevoked(ctx, jti)
+}
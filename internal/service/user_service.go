@@ -1,10 +1,13 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"html"
+	"net/mail"
 	"strings"
 
+	"rio/internal/apperr"
 	"rio/internal/models"
 	repository "rio/internal/repository/user"
 	"rio/utils/token"
@@ -15,32 +18,48 @@ import (
 )
 
 type UserService struct {
-	repo repository.UserRepository
+	repo     repository.UserRepository
+	sessions *SessionService
+	tokens   *TokenService
+	guard    *LoginGuard
+	policy   PasswordPolicy
 }
 
 func VerifyPassword(password, hashedPassword string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
 
-func NewUserService(repo repository.UserRepository) *UserService {
-	return &UserService{repo: repo}
+func NewUserService(
+	repo repository.UserRepository,
+	sessions *SessionService,
+	tokens *TokenService,
+	guard *LoginGuard,
+	policy PasswordPolicy,
+) *UserService {
+	return &UserService{
+		repo:     repo,
+		sessions: sessions,
+		tokens:   tokens,
+		guard:    guard,
+		policy:   policy,
+	}
 }
 
 func validateUsername(username *string) error {
 	clean := html.EscapeString(strings.TrimSpace(*username))
 	if clean == "" {
-		return errors.New("username cannot be empty")
+		return apperr.Invalid("username cannot be empty")
 	}
 
 	if len(clean) < 3 || len(clean) > 25 {
-		return errors.New("username must be between 3 and 25 characters")
+		return apperr.Invalid("username must be between 3 and 25 characters")
 	}
 
 	first := clean[0]
 	last := clean[len(clean)-1]
 
 	if first == '_' || last == '_' {
-		return errors.New("username cannot start or end with an underscore")
+		return apperr.Invalid("username cannot start or end with an underscore")
 	}
 
 	for _, ch := range clean {
@@ -48,7 +67,7 @@ func validateUsername(username *string) error {
 			(ch >= 'A' && ch <= 'Z') ||
 			(ch >= '0' && ch <= '9') ||
 			ch == '_' || ch == '-') {
-			return errors.New("username can only contain letters, numbers, underscores, and hyphens")
+			return apperr.Invalid("username can only contain letters, numbers, underscores, and hyphens")
 		}
 	}
 
@@ -57,40 +76,114 @@ func validateUsername(username *string) error {
 	return nil
 }
 
-func (s *UserService) LoginCheck(username, password string) (string, error) {
-	u, err := s.repo.FindByUsername(username)
+// LoginCheck verifies a username and password. Logins are refused with a
+// *LoginThrottledError after repeated failures, and every outcome is
+// recorded in the security log. When the account has two-factor
+// authentication enabled the result carries an MFA ticket instead of tokens.
+func (s *UserService) LoginCheck(ctx context.Context, username, password string, client ClientInfo) (*LoginResult, error) {
+	u, err := s.repo.FindByUsername(ctx, username)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	if u == nil {
-		return "", errors.New("invalid username or password")
+
+	userID := ""
+	if u != nil {
+		userID = u.ULID
+	}
+
+	if err := s.guard.Check(ctx, username, client); err != nil {
+		var throttled *LoginThrottledError
+		if errors.As(err, &throttled) {
+			if err := s.guard.Record(ctx, userID, username, LoginMethodPassword, client, false, models.LoginReasonThrottled); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+
+	// Bots authenticate with API tokens and never by password.
+	if u == nil || u.IsBot {
+		// Spend the same time as a real comparison so response times do
+		// not reveal which usernames exist.
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		if err := s.guard.Record(ctx, "", username, LoginMethodPassword, client, false, models.LoginReasonUnknownUser); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
 	}
 
 	if err := VerifyPassword(password, u.Password); err != nil {
-		return "", errors.New("invalid username or password")
+		if err := s.guard.Record(ctx, u.ULID, username, LoginMethodPassword, client, false, models.LoginReasonBadPassword); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
 	}
 
-	return token.GenerateToken(u.ULID)
-}
+	// The login only succeeds once the second factor has been checked, so
+	// nothing is recorded yet.
+	if u.TOTPEnabled {
+		ticket, err := token.GenerateMFATicket(u.ULID)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{MFARequired: true, MFATicket: ticket}, nil
+	}
 
-func (s *UserService) Register(username, password string) (*models.User, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err := s.guard.Record(ctx, u.ULID, username, LoginMethodPassword, client, true, ""); err != nil {
+		return nil, err
+	}
 
+	tokens, err := s.tokens.StartSession(ctx, u.ULID, client)
 	if err != nil {
 		return nil, err
 	}
+	return &LoginResult{TokenPair: tokens}, nil
+}
 
+// SecurityLog returns the recent login attempts against userID's account.
+func (s *UserService) SecurityLog(ctx context.Context, userID string) ([]*models.LoginAttempt, error) {
+	return s.guard.SecurityLog(ctx, userID)
+}
+
+func (s *UserService) Register(ctx context.Context, username, password, email string) (*models.User, error) {
 	if err := validateUsername(&username); err != nil {
 		return nil, err
 	}
 
+	if err := s.policy.Validate(password, username); err != nil {
+		return nil, err
+	}
+
+	email = strings.TrimSpace(email)
+	if email != "" {
+		addr, err := mail.ParseAddress(email)
+		if err != nil || addr.Address != email {
+			return nil, apperr.Invalid("email address is invalid")
+		}
+
+		existing, err := s.repo.FindByEmail(ctx, email)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, apperr.Conflict("email address is already in use")
+		}
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+
+	if err != nil {
+		return nil, err
+	}
+
 	user := &models.User{
 		ULID:     ulid.Make().String(),
 		Username: username,
 		Password: string(hashedPassword),
+		Email:    email,
 	}
 
-	if err := s.repo.Create(user); err != nil {
+	if err := s.repo.Create(ctx, user); err != nil {
 		return nil, err
 	}
 
@@ -98,23 +191,37 @@ func (s *UserService) Register(username, password string) (*models.User, error)
 }
 
 func (s *UserService) FindCurrentUser(c *gin.Context) (models.User, error) {
-	uid, err := token.ExtractTokenID(c)
-	if err != nil {
-		return models.User{}, err
+	// The auth middleware has already resolved the caller, whether it
+	// presented a user's access token or a bot's API token.
+	uid := c.GetString("user_id")
+	if uid == "" {
+		var err error
+		uid, err = token.ExtractTokenID(c)
+		if err != nil {
+			return models.User{}, apperr.Unauthorized("%w", err)
+		}
 	}
 
-	user, err := s.repo.GetUserByID(uid)
+	user, err := s.repo.GetUserByID(c.Request.Context(), uid)
 	if err != nil {
 		return models.User{}, err
 	}
+	if user == nil {
+		return models.User{}, apperr.NotFound("user not found")
+	}
 	user.Password = ""
 	return *user, nil
 }
 
-func (s *UserService) FindUser(username string) (*models.User, error) {
-	return s.repo.FindByUsername(username)
+func (s *UserService) FindUser(ctx context.Context, username string) (*models.User, error) {
+	return s.repo.FindByUsername(ctx, username)
+}
+
+// GetUser returns the user with the given ID, or nil if there is none.
+func (s *UserService) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	return s.repo.GetUserByID(ctx, userID)
 }
 
-func (s *UserService) GetAllUsers() ([]models.User, error) {
-	return s.repo.FindAll()
+func (s *UserService) GetAllUsers(ctx context.Context) ([]models.User, error) {
+	return s.repo.FindAll(ctx)
 }
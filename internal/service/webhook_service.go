@@ -0,0 +1,535 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	mathrand "math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/events"
+	"rio/internal/models"
+	serverRepo "rio/internal/repository/server"
+	webhookRepo "rio/internal/repository/webhook"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// Deliveries are attempted up to maxWebhookAttempts times. After a failed
+// attempt n the next one is scheduled webhookRetryBase*2^(n-1) later, with
+// up to 10% jitter, which spreads the attempts over roughly an hour. A
+// delivery whose attempts are exhausted is marked dead.
+const (
+	maxWebhookAttempts   = 8
+	webhookRetryBase     = 30 * time.Second
+	webhookTimeout       = outboundTimeout
+	webhookLease         = 2 * webhookTimeout
+	webhookPollInterval  = 5 * time.Second
+	webhookBatchSize     = 20
+	webhookConcurrency   = 8
+	maxWebhooksPerServer = 10
+	webhookDeliveryLimit = 100
+)
+
+// Headers sent with every delivery. The signature is
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>" keyed by the
+// webhook secret>"; receivers should recompute it and reject stale
+// timestamps.
+const (
+	WebhookEventHeader     = "X-Rio-Event"
+	WebhookDeliveryHeader  = "X-Rio-Delivery"
+	WebhookSignatureHeader = "X-Rio-Signature"
+)
+
+// WebhookWithSecret is returned when a webhook is created; it is the only
+// time the signing secret is shown.
+type WebhookWithSecret struct {
+	*models.Webhook
+	Secret string `json:"secret"`
+}
+
+type WebhookService struct {
+	repo       webhookRepo.WebhookRepository
+	serverRepo serverRepo.ServerRepository
+	client     *http.Client
+	insecure   bool
+
+	wake chan struct{}
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewWebhookService subscribes to bus and queues a delivery for every
+// matching webhook. allowInsecure permits plain-HTTP URLs and private or
+// loopback addresses, which is only meant for development and tests.
+func NewWebhookService(
+	repo webhookRepo.WebhookRepository,
+	sRepo serverRepo.ServerRepository,
+	bus *events.Bus,
+	allowInsecure bool,
+) *WebhookService {
+	s := &WebhookService{
+		repo:       repo,
+		serverRepo: sRepo,
+		client:     newOutboundClient(allowInsecure),
+		insecure:   allowInsecure,
+		wake:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+	}
+	bus.Subscribe(s.enqueue)
+	return s
+}
+
+func (s *WebhookService) requireAdmin(ctx context.Context, currentUserID, serverID string) error {
+	membership, err := s.serverRepo.GetUserMembership(ctx, currentUserID, serverID)
+	if err != nil {
+		return err
+	}
+	if membership == nil {
+		return ErrNotMember
+	}
+	if membership.Role != "owner" && membership.Role != "admin" {
+		return apperr.Forbidden("insufficient permissions: only the server owner or an admin can manage webhooks")
+	}
+	return nil
+}
+
+func (s *WebhookService) validateURL(raw string) (string, error) {
+	return validateEndpointURL("webhook", raw, s.insecure)
+}
+
+// validateEndpointURL checks a URL rio will POST to; kind names it in
+// errors.
+func validateEndpointURL(kind, raw string, allowInsecure bool) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return "", apperr.Invalid("%s URL is invalid", kind)
+	}
+	if u.Scheme != "https" && !(allowInsecure && u.Scheme == "http") {
+		return "", apperr.Invalid("%s URL must use https", kind)
+	}
+	if u.User != nil {
+		return "", apperr.Invalid("%s URL must not contain credentials", kind)
+	}
+	if len(u.String()) > 2048 {
+		return "", apperr.Invalid("%s URL must be at most 2048 characters", kind)
+	}
+	return u.String(), nil
+}
+
+func validateEventTypes(types []string) (string, error) {
+	if len(types) == 0 {
+		return "", apperr.Invalid("subscribe to at least one event type")
+	}
+
+	var clean []string
+	for _, t := range types {
+		t = strings.TrimSpace(t)
+		if !slices.Contains(events.Types, t) {
+			return "", apperr.Invalid("unknown event type %q; must be one of: %s", t, strings.Join(events.Types, ", "))
+		}
+		if !slices.Contains(clean, t) {
+			clean = append(clean, t)
+		}
+	}
+	return strings.Join(clean, ","), nil
+}
+
+func withEventTypes(w *models.Webhook) *models.Webhook {
+	w.EventTypes = strings.Split(w.Events, ",")
+	return w
+}
+
+func (s *WebhookService) CreateWebhook(ctx context.Context, currentUserID, serverID, rawURL string, eventTypes []string) (*WebhookWithSecret, error) {
+	if err := s.requireAdmin(ctx, currentUserID, serverID); err != nil {
+		return nil, err
+	}
+
+	target, err := s.validateURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	subscribed, err := validateEventTypes(eventTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.GetWebhooksByServer(ctx, serverID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxWebhooksPerServer {
+		return nil, apperr.Invalid("a server can have at most %d webhooks", maxWebhooksPerServer)
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	secret := hex.EncodeToString(buf)
+
+	webhook := &models.Webhook{
+		ULID:      ulid.Make().String(),
+		ServerID:  serverID,
+		URL:       target,
+		Secret:    secret,
+		Events:    subscribed,
+		Active:    true,
+		CreatedBy: currentUserID,
+	}
+	if err := s.repo.CreateWebhook(ctx, webhook); err != nil {
+		return nil, err
+	}
+
+	return &WebhookWithSecret{Webhook: withEventTypes(webhook), Secret: secret}, nil
+}
+
+func (s *WebhookService) ListWebhooks(ctx context.Context, currentUserID, serverID string) ([]*models.Webhook, error) {
+	if err := s.requireAdmin(ctx, currentUserID, serverID); err != nil {
+		return nil, err
+	}
+
+	webhooks, err := s.repo.GetWebhooksByServer(ctx, serverID)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*models.Webhook, 0, len(webhooks))
+	for _, w := range webhooks {
+		list = append(list, withEventTypes(w))
+	}
+	return list, nil
+}
+
+func (s *WebhookService) getWebhook(ctx context.Context, currentUserID, serverID, webhookID string) (*models.Webhook, error) {
+	if err := s.requireAdmin(ctx, currentUserID, serverID); err != nil {
+		return nil, err
+	}
+
+	webhook, err := s.repo.GetWebhookByID(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if webhook == nil || webhook.ServerID != serverID {
+		return nil, apperr.NotFound("webhook not found")
+	}
+	return webhook, nil
+}
+
+// UpdateWebhook changes any of the URL, subscribed events and active flag;
+// nil arguments are left as they are.
+func (s *WebhookService) UpdateWebhook(ctx context.Context, currentUserID, serverID, webhookID string, rawURL *string, eventTypes []string, active *bool) (*models.Webhook, error) {
+	webhook, err := s.getWebhook(ctx, currentUserID, serverID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	if rawURL != nil {
+		if webhook.URL, err = s.validateURL(*rawURL); err != nil {
+			return nil, err
+		}
+	}
+	if eventTypes != nil {
+		if webhook.Events, err = validateEventTypes(eventTypes); err != nil {
+			return nil, err
+		}
+	}
+	if active != nil {
+		webhook.Active = *active
+	}
+
+	if err := s.repo.UpdateWebhook(ctx, webhook); err != nil {
+		return nil, err
+	}
+	return withEventTypes(webhook), nil
+}
+
+func (s *WebhookService) DeleteWebhook(ctx context.Context, currentUserID, serverID, webhookID string) error {
+	if err := s.requireAdmin(ctx, currentUserID, serverID); err != nil {
+		return err
+	}
+	return s.repo.DeleteWebhook(ctx, serverID, webhookID)
+}
+
+// ListDeliveries returns a webhook's most recent deliveries. Pass status
+// "dead" for the dead-letter list.
+func (s *WebhookService) ListDeliveries(ctx context.Context, currentUserID, serverID, webhookID, status string) ([]*models.WebhookDelivery, error) {
+	if _, err := s.getWebhook(ctx, currentUserID, serverID, webhookID); err != nil {
+		return nil, err
+	}
+
+	switch status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliverySucceeded, models.WebhookDeliveryDead:
+	default:
+		return nil, apperr.Invalid("invalid status; must be one of: pending, succeeded, dead")
+	}
+
+	deliveries, err := s.repo.GetDeliveriesByWebhook(ctx, webhookID, status, webhookDeliveryLimit)
+	if err != nil {
+		return nil, err
+	}
+	if deliveries == nil {
+		deliveries = []*models.WebhookDelivery{}
+	}
+	return deliveries, nil
+}
+
+// Redeliver requeues a delivery with a fresh set of attempts.
+func (s *WebhookService) Redeliver(ctx context.Context, currentUserID, serverID, webhookID, deliveryID string) (*models.WebhookDelivery, error) {
+	if _, err := s.getWebhook(ctx, currentUserID, serverID, webhookID); err != nil {
+		return nil, err
+	}
+
+	delivery, err := s.repo.GetDeliveryByID(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery == nil || delivery.WebhookID != webhookID {
+		return nil, apperr.NotFound("delivery not found")
+	}
+	if delivery.Status == models.WebhookDeliveryPending {
+		return nil, apperr.Conflict("delivery is already queued")
+	}
+
+	now := time.Now()
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = &now
+	delivery.LastError = ""
+	if err := s.repo.UpdateDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+
+	s.nudge()
+	return delivery, nil
+}
+
+// enqueue records a pending delivery for each active webhook subscribed to
+// the event. Deliveries are persisted before the publishing request
+// returns, so events survive a restart.
+func (s *WebhookService) enqueue(e events.Event) {
+	if e.ServerID == "" {
+		return
+	}
+
+	ctx := context.Background()
+	webhooks, err := s.repo.GetWebhooksByServer(ctx, e.ServerID)
+	if err != nil {
+		log.Printf("webhooks: cannot load webhooks for server %s: %v", e.ServerID, err)
+		return
+	}
+
+	var payload []byte
+	queued := false
+	for _, w := range webhooks {
+		if !w.Active || !slices.Contains(strings.Split(w.Events, ","), e.Type) {
+			continue
+		}
+
+		if payload == nil {
+			if payload, err = json.Marshal(e); err != nil {
+				log.Printf("webhooks: cannot encode %s event: %v", e.Type, err)
+				return
+			}
+		}
+
+		now := time.Now()
+		delivery := &models.WebhookDelivery{
+			ULID:          ulid.Make().String(),
+			WebhookID:     w.ULID,
+			EventID:       e.ID,
+			EventType:     e.Type,
+			Payload:       string(payload),
+			Status:        models.WebhookDeliveryPending,
+			NextAttemptAt: &now,
+		}
+		if err := s.repo.CreateDelivery(ctx, delivery); err != nil {
+			log.Printf("webhooks: cannot queue delivery to %s: %v", w.ULID, err)
+			continue
+		}
+		queued = true
+	}
+
+	if queued {
+		s.nudge()
+	}
+}
+
+func (s *WebhookService) nudge() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the delivery worker until Stop is called. Several instances
+// may run workers against the same database; each delivery is claimed by
+// exactly one of them.
+func (s *WebhookService) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(webhookPollInterval)
+		defer ticker.Stop()
+
+		ctx := context.Background()
+		sem := make(chan struct{}, webhookConcurrency)
+		for {
+			s.deliverDue(ctx, sem)
+
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+		}
+	}()
+}
+
+// Stop stops the worker and waits for in-flight deliveries to finish.
+func (s *WebhookService) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+func (s *WebhookService) deliverDue(ctx context.Context, sem chan struct{}) {
+	now := time.Now()
+	due, err := s.repo.GetDueDeliveries(ctx, now, webhookBatchSize)
+	if err != nil {
+		log.Printf("webhooks: cannot load due deliveries: %v", err)
+		return
+	}
+
+	for _, d := range due {
+		claimed, err := s.repo.ClaimDelivery(ctx, d.ULID, now, now.Add(webhookLease))
+		if err != nil {
+			log.Printf("webhooks: cannot claim delivery %s: %v", d.ULID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		sem <- struct{}{}
+		s.wg.Add(1)
+		go func(d *models.WebhookDelivery) {
+			defer func() {
+				<-sem
+				s.wg.Done()
+			}()
+			s.attempt(ctx, d)
+		}(d)
+	}
+}
+
+func (s *WebhookService) attempt(ctx context.Context, d *models.WebhookDelivery) {
+	webhook, err := s.repo.GetWebhookByID(ctx, d.WebhookID)
+	if err != nil {
+		log.Printf("webhooks: cannot load webhook %s: %v", d.WebhookID, err)
+		return
+	}
+
+	d.Attempts++
+	switch {
+	case webhook == nil:
+		s.finish(ctx, d, 0, apperr.NotFound("webhook was deleted"), true)
+		return
+	case !webhook.Active:
+		s.finish(ctx, d, 0, apperr.Forbidden("webhook is disabled"), true)
+		return
+	}
+
+	status, err := s.send(webhook, d)
+	s.finish(ctx, d, status, err, false)
+}
+
+func signWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+func (s *WebhookService) send(webhook *models.Webhook, d *models.WebhookDelivery) (int, error) {
+	body := []byte(d.Payload)
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "rio-webhooks/1")
+	req.Header.Set(WebhookEventHeader, d.EventType)
+	req.Header.Set(WebhookDeliveryHeader, d.ULID)
+	req.Header.Set(WebhookSignatureHeader, signWebhookPayload(webhook.Secret, time.Now().Unix(), body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, apperr.Unavailable("endpoint responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// finish records the outcome of an attempt and schedules the next one if
+// the delivery failed and has attempts left.
+func (s *WebhookService) finish(ctx context.Context, d *models.WebhookDelivery, status int, deliveryErr error, final bool) {
+	now := time.Now()
+	d.LastStatusCode = status
+
+	switch {
+	case deliveryErr == nil:
+		d.Status = models.WebhookDeliverySucceeded
+		d.LastError = ""
+		d.NextAttemptAt = nil
+		d.DeliveredAt = &now
+
+	case final || d.Attempts >= maxWebhookAttempts:
+		d.Status = models.WebhookDeliveryDead
+		d.LastError = truncate(deliveryErr.Error(), 512)
+		d.NextAttemptAt = nil
+
+	default:
+		backoff := webhookRetryBase << (d.Attempts - 1)
+		backoff += time.Duration(mathrand.Int64N(int64(backoff)/10 + 1))
+		next := now.Add(backoff)
+
+		d.Status = models.WebhookDeliveryPending
+		d.LastError = truncate(deliveryErr.Error(), 512)
+		d.NextAttemptAt = &next
+	}
+
+	if err := s.repo.UpdateDelivery(ctx, d); err != nil {
+		log.Printf("webhooks: cannot record delivery %s: %v", d.ULID, err)
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
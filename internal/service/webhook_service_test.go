@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"rio/internal/events"
+	"rio/internal/models"
+	serverRepo "rio/internal/repository/server"
+	webhookRepo "rio/internal/repository/webhook"
+	"rio/internal/store"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// TestWebhookDeliveryRetriesAndDeadLetters delivers an event to a receiver
+// that always fails: every attempt must be signed with the webhook secret,
+// retried with a growing backoff and finally dead-lettered.
+func TestWebhookDeliveryRetriesAndDeadLetters(t *testing.T) {
+	const secret = "s3cret"
+
+	var mu sync.Mutex
+	var received int
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received++
+
+		body, _ := io.ReadAll(r.Body)
+		if err := checkWebhookSignature(r.Header.Get(WebhookSignatureHeader), secret, body); err != "" {
+			t.Errorf("attempt %d: %s", received, err)
+		}
+		if got := r.Header.Get(WebhookEventHeader); got != events.MessageCreated {
+			t.Errorf("%s is %q, want %q", WebhookEventHeader, got, events.MessageCreated)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	ctx := context.Background()
+	s := store.New()
+	repo := webhookRepo.NewInMemoryWebhookRepository(s)
+	bus := events.NewBus()
+	service := NewWebhookService(repo, serverRepo.NewInMemoryServerRepository(s), bus, true)
+
+	webhook := &models.Webhook{
+		ULID:     ulid.Make().String(),
+		ServerID: ulid.Make().String(),
+		URL:      receiver.URL,
+		Secret:   secret,
+		Events:   events.MessageCreated,
+		Active:   true,
+	}
+	if err := repo.CreateWebhook(ctx, webhook); err != nil {
+		t.Fatal(err)
+	}
+
+	bus.Publish(events.MessageCreated, webhook.ServerID, map[string]string{"content": "hi"})
+
+	queued, err := repo.GetDeliveriesByWebhook(ctx, webhook.ULID, models.WebhookDeliveryPending, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queued) != 1 {
+		t.Fatalf("%d deliveries queued, want 1", len(queued))
+	}
+	id := queued[0].ULID
+
+	var lastBackoff time.Duration
+	for attempt := 1; attempt <= maxWebhookAttempts; attempt++ {
+		d, err := repo.GetDeliveryByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		before := time.Now()
+		service.attempt(ctx, d)
+
+		d, err = repo.GetDeliveryByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.LastStatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("attempt %d: recorded status %d", attempt, d.LastStatusCode)
+		}
+
+		if attempt < maxWebhookAttempts {
+			if d.Status != models.WebhookDeliveryPending || d.NextAttemptAt == nil {
+				t.Fatalf("attempt %d: delivery is %s, want it retried", attempt, d.Status)
+			}
+			backoff := d.NextAttemptAt.Sub(before)
+			if backoff < webhookRetryBase || backoff <= lastBackoff {
+				t.Fatalf("attempt %d: retried after %s, following %s", attempt, backoff, lastBackoff)
+			}
+			lastBackoff = backoff
+			continue
+		}
+
+		if d.Status != models.WebhookDeliveryDead || d.NextAttemptAt != nil {
+			t.Fatalf("delivery is %s after %d attempts, want it dead", d.Status, attempt)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if received != maxWebhookAttempts {
+		t.Fatalf("receiver got %d attempts, want %d", received, maxWebhookAttempts)
+	}
+
+	dead, err := repo.GetDeliveriesByWebhook(ctx, webhook.ULID, models.WebhookDeliveryDead, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 || dead[0].ULID != id {
+		t.Fatalf("dead letters are %v, want %s", dead, id)
+	}
+}
+
+// checkWebhookSignature verifies a signature header as a receiver would,
+// returning what is wrong with it.
+func checkWebhookSignature(header, secret string, body []byte) string {
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+
+	t, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "signature has no timestamp: " + header
+	}
+	if age := time.Since(time.Unix(t, 0)); age < -time.Minute || age > time.Minute {
+		return "signature timestamp is " + age.String() + " old"
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	if !hmac.Equal([]byte(signature), []byte(hex.EncodeToString(mac.Sum(nil)))) {
+		return "signature does not match the body: " + header
+	}
+	return ""
+}
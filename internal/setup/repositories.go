@@ -0,0 +1,105 @@
+package setup
+
+import (
+	appRepo "rio/internal/repository/application"
+	attachmentRepo "rio/internal/repository/attachment"
+	channelRepo "rio/internal/repository/channel"
+	emailRepo "rio/internal/repository/channelemail"
+	commandRepo "rio/internal/repository/command"
+	hookRepo "rio/internal/repository/incomingwebhook"
+	interactionRepo "rio/internal/repository/interaction"
+	inviteRepo "rio/internal/repository/invite"
+	loginAttemptRepo "rio/internal/repository/loginattempt"
+	messageRepo "rio/internal/repository/message"
+	mfaRepo "rio/internal/repository/mfa"
+	passkeyRepo "rio/internal/repository/passkey"
+	resetRepo "rio/internal/repository/passwordreset"
+	serverRepo "rio/internal/repository/server"
+	sessionRepo "rio/internal/repository/session"
+	snowflakeRepo "rio/internal/repository/snowflake"
+	tokenRepo "rio/internal/repository/token"
+	unitOfWork "rio/internal/repository/unitofwork"
+	userRepo "rio/internal/repository/user"
+	webhookRepo "rio/internal/repository/webhook"
+	"rio/internal/store"
+)
+
+// repositories is every repository the services are built from, backed by
+// either the database or an in-memory store.
+type repositories struct {
+	uow unitOfWork.UnitOfWork
+
+	users         userRepo.UserRepository
+	servers       serverRepo.ServerRepository
+	invites       inviteRepo.InviteRepository
+	channels      channelRepo.ChannelRepository
+	messages      messageRepo.MessageRepository
+	attachments   attachmentRepo.AttachmentRepository
+	tokens        tokenRepo.TokenRepository
+	sessions      sessionRepo.SessionRepository
+	loginAttempts loginAttemptRepo.LoginAttemptRepository
+	mfa           mfaRepo.MFARepository
+	passkeys      passkeyRepo.PasskeyRepository
+	resets        resetRepo.PasswordResetRepository
+	applications  appRepo.ApplicationRepository
+	webhooks      webhookRepo.WebhookRepository
+	hooks         hookRepo.IncomingWebhookRepository
+	commands      commandRepo.CommandRepository
+	interactions  interactionRepo.InteractionRepository
+	emails        emailRepo.ChannelEmailRepository
+	snowflakes    snowflakeRepo.SnowflakeRepository
+}
+
+func newDBRepositories() *repositories {
+	return &repositories{
+		uow: unitOfWork.NewDBUnitOfWork(),
+
+		users:         userRepo.NewDBUserRepository(),
+		servers:       serverRepo.NewDBServerRepository(),
+		invites:       inviteRepo.NewDBInviteRepository(),
+		channels:      channelRepo.NewDBChannelRepository(),
+		messages:      messageRepo.NewDBMessageRepository(),
+		attachments:   attachmentRepo.NewDBAttachmentRepository(),
+		tokens:        tokenRepo.NewDBTokenRepository(),
+		sessions:      sessionRepo.NewDBSessionRepository(),
+		loginAttempts: loginAttemptRepo.NewDBLoginAttemptRepository(),
+		mfa:           mfaRepo.NewDBMFARepository(),
+		passkeys:      passkeyRepo.NewDBPasskeyRepository(),
+		resets:        resetRepo.NewDBPasswordResetRepository(),
+		applications:  appRepo.NewDBApplicationRepository(),
+		webhooks:      webhookRepo.NewDBWebhookRepository(),
+		hooks:         hookRepo.NewDBIncomingWebhookRepository(),
+		commands:      commandRepo.NewDBCommandRepository(),
+		interactions:  interactionRepo.NewDBInteractionRepository(),
+		emails:        emailRepo.NewDBChannelEmailRepository(),
+		snowflakes:    snowflakeRepo.NewDBSnowflakeRepository(),
+	}
+}
+
+// newInMemoryRepositories keeps everything in s, so it is lost when the
+// process exits.
+func newInMemoryRepositories(s *store.Store) *repositories {
+	return &repositories{
+		uow: unitOfWork.NewInMemoryUnitOfWork(s),
+
+		users:         userRepo.NewInMemoryUserRepository(s),
+		servers:       serverRepo.NewInMemoryServerRepository(s),
+		invites:       inviteRepo.NewInMemoryInviteRepository(s),
+		channels:      channelRepo.NewInMemoryChannelRepository(s),
+		messages:      messageRepo.NewInMemoryMessageRepository(s),
+		attachments:   attachmentRepo.NewInMemoryAttachmentRepository(s),
+		tokens:        tokenRepo.NewInMemoryTokenRepository(s),
+		sessions:      sessionRepo.NewInMemorySessionRepository(s),
+		loginAttempts: loginAttemptRepo.NewInMemoryLoginAttemptRepository(s),
+		mfa:           mfaRepo.NewInMemoryMFARepository(s),
+		passkeys:      passkeyRepo.NewInMemoryPasskeyRepository(s),
+		resets:        resetRepo.NewInMemoryPasswordResetRepository(s),
+		applications:  appRepo.NewInMemoryApplicationRepository(s),
+		webhooks:      webhookRepo.NewInMemoryWebhookRepository(s),
+		hooks:         hookRepo.NewInMemoryIncomingWebhookRepository(s),
+		commands:      commandRepo.NewInMemoryCommandRepository(s),
+		interactions:  interactionRepo.NewInMemoryInteractionRepository(s),
+		emails:        emailRepo.NewInMemoryChannelEmailRepository(s),
+		snowflakes:    snowflakeRepo.NewInMemorySnowflakeRepository(s),
+	}
+}
@@ -1,43 +1,243 @@
 package setup
 
 import (
+	"crypto/ed25519"
+	"crypto/tls"
+	"log"
+
+	"rio/internal/config"
 	"rio/internal/db"
+	"rio/internal/events"
 	"rio/internal/handlers"
-	serverRepo "rio/internal/repository/server"
-	userRepo "rio/internal/repository/user"
+	"rio/internal/irc"
+	"rio/internal/mailer"
+	"rio/internal/ratelimit"
 	"rio/internal/service"
+	"rio/internal/smtpd"
+	"rio/internal/store"
+	"rio/middlewares"
+	"rio/utils/httpsig"
+	"rio/utils/token"
 )
 
 type Dependencies struct {
-	UserHandler   *handlers.UserHandler
-	ServerHandler *handlers.ServerHandler
-	// ChannelHandler *handlers.ChannelHandler
-	// MessageHandler *handlers.MessageHandler
+	UserHandler     *handlers.UserHandler
+	ServerHandler   *handlers.ServerHandler
+	TokenHandler    *handlers.TokenHandler
+	TokenService    *service.TokenService
+	SessionHandler  *handlers.SessionHandler
+	SessionService  *service.SessionService
+	PasswordHandler *handlers.PasswordHandler
+	MFAHandler      *handlers.MFAHandler
+	PasskeyHandler  *handlers.PasskeyHandler
+	AppHandler      *handlers.ApplicationHandler
+	AppService      *service.ApplicationService
+	RateLimitStore  ratelimit.Store
+	RateLimits      map[string]ratelimit.Limit
+	Timeouts        middlewares.Timeouts
+	ChannelHandler  *handlers.ChannelHandler
+	MessageHandler  *handlers.MessageHandler
+	WebhookHandler  *handlers.WebhookHandler
+	WebhookService  *service.WebhookService
+
+	IncomingWebhookHandler *handlers.IncomingWebhookHandler
+	CommandHandler         *handlers.CommandHandler
+	GatewayHandler         *handlers.GatewayHandler
+
+	// IRCServer is nil unless IRC_ADDR is set.
+	IRCServer *irc.Server
+
+	ChannelEmailHandler *handlers.ChannelEmailHandler
+	// SMTPServer is nil unless INBOUND_SMTP_ADDR is set.
+	SMTPServer *smtpd.Server
+
+	FederationHandler *handlers.FederationHandler
+	FederationService *service.FederationService
+
+	// DiscordHandler is nil unless DISCORD_COMPAT is "true".
+	DiscordHandler *handlers.DiscordHandler
 }
 
-func Setup() *Dependencies {
-	db.ConnectDataBase()
+// Setup builds everything the server runs from cfg, which must have been
+// validated.
+func Setup(cfg *config.Config) *Dependencies {
+	var repos *repositories
+	if cfg.Store.InMemory {
+		p := cfg.Store.Persistence()
+		s := store.New()
+		if p.Dir != "" {
+			var err error
+			if s, err = store.Open(p); err != nil {
+				log.Fatal("cannot open the store in ", p.Dir, ": ", err)
+			}
+			log.Println("running in memory, persisted to", p.Describe())
+		} else {
+			log.Println("running in memory: data will be lost on exit")
+		}
+		repos = newInMemoryRepositories(s)
+	} else {
+		db.ConnectDataBase(cfg.Database.Config())
+		repos = newDBRepositories()
+	}
+
+	if err := token.Configure(cfg.Auth.Tokens()); err != nil {
+		log.Fatal("cannot load JWT signing keys: ", err)
+	}
+
+	sessionService := service.NewSessionService(repos.sessions, repos.tokens)
+	sessionHandler := handlers.NewSessionHandler(sessionService)
+	tokenService := service.NewTokenService(repos.tokens, sessionService, repos.uow)
+	tokenHandler := handlers.NewTokenHandler(tokenService)
+
+	passwordPolicy := cfg.Passwords.Policy()
+
+	mail, err := mailer.New(cfg.Mail.Config())
+	if err != nil {
+		log.Fatal("cannot configure mailer: ", err)
+	}
+	if cfg.Mail.Mailer == "log" {
+		log.Println("warning: mail is logged, not sent; password reset links in it can be used by anyone who reads the log")
+	}
+
+	loginGuard := service.NewLoginGuard(repos.loginAttempts)
+
+	rateLimitStore, err := ratelimit.NewStore(cfg.RateLimit.Store)
+	if err != nil {
+		log.Fatal("cannot configure rate limiting: ", err)
+	}
+
+	timeouts, err := cfg.Server.Timeouts()
+	if err != nil {
+		log.Fatal("invalid request timeout: ", err)
+	}
 
-	userRepository := userRepo.NewDBUserRepository()
-	userService := service.NewUserService(userRepository)
+	userService := service.NewUserService(repos.users, sessionService, tokenService, loginGuard, passwordPolicy)
 	userHandler := handlers.NewUserHandler(userService)
 
-	serverRepository := serverRepo.NewDBServerRepository()
-	serverService := service.NewServerService(serverRepository, userRepository)
+	mfaService := service.NewMFAService(repos.users, repos.mfa, repos.uow, sessionService, tokenService, loginGuard)
+	mfaHandler := handlers.NewMFAHandler(mfaService)
+
+	relyingParty := cfg.WebAuthn.RelyingParty()
+	passkeyService := service.NewPasskeyService(repos.passkeys, repos.users, sessionService, tokenService, loginGuard, relyingParty)
+	passkeyHandler := handlers.NewPasskeyHandler(passkeyService)
+
+	passwordService := service.NewPasswordService(repos.users, repos.resets, repos.uow, sessionService, mail, passwordPolicy, cfg.Passwords.ResetURL, cfg.Passwords.ResetLifespan())
+	passwordHandler := handlers.NewPasswordHandler(passwordService)
+
+	bus := events.NewBus()
+
+	applicationService := service.NewApplicationService(repos.applications, repos.users, repos.uow)
+	applicationHandler := handlers.NewApplicationHandler(applicationService)
+
+	serverService := service.NewServerService(repos.servers, repos.users, repos.applications, repos.invites, repos.uow, bus)
 	serverHandler := handlers.NewServerHandler(serverService)
 
-	// channelRepository := channel.NewDBChannelRepository()
-	// channelService := service.NewChannelService(channelRepository, serverRepository)
-	// channelHandler := handlers.NewChannelHandler(channelService)
+	channelService := service.NewChannelService(repos.channels, repos.servers)
+	channelHandler := handlers.NewChannelHandler(channelService)
 
-	// messageRepository := message.NewDBMessageRepository()
-	// messageService := service.NewMessageService(messageRepository, channelRepository, userRepository)
-	// messageHandler := handlers.NewMessageHandler(messageService)
+	messageService := service.NewMessageService(repos.messages, repos.channels, repos.servers, repos.users, repos.attachments, repos.uow, bus)
+	messageHandler := handlers.NewMessageHandler(messageService)
+
+	gatewayService := service.NewGatewayService(repos.servers, bus)
+	gatewayHandler := handlers.NewGatewayHandler(gatewayService)
+
+	allowInsecure := cfg.Webhooks.AllowInsecure
+
+	webhookService := service.NewWebhookService(repos.webhooks, repos.servers, bus, allowInsecure)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+
+	incomingWebhookService := service.NewIncomingWebhookService(repos.hooks, repos.channels, repos.servers, messageService, cfg.Server.PublicURL)
+	incomingWebhookHandler := handlers.NewIncomingWebhookHandler(incomingWebhookService)
+
+	commandService := service.NewCommandService(repos.commands, repos.interactions, repos.applications, repos.channels, repos.servers, repos.users, serverService, messageService, gatewayService, allowInsecure)
+	commandHandler := handlers.NewCommandHandler(commandService)
+
+	var ircServer *irc.Server
+	if addr := cfg.IRC.Addr; addr != "" {
+		tlsConfig, err := loadTLS(cfg.IRC.TLSCert, cfg.IRC.TLSKey)
+		if err != nil {
+			log.Fatal("cannot load IRC TLS certificate: ", err)
+		}
+		ircServer = irc.NewServer(addr, tlsConfig, userService, serverService, channelService, messageService, gatewayService, tokenService, sessionService, applicationService)
+	}
+
+	channelEmailService := service.NewChannelEmailService(repos.emails, repos.channels, repos.servers, messageService, cfg.InboundMail.Domain)
+	channelEmailHandler := handlers.NewChannelEmailHandler(channelEmailService)
+
+	var smtpServer *smtpd.Server
+	if addr := cfg.InboundMail.SMTPAddr; addr != "" {
+		tlsConfig, err := loadTLS(cfg.InboundMail.TLSCert, cfg.InboundMail.TLSKey)
+		if err != nil {
+			log.Fatal("cannot load inbound SMTP TLS certificate: ", err)
+		}
+		smtpServer = smtpd.NewServer(addr, tlsConfig, channelEmailService)
+	}
+
+	// Federation is enabled by naming this instance. Its key is generated
+	// on first start.
+	federationName := cfg.Federation.Name
+	var federationKey ed25519.PrivateKey
+	if federationName != "" {
+		federationKey, err = httpsig.LoadOrCreateKey(cfg.Federation.KeyFile)
+		if err != nil {
+			log.Fatal("cannot load federation key: ", err)
+		}
+	}
+	federationService := service.NewFederationService(federationName, federationKey, cfg.Federation.AllowedInstances, cfg.Federation.AllowInsecure, repos.users, repos.servers, repos.uow, serverService, channelService, messageService, bus)
+	federationHandler := handlers.NewFederationHandler(federationService)
+
+	var discordHandler *handlers.DiscordHandler
+	if cfg.Discord.Compat {
+		discordService := service.NewDiscordService(repos.snowflakes, repos.users, repos.servers, serverService, channelService, messageService, cfg.Server.PublicURL)
+		discordHandler = handlers.NewDiscordHandler(discordService)
+	}
 
 	return &Dependencies{
-		UserHandler:   userHandler,
-		ServerHandler: serverHandler,
-		// ChannelHandler: channelHandler,
-		// MessageHandler: messageHandler,
+		UserHandler:     userHandler,
+		ServerHandler:   serverHandler,
+		TokenHandler:    tokenHandler,
+		TokenService:    tokenService,
+		SessionHandler:  sessionHandler,
+		SessionService:  sessionService,
+		PasswordHandler: passwordHandler,
+		MFAHandler:      mfaHandler,
+		PasskeyHandler:  passkeyHandler,
+		AppHandler:      applicationHandler,
+		AppService:      applicationService,
+		RateLimitStore:  rateLimitStore,
+		RateLimits:      cfg.RateLimit.Limits(),
+		Timeouts:        timeouts,
+		ChannelHandler:  channelHandler,
+		MessageHandler:  messageHandler,
+		WebhookHandler:  webhookHandler,
+		WebhookService:  webhookService,
+
+		IncomingWebhookHandler: incomingWebhookHandler,
+		CommandHandler:         commandHandler,
+		GatewayHandler:         gatewayHandler,
+
+		IRCServer: ircServer,
+
+		ChannelEmailHandler: channelEmailHandler,
+		SMTPServer:          smtpServer,
+
+		FederationHandler: federationHandler,
+		FederationService: federationService,
+
+		DiscordHandler: discordHandler,
+	}
+}
+
+// loadTLS loads a certificate and key, or returns nil to serve plain TCP
+// when neither is given.
+func loadTLS(certFile, keyFile string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
 	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
 }
@@ -0,0 +1,83 @@
+package smtpd
+
+import (
+	"crypto/tls"
+	"log"
+	"net"
+	"sync"
+
+	"rio/internal/service"
+)
+
+// maxConnections bounds how many SMTP sessions run at once; further
+// connections wait to be accepted.
+const maxConnections = 100
+
+// Server is a receive-only SMTP listener that posts mail sent to channel
+// email addresses into their channels. It accepts mail for the channel
+// email domain only and never relays.
+type Server struct {
+	addr      string
+	tlsConfig *tls.Config
+	emails    *service.ChannelEmailService
+
+	slots chan struct{}
+
+	mu       sync.Mutex
+	listener net.Listener
+}
+
+// NewServer returns a listener on addr. With a non-nil tlsConfig it offers
+// STARTTLS.
+func NewServer(
+	addr string,
+	tlsConfig *tls.Config,
+	emails *service.ChannelEmailService,
+) *Server {
+	return &Server{
+		addr:      addr,
+		tlsConfig: tlsConfig,
+		emails:    emails,
+		slots:     make(chan struct{}, maxConnections),
+	}
+}
+
+// ListenAndServe accepts SMTP connections until Close is called.
+func (s *Server) ListenAndServe() error {
+	l, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts SMTP connections on l until Close is called.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	s.listener = l
+	s.mu.Unlock()
+
+	log.Printf("smtpd: listening on %s for mail to @%s", l.Addr(), s.emails.Domain())
+	for {
+		s.slots <- struct{}{}
+		conn, err := l.Accept()
+		if err != nil {
+			<-s.slots
+			return err
+		}
+		go func() {
+			defer func() { <-s.slots }()
+			newSession(s, conn).serve()
+		}()
+	}
+}
+
+// Close stops accepting connections. Open sessions are left to finish.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
@@ -0,0 +1,355 @@
+package smtpd
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/models"
+	"rio/internal/service"
+)
+
+const (
+	commandTimeout = 5 * time.Minute
+	dataTimeout    = 10 * time.Minute
+	// maxLineLength bounds command lines; RFC 5321 allows 512 bytes.
+	maxLineLength = 4096
+	maxRecipients = 10
+	// maxErrors closes sessions that keep sending bad commands.
+	maxErrors = 10
+)
+
+// session is one SMTP connection.
+type session struct {
+	srv  *Server
+	conn net.Conn
+	r    *bufio.Reader
+
+	// ctx is cancelled when the connection ends.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	helo   string
+	tls    bool
+	errors int
+
+	// The current mail transaction, started by MAIL.
+	inTransaction bool
+	size          int
+	recipients    []*models.ChannelEmail
+}
+
+func newSession(srv *Server, conn net.Conn) *session {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &session{
+		srv:    srv,
+		conn:   conn,
+		r:      bufio.NewReaderSize(conn, maxLineLength),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (s *session) serve() {
+	defer s.conn.Close()
+	defer s.cancel()
+
+	s.reply(220, "%s ESMTP rio", s.srv.emails.Domain())
+	for {
+		s.conn.SetReadDeadline(time.Now().Add(commandTimeout))
+		line, err := s.r.ReadSlice('\n')
+		if err != nil {
+			if errors.Is(err, bufio.ErrBufferFull) {
+				s.reply(500, "5.5.2 Line too long")
+			}
+			return
+		}
+
+		verb, arg, _ := strings.Cut(strings.TrimRight(string(line), "\r\n"), " ")
+		if !s.handle(strings.ToUpper(verb), strings.TrimSpace(arg)) {
+			return
+		}
+	}
+}
+
+func (s *session) reply(code int, format string, args ...interface{}) {
+	s.conn.SetWriteDeadline(time.Now().Add(commandTimeout))
+	fmt.Fprintf(s.conn, "%d %s\r\n", code, fmt.Sprintf(format, args...))
+}
+
+// replyError answers with the SMTP status matching a delivery error.
+// Unexpected errors are logged and reported as temporary, so the sender
+// retries.
+func (s *session) replyError(err error) {
+	msg := err.Error()
+	switch {
+	case errors.Is(err, apperr.ErrNotFound):
+		s.reply(550, "5.1.1 %s", msg)
+	case errors.Is(err, apperr.ErrForbidden):
+		s.reply(550, "5.7.1 %s", msg)
+	case errors.Is(err, service.ErrEmailTooLarge):
+		s.reply(552, "5.3.4 %s", msg)
+	case errors.Is(err, apperr.ErrInvalid):
+		s.reply(554, "5.6.0 %s", msg)
+	default:
+		log.Printf("smtpd: delivery failed: %v", err)
+		s.reply(451, "4.3.0 Temporary failure, try again later")
+	}
+}
+
+func (s *session) reset() {
+	s.inTransaction = false
+	s.size = 0
+	s.recipients = nil
+}
+
+// handle runs one command and reports whether the session continues.
+func (s *session) handle(verb, arg string) bool {
+	switch verb {
+	case "HELO", "EHLO":
+		if arg == "" {
+			s.reply(501, "5.5.4 Syntax: %s hostname", verb)
+			return true
+		}
+		s.helo = arg
+		s.reset()
+		if verb == "HELO" {
+			s.reply(250, "%s", s.srv.emails.Domain())
+			return true
+		}
+		s.ehlo()
+	case "MAIL":
+		s.mail(arg)
+	case "RCPT":
+		s.rcpt(arg)
+	case "DATA":
+		return s.data()
+	case "RSET":
+		s.reset()
+		s.reply(250, "2.0.0 OK")
+	case "NOOP":
+		s.reply(250, "2.0.0 OK")
+	case "VRFY":
+		s.reply(252, "2.5.0 Cannot VRFY user")
+	case "STARTTLS":
+		return s.startTLS()
+	case "QUIT":
+		s.reply(221, "2.0.0 Bye")
+		return false
+	default:
+		s.errors++
+		if s.errors >= maxErrors {
+			s.reply(421, "4.7.0 Too many errors")
+			return false
+		}
+		s.reply(500, "5.5.2 Command not recognized")
+	}
+	return true
+}
+
+func (s *session) ehlo() {
+	lines := []string{
+		s.srv.emails.Domain(),
+		"SIZE " + strconv.Itoa(service.MaxEmailSize),
+		"8BITMIME",
+		"ENHANCEDSTATUSCODES",
+	}
+	if s.srv.tlsConfig != nil && !s.tls {
+		lines = append(lines, "STARTTLS")
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(commandTimeout))
+	for i, line := range lines {
+		sep := "-"
+		if i == len(lines)-1 {
+			sep = " "
+		}
+		fmt.Fprintf(s.conn, "250%s%s\r\n", sep, line)
+	}
+}
+
+// parsePath parses the argument of MAIL or RCPT, "FROM:<path> params" or
+// "TO:<path> params", into the address and its parameters.
+func parsePath(arg, prefix string) (string, []string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", nil, false
+	}
+	rest := strings.TrimSpace(arg[len(prefix):])
+
+	if !strings.HasPrefix(rest, "<") {
+		path, params, _ := strings.Cut(rest, " ")
+		return path, strings.Fields(params), path != ""
+	}
+
+	end := strings.IndexByte(rest, '>')
+	if end < 0 {
+		return "", nil, false
+	}
+	path := rest[1:end]
+	// Drop source routes such as <@relay.example:user@example.com>.
+	if strings.HasPrefix(path, "@") {
+		if _, after, ok := strings.Cut(path, ":"); ok {
+			path = after
+		}
+	}
+	return path, strings.Fields(rest[end+1:]), true
+}
+
+func (s *session) mail(arg string) {
+	if s.helo == "" {
+		s.reply(503, "5.5.1 Send HELO or EHLO first")
+		return
+	}
+	if s.inTransaction {
+		s.reply(503, "5.5.1 Nested MAIL command")
+		return
+	}
+
+	_, params, ok := parsePath(arg, "FROM:")
+	if !ok {
+		s.reply(501, "5.5.4 Syntax: MAIL FROM:<address>")
+		return
+	}
+
+	size := 0
+	for _, p := range params {
+		key, value, _ := strings.Cut(p, "=")
+		if !strings.EqualFold(key, "SIZE") {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			s.reply(501, "5.5.4 Invalid SIZE parameter")
+			return
+		}
+		if n > service.MaxEmailSize {
+			s.reply(552, "5.3.4 Message too big")
+			return
+		}
+		size = n
+	}
+
+	s.inTransaction = true
+	s.size = size
+	s.reply(250, "2.1.0 OK")
+}
+
+func (s *session) rcpt(arg string) {
+	if !s.inTransaction {
+		s.reply(503, "5.5.1 Need MAIL command")
+		return
+	}
+
+	path, _, ok := parsePath(arg, "TO:")
+	if !ok || path == "" {
+		s.reply(501, "5.5.4 Syntax: RCPT TO:<address>")
+		return
+	}
+	if len(s.recipients) >= maxRecipients {
+		s.reply(452, "4.5.3 Too many recipients")
+		return
+	}
+
+	email, err := s.srv.emails.Recipient(s.ctx, path)
+	if err != nil {
+		s.replyError(err)
+		return
+	}
+	if s.size > email.MaxSize {
+		s.reply(552, "5.3.4 Message too big for this address")
+		return
+	}
+
+	for _, r := range s.recipients {
+		if r.ULID == email.ULID {
+			s.reply(250, "2.1.5 OK")
+			return
+		}
+	}
+	s.recipients = append(s.recipients, email)
+	s.reply(250, "2.1.5 OK")
+}
+
+// data reads the message and posts it to every recipient's channel. SMTP
+// has a single reply for all recipients, so the mail is accepted if any
+// channel got it.
+func (s *session) data() bool {
+	if len(s.recipients) == 0 {
+		s.reply(503, "5.5.1 Need RCPT command")
+		return true
+	}
+	s.reply(354, "End data with <CR><LF>.<CR><LF>")
+
+	s.conn.SetReadDeadline(time.Now().Add(dataTimeout))
+	dr := textproto.NewReader(s.r).DotReader()
+	raw, err := io.ReadAll(io.LimitReader(dr, service.MaxEmailSize+1))
+	if err != nil {
+		return false
+	}
+
+	recipients := s.recipients
+	s.reset()
+
+	if len(raw) > service.MaxEmailSize {
+		if _, err := io.Copy(io.Discard, dr); err != nil {
+			return false
+		}
+		s.reply(552, "5.3.4 Message too big")
+		return true
+	}
+
+	delivered := 0
+	var firstErr error
+	for _, r := range recipients {
+		if _, err := s.srv.emails.Deliver(s.ctx, r, raw); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		delivered++
+	}
+
+	if delivered == 0 {
+		s.replyError(firstErr)
+		return true
+	}
+	if firstErr != nil {
+		log.Printf("smtpd: mail delivered to %d of %d channels: %v", delivered, len(recipients), firstErr)
+	}
+	s.reply(250, "2.0.0 OK: delivered")
+	return true
+}
+
+func (s *session) startTLS() bool {
+	if s.srv.tlsConfig == nil || s.tls {
+		s.reply(502, "5.5.1 STARTTLS not available")
+		return true
+	}
+	s.reply(220, "2.0.0 Ready to start TLS")
+
+	tlsConn := tls.Server(s.conn, s.srv.tlsConfig)
+	tlsConn.SetDeadline(time.Now().Add(commandTimeout))
+	if err := tlsConn.Handshake(); err != nil {
+		return false
+	}
+
+	// Anything the client sent before the handshake is discarded along
+	// with the old reader, and the session starts over.
+	s.conn = tlsConn
+	s.r = bufio.NewReaderSize(tlsConn, maxLineLength)
+	s.tls = true
+	s.helo = ""
+	s.reset()
+	return true
+}
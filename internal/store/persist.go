@@ -0,0 +1,315 @@
+package store
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Persistence describes how a store keeps its records on disk.
+type Persistence struct {
+	// Dir holds the snapshot and the write-ahead log. When it is empty
+	// the store is not persisted.
+	Dir string
+	// Sync decides when the write-ahead log is flushed to disk, every
+	// SyncInterval for SyncInterval.
+	Sync         SyncPolicy
+	SyncInterval time.Duration
+	// SnapshotInterval is how often a snapshot is taken, after which the
+	// write-ahead log up to it is deleted. Zero only takes one on start.
+	SnapshotInterval time.Duration
+}
+
+// DefaultPersistence is how a store is persisted unless configured
+// otherwise, once it is given a Dir.
+func DefaultPersistence() Persistence {
+	return Persistence{
+		Sync:             SyncAlways,
+		SyncInterval:     time.Second,
+		SnapshotInterval: 10 * time.Minute,
+	}
+}
+
+// Validate reports the first setting of p that cannot be used.
+func (p Persistence) Validate() error {
+	switch p.Sync {
+	case SyncAlways, SyncInterval, SyncNever:
+	default:
+		return fmt.Errorf("unknown fsync policy %q; must be always, interval or never", p.Sync)
+	}
+	if p.SyncInterval <= 0 {
+		return fmt.Errorf("invalid fsync interval %s: must be positive", p.SyncInterval)
+	}
+	if p.SnapshotInterval < 0 {
+		return fmt.Errorf("invalid snapshot interval %s: must be positive, or 0 for none", p.SnapshotInterval)
+	}
+	return nil
+}
+
+// Describe summarises how p persists a store, for logging at startup.
+func (p Persistence) Describe() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (fsync %s", p.Dir, p.Sync)
+	if p.Sync == SyncInterval {
+		fmt.Fprintf(&b, " every %s", p.SyncInterval)
+	}
+	if p.SnapshotInterval > 0 {
+		fmt.Fprintf(&b, ", snapshot every %s", p.SnapshotInterval)
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+const (
+	snapshotFile    = "snapshot.db"
+	snapshotVersion = 1
+)
+
+// snapshotHeader starts a snapshot. It is followed by Rows log entries, one
+// putting each record.
+type snapshotHeader struct {
+	Version int
+	// Segment is the last write-ahead log segment the snapshot includes.
+	Segment uint64
+	NextID  uint
+	Rows    int
+}
+
+// persister takes the snapshots of a persisted store.
+type persister struct {
+	mu  sync.Mutex
+	dir string
+	// segment is the last write-ahead log segment in the snapshot on disk.
+	segment uint64
+}
+
+// Open loads the store persisted in p.Dir, creating it if there is none,
+// and keeps it persisted there from then on: every commit is written to
+// the write-ahead log and snapshots are taken every p.SnapshotInterval.
+func Open(p Persistence) (*Store, error) {
+	if err := os.MkdirAll(p.Dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	t := newTables()
+	header, err := loadSnapshot(t, filepath.Join(p.Dir, snapshotFile))
+	if err != nil {
+		return nil, fmt.Errorf("cannot load snapshot: %w", err)
+	}
+
+	seqs, err := segments(p.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	next, replayed := header.Segment+1, false
+	for i, seq := range seqs {
+		path := filepath.Join(p.Dir, segmentName(seq))
+		if seq <= header.Segment {
+			// Left over from a snapshot whose clean-up was cut short.
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := replaySegment(t, p.Dir, seq, i == len(seqs)-1); err != nil {
+			return nil, fmt.Errorf("cannot replay write-ahead log: %w", err)
+		}
+		next, replayed = seq+1, true
+	}
+	t.rebuildIndexes()
+
+	w, err := openWAL(p.Dir, next, p.Sync)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{
+		tables:    t,
+		wal:       w,
+		persister: &persister{dir: p.Dir, segment: header.Segment},
+	}
+
+	// Fold what was replayed into a snapshot so the log does not grow
+	// across restarts.
+	if replayed {
+		if err := s.Snapshot(); err != nil {
+			return nil, fmt.Errorf("cannot write snapshot: %w", err)
+		}
+	}
+
+	if p.Sync == SyncInterval {
+		go every(p.SyncInterval, func() {
+			if err := w.sync(); err != nil {
+				log.Printf("store: %v", err)
+			}
+		})
+	}
+	if p.SnapshotInterval > 0 {
+		go every(p.SnapshotInterval, func() {
+			if err := s.Snapshot(); err != nil {
+				log.Printf("store: cannot write snapshot: %v", err)
+			}
+		})
+	}
+
+	return s, nil
+}
+
+func every(d time.Duration, fn func()) {
+	for range time.Tick(d) {
+		fn()
+	}
+}
+
+// Snapshot writes every record to a new snapshot and deletes the
+// write-ahead log it replaces. The store is only locked while the records
+// are copied. It does nothing for a store that is not persisted or has not
+// changed since the last snapshot.
+func (s *Store) Snapshot() error {
+	if s.persister == nil {
+		return nil
+	}
+	p := s.persister
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s.mu.Lock()
+	if s.wal.empty() && s.wal.seq == p.segment+1 {
+		s.mu.Unlock()
+		return nil
+	}
+	segment, err := s.wal.rotate()
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	header := snapshotHeader{Version: snapshotVersion, Segment: segment, NextID: s.tables.nextID}
+	var tables []tableSnapshot
+	for _, tbl := range s.tables.tables {
+		rows, entries := tbl.snapshot()
+		header.Rows += rows
+		tables = append(tables, entries)
+	}
+	s.mu.Unlock()
+
+	if err := writeSnapshot(p.dir, header, tables); err != nil {
+		return err
+	}
+	p.segment = segment
+
+	seqs, err := segments(p.dir)
+	if err != nil {
+		return err
+	}
+	for _, seq := range seqs {
+		if seq <= segment {
+			if err := os.Remove(filepath.Join(p.dir, segmentName(seq))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeSnapshot writes the snapshot to a temporary file and renames it over
+// the previous one once it is safely on disk, so a crash leaves one or the
+// other complete.
+func writeSnapshot(dir string, header snapshotHeader, tables []tableSnapshot) error {
+	tmp := filepath.Join(dir, snapshotFile+".tmp")
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	write := func(v any) error {
+		frame, err := encodeFrame(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(frame)
+		return err
+	}
+
+	if err := write(header); err != nil {
+		return err
+	}
+	for _, entries := range tables {
+		for e, err := range entries {
+			if err != nil {
+				return err
+			}
+			if err := write(e); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, snapshotFile)); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// loadSnapshot reads the snapshot at path into t. A missing snapshot is an
+// empty store; any damage to one is an error, since it was written whole.
+func loadSnapshot(t *Tables, path string) (snapshotHeader, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return snapshotHeader{}, nil
+	}
+	if err != nil {
+		return snapshotHeader{}, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var header snapshotHeader
+	if _, err := readFrame(r, &header); err != nil {
+		return snapshotHeader{}, fmt.Errorf("header: %w", err)
+	}
+	if header.Version != snapshotVersion {
+		return snapshotHeader{}, fmt.Errorf("unsupported version %d", header.Version)
+	}
+
+	for i := range header.Rows {
+		var e logEntry
+		if _, err := readFrame(r, &e); err != nil {
+			if err == io.EOF {
+				err = errTorn
+			}
+			return snapshotHeader{}, fmt.Errorf("record %d: %w", i, err)
+		}
+		tbl := t.table(e.Table)
+		if tbl == nil {
+			return snapshotHeader{}, fmt.Errorf("record %d: unknown table %q", i, e.Table)
+		}
+		if err := tbl.apply(e); err != nil {
+			return snapshotHeader{}, fmt.Errorf("record %d: table %s: %w", i, e.Table, err)
+		}
+	}
+	if _, err := r.Peek(1); err != io.EOF {
+		return snapshotHeader{}, errors.New("unexpected data after the last record")
+	}
+
+	t.nextID = header.NextID
+	return header, nil
+}
@@ -0,0 +1,248 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"rio/internal/models"
+)
+
+func openStore(t *testing.T, dir string) *Store {
+	t.Helper()
+	s, err := Open(Persistence{Dir: dir, Sync: SyncAlways, SyncInterval: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.wal.f.Close() })
+	return s
+}
+
+func putUser(t *testing.T, s *Store, username string) {
+	t.Helper()
+	err := s.Update(context.Background(), func(t *Tables) error {
+		user := models.User{ULID: "user-" + username, Username: username}
+		t.Stamp(&user.Model)
+		t.Users.Put(user.ULID, user)
+		t.UsersByName.Put(strings.ToLower(username), user.ULID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// usernames lists the users of s, checking the index agrees with the table.
+func usernames(t *testing.T, s *Store) []string {
+	t.Helper()
+	names, err := Query(context.Background(), s, func(tables *Tables) []string {
+		var names []string
+		for id, u := range tables.Users.All() {
+			if indexed, _ := tables.UsersByName.Lookup(strings.ToLower(u.Username)); indexed != id {
+				t.Errorf("%s is indexed as %q", id, indexed)
+			}
+			names = append(names, u.Username)
+		}
+		return names
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// segmentSizes returns the size of the current segment of s after each
+// call to write, which is where each record written by write ends.
+func segmentSizes(t *testing.T, s *Store, writes ...func()) (string, []int64) {
+	t.Helper()
+	path := filepath.Join(s.persister.dir, segmentName(s.wal.seq))
+	var sizes []int64
+	for _, write := range writes {
+		write()
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, info.Size())
+	}
+	return path, sizes
+}
+
+func TestReplayAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir)
+	putUser(t, s, "alice")
+	putUser(t, s, "bob")
+	err := s.Update(context.Background(), func(t *Tables) error {
+		t.Users.Delete("user-bob")
+		t.UsersByName.Delete("bob")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextID := s.tables.nextID
+
+	restarted := openStore(t, dir)
+	if got := usernames(t, restarted); !slices.Equal(got, []string{"alice"}) {
+		t.Fatalf("users after a restart = %v; want alice", got)
+	}
+	if restarted.tables.nextID != nextID {
+		t.Fatalf("next ID after a restart = %d; want %d", restarted.tables.nextID, nextID)
+	}
+}
+
+func TestRollbackStaysOutOfTheLog(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir)
+	failed := errors.New("failed")
+
+	err := s.Update(context.Background(), func(t *Tables) error {
+		t.Users.Put("user-alice", models.User{ULID: "user-alice", Username: "alice"})
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("Update = %v; want its error", err)
+	}
+	err = s.Transaction(context.Background(), func(ctx context.Context) error {
+		if err := s.Update(ctx, func(t *Tables) error {
+			t.Users.Put("user-bob", models.User{ULID: "user-bob", Username: "bob"})
+			return nil
+		}); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("Transaction = %v; want its error", err)
+	}
+
+	if !s.wal.empty() {
+		t.Fatalf("the write-ahead log holds %d bytes of rolled-back changes", s.wal.size)
+	}
+	if got := usernames(t, openStore(t, dir)); len(got) != 0 {
+		t.Fatalf("users after a restart = %v; want none", got)
+	}
+}
+
+func TestTornTailIsCutOff(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir)
+	path, sizes := segmentSizes(t, s,
+		func() { putUser(t, s, "alice") },
+		func() { putUser(t, s, "bob") },
+	)
+
+	// A crash in the middle of writing bob's record.
+	if err := os.Truncate(path, sizes[1]-3); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := usernames(t, openStore(t, dir)); !slices.Equal(got, []string{"alice"}) {
+		t.Fatalf("users after a torn write = %v; want alice", got)
+	}
+}
+
+func TestCorruptTailIsCutOff(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir)
+	path, sizes := segmentSizes(t, s,
+		func() { putUser(t, s, "alice") },
+		func() { putUser(t, s, "bob") },
+	)
+	corrupt(t, path, sizes[1]-1)
+
+	if got := usernames(t, openStore(t, dir)); !slices.Equal(got, []string{"alice"}) {
+		t.Fatalf("users after a corrupt last record = %v; want alice", got)
+	}
+}
+
+func TestCorruptionInTheMiddleIsReported(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir)
+	path, sizes := segmentSizes(t, s,
+		func() { putUser(t, s, "alice") },
+		func() { putUser(t, s, "bob") },
+	)
+	corrupt(t, path, sizes[0]-1)
+
+	_, err := Open(Persistence{Dir: dir, Sync: SyncAlways, SyncInterval: time.Second})
+	if !errors.Is(err, errChecksum) {
+		t.Fatalf("Open with a corrupt record before the last = %v; want a checksum mismatch", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != sizes[1] {
+		t.Fatalf("segment is %d bytes after a failed Open; want it left at %d", info.Size(), sizes[1])
+	}
+}
+
+// corrupt flips the byte at offset in the file at path.
+func corrupt(t *testing.T, path string, offset int64) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[offset] ^= 0xff
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshotCompactsTheLog(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir)
+	putUser(t, s, "alice")
+	if err := s.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+
+	seqs, err := segments(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(seqs, []uint64{s.wal.seq}) {
+		t.Fatalf("segments after a snapshot = %v; want only the current one, %d", seqs, s.wal.seq)
+	}
+	if _, err := os.Stat(filepath.Join(dir, snapshotFile)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing changed, so there is nothing to snapshot.
+	seq := s.wal.seq
+	if err := s.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if s.wal.seq != seq {
+		t.Fatalf("a snapshot of an unchanged store started segment %d", s.wal.seq)
+	}
+
+	putUser(t, s, "bob")
+
+	// A segment the snapshot includes, left over by a crash before it was
+	// deleted, is not replayed again.
+	stale := filepath.Join(dir, segmentName(seq-1))
+	if err := os.WriteFile(stale, []byte("stale"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := openStore(t, dir)
+	if got := usernames(t, restarted); !slices.Equal(got, []string{"alice", "bob"}) {
+		t.Fatalf("users after a restart = %v; want alice and bob", got)
+	}
+	if seqs, err = segments(dir); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(seqs, []uint64{restarted.wal.seq}) {
+		t.Fatalf("segments after replaying = %v; want them folded into a snapshot", seqs)
+	}
+}
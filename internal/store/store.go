@@ -1,40 +1,324 @@
+// Package store holds the records of the in-memory repositories. A Store
+// is safe for concurrent use: repositories reach its tables through View
+// and Update, which hold its lock, and Transaction holds it for a whole
+// unit of work.
 package store
 
-import "rio/internal/models"
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
-var (
-	Users       = []models.User{}
-	Servers     = []models.Server{}
-	Channels    = []models.Channel{}
-	Messages    = []models.Message{}
-	UserServers = []models.UserServer{}
+	"rio/internal/models"
 
-	nextUserID    = 1
-	nextServerID  = 1
-	nextChannelID = 1
-	nextMessageID = 1
+	"github.com/jinzhu/gorm"
 )
 
-func GetNextUserId() int {
-	id := nextUserID
-	nextUserID++
-	return id
+// Membership is the key of a user's membership of, or ban from, a server.
+type Membership struct {
+	ServerID string
+	UserID   string
 }
 
-func GetNextServerId() int {
-	id := nextServerID
-	nextServerID++
-	return id
+// Tables are the records, each table keyed by the record's ID and holding
+// records by value: repositories copy records in and out, so callers never
+// share memory with the store. The tables whose name ends in By are indexes
+// the repositories keep up to date themselves; they are not persisted but
+// rebuilt when the store is loaded.
+type Tables struct {
+	Users        *Table[string, models.User] // by ULID
+	UsersByName  *Table[string, string]      // lowercased username to ULID
+	Servers      *Table[string, models.Server]
+	Members      *Table[Membership, models.UserServer]
+	ServerBans   *Table[Membership, models.ServerBan]
+	Channels     *Table[string, models.Channel]
+	Messages     *Table[string, models.Message]
+	MessagesByCh *Table[string, []string] // channel ULID to message ULIDs, sorted
+
+	Sessions      *Table[string, models.Session]
+	RefreshTokens *Table[string, models.RefreshToken]
+	RevokedTokens *Table[string, models.RevokedToken] // by JTI
+
+	PasswordResets *Table[string, models.PasswordReset]
+	RecoveryCodes  *Table[uint, models.RecoveryCode]
+	Passkeys       *Table[string, models.Passkey]
+	LoginAttempts  *Table[uint, models.LoginAttempt]
+	Applications   *Table[string, models.Application]
+
+	Webhooks          *Table[string, models.Webhook]
+	WebhookDeliveries *Table[string, models.WebhookDelivery]
+	IncomingWebhooks  *Table[string, models.IncomingWebhook]
+
+	Invites      *Table[string, models.Invite] // by code
+	Commands     *Table[string, models.ApplicationCommand]
+	Interactions *Table[string, models.Interaction]
+
+	ChannelEmails *Table[string, models.ChannelEmail]
+	Attachments   *Table[string, models.Attachment]
+	Snowflakes    *Table[string, models.Snowflake] // by snowflake
+
+	nextID uint
+	log    changeLog
+	tables []persistent
+}
+
+func newTables() *Tables {
+	t := &Tables{}
+	log := &t.log
+
+	t.Users = newTable[string, models.User](log, "users")
+	t.UsersByName = newTable[string, string](log, "")
+	t.Servers = newTable[string, models.Server](log, "servers")
+	t.Members = newTable[Membership, models.UserServer](log, "members")
+	t.ServerBans = newTable[Membership, models.ServerBan](log, "server_bans")
+	t.Channels = newTable[string, models.Channel](log, "channels")
+	t.Messages = newTable[string, models.Message](log, "messages")
+	t.MessagesByCh = newTable[string, []string](log, "")
+
+	t.Sessions = newTable[string, models.Session](log, "sessions")
+	t.RefreshTokens = newTable[string, models.RefreshToken](log, "refresh_tokens")
+	t.RevokedTokens = newTable[string, models.RevokedToken](log, "revoked_tokens")
+
+	t.PasswordResets = newTable[string, models.PasswordReset](log, "password_resets")
+	t.RecoveryCodes = newTable[uint, models.RecoveryCode](log, "recovery_codes")
+	t.Passkeys = newTable[string, models.Passkey](log, "passkeys")
+	t.LoginAttempts = newTable[uint, models.LoginAttempt](log, "login_attempts")
+	t.Applications = newTable[string, models.Application](log, "applications")
+
+	t.Webhooks = newTable[string, models.Webhook](log, "webhooks")
+	t.WebhookDeliveries = newTable[string, models.WebhookDelivery](log, "webhook_deliveries")
+	t.IncomingWebhooks = newTable[string, models.IncomingWebhook](log, "incoming_webhooks")
+
+	t.Invites = newTable[string, models.Invite](log, "invites")
+	t.Commands = newTable[string, models.ApplicationCommand](log, "commands")
+	t.Interactions = newTable[string, models.Interaction](log, "interactions")
+
+	t.ChannelEmails = newTable[string, models.ChannelEmail](log, "channel_emails")
+	t.Attachments = newTable[string, models.Attachment](log, "attachments")
+	t.Snowflakes = newTable[string, models.Snowflake](log, "snowflakes")
+
+	t.tables = []persistent{
+		t.Users, t.Servers, t.Members, t.ServerBans, t.Channels, t.Messages,
+		t.Sessions, t.RefreshTokens, t.RevokedTokens,
+		t.PasswordResets, t.RecoveryCodes, t.Passkeys, t.LoginAttempts, t.Applications,
+		t.Webhooks, t.WebhookDeliveries, t.IncomingWebhooks,
+		t.Invites, t.Commands, t.Interactions,
+		t.ChannelEmails, t.Attachments, t.Snowflakes,
+	}
+	return t
+}
+
+// rebuildIndexes fills the indexes from the tables after a load.
+func (t *Tables) rebuildIndexes() {
+	for id, u := range t.Users.All() {
+		t.UsersByName.rows[strings.ToLower(u.Username)] = id
+	}
+	for id, m := range t.Messages.All() {
+		t.MessagesByCh.rows[m.ChannelID] = append(t.MessagesByCh.rows[m.ChannelID], id)
+	}
+	for _, ids := range t.MessagesByCh.All() {
+		slices.Sort(ids)
+	}
+}
+
+func (t *Tables) table(name string) persistent {
+	for _, tbl := range t.tables {
+		if tbl.tableName() == name {
+			return tbl
+		}
+	}
+	return nil
+}
+
+// begin starts collecting the changes of an update or transaction.
+func (t *Tables) begin() {
+	t.log = changeLog{nextID: t.nextID}
+}
+
+// rollbackTo undoes the changes collected since the n-th one.
+func (t *Tables) rollbackTo(n int) {
+	for i := len(t.log.changes) - 1; i >= n; i-- {
+		t.log.changes[i].undo()
+	}
+	t.log.changes = t.log.changes[:n]
+}
+
+// rollback undoes every change collected since begin.
+func (t *Tables) rollback() {
+	t.rollbackTo(0)
+	t.nextID = t.log.nextID
+	t.log = changeLog{}
+}
+
+// batch encodes the changes collected since begin for the write-ahead log.
+// It returns nil if nothing persisted changed.
+func (t *Tables) batch() (*logBatch, error) {
+	var batch *logBatch
+	for _, c := range t.log.changes {
+		if c.entry == nil {
+			continue
+		}
+		e, err := c.entry()
+		if err != nil {
+			return nil, err
+		}
+		if batch == nil {
+			batch = &logBatch{NextID: t.nextID}
+		}
+		batch.Entries = append(batch.Entries, e)
+	}
+	return batch, nil
+}
+
+// Stamp gives a new record the ID and timestamps the database would. IDs
+// are shared by every table, so they also order records of one table by
+// creation.
+func (t *Tables) Stamp(m *gorm.Model) {
+	t.nextID++
+	m.ID = t.nextID
+
+	now := time.Now()
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = now
+	}
+	m.UpdatedAt = now
+}
+
+// Store guards a set of tables. A store made by Open also writes every
+// change to a write-ahead log and snapshots; one made by New lives only in
+// memory.
+type Store struct {
+	mu        sync.RWMutex
+	tables    *Tables
+	wal       *wal
+	persister *persister
+}
+
+func New() *Store {
+	return &Store{tables: newTables()}
+}
+
+type txKey struct{}
+
+// inTransaction reports whether ctx comes from a transaction on s, which
+// already holds the lock.
+func (s *Store) inTransaction(ctx context.Context) bool {
+	return ctx != nil && ctx.Value(txKey{}) == s
+}
+
+func ctxErr(ctx context.Context) error {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Err()
+}
+
+// View runs fn with the tables for reading, unless ctx is already done.
+func (s *Store) View(ctx context.Context, fn func(t *Tables) error) error {
+	if err := ctxErr(ctx); err != nil {
+		return err
+	}
+	if !s.inTransaction(ctx) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+	return fn(s.tables)
+}
+
+// Update runs fn with the tables for writing, unless ctx is already done.
+// Its changes are applied as a whole or, if fn returns an error or panics,
+// not at all.
+func (s *Store) Update(ctx context.Context, fn func(t *Tables) error) error {
+	if err := ctxErr(ctx); err != nil {
+		return err
+	}
+
+	if s.inTransaction(ctx) {
+		// The transaction holds the lock and commits; only undo what fn
+		// did if it fails, in case the transaction carries on.
+		n := len(s.tables.log.changes)
+		if err := fn(s.tables); err != nil {
+			s.tables.rollbackTo(n)
+			return err
+		}
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.run(func() error {
+		return fn(s.tables)
+	})
+}
+
+// Transaction runs fn holding the store's lock, passing it a context that
+// View and Update recognise. If fn returns an error or panics, everything
+// it changed is undone. Called with a context from a transaction, fn joins
+// it. Everything fn does to the store must go through its context: anything
+// else would wait for the lock fn holds.
+func (s *Store) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if s.inTransaction(ctx) {
+		return fn(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.run(func() error {
+		return fn(context.WithValue(ctx, txKey{}, s))
+	})
+}
+
+// run calls fn, which changes the tables, and commits its changes or rolls
+// them back. It must be called holding the lock.
+func (s *Store) run(fn func() error) error {
+	t := s.tables
+	t.begin()
+	defer func() {
+		if p := recover(); p != nil {
+			t.rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(); err != nil {
+		t.rollback()
+		return err
+	}
+	return s.commit()
 }
 
-func GetNextChannelId() uint {
-	id := uint(nextChannelID)
-	nextChannelID++
-	return id
+// commit writes the changes collected since begin to the write-ahead log,
+// rolling them back if that fails.
+func (s *Store) commit() error {
+	t := s.tables
+	if s.wal != nil {
+		batch, err := t.batch()
+		if err == nil && batch != nil {
+			err = s.wal.append(batch)
+		}
+		if err != nil {
+			t.rollback()
+			return err
+		}
+	}
+	t.log = changeLog{}
+	return nil
 }
 
-func GetNextMessageId() uint {
-	id := uint(nextMessageID)
-	nextMessageID++
-	return id
+// Query runs fn with the tables for reading and returns what it returns.
+func Query[T any](ctx context.Context, s *Store, fn func(t *Tables) T) (T, error) {
+	var out T
+	err := s.View(ctx, func(t *Tables) error {
+		out = fn(t)
+		return nil
+	})
+	return out, err
 }
@@ -0,0 +1,215 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"rio/internal/models"
+)
+
+// TestConcurrentUpdates runs updates and transactions from many goroutines,
+// half of them failing, and checks that exactly the changes of the ones that
+// succeeded are left. Run it with -race.
+func TestConcurrentUpdates(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+	failed := errors.New("failed")
+
+	const workers, rounds = 8, 50
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range rounds {
+				id := fmt.Sprintf("%d-%d", w, i)
+				fail := i%2 == 1
+
+				var err error
+				if w%2 == 0 {
+					err = s.Update(ctx, func(t *Tables) error {
+						t.Channels.Put(id, models.Channel{ULID: id})
+						if fail {
+							return failed
+						}
+						return nil
+					})
+				} else {
+					err = s.Transaction(ctx, func(ctx context.Context) error {
+						if err := s.Update(ctx, func(t *Tables) error {
+							t.Channels.Put(id, models.Channel{ULID: id})
+							return nil
+						}); err != nil {
+							return err
+						}
+						if _, err := Query(ctx, s, func(t *Tables) bool { return t.Channels.Has(id) }); err != nil {
+							return err
+						}
+						if fail {
+							return failed
+						}
+						return nil
+					})
+				}
+				if fail != errors.Is(err, failed) {
+					t.Errorf("%s: err = %v, want failure %v", id, err, fail)
+				}
+			}
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range rounds {
+				if _, err := Query(ctx, s, func(t *Tables) int { return t.Channels.Len() }); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := Query(ctx, s, func(t *Tables) map[string]bool {
+		ids := map[string]bool{}
+		for id := range t.Channels.All() {
+			ids[id] = true
+		}
+		return ids
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != workers*rounds/2 {
+		t.Errorf("%d channels left; want the %d put by updates that succeeded", len(got), workers*rounds/2)
+	}
+	for id := range got {
+		var w, i int
+		fmt.Sscanf(id, "%d-%d", &w, &i)
+		if i%2 == 1 {
+			t.Errorf("channel %s was put by a failed update", id)
+		}
+	}
+}
+
+func TestRollback(t *testing.T) {
+	ctx := context.Background()
+	failed := errors.New("failed")
+
+	newStore := func(t *testing.T) *Store {
+		t.Helper()
+		s := New()
+		err := s.Update(ctx, func(t *Tables) error {
+			t.Channels.Put("kept", models.Channel{ULID: "kept", Name: "before"})
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	// unchanged checks that s is as newStore left it.
+	unchanged := func(t *testing.T, s *Store) {
+		t.Helper()
+		channels, err := Query(ctx, s, func(t *Tables) []models.Channel {
+			var channels []models.Channel
+			for c := range t.Channels.Values() {
+				channels = append(channels, c)
+			}
+			return channels
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(channels) != 1 || channels[0].Name != "before" {
+			t.Fatalf("channels = %+v; want only the one from before", channels)
+		}
+	}
+	change := func(t *Tables) {
+		c := t.Channels.Get("kept")
+		c.Name = "after"
+		t.Channels.Put("kept", *c)
+		t.Channels.Put("new", models.Channel{ULID: "new"})
+	}
+
+	t.Run("an update that fails", func(t *testing.T) {
+		s := newStore(t)
+		err := s.Update(ctx, func(t *Tables) error {
+			change(t)
+			return failed
+		})
+		if !errors.Is(err, failed) {
+			t.Fatalf("Update = %v; want its error", err)
+		}
+		unchanged(t, s)
+	})
+
+	t.Run("an update that panics", func(t *testing.T) {
+		s := newStore(t)
+		func() {
+			defer func() { recover() }()
+			s.Update(ctx, func(t *Tables) error {
+				change(t)
+				panic("boom")
+			})
+		}()
+		unchanged(t, s)
+	})
+
+	t.Run("a transaction that fails after its updates succeeded", func(t *testing.T) {
+		s := newStore(t)
+		err := s.Transaction(ctx, func(ctx context.Context) error {
+			if err := s.Update(ctx, func(t *Tables) error {
+				change(t)
+				return nil
+			}); err != nil {
+				return err
+			}
+			return failed
+		})
+		if !errors.Is(err, failed) {
+			t.Fatalf("Transaction = %v; want its error", err)
+		}
+		unchanged(t, s)
+	})
+
+	t.Run("a failed update inside a transaction that carries on", func(t *testing.T) {
+		s := newStore(t)
+		err := s.Transaction(ctx, func(ctx context.Context) error {
+			if err := s.Update(ctx, func(t *Tables) error {
+				change(t)
+				return failed
+			}); !errors.Is(err, failed) {
+				return fmt.Errorf("Update = %v; want its error", err)
+			}
+			return s.Update(ctx, func(t *Tables) error {
+				t.Channels.Put("other", models.Channel{ULID: "other"})
+				return nil
+			})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.View(ctx, func(tables *Tables) error {
+			if tables.Channels.Get("kept").Name != "before" || tables.Channels.Has("new") || !tables.Channels.Has("other") {
+				t.Errorf("channels = %v; want only the second update's change", tables.Channels.rows)
+			}
+			return nil
+		})
+	})
+
+	t.Run("IDs handed out by a rolled-back update", func(t *testing.T) {
+		s := newStore(t)
+		before := s.tables.nextID
+		s.Update(ctx, func(t *Tables) error {
+			var m models.Channel
+			t.Stamp(&m.Model)
+			return failed
+		})
+		if s.tables.nextID != before {
+			t.Fatalf("next ID = %d after a rollback; want %d", s.tables.nextID, before)
+		}
+	})
+}
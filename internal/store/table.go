@@ -0,0 +1,197 @@
+package store
+
+import (
+	"bytes"
+	"encoding/gob"
+	"iter"
+	"maps"
+	"slices"
+)
+
+// Table holds records by value, keyed by their ID. Changes made through Put
+// and Delete are recorded in the tables' change log, so a failed update can
+// be undone and a committed one written to the write-ahead log.
+type Table[K comparable, V any] struct {
+	// name identifies the table in the write-ahead log and snapshots. It
+	// is empty for indexes, which are rebuilt on load instead.
+	name string
+	rows map[K]V
+	log  *changeLog
+}
+
+func newTable[K comparable, V any](log *changeLog, name string) *Table[K, V] {
+	return &Table[K, V]{name: name, rows: map[K]V{}, log: log}
+}
+
+// Get returns a copy of the record stored under key, or nil.
+func (t *Table[K, V]) Get(key K) *V {
+	v, ok := t.rows[key]
+	if !ok {
+		return nil
+	}
+	return &v
+}
+
+// Lookup returns the record stored under key and whether there is one.
+func (t *Table[K, V]) Lookup(key K) (V, bool) {
+	v, ok := t.rows[key]
+	return v, ok
+}
+
+func (t *Table[K, V]) Has(key K) bool {
+	_, ok := t.rows[key]
+	return ok
+}
+
+func (t *Table[K, V]) Len() int {
+	return len(t.rows)
+}
+
+// All iterates over the records in no particular order. Records may be put
+// or deleted while iterating.
+func (t *Table[K, V]) All() iter.Seq2[K, V] {
+	return maps.All(t.rows)
+}
+
+func (t *Table[K, V]) Values() iter.Seq[V] {
+	return maps.Values(t.rows)
+}
+
+// Find returns a copy of a record matching match, or nil. It is meant for
+// lookups by a unique field.
+func (t *Table[K, V]) Find(match func(v *V) bool) *V {
+	for _, v := range t.rows {
+		if match(&v) {
+			return &v
+		}
+	}
+	return nil
+}
+
+// Filter returns copies of the records matching match, ordered by cmp.
+func (t *Table[K, V]) Filter(match func(v *V) bool, cmp func(a, b *V) int) []*V {
+	var out []*V
+	for _, v := range t.rows {
+		if match(&v) {
+			out = append(out, &v)
+		}
+	}
+	if cmp != nil {
+		slices.SortFunc(out, cmp)
+	}
+	return out
+}
+
+func (t *Table[K, V]) Put(key K, v V) {
+	t.record(key, &v)
+	t.rows[key] = v
+}
+
+func (t *Table[K, V]) Delete(key K) {
+	if _, ok := t.rows[key]; !ok {
+		return
+	}
+	t.record(key, nil)
+	delete(t.rows, key)
+}
+
+// record notes the change about to be made to key, v being nil for a
+// deletion.
+func (t *Table[K, V]) record(key K, v *V) {
+	prev, existed := t.rows[key]
+	c := change{undo: func() {
+		if existed {
+			t.rows[key] = prev
+		} else {
+			delete(t.rows, key)
+		}
+	}}
+	if t.name != "" {
+		c.entry = func() (logEntry, error) {
+			return t.encode(key, v)
+		}
+	}
+	t.log.changes = append(t.log.changes, c)
+}
+
+// encode turns a change to key into a log entry, v being nil for a
+// deletion.
+func (t *Table[K, V]) encode(key K, v *V) (logEntry, error) {
+	e := logEntry{Table: t.name, Delete: v == nil}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(key); err != nil {
+		return logEntry{}, err
+	}
+	e.Key = buf.Bytes()
+
+	if v != nil {
+		buf = bytes.Buffer{}
+		if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+			return logEntry{}, err
+		}
+		e.Value = buf.Bytes()
+	}
+	return e, nil
+}
+
+// apply replays a log entry without recording it.
+func (t *Table[K, V]) apply(e logEntry) error {
+	var key K
+	if err := gob.NewDecoder(bytes.NewReader(e.Key)).Decode(&key); err != nil {
+		return err
+	}
+	if e.Delete {
+		delete(t.rows, key)
+		return nil
+	}
+
+	var v V
+	if err := gob.NewDecoder(bytes.NewReader(e.Value)).Decode(&v); err != nil {
+		return err
+	}
+	t.rows[key] = v
+	return nil
+}
+
+func (t *Table[K, V]) tableName() string {
+	return t.name
+}
+
+// snapshot copies the rows, to be called under the store's lock, and
+// returns how many there are and the log entries putting each of them.
+// Entries are encoded as they are iterated, which may happen after the
+// lock is released.
+func (t *Table[K, V]) snapshot() (int, tableSnapshot) {
+	rows := maps.Clone(t.rows)
+	return len(rows), func(yield func(logEntry, error) bool) {
+		for key, v := range rows {
+			if !yield(t.encode(key, &v)) {
+				return
+			}
+		}
+	}
+}
+
+// persistent is a table as the write-ahead log and snapshots see it.
+type persistent interface {
+	tableName() string
+	apply(e logEntry) error
+	snapshot() (int, tableSnapshot)
+}
+
+type tableSnapshot = iter.Seq2[logEntry, error]
+
+// change is a change made to a table: undo reverts it and entry, nil for
+// indexes, encodes it for the write-ahead log.
+type change struct {
+	undo  func()
+	entry func() (logEntry, error)
+}
+
+// changeLog collects the changes made by an update or transaction until it
+// commits or rolls back.
+type changeLog struct {
+	changes []change
+	nextID  uint
+}
@@ -0,0 +1,319 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// logEntry is a change to one record: the record's key and, unless it was
+// deleted, its new value, both gob-encoded.
+type logEntry struct {
+	Table  string
+	Key    []byte
+	Value  []byte
+	Delete bool
+}
+
+// logBatch is the changes of one committed update or transaction, which are
+// replayed as a whole or not at all.
+type logBatch struct {
+	NextID  uint
+	Entries []logEntry
+}
+
+// Frames hold one record of the write-ahead log or a snapshot: the length of
+// the payload and its CRC-32C, then the payload.
+const (
+	frameHeaderSize = 8
+	maxFrameSize    = 1 << 30
+)
+
+var (
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+	// errTorn is a frame cut short, as left by a crash in the middle of a
+	// write.
+	errTorn = errors.New("incomplete record")
+	// errChecksum is a frame whose payload does not match its checksum.
+	errChecksum = errors.New("checksum mismatch")
+)
+
+func encodeFrame(v any) ([]byte, error) {
+	buf := bytes.NewBuffer(make([]byte, frameHeaderSize))
+	if err := gob.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+
+	frame := buf.Bytes()
+	payload := frame[frameHeaderSize:]
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crcTable))
+	return frame, nil
+}
+
+// readFrame reads the next frame into v, returning its size. It returns
+// io.EOF at the end of r, errTorn if r ends inside the frame and
+// errChecksum if the frame is corrupt.
+func readFrame(r *bufio.Reader, v any) (int64, error) {
+	var header [frameHeaderSize]byte
+	n, err := io.ReadFull(r, header[:])
+	if err == io.EOF {
+		return 0, io.EOF
+	}
+	if err != nil {
+		return int64(n), errTorn
+	}
+
+	size := binary.LittleEndian.Uint32(header[0:4])
+	if size > maxFrameSize {
+		return frameHeaderSize, errChecksum
+	}
+	payload := make([]byte, size)
+	if n, err := io.ReadFull(r, payload); err != nil {
+		return frameHeaderSize + int64(n), errTorn
+	}
+	if crc32.Checksum(payload, crcTable) != binary.LittleEndian.Uint32(header[4:8]) {
+		return frameHeaderSize + int64(size), errChecksum
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(v); err != nil {
+		return frameHeaderSize + int64(size), fmt.Errorf("%w: %v", errChecksum, err)
+	}
+	return frameHeaderSize + int64(size), nil
+}
+
+// SyncPolicy decides when writes to the write-ahead log are flushed to
+// disk.
+type SyncPolicy string
+
+const (
+	// SyncAlways flushes every commit before it returns, so nothing
+	// committed is lost.
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval flushes in the background every SyncInterval, so a
+	// crash loses at most that much.
+	SyncInterval SyncPolicy = "interval"
+	// SyncNever leaves flushing to the operating system.
+	SyncNever SyncPolicy = "never"
+)
+
+// wal is the write-ahead log: a numbered series of segments, each holding
+// the batches committed after the previous one. Taking a snapshot starts a
+// new segment, and the segments before it are deleted once the snapshot
+// is written.
+type wal struct {
+	mu     sync.Mutex
+	dir    string
+	policy SyncPolicy
+	f      *os.File
+	seq    uint64
+	size   int64
+	dirty  bool
+	// err is set once a failed write or flush leaves the log in an unknown
+	// state; nothing more is written after that.
+	err error
+}
+
+func segmentName(seq uint64) string {
+	return fmt.Sprintf("wal-%016d.log", seq)
+}
+
+// segments lists the sequence numbers of the segments in dir, in order.
+func segments(dir string) ([]uint64, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "wal-*.log"))
+	if err != nil {
+		return nil, err
+	}
+
+	var seqs []uint64
+	for _, name := range names {
+		num := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(name), "wal-"), ".log")
+		seq, err := strconv.ParseUint(num, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected file %s", name)
+		}
+		seqs = append(seqs, seq)
+	}
+	slices.Sort(seqs)
+	return seqs, nil
+}
+
+func openWAL(dir string, seq uint64, policy SyncPolicy) (*wal, error) {
+	w := &wal{dir: dir, policy: policy}
+	if err := w.open(seq); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *wal) open(seq uint64) error {
+	f, err := os.OpenFile(filepath.Join(w.dir, segmentName(seq)), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := syncDir(w.dir); err != nil {
+		f.Close()
+		return err
+	}
+	w.f, w.seq, w.size, w.dirty = f, seq, 0, false
+	return nil
+}
+
+// append writes a batch to the log. If the write fails, whatever part of it
+// made it to the file is cut off again.
+func (w *wal) append(b *logBatch) error {
+	frame, err := encodeFrame(b)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+
+	if _, err := w.f.Write(frame); err != nil {
+		if terr := w.f.Truncate(w.size); terr != nil {
+			w.err = fmt.Errorf("write-ahead log is unusable after a failed write: %w", err)
+		}
+		return err
+	}
+	w.size += int64(len(frame))
+
+	if w.policy == SyncAlways {
+		return w.syncLocked()
+	}
+	w.dirty = true
+	return nil
+}
+
+// sync flushes the current segment if it has unflushed writes.
+func (w *wal) sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil || !w.dirty {
+		return w.err
+	}
+	return w.syncLocked()
+}
+
+// syncLocked flushes the current segment. A failed flush may have lost
+// writes the log cannot tell which, so the log is given up on.
+func (w *wal) syncLocked() error {
+	if err := w.f.Sync(); err != nil {
+		w.err = fmt.Errorf("write-ahead log is unusable after a failed flush: %w", err)
+		return w.err
+	}
+	w.dirty = false
+	return nil
+}
+
+// rotate flushes and closes the current segment and starts the next one,
+// returning the number of the closed segment.
+func (w *wal) rotate() (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	if err := w.syncLocked(); err != nil {
+		return 0, err
+	}
+	if err := w.f.Close(); err != nil {
+		return 0, err
+	}
+	closed := w.seq
+	if err := w.open(closed + 1); err != nil {
+		w.err = fmt.Errorf("cannot start a write-ahead log segment: %w", err)
+		return 0, w.err
+	}
+	return closed, nil
+}
+
+// empty reports whether nothing has been written since the segment began.
+func (w *wal) empty() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.size == 0
+}
+
+// replaySegment applies the batches in a segment to the tables. A batch cut
+// short or corrupt at the very end of the last segment is what a crash
+// during a write leaves behind; it was never committed, so it is cut off.
+// Damage anywhere else is reported.
+func replaySegment(t *Tables, dir string, seq uint64, last bool) error {
+	path := filepath.Join(dir, segmentName(seq))
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		var b logBatch
+		n, err := readFrame(r, &b)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if last && (errors.Is(err, errTorn) || offset+n == info.Size()) {
+				if err := f.Truncate(offset); err != nil {
+					return err
+				}
+				return f.Sync()
+			}
+			return fmt.Errorf("%s: record at offset %d: %w", path, offset, err)
+		}
+
+		if err := t.applyBatch(&b); err != nil {
+			return fmt.Errorf("%s: record at offset %d: %w", path, offset, err)
+		}
+		offset += n
+	}
+}
+
+func (t *Tables) applyBatch(b *logBatch) error {
+	for _, e := range b.Entries {
+		tbl := t.table(e.Table)
+		if tbl == nil {
+			return fmt.Errorf("unknown table %q", e.Table)
+		}
+		if err := tbl.apply(e); err != nil {
+			return fmt.Errorf("table %s: %w", e.Table, err)
+		}
+	}
+	t.nextID = b.NextID
+	return nil
+}
+
+// syncDir flushes a directory, making files created or renamed in it
+// durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestReadFrame(t *testing.T) {
+	batch := &logBatch{NextID: 7, Entries: []logEntry{{Table: "users", Key: []byte("k"), Value: []byte("v")}}}
+	frame, err := encodeFrame(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(data []byte) (*logBatch, int64, error) {
+		var b logBatch
+		n, err := readFrame(bufio.NewReader(bytes.NewReader(data)), &b)
+		return &b, n, err
+	}
+
+	t.Run("intact", func(t *testing.T) {
+		b, n, err := read(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(len(frame)) || b.NextID != 7 || len(b.Entries) != 1 || b.Entries[0].Table != "users" {
+			t.Fatalf("read %d bytes: %+v; want the whole batch back", n, b)
+		}
+		if _, _, err := read(nil); err != io.EOF {
+			t.Fatalf("reading an empty log: %v; want io.EOF", err)
+		}
+	})
+
+	t.Run("payload does not match its CRC-32C", func(t *testing.T) {
+		corrupt := bytes.Clone(frame)
+		corrupt[len(corrupt)-1] ^= 0xff
+		if _, n, err := read(corrupt); !errors.Is(err, errChecksum) || n != int64(len(frame)) {
+			t.Fatalf("read %d bytes: %v; want a checksum mismatch over the whole frame", n, err)
+		}
+	})
+
+	t.Run("cut short", func(t *testing.T) {
+		for _, size := range []int{frameHeaderSize / 2, frameHeaderSize, len(frame) - 1} {
+			if _, _, err := read(frame[:size]); !errors.Is(err, errTorn) {
+				t.Errorf("frame cut to %d bytes: %v; want errTorn", size, err)
+			}
+		}
+	})
+}
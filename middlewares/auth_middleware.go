@@ -1,31 +1,76 @@
 package middlewares
 
 import (
-	"net/http"
+	"fmt"
 
+	"rio/internal/apperr"
 	"rio/utils/token"
 
 	"github.com/gin-gonic/gin"
 )
 
-func JwtAuthMiddleware() gin.HandlerFunc {
+// JwtAuthMiddleware authenticates users by their JWT access token and bots
+// by their API token, setting user_id (and is_bot for bots) on the context.
+// An error of a checker that is not an apperr is passed on as it is, to be
+// answered with a 500.
+func JwtAuthMiddleware(revocations token.RevocationChecker, sessions token.SessionChecker, bots token.BotAuthenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		err := token.TokenValid(c)
+		if apiToken := token.ExtractBotToken(c); apiToken != "" {
+			botID, err := bots.AuthenticateBot(c.Request.Context(), apiToken)
+			if err != nil {
+				c.Error(err)
+				c.Abort()
+				return
+			}
+
+			c.Set("user_id", botID)
+			c.Set("is_bot", true)
+
+			c.Next()
+			return
+		}
+
+		claims, err := token.ExtractClaims(c)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Error(apperr.Unauthorized("%w", err))
 			c.Abort()
 			return
 		}
 
-		userID, err := token.ExtractTokenID(c)
+		revoked, err := revocations.IsAccessTokenRevoked(c.Request.Context(), claims.ID)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims"})
+			c.Error(fmt.Errorf("could not verify token: %w", err))
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.Error(apperr.Unauthorized("token has been revoked"))
+			c.Abort()
+			return
+		}
+
+		if err := sessions.CheckSession(c.Request.Context(), claims.SessionID); err != nil {
+			c.Error(err)
 			c.Abort()
 			return
 		}
 
-		c.Set("user_id", userID)
+		c.Set("user_id", claims.Subject)
+		c.Set("session_id", claims.SessionID)
+
+		c.Next()
+	}
+}
 
+// HumanOnlyMiddleware rejects bots from routes that manage a person's own
+// account, such as passwords, sessions and second factors.
+func HumanOnlyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool("is_bot") {
+			c.Error(apperr.Forbidden("bots cannot use this endpoint"))
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
@@ -0,0 +1,79 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/utils/token"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeAuth struct {
+	revoked    bool
+	sessionErr error
+	botErr     error
+}
+
+func (f *fakeAuth) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	return f.revoked, nil
+}
+
+func (f *fakeAuth) CheckSession(ctx context.Context, sessionID string) error {
+	return f.sessionErr
+}
+
+func (f *fakeAuth) AuthenticateBot(ctx context.Context, apiToken string) (string, error) {
+	return "bot", f.botErr
+}
+
+func TestJwtAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	if err := token.Configure(token.Settings{Alg: "HS256", Secret: "secret", AccessLifespan: time.Minute, RefreshLifespan: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	access, err := token.GenerateToken("user", "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := errors.New("database is down")
+
+	tests := []struct {
+		name          string
+		authorization string
+		auth          fakeAuth
+		status        int
+	}{
+		{"valid token", "Bearer " + access, fakeAuth{}, http.StatusOK},
+		{"no token", "", fakeAuth{}, http.StatusUnauthorized},
+		{"malformed token", "Bearer not-a-jwt", fakeAuth{}, http.StatusUnauthorized},
+		{"revoked token", "Bearer " + access, fakeAuth{revoked: true}, http.StatusUnauthorized},
+		{"revoked session", "Bearer " + access, fakeAuth{sessionErr: apperr.Unauthorized("session has been revoked")}, http.StatusUnauthorized},
+		{"session check fails", "Bearer " + access, fakeAuth{sessionErr: down}, http.StatusInternalServerError},
+		{"valid bot token", "Bot token", fakeAuth{}, http.StatusOK},
+		{"invalid bot token", "Bot token", fakeAuth{botErr: apperr.Unauthorized("invalid bot token")}, http.StatusUnauthorized},
+		{"bot check fails", "Bot token", fakeAuth{botErr: down}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(ErrorMiddleware(), JwtAuthMiddleware(&tt.auth, &tt.auth, &tt.auth))
+			router.GET("/", func(c *gin.Context) { c.String(http.StatusOK, c.GetString("user_id")) })
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d; want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
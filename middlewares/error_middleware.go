@@ -0,0 +1,68 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+
+	"rio/internal/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorResponse is the body of every error answer of the API. Error is the
+// human-readable message and Code its machine-readable kind, one of the
+// apperr.Kind values.
+type ErrorResponse struct {
+	Error      string              `json:"error"`
+	Code       apperr.Kind         `json:"code"`
+	RequestID  string              `json:"request_id,omitempty"`
+	Fields     []apperr.FieldError `json:"fields,omitempty"`
+	RetryAfter int                 `json:"retry_after,omitempty"`
+}
+
+// ErrorMiddleware renders the last error handlers and middlewares attached
+// with c.Error, if they did not answer themselves. Errors that are not an
+// *apperr.Error are internal: they are logged and answered with a generic
+// message, except for expired request deadlines, which are answered with
+// 504. Nothing is written to clients that have gone away. Register it
+// right after RequestIDMiddleware, ahead of the middlewares whose errors it
+// renders.
+func ErrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		if errors.Is(c.Request.Context().Err(), context.Canceled) {
+			return
+		}
+		err := c.Errors.Last().Err
+
+		res := ErrorResponse{
+			Error:     err.Error(),
+			Code:      apperr.KindOf(err),
+			RequestID: c.GetString("request_id"),
+		}
+		if e := apperr.As(err); e != nil {
+			res.Fields = e.Fields
+			res.RetryAfter = e.RetryAfter
+		}
+
+		switch {
+		case res.Code == apperr.KindInternal:
+			log.Printf("request %s: %s %s: %v", res.RequestID, c.Request.Method, c.FullPath(), err)
+			res.Error = "internal server error"
+		case res.Code == apperr.KindTimeout && apperr.As(err) == nil:
+			log.Printf("request %s: %s %s: %v", res.RequestID, c.Request.Method, c.FullPath(), err)
+			res.Error = "request timed out"
+		}
+		if res.RetryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(res.RetryAfter))
+		}
+
+		c.JSON(res.Code.Status(), res)
+	}
+}
@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"rio/internal/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FederationAuthenticator verifies a request signed by another rio instance
+// and returns that instance and the user there the request acts for.
+type FederationAuthenticator interface {
+	Authenticate(req *http.Request, body []byte) (string, string, error)
+}
+
+// FederationAuthMiddleware authenticates requests between instances,
+// setting federation_origin and federation_user on the context. The body is
+// read up to maxBody bytes to check its digest and put back for the
+// handler.
+func FederationAuthMiddleware(auth FederationAuthenticator, maxBody int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBody+1))
+		if err != nil {
+			c.Error(apperr.Invalid("cannot read request body"))
+			c.Abort()
+			return
+		}
+		if int64(len(body)) > maxBody {
+			c.Error(apperr.TooLarge("request body is too large"))
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		origin, userID, err := auth.Authenticate(c.Request, body)
+		if err != nil {
+			c.Error(apperr.Unauthorized("%w", err))
+			c.Abort()
+			return
+		}
+
+		c.Set("federation_origin", origin)
+		c.Set("federation_user", userID)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"rio/internal/apperr"
+	"rio/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitMiddleware takes a token from the named bucket for every request.
+// Buckets are per user when the request is authenticated (so it must run
+// after JwtAuthMiddleware on protected routes) and per client IP otherwise.
+// If the store fails the request is let through rather than turning an
+// outage of the store into an outage of the API.
+func RateLimitMiddleware(store ratelimit.Store, bucket string, limit ratelimit.Limit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := bucket + ":ip:" + c.ClientIP()
+		if userID := c.GetString("user_id"); userID != "" {
+			key = bucket + ":user:" + userID
+		}
+
+		res, err := store.Take(key, limit)
+		if err != nil {
+			log.Printf("rate limit %s: %v", bucket, err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+		if !res.Allowed {
+			c.Error(apperr.RateLimited(ceilSeconds(res.RetryAfter), "rate limit exceeded"))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,111 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeouts holds the deadline of requests to each route. Routes are written
+// as "METHOD /path" with the path as it was registered, e.g.
+// "POST /api/channels/:id/messages". Routes without an entry get Default,
+// and a zero duration means no deadline.
+type Timeouts struct {
+	Default time.Duration
+	Routes  map[string]time.Duration
+}
+
+// For returns the deadline of requests to the route.
+func (t Timeouts) For(method, path string) time.Duration {
+	if d, ok := t.Routes[method+" "+path]; ok {
+		return d
+	}
+	return t.Default
+}
+
+// DefaultRequestTimeout is the deadline of routes not given one of their
+// own.
+const DefaultRequestTimeout = 10 * time.Second
+
+// defaultRouteTimeouts lists the routes that need other than the default.
+// The gateway streams events for as long as the client stays connected.
+var defaultRouteTimeouts = map[string]time.Duration{
+	"GET /api/gateway": 0,
+}
+
+// NewTimeouts gives every route the deadline def, except for those listed
+// in routes as overrides such as "POST /api/channels/:id/messages=30s" or
+// "GET /api/users=0".
+func NewTimeouts(def time.Duration, routes []string) (Timeouts, error) {
+	if def < 0 {
+		return Timeouts{}, fmt.Errorf("invalid timeout %s: must not be negative", def)
+	}
+
+	t := Timeouts{
+		Default: def,
+		Routes:  make(map[string]time.Duration, len(defaultRouteTimeouts)),
+	}
+	for route, d := range defaultRouteTimeouts {
+		t.Routes[route] = d
+	}
+
+	for _, entry := range routes {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, v, ok := strings.Cut(entry, "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok || !hasPath || !strings.HasPrefix(strings.TrimSpace(path), "/") {
+			return Timeouts{}, fmt.Errorf("invalid route timeout %q; must be METHOD /path=duration", entry)
+		}
+		d, err := parseTimeout(v)
+		if err != nil {
+			return Timeouts{}, fmt.Errorf("%s: %w", route, err)
+		}
+		t.Routes[strings.ToUpper(method)+" "+strings.TrimSpace(path)] = d
+	}
+
+	return t, nil
+}
+
+func parseTimeout(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "0" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid timeout %q: must be a duration such as 10s, or 0 for none", s)
+	}
+	return d, nil
+}
+
+// TimeoutMiddleware puts a deadline on the request's context, chosen by
+// its route. Handlers hand that context to the services, which pass it on
+// to their repositories, so their work stops when the deadline passes or
+// the client goes away, and ErrorMiddleware answers the errors they return
+// with 504. Work that outlives the request, such as webhook deliveries and
+// federation relays, runs under a context of its own.
+func TimeoutMiddleware(timeouts Timeouts) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		d := timeouts.For(c.Request.Method, c.FullPath())
+		if d <= 0 {
+			c.Next()
+			return
+		}
+
+		// The original request is put back afterwards, so middlewares that
+		// run after this one see whether the client itself went away.
+		req := c.Request
+		ctx, cancel := context.WithTimeout(req.Context(), d)
+		defer cancel()
+
+		c.Request = req.WithContext(ctx)
+		c.Next()
+		c.Request = req
+	}
+}
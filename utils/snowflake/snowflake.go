@@ -0,0 +1,44 @@
+package snowflake
+
+import (
+	"hash/fnv"
+	"strconv"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// Epoch is the Discord epoch, the first millisecond of 2015, in Unix
+// milliseconds.
+const Epoch = 1420070400000
+
+// FromULID derives a Discord-style snowflake from a ULID. The top 42 bits
+// hold the ULID's timestamp in milliseconds since Epoch, so snowflakes sort
+// like the ULIDs they come from; the low 22 bits are a hash of the ULID and
+// salt, which tells apart several IDs derived from the same ULID.
+//
+// Snowflakes cannot be turned back into ULIDs; callers record the ones they
+// hand out.
+func FromULID(id, salt string) (string, error) {
+	u, err := ulid.ParseStrict(id)
+	if err != nil {
+		return "", err
+	}
+
+	var ms uint64
+	if t := u.Time(); t > Epoch {
+		ms = t - Epoch
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	h.Write([]byte{0})
+	h.Write([]byte(salt))
+
+	return strconv.FormatUint(ms<<22|h.Sum64()&(1<<22-1), 10), nil
+}
+
+// Valid reports whether s looks like a snowflake.
+func Valid(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 64)
+	return err == nil
+}
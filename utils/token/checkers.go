@@ -0,0 +1,25 @@
+package token
+
+import "context"
+
+// The checkers below are what the REST API and the IRC gateway need to
+// authenticate a client beyond verifying its token. They report a
+// credential they refuse as an apperr; any other error means the check
+// itself failed.
+
+// RevocationChecker reports whether an access token, named by its ID, has
+// been revoked before it expired.
+type RevocationChecker interface {
+	IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// SessionChecker checks the login session an access token was issued for
+// is still live.
+type SessionChecker interface {
+	CheckSession(ctx context.Context, sessionID string) error
+}
+
+// BotAuthenticator resolves a bot API token to the bot's user ID.
+type BotAuthenticator interface {
+	AuthenticateBot(ctx context.Context, apiToken string) (string, error)
+}
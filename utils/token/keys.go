@@ -0,0 +1,470 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"iter"
+	"maps"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/oklog/ulid/v2"
+)
+
+// Signing keys for EdDSA and RS256 live in Settings.KeysDir as PKCS#8 PEM
+// files named <kid>.pem, where the kid is a ULID minted when the key was
+// generated. Every key in the directory is accepted for verification; the
+// newest key of the configured algorithm that is older than
+// keyActivationDelay signs new tokens, which gives every instance time to
+// pick up a rotated key before tokens signed with it reach them.
+const (
+	keyActivationDelay = 5 * time.Minute
+	keyReloadInterval  = time.Minute
+)
+
+type signingKey struct {
+	kid     string
+	created time.Time
+	method  jwt.SigningMethod
+	private crypto.Signer
+}
+
+type keySet struct {
+	mu       sync.RWMutex
+	loadedAt time.Time
+	keys     map[string]*signingKey
+}
+
+var keys = &keySet{keys: map[string]*signingKey{}}
+
+// Settings describe how tokens are signed and how long they last.
+type Settings struct {
+	// Alg is the algorithm new tokens are signed with: HS256, EdDSA or
+	// RS256.
+	Alg string
+	// Secret is the HS256 key.
+	Secret string
+	// KeysDir holds the EdDSA and RS256 signing keys.
+	KeysDir string
+	// VerifyKeys accepts tokens signed with the keys in KeysDir while
+	// signing with HS256, e.g. until the tokens issued before switching
+	// to HS256 expire. They are always accepted when Alg is asymmetric.
+	VerifyKeys bool
+
+	AccessLifespan  time.Duration
+	RefreshLifespan time.Duration
+}
+
+var settings = Settings{Alg: jwt.SigningMethodHS256.Alg(), KeysDir: "keys"}
+
+// SigningAlgorithm returns the name jwt uses for alg, which may be given in
+// any case, with Ed25519 standing for EdDSA. An empty alg means HS256.
+func SigningAlgorithm(alg string) (string, error) {
+	switch strings.ToUpper(strings.TrimSpace(alg)) {
+	case "", "HS256":
+		return jwt.SigningMethodHS256.Alg(), nil
+	case "EDDSA", "ED25519":
+		return jwt.SigningMethodEdDSA.Alg(), nil
+	case "RS256":
+		return jwt.SigningMethodRS256.Alg(), nil
+	}
+	return "", fmt.Errorf("unknown signing algorithm %q; must be HS256, EdDSA or RS256", alg)
+}
+
+func signingAlgorithm() string {
+	return settings.Alg
+}
+
+func keysDir() string {
+	return settings.KeysDir
+}
+
+// verifiesKeys reports whether tokens signed with the keys in KeysDir are
+// accepted.
+func verifiesKeys() bool {
+	return signingAlgorithm() != jwt.SigningMethodHS256.Alg() || settings.VerifyKeys
+}
+
+// Configure sets how tokens are signed and how long they last, and reads
+// the signing keys from s.KeysDir unless tokens are signed with HS256
+// without s.VerifyKeys. It is called once, before any token is issued.
+func Configure(s Settings) error {
+	alg, err := SigningAlgorithm(s.Alg)
+	if err != nil {
+		return err
+	}
+	s.Alg = alg
+	settings = s
+
+	if !verifiesKeys() {
+		return nil
+	}
+	if err := keys.reload(); err != nil {
+		return err
+	}
+	if signingAlgorithm() == jwt.SigningMethodHS256.Alg() {
+		return nil
+	}
+	if _, err := keys.current(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ks *keySet) reload() error {
+	loaded, err := readKeyDir(keysDir())
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[string]*signingKey, len(loaded))
+	for _, k := range loaded {
+		byID[k.kid] = k
+	}
+
+	ks.mu.Lock()
+	ks.keys = byID
+	ks.loadedAt = time.Now()
+	ks.mu.Unlock()
+
+	return nil
+}
+
+func (ks *keySet) stale() bool {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return time.Since(ks.loadedAt) > keyReloadInterval
+}
+
+// current returns the key new tokens should be signed with.
+func (ks *keySet) current() (*signingKey, error) {
+	if ks.stale() {
+		if err := ks.reload(); err != nil {
+			return nil, err
+		}
+	}
+
+	alg := signingAlgorithm()
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	if k := signingKeyOf(maps.Values(ks.keys), alg); k != nil {
+		return k, nil
+	}
+	return nil, fmt.Errorf("no %s signing key found in %s", alg, keysDir())
+}
+
+// signingKeyOf returns the key of alg that signs new tokens: the newest one
+// older than keyActivationDelay or, before any is, the newest one. It is
+// nil when there is no key of alg.
+func signingKeyOf(keys iter.Seq[*signingKey], alg string) *signingKey {
+	var newest, newestActive *signingKey
+	for k := range keys {
+		if k.method.Alg() != alg {
+			continue
+		}
+		if newest == nil || k.kid > newest.kid {
+			newest = k
+		}
+		if k.active() && (newestActive == nil || k.kid > newestActive.kid) {
+			newestActive = k
+		}
+	}
+
+	if newestActive != nil {
+		return newestActive
+	}
+	return newest
+}
+
+// active reports whether k is old enough to sign tokens.
+func (k *signingKey) active() bool {
+	return time.Since(k.created) >= keyActivationDelay
+}
+
+// lookup returns the key kid. Like current, it first rereads the
+// directory when it is stale, so keys rotated in by another instance are
+// found and pruned ones stop verifying tokens.
+func (ks *keySet) lookup(kid string) (*signingKey, error) {
+	if ks.stale() {
+		if err := ks.reload(); err != nil {
+			return nil, err
+		}
+	}
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	k, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %s", kid)
+	}
+	return k, nil
+}
+
+func signToken(claims jwt.Claims) (string, error) {
+	if signingAlgorithm() == jwt.SigningMethodHS256.Alg() {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(settings.Secret))
+	}
+
+	key, err := keys.current()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
+}
+
+// keyFunc resolves the verification key for a token. HS256 tokens are only
+// accepted while HS256 is the configured algorithm, and asymmetric ones
+// only while an asymmetric algorithm is or VerifyKeys is set; they must
+// name a known key in their kid header.
+func keyFunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if signingAlgorithm() != jwt.SigningMethodHS256.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(settings.Secret), nil
+	}
+	if !verifiesKeys() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token has no key ID")
+	}
+
+	key, err := keys.lookup(kid)
+	if err != nil {
+		return nil, err
+	}
+	if key.method.Alg() != token.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.private.Public(), nil
+}
+
+var validMethods = []string{
+	jwt.SigningMethodHS256.Alg(),
+	jwt.SigningMethodEdDSA.Alg(),
+	jwt.SigningMethodRS256.Alg(),
+}
+
+// JWK is a single public key in a JSON Web Key Set.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// PublicJWKS returns every verification key in JWKS form. It is empty when
+// tokens are signed with the shared HS256 secret.
+func PublicJWKS() ([]JWK, error) {
+	if signingAlgorithm() == jwt.SigningMethodHS256.Alg() {
+		return []JWK{}, nil
+	}
+	if keys.stale() {
+		if err := keys.reload(); err != nil {
+			return nil, err
+		}
+	}
+
+	keys.mu.RLock()
+	defer keys.mu.RUnlock()
+
+	jwks := make([]JWK, 0, len(keys.keys))
+	for _, k := range keys.keys {
+		jwk := JWK{Kid: k.kid, Use: "sig", Alg: k.method.Alg()}
+		switch pub := k.private.Public().(type) {
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		default:
+			continue
+		}
+		jwks = append(jwks, jwk)
+	}
+
+	sort.Slice(jwks, func(i, j int) bool { return jwks[i].Kid > jwks[j].Kid })
+	return jwks, nil
+}
+
+// KeyInfo describes a key file in the keys directory.
+type KeyInfo struct {
+	Kid     string
+	Alg     string
+	Created time.Time
+}
+
+// readKeyDir parses every <kid>.pem file in dir. A missing directory holds
+// no keys.
+func readKeyDir(dir string) ([]*signingKey, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var loaded []*signingKey
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".pem" {
+			continue
+		}
+
+		kid := strings.TrimSuffix(e.Name(), ".pem")
+		id, err := ulid.ParseStrict(kid)
+		if err != nil {
+			return nil, fmt.Errorf("key file %s: name is not a ULID key ID", e.Name())
+		}
+
+		raw, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		block, _ := pem.Decode(raw)
+		if block == nil {
+			return nil, fmt.Errorf("key file %s: no PEM block", e.Name())
+		}
+
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("key file %s: %w", e.Name(), err)
+		}
+
+		k := &signingKey{kid: kid, created: ulid.Time(id.Time())}
+		switch priv := parsed.(type) {
+		case ed25519.PrivateKey:
+			k.method = jwt.SigningMethodEdDSA
+			k.private = priv
+		case *rsa.PrivateKey:
+			k.method = jwt.SigningMethodRS256
+			k.private = priv
+		default:
+			return nil, fmt.Errorf("key file %s: unsupported key type %T", e.Name(), parsed)
+		}
+		loaded = append(loaded, k)
+	}
+
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].kid < loaded[j].kid })
+	return loaded, nil
+}
+
+// ListKeys describes the keys in dir, oldest first.
+func ListKeys(dir string) ([]KeyInfo, error) {
+	loaded, err := readKeyDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]KeyInfo, 0, len(loaded))
+	for _, k := range loaded {
+		infos = append(infos, KeyInfo{Kid: k.kid, Alg: k.method.Alg(), Created: k.created})
+	}
+	return infos, nil
+}
+
+// GenerateKey writes a new private key for alg (EdDSA or RS256) to dir and
+// returns its kid.
+func GenerateKey(dir, alg string) (string, error) {
+	var priv crypto.Signer
+	var err error
+
+	switch strings.ToUpper(alg) {
+	case "EDDSA", "ED25519":
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	case "RS256":
+		priv, err = rsa.GenerateKey(rand.Reader, 3072)
+	default:
+		return "", fmt.Errorf("unsupported signing algorithm %q; must be EdDSA or RS256", alg)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	kid := ulid.Make().String()
+	path := filepath.Join(dir, kid+".pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", err
+	}
+	return kid, nil
+}
+
+// PruneKeys deletes all but the newest keep keys from dir and returns the
+// kids it removed. The key signing tokens of each algorithm and keys not
+// yet active are always kept, so pruning right after a rotation removes
+// neither the key in use nor the one replacing it. Only prune once tokens
+// signed with the removed keys have expired.
+func PruneKeys(dir string, keep int) ([]string, error) {
+	if keep < 1 {
+		return nil, errors.New("at least one key must be kept")
+	}
+
+	loaded, err := readKeyDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(loaded) <= keep {
+		return nil, nil
+	}
+
+	inUse := map[*signingKey]bool{}
+	for _, alg := range []string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg()} {
+		if k := signingKeyOf(slices.Values(loaded), alg); k != nil {
+			inUse[k] = true
+		}
+	}
+
+	var removed []string
+	for _, k := range loaded[:len(loaded)-keep] {
+		if inUse[k] || !k.active() {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, k.kid+".pem")); err != nil {
+			return removed, err
+		}
+		removed = append(removed, k.kid)
+	}
+	return removed, nil
+}
@@ -0,0 +1,104 @@
+package token
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// backdateKey renames the key file kid in dir to a kid minted age ago, as
+// if the key had been generated then.
+func backdateKey(t *testing.T, dir, kid string, age time.Duration) string {
+	t.Helper()
+	old := ulid.MustNew(ulid.Timestamp(time.Now().Add(-age)), nil).String()
+	if err := os.Rename(filepath.Join(dir, kid+".pem"), filepath.Join(dir, old+".pem")); err != nil {
+		t.Fatal(err)
+	}
+	return old
+}
+
+func TestPruneKeysKeepsSigningAndPendingKeys(t *testing.T) {
+	dir := t.TempDir()
+
+	generate := func(alg string) string {
+		t.Helper()
+		kid, err := GenerateKey(dir, alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return kid
+	}
+
+	retired := backdateKey(t, dir, generate("EdDSA"), 48*time.Hour)
+	signing := backdateKey(t, dir, generate("EdDSA"), 24*time.Hour)
+	// Rotated in just now, so not yet signing tokens.
+	pending := generate("EdDSA")
+
+	removed, err := PruneKeys(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(removed, []string{retired}) {
+		t.Fatalf("removed %v, want only %s", removed, retired)
+	}
+
+	infos, err := ListKeys(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var left []string
+	for _, k := range infos {
+		left = append(left, k.Kid)
+	}
+	if !slices.Equal(left, []string{signing, pending}) {
+		t.Fatalf("kept %v, want %v", left, []string{signing, pending})
+	}
+}
+
+func TestKeyFuncAcceptsKeysOnlyWhenConfigured(t *testing.T) {
+	t.Cleanup(func() { settings = Settings{Alg: "HS256", KeysDir: "keys"} })
+	dir := t.TempDir()
+	kid, err := GenerateKey(dir, "EdDSA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid = backdateKey(t, dir, kid, time.Hour)
+
+	configure := func(s Settings) {
+		t.Helper()
+		s.KeysDir, s.Secret, s.AccessLifespan = dir, "secret", time.Minute
+		if err := Configure(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configure(Settings{Alg: "EdDSA"})
+	signed, err := GenerateToken("user", "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configure(Settings{Alg: "HS256"})
+	if _, err := ParseAccessToken(signed); err == nil {
+		t.Error("EdDSA token accepted while signing with HS256")
+	}
+
+	configure(Settings{Alg: "HS256", VerifyKeys: true})
+	if _, err := ParseAccessToken(signed); err != nil {
+		t.Errorf("EdDSA token refused with VerifyKeys: %v", err)
+	}
+
+	// A pruned key stops verifying tokens once the directory is reread.
+	if err := os.Remove(filepath.Join(dir, kid+".pem")); err != nil {
+		t.Fatal(err)
+	}
+	keys.mu.Lock()
+	keys.loadedAt = time.Now().Add(-2 * keyReloadInterval)
+	keys.mu.Unlock()
+	if _, err := ParseAccessToken(signed); err == nil {
+		t.Error("token signed with a pruned key accepted")
+	}
+}
@@ -1,58 +1,111 @@
 package token
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
-	"fmt"
-	"os"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/oklog/ulid/v2"
 )
 
-func GenerateToken(user_id string) (string, error) {
-	token_lifespan, err := strconv.Atoi(os.Getenv("TOKEN_HOUR_LIFESPAN"))
+const (
+	accessAudience = "rio-chat-client"
+	mfaAudience    = "rio-mfa"
 
-	if err != nil {
-		return "", err
-	}
+	mfaTicketLifespan = 5 * time.Minute
+)
 
-	claims := jwt.RegisteredClaims{
-		Issuer:    "rio",
-		Audience:  jwt.ClaimStrings{"rio-chat-client"},
-		Subject:   user_id,
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(token_lifespan))),
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-	}
+// Claims are the claims carried by rio access tokens. SessionID ties the
+// token to the login session it was issued for.
+type Claims struct {
+	SessionID string `json:"sid,omitempty"`
+	jwt.RegisteredClaims
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+func GenerateToken(user_id, session_id string) (string, error) {
+	claims := Claims{
+		SessionID: session_id,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        ulid.Make().String(),
+			Issuer:    "rio",
+			Audience:  jwt.ClaimStrings{accessAudience},
+			Subject:   user_id,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(settings.AccessLifespan)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
 
-	return token.SignedString([]byte(os.Getenv("API_SECRET")))
+	return signToken(claims)
 }
 
-func TokenValid(c *gin.Context) error {
-	tokenString := ExtractToken(c)
-
-	if tokenString == "" {
-		return errors.New("Token not found")
+// GenerateMFATicket issues the short-lived ticket a client exchanges, along
+// with a second factor, for real tokens. Its audience keeps it from being
+// accepted as an access token.
+func GenerateMFATicket(user_id string) (string, error) {
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        ulid.Make().String(),
+			Issuer:    "rio",
+			Audience:  jwt.ClaimStrings{mfaAudience},
+			Subject:   user_id,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(mfaTicketLifespan)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
 	}
 
-	_, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("API_SECRET")), nil
-	})
+	return signToken(claims)
+}
 
+func ParseMFATicket(ticket string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(ticket, claims, keyFunc, jwt.WithValidMethods(validMethods), jwt.WithAudience(mfaAudience))
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if !token.Valid || claims.Subject == "" {
+		return nil, errors.New("invalid MFA ticket")
+	}
+	return claims, nil
+}
+
+// GenerateRefreshToken returns an opaque random refresh token together with
+// its expiry. Only the hash of the token (see HashToken) is ever persisted.
+func GenerateRefreshToken() (string, time.Time, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, err
 	}
 
-	return nil
+	return base64.RawURLEncoding.EncodeToString(buf), time.Now().Add(settings.RefreshLifespan), nil
+}
+
+func HashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// BotScheme is the Authorization scheme bots send their API token with,
+// as in "Authorization: Bot <token>".
+const BotScheme = "Bot"
+
+func splitAuthorization(c *gin.Context) (string, string, bool) {
+	parts := strings.Split(c.Request.Header.Get("Authorization"), " ")
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
 }
 
+// ExtractToken returns the JWT access token from the ?token= query
+// parameter or the Authorization header. Bot API tokens are not JWTs and
+// are returned by ExtractBotToken instead.
 func ExtractToken(c *gin.Context) string {
 	token := c.Query("token")
 
@@ -60,22 +113,63 @@ func ExtractToken(c *gin.Context) string {
 		return token
 	}
 
-	bearerToken := c.Request.Header.Get("Authorization")
-	if len(strings.Split(bearerToken, " ")) == 2 {
-		return strings.Split(bearerToken, " ")[1]
+	scheme, credential, ok := splitAuthorization(c)
+	if ok && !strings.EqualFold(scheme, BotScheme) {
+		return credential
 	}
 
 	return ""
 }
 
+// ExtractBotToken returns the API token of a request sent with the Bot
+// scheme, or "" for any other request.
+func ExtractBotToken(c *gin.Context) string {
+	scheme, credential, ok := splitAuthorization(c)
+	if ok && strings.EqualFold(scheme, BotScheme) {
+		return credential
+	}
+	return ""
+}
+
+// GenerateAPIToken returns a random, non-expiring bot API token. Only its
+// HashToken digest should be stored.
+func GenerateAPIToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// ExtractClaims parses and validates the request's access token and returns
+// its claims.
+func ExtractClaims(c *gin.Context) (*Claims, error) {
+	tokenString := ExtractToken(c)
+	if tokenString == "" {
+		return nil, errors.New("Token not found")
+	}
+
+	return ParseAccessToken(tokenString)
+}
+
+// ParseAccessToken validates a raw access token, for clients that do not
+// send it in an HTTP request, and returns its claims.
+func ParseAccessToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, keyFunc, jwt.WithValidMethods(validMethods), jwt.WithAudience(accessAudience))
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid || claims.Subject == "" {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
 func ExtractTokenID(c *gin.Context) (string, error) {
 	tokenString := ExtractToken(c)
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("API_SECRET")), nil
-	})
+	token, err := jwt.Parse(tokenString, keyFunc, jwt.WithValidMethods(validMethods), jwt.WithAudience(accessAudience))
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,156 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"slices"
+)
+
+// SoftAuthenticator is an in-process authenticator holding P-256 passkeys in
+// memory. It performs the client and authenticator halves of both
+// ceremonies, so registration and passwordless login can be exercised from
+// Go code without a browser.
+type SoftAuthenticator struct {
+	Origin      string
+	credentials []*softCredential
+}
+
+type softCredential struct {
+	id         []byte
+	rpID       string
+	userHandle []byte
+	key        *ecdsa.PrivateKey
+	signCount  uint32
+}
+
+func NewSoftAuthenticator(origin string) *SoftAuthenticator {
+	return &SoftAuthenticator{Origin: origin}
+}
+
+func (a *SoftAuthenticator) clientData(ceremony, challenge string) []byte {
+	raw, _ := json.Marshal(clientData{Type: ceremony, Challenge: challenge, Origin: a.Origin})
+	return raw
+}
+
+func (a *SoftAuthenticator) authData(rpID string, flags byte, signCount uint32, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+
+	out := append([]byte{}, rpIDHash[:]...)
+	out = append(out, flags)
+	out = binary.BigEndian.AppendUint32(out, signCount)
+	return append(out, attested...)
+}
+
+// Create answers a registration ceremony with a new resident credential.
+func (a *SoftAuthenticator) Create(opts CreationOptions) (RegistrationResponse, error) {
+	var resp RegistrationResponse
+
+	if !slices.ContainsFunc(opts.PubKeyCredParams, func(p CredentialParameter) bool { return p.Alg == AlgES256 }) {
+		return resp, errors.New("soft authenticator only supports ES256")
+	}
+
+	excluded := map[string]bool{}
+	for _, d := range opts.ExcludeCredentials {
+		excluded[d.ID] = true
+	}
+	for _, c := range a.credentials {
+		if c.rpID == opts.RP.ID && excluded[EncodeID(c.id)] {
+			return resp, errors.New("authenticator already holds a credential for this user")
+		}
+	}
+
+	userHandle, err := DecodeID(opts.User.ID)
+	if err != nil {
+		return resp, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return resp, err
+	}
+
+	cred := &softCredential{id: make([]byte, 16), rpID: opts.RP.ID, userHandle: userHandle, key: key}
+	if _, err := rand.Read(cred.id); err != nil {
+		return resp, err
+	}
+
+	var x, y [32]byte
+	key.PublicKey.X.FillBytes(x[:])
+	key.PublicKey.Y.FillBytes(y[:])
+	coseKey := encodeCBOR([]cborPair{
+		{coseKty, coseKtyEC2},
+		{coseAlg, AlgES256},
+		{-1, coseCrvP256},
+		{-2, x[:]},
+		{-3, y[:]},
+	})
+
+	attested := make([]byte, 16)
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(cred.id)))
+	attested = append(attested, cred.id...)
+	attested = append(attested, coseKey...)
+
+	authData := a.authData(opts.RP.ID, flagUserPresent|flagUserVerified|flagAttestedData, 0, attested)
+	attestationObject := encodeCBOR([]cborPair{
+		{"fmt", "none"},
+		{"attStmt", []cborPair{}},
+		{"authData", authData},
+	})
+
+	a.credentials = append(a.credentials, cred)
+
+	resp.ID = EncodeID(cred.id)
+	resp.RawID = resp.ID
+	resp.Type = "public-key"
+	resp.Response.ClientDataJSON = EncodeID(a.clientData("webauthn.create", opts.Challenge))
+	resp.Response.AttestationObject = EncodeID(attestationObject)
+	resp.Response.Transports = []string{"internal"}
+	return resp, nil
+}
+
+// Get answers an authentication ceremony with the first matching
+// credential.
+func (a *SoftAuthenticator) Get(opts RequestOptions) (AssertionResponse, error) {
+	var resp AssertionResponse
+
+	allowed := map[string]bool{}
+	for _, d := range opts.AllowCredentials {
+		allowed[d.ID] = true
+	}
+
+	var cred *softCredential
+	for _, c := range a.credentials {
+		if c.rpID == opts.RPID && (len(allowed) == 0 || allowed[EncodeID(c.id)]) {
+			cred = c
+			break
+		}
+	}
+	if cred == nil {
+		return resp, errors.New("no matching credential")
+	}
+
+	cred.signCount++
+	authData := a.authData(opts.RPID, flagUserPresent|flagUserVerified, cred.signCount, nil)
+	rawClientData := a.clientData("webauthn.get", opts.Challenge)
+
+	clientDataHash := sha256.Sum256(rawClientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, cred.key, digest[:])
+	if err != nil {
+		return resp, err
+	}
+
+	resp.ID = EncodeID(cred.id)
+	resp.RawID = resp.ID
+	resp.Type = "public-key"
+	resp.Response.ClientDataJSON = EncodeID(rawClientData)
+	resp.Response.AuthenticatorData = EncodeID(authData)
+	resp.Response.Signature = EncodeID(sig)
+	resp.Response.UserHandle = EncodeID(cred.userHandle)
+	return resp, nil
+}
@@ -0,0 +1,417 @@
+// Package webauthn implements the relying-party side of WebAuthn Level 2
+// registration and authentication ceremonies for passkeys. Only "none" and
+// self-signed "packed" attestation are accepted: rio asks for no attestation,
+// so it never needs to trust authenticator vendors.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	flagUserPresent    = 0x01
+	flagUserVerified   = 0x04
+	flagBackupEligible = 0x08
+	flagBackupState    = 0x10
+	flagAttestedData   = 0x40
+	flagExtensionData  = 0x80
+
+	// Timeout is the ceremony timeout, in milliseconds, sent to clients.
+	Timeout = 5 * 60 * 1000
+)
+
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions is the JSON form of PublicKeyCredentialCreationOptions.
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     RelyingPartyEntity     `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int                    `json:"timeout"`
+	Attestation            string                 `json:"attestation"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+}
+
+// RequestOptions is the JSON form of PublicKeyCredentialRequestOptions.
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	RPID             string                 `json:"rpId"`
+	Timeout          int                    `json:"timeout"`
+	UserVerification string                 `json:"userVerification"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+}
+
+// RegistrationResponse is the JSON form of a PublicKeyCredential returned
+// by navigator.credentials.create().
+type RegistrationResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON"`
+		AttestationObject string   `json:"attestationObject"`
+		Transports        []string `json:"transports"`
+	} `json:"response"`
+}
+
+// AssertionResponse is the JSON form of a PublicKeyCredential returned by
+// navigator.credentials.get().
+type AssertionResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+}
+
+// Credential is what a relying party stores after a successful registration.
+type Credential struct {
+	ID             []byte
+	PublicKey      []byte
+	SignCount      uint32
+	AAGUID         []byte
+	Transports     []string
+	BackupEligible bool
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+// EncodeID encodes binary IDs the way WebAuthn JSON does: unpadded
+// base64url.
+func EncodeID(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeID accepts both padded and unpadded base64url.
+func DecodeID(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// NewChallenge returns a fresh random challenge in base64url.
+func NewChallenge() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return EncodeID(buf), nil
+}
+
+func descriptors(ids [][]byte) []CredentialDescriptor {
+	out := make([]CredentialDescriptor, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, CredentialDescriptor{Type: "public-key", ID: EncodeID(id)})
+	}
+	return out
+}
+
+// CreationOptions builds registration options for a user. exclude lists the
+// user's existing credential IDs so the same authenticator is not enrolled
+// twice.
+func (rp *RelyingParty) CreationOptions(challenge string, userHandle []byte, name, displayName string, exclude [][]byte) CreationOptions {
+	params := make([]CredentialParameter, 0, len(SupportedAlgorithms))
+	for _, alg := range SupportedAlgorithms {
+		params = append(params, CredentialParameter{Type: "public-key", Alg: alg})
+	}
+
+	return CreationOptions{
+		Challenge:        challenge,
+		RP:               RelyingPartyEntity{ID: rp.ID, Name: rp.Name},
+		User:             UserEntity{ID: EncodeID(userHandle), Name: name, DisplayName: displayName},
+		PubKeyCredParams: params,
+		Timeout:          Timeout,
+		Attestation:      "none",
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "required",
+			UserVerification: "required",
+		},
+		ExcludeCredentials: descriptors(exclude),
+	}
+}
+
+// RequestOptions builds authentication options. An empty allow list asks
+// the client for any discoverable credential for this relying party.
+func (rp *RelyingParty) RequestOptions(challenge string, allow [][]byte) RequestOptions {
+	return RequestOptions{
+		Challenge:        challenge,
+		RPID:             rp.ID,
+		Timeout:          Timeout,
+		UserVerification: "required",
+		AllowCredentials: descriptors(allow),
+	}
+}
+
+func (rp *RelyingParty) verifyClientData(raw []byte, ceremony, challenge string) error {
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return errors.New("malformed client data")
+	}
+
+	if cd.Type != ceremony {
+		return fmt.Errorf("unexpected client data type %q", cd.Type)
+	}
+	if subtle.ConstantTimeCompare([]byte(strings.TrimRight(cd.Challenge, "=")), []byte(challenge)) != 1 {
+		return errors.New("challenge mismatch")
+	}
+	if !slices.Contains(rp.Origins, cd.Origin) {
+		return fmt.Errorf("origin %q is not allowed", cd.Origin)
+	}
+	if cd.CrossOrigin {
+		return errors.New("cross-origin ceremonies are not allowed")
+	}
+	return nil
+}
+
+func (rp *RelyingParty) parseAuthenticatorData(raw []byte, requireAttested bool) (*authenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, errors.New("authenticator data is too short")
+	}
+
+	ad := &authenticatorData{
+		rpIDHash:  raw[:32],
+		flags:     raw[32],
+		signCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+
+	expected := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(ad.rpIDHash, expected[:]) {
+		return nil, errors.New("relying party ID mismatch")
+	}
+	if ad.flags&flagUserPresent == 0 {
+		return nil, errors.New("user was not present")
+	}
+	if ad.flags&flagUserVerified == 0 {
+		return nil, errors.New("user was not verified")
+	}
+	if ad.flags&flagBackupState != 0 && ad.flags&flagBackupEligible == 0 {
+		return nil, errors.New("invalid backup flags")
+	}
+
+	rest := raw[37:]
+
+	if ad.flags&flagAttestedData != 0 {
+		if len(rest) < 18 {
+			return nil, errors.New("attested credential data is too short")
+		}
+		ad.aaguid = rest[:16]
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+			return nil, errors.New("invalid credential ID length")
+		}
+		ad.credentialID = rest[:idLen]
+		rest = rest[idLen:]
+
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid credential public key: %w", err)
+		}
+		ad.publicKey = rest[:len(rest)-len(after)]
+		rest = after
+	} else if requireAttested {
+		return nil, errors.New("authenticator data has no attested credential")
+	}
+
+	if ad.flags&flagExtensionData != 0 {
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extension data: %w", err)
+		}
+		rest = after
+	}
+
+	if len(rest) != 0 {
+		return nil, errors.New("trailing bytes in authenticator data")
+	}
+	return ad, nil
+}
+
+// VerifyRegistration checks a registration response against the challenge
+// issued for it and returns the new credential.
+func (rp *RelyingParty) VerifyRegistration(challenge string, resp RegistrationResponse) (*Credential, error) {
+	if resp.Type != "public-key" {
+		return nil, errors.New("unexpected credential type")
+	}
+
+	rawClientData, err := DecodeID(resp.Response.ClientDataJSON)
+	if err != nil {
+		return nil, errors.New("malformed client data")
+	}
+	if err := rp.verifyClientData(rawClientData, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	rawAttestation, err := DecodeID(resp.Response.AttestationObject)
+	if err != nil {
+		return nil, errors.New("malformed attestation object")
+	}
+	decoded, _, err := decodeCBOR(rawAttestation)
+	if err != nil {
+		return nil, fmt.Errorf("malformed attestation object: %w", err)
+	}
+	attestation, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("malformed attestation object")
+	}
+
+	format, _ := attestation["fmt"].(string)
+	rawAuthData, _ := attestation["authData"].([]byte)
+	attStmt, _ := attestation["attStmt"].(map[interface{}]interface{})
+
+	ad, err := rp.parseAuthenticatorData(rawAuthData, true)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := parsePublicKey(ad.publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "none":
+		if len(attStmt) != 0 {
+			return nil, errors.New("\"none\" attestation must have an empty statement")
+		}
+	case "packed":
+		// Self attestation: signed by the credential key itself.
+		if _, hasCert := attStmt["x5c"]; hasCert {
+			return nil, errors.New("certificate attestation is not supported")
+		}
+		alg, _ := attStmt["alg"].(int64)
+		sig, _ := attStmt["sig"].([]byte)
+		if alg != key.alg {
+			return nil, errors.New("attestation algorithm does not match the credential key")
+		}
+		clientDataHash := sha256.Sum256(rawClientData)
+		if err := key.verify(append(append([]byte{}, rawAuthData...), clientDataHash[:]...), sig); err != nil {
+			return nil, fmt.Errorf("attestation: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported attestation format %q", format)
+	}
+
+	rawID, err := DecodeID(resp.ID)
+	if err != nil || !bytes.Equal(rawID, ad.credentialID) {
+		return nil, errors.New("credential ID mismatch")
+	}
+
+	return &Credential{
+		ID:             ad.credentialID,
+		PublicKey:      ad.publicKey,
+		SignCount:      ad.signCount,
+		AAGUID:         ad.aaguid,
+		Transports:     resp.Response.Transports,
+		BackupEligible: ad.flags&flagBackupEligible != 0,
+	}, nil
+}
+
+// VerifyAssertion checks an authentication response against the challenge
+// issued for it and the stored credential, returning the authenticator's new
+// signature counter.
+func (rp *RelyingParty) VerifyAssertion(challenge string, resp AssertionResponse, publicKeyCOSE []byte, storedSignCount uint32) (uint32, error) {
+	if resp.Type != "public-key" {
+		return 0, errors.New("unexpected credential type")
+	}
+
+	rawClientData, err := DecodeID(resp.Response.ClientDataJSON)
+	if err != nil {
+		return 0, errors.New("malformed client data")
+	}
+	if err := rp.verifyClientData(rawClientData, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+
+	rawAuthData, err := DecodeID(resp.Response.AuthenticatorData)
+	if err != nil {
+		return 0, errors.New("malformed authenticator data")
+	}
+	ad, err := rp.parseAuthenticatorData(rawAuthData, false)
+	if err != nil {
+		return 0, err
+	}
+
+	signature, err := DecodeID(resp.Response.Signature)
+	if err != nil {
+		return 0, errors.New("malformed signature")
+	}
+
+	key, err := parsePublicKey(publicKeyCOSE)
+	if err != nil {
+		return 0, err
+	}
+
+	clientDataHash := sha256.Sum256(rawClientData)
+	signed := append(append([]byte{}, rawAuthData...), clientDataHash[:]...)
+	if err := key.verify(signed, signature); err != nil {
+		return 0, err
+	}
+
+	// Authenticators that keep a counter must never go backwards; if one
+	// does, the credential has probably been cloned.
+	if (ad.signCount != 0 || storedSignCount != 0) && ad.signCount <= storedSignCount {
+		return 0, errors.New("signature counter did not increase; the authenticator may be cloned")
+	}
+
+	return ad.signCount, nil
+}